@@ -0,0 +1,32 @@
+package mockautoscaling
+
+import (
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"sync"
+)
+
+// MockAutoscaling is an in-memory implementation of the subset of the AutoScaling API used by kops.
+// Autoscaling groups are recorded but do not launch instances, unless RealizeGroups is set.
+type MockAutoscaling struct {
+	autoscalingiface.AutoScalingAPI
+
+	// RealizeGroups keeps each group at its desired capacity with fake (always InService) instances
+	RealizeGroups bool
+
+	mutex sync.Mutex
+
+	groups               map[string]*autoscaling.Group
+	launchConfigurations map[string]*autoscaling.LaunchConfiguration
+
+	lastInstanceID int
+}
+
+var _ autoscalingiface.AutoScalingAPI = &MockAutoscaling{}
+
+func NewMockAutoscaling() *MockAutoscaling {
+	return &MockAutoscaling{
+		groups:               make(map[string]*autoscaling.Group),
+		launchConfigurations: make(map[string]*autoscaling.LaunchConfiguration),
+	}
+}
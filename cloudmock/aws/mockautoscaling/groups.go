@@ -0,0 +1,334 @@
+package mockautoscaling
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"time"
+)
+
+func (m *MockAutoscaling) CreateAutoScalingGroup(request *autoscaling.CreateAutoScalingGroupInput) (*autoscaling.CreateAutoScalingGroupOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.AutoScalingGroupName)
+	if m.groups[name] != nil {
+		return nil, awserr.New("AlreadyExists", fmt.Sprintf("AutoScalingGroup by this name already exists: %q", name), nil)
+	}
+	lcName := aws.StringValue(request.LaunchConfigurationName)
+	if m.launchConfigurations[lcName] == nil {
+		return nil, awserr.New("ValidationError", fmt.Sprintf("Launch configuration name not found: %q", lcName), nil)
+	}
+
+	g := &autoscaling.Group{
+		AutoScalingGroupName:    request.AutoScalingGroupName,
+		LaunchConfigurationName: request.LaunchConfigurationName,
+		MinSize:                 request.MinSize,
+		MaxSize:                 request.MaxSize,
+		DesiredCapacity:         request.DesiredCapacity,
+		VPCZoneIdentifier:       request.VPCZoneIdentifier,
+		AvailabilityZones:       request.AvailabilityZones,
+		LoadBalancerNames:       request.LoadBalancerNames,
+		CreatedTime:             aws.Time(time.Now()),
+	}
+	if g.DesiredCapacity == nil {
+		g.DesiredCapacity = g.MinSize
+	}
+	for _, tag := range request.Tags {
+		g.Tags = append(g.Tags, &autoscaling.TagDescription{
+			Key:               tag.Key,
+			Value:             tag.Value,
+			PropagateAtLaunch: tag.PropagateAtLaunch,
+			ResourceId:        request.AutoScalingGroupName,
+			ResourceType:      aws.String("auto-scaling-group"),
+		})
+	}
+	m.groups[name] = g
+	m.realize(g)
+
+	return &autoscaling.CreateAutoScalingGroupOutput{}, nil
+}
+
+func (m *MockAutoscaling) UpdateAutoScalingGroup(request *autoscaling.UpdateAutoScalingGroupInput) (*autoscaling.UpdateAutoScalingGroupOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.AutoScalingGroupName)
+	g := m.groups[name]
+	if g == nil {
+		return nil, awserr.New("ValidationError", fmt.Sprintf("AutoScalingGroup name not found: %q", name), nil)
+	}
+
+	if request.LaunchConfigurationName != nil {
+		g.LaunchConfigurationName = request.LaunchConfigurationName
+	}
+	if request.MinSize != nil {
+		g.MinSize = request.MinSize
+	}
+	if request.MaxSize != nil {
+		g.MaxSize = request.MaxSize
+	}
+	if request.DesiredCapacity != nil {
+		g.DesiredCapacity = request.DesiredCapacity
+	}
+	if request.VPCZoneIdentifier != nil {
+		g.VPCZoneIdentifier = request.VPCZoneIdentifier
+	}
+	m.realize(g)
+	return &autoscaling.UpdateAutoScalingGroupOutput{}, nil
+}
+
+func (m *MockAutoscaling) SetDesiredCapacity(request *autoscaling.SetDesiredCapacityInput) (*autoscaling.SetDesiredCapacityOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.AutoScalingGroupName)
+	g := m.groups[name]
+	if g == nil {
+		return nil, awserr.New("ValidationError", fmt.Sprintf("AutoScalingGroup name not found: %q", name), nil)
+	}
+	desired := aws.Int64Value(request.DesiredCapacity)
+	if desired < aws.Int64Value(g.MinSize) || desired > aws.Int64Value(g.MaxSize) {
+		return nil, awserr.New("ValidationError", fmt.Sprintf("New SetDesiredCapacity value %d is outside of the group bounds", desired), nil)
+	}
+	g.DesiredCapacity = request.DesiredCapacity
+	m.realize(g)
+	return &autoscaling.SetDesiredCapacityOutput{}, nil
+}
+
+func (m *MockAutoscaling) DetachInstances(request *autoscaling.DetachInstancesInput) (*autoscaling.DetachInstancesOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.AutoScalingGroupName)
+	g := m.groups[name]
+	if g == nil {
+		return nil, awserr.New("ValidationError", fmt.Sprintf("AutoScalingGroup name not found: %q", name), nil)
+	}
+	for _, id := range request.InstanceIds {
+		found := false
+		var kept []*autoscaling.Instance
+		for _, i := range g.Instances {
+			if aws.StringValue(i.InstanceId) == aws.StringValue(id) {
+				found = true
+			} else {
+				kept = append(kept, i)
+			}
+		}
+		if !found {
+			return nil, awserr.New("ValidationError", fmt.Sprintf("The instance %s is not part of Auto Scaling group %s", aws.StringValue(id), name), nil)
+		}
+		if aws.BoolValue(request.ShouldDecrementDesiredCapacity) {
+			if aws.Int64Value(g.DesiredCapacity) <= aws.Int64Value(g.MinSize) {
+				return nil, awserr.New("ValidationError", fmt.Sprintf("Detaching %s would take Auto Scaling group %s below its minimum size", aws.StringValue(id), name), nil)
+			}
+		}
+		g.Instances = kept
+		if aws.BoolValue(request.ShouldDecrementDesiredCapacity) {
+			g.DesiredCapacity = aws.Int64(aws.Int64Value(g.DesiredCapacity) - 1)
+		}
+	}
+	m.realize(g)
+	return &autoscaling.DetachInstancesOutput{}, nil
+}
+
+// realize launches or terminates instances so the group matches its desired capacity, if RealizeGroups is set.
+// As with AWS, instances with an old launch configuration are terminated first.
+func (m *MockAutoscaling) realize(g *autoscaling.Group) {
+	if !m.RealizeGroups {
+		return
+	}
+	desired := int(aws.Int64Value(g.DesiredCapacity))
+	for len(g.Instances) < desired {
+		m.lastInstanceID++
+		g.Instances = append(g.Instances, &autoscaling.Instance{
+			InstanceId:              aws.String(fmt.Sprintf("i-%d", m.lastInstanceID)),
+			LaunchConfigurationName: g.LaunchConfigurationName,
+			LifecycleState:          aws.String(autoscaling.LifecycleStateInService),
+			HealthStatus:            aws.String("Healthy"),
+		})
+	}
+	for len(g.Instances) > desired {
+		victim := 0
+		for j, i := range g.Instances {
+			if aws.StringValue(i.LaunchConfigurationName) != aws.StringValue(g.LaunchConfigurationName) {
+				victim = j
+				break
+			}
+		}
+		g.Instances = append(g.Instances[:victim], g.Instances[victim+1:]...)
+	}
+}
+
+func (m *MockAutoscaling) DescribeAutoScalingGroups(request *autoscaling.DescribeAutoScalingGroupsInput) (*autoscaling.DescribeAutoScalingGroupsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response := &autoscaling.DescribeAutoScalingGroupsOutput{}
+	for name, g := range m.groups {
+		if len(request.AutoScalingGroupNames) != 0 {
+			found := false
+			for _, n := range request.AutoScalingGroupNames {
+				if aws.StringValue(n) == name {
+					found = true
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+		copy := *g
+		copy.Instances = nil
+		for _, i := range g.Instances {
+			c := *i
+			copy.Instances = append(copy.Instances, &c)
+		}
+		copy.Tags = nil
+		for _, tag := range g.Tags {
+			t := *tag
+			copy.Tags = append(copy.Tags, &t)
+		}
+		response.AutoScalingGroups = append(response.AutoScalingGroups, &copy)
+	}
+	return response, nil
+}
+
+func (m *MockAutoscaling) DescribeAutoScalingGroupsPages(request *autoscaling.DescribeAutoScalingGroupsInput, callback func(*autoscaling.DescribeAutoScalingGroupsOutput, bool) bool) error {
+	// For the mock, we return everything as a single page
+	response, err := m.DescribeAutoScalingGroups(request)
+	if err != nil {
+		return err
+	}
+	callback(response, true)
+	return nil
+}
+
+func (m *MockAutoscaling) DeleteAutoScalingGroup(request *autoscaling.DeleteAutoScalingGroupInput) (*autoscaling.DeleteAutoScalingGroupOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.AutoScalingGroupName)
+	if m.groups[name] == nil {
+		return nil, awserr.New("ValidationError", fmt.Sprintf("AutoScalingGroup name not found: %q", name), nil)
+	}
+	delete(m.groups, name)
+	return &autoscaling.DeleteAutoScalingGroupOutput{}, nil
+}
+
+func (m *MockAutoscaling) AttachLoadBalancers(request *autoscaling.AttachLoadBalancersInput) (*autoscaling.AttachLoadBalancersOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.AutoScalingGroupName)
+	g := m.groups[name]
+	if g == nil {
+		return nil, awserr.New("ValidationError", fmt.Sprintf("AutoScalingGroup name not found: %q", name), nil)
+	}
+
+	for _, lb := range request.LoadBalancerNames {
+		found := false
+		for _, existing := range g.LoadBalancerNames {
+			if aws.StringValue(existing) == aws.StringValue(lb) {
+				found = true
+			}
+		}
+		if !found {
+			g.LoadBalancerNames = append(g.LoadBalancerNames, lb)
+		}
+	}
+	return &autoscaling.AttachLoadBalancersOutput{}, nil
+}
+
+func (m *MockAutoscaling) CreateOrUpdateTags(request *autoscaling.CreateOrUpdateTagsInput) (*autoscaling.CreateOrUpdateTagsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, tag := range request.Tags {
+		name := aws.StringValue(tag.ResourceId)
+		g := m.groups[name]
+		if g == nil {
+			return nil, awserr.New("ValidationError", fmt.Sprintf("AutoScalingGroup name not found: %q", name), nil)
+		}
+		replaced := false
+		for _, existing := range g.Tags {
+			if aws.StringValue(existing.Key) == aws.StringValue(tag.Key) {
+				existing.Value = tag.Value
+				existing.PropagateAtLaunch = tag.PropagateAtLaunch
+				replaced = true
+			}
+		}
+		if !replaced {
+			g.Tags = append(g.Tags, &autoscaling.TagDescription{
+				Key:               tag.Key,
+				Value:             tag.Value,
+				PropagateAtLaunch: tag.PropagateAtLaunch,
+				ResourceId:        tag.ResourceId,
+				ResourceType:      aws.String("auto-scaling-group"),
+			})
+		}
+	}
+	return &autoscaling.CreateOrUpdateTagsOutput{}, nil
+}
+
+func (m *MockAutoscaling) DeleteTags(request *autoscaling.DeleteTagsInput) (*autoscaling.DeleteTagsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, tag := range request.Tags {
+		name := aws.StringValue(tag.ResourceId)
+		g := m.groups[name]
+		if g == nil {
+			return nil, awserr.New("ValidationError", fmt.Sprintf("AutoScalingGroup name not found: %q", name), nil)
+		}
+		var kept []*autoscaling.TagDescription
+		for _, existing := range g.Tags {
+			if aws.StringValue(existing.Key) != aws.StringValue(tag.Key) {
+				kept = append(kept, existing)
+			}
+		}
+		g.Tags = kept
+	}
+	return &autoscaling.DeleteTagsOutput{}, nil
+}
+
+func (m *MockAutoscaling) DescribeTagsPages(request *autoscaling.DescribeTagsInput, callback func(*autoscaling.DescribeTagsOutput, bool) bool) error {
+	m.mutex.Lock()
+	response := &autoscaling.DescribeTagsOutput{}
+	for _, g := range m.groups {
+		for _, tag := range g.Tags {
+			match := true
+			for _, filter := range request.Filters {
+				var v string
+				switch aws.StringValue(filter.Name) {
+				case "auto-scaling-group":
+					v = aws.StringValue(tag.ResourceId)
+				case "key":
+					v = aws.StringValue(tag.Key)
+				case "value":
+					v = aws.StringValue(tag.Value)
+				default:
+					m.mutex.Unlock()
+					return fmt.Errorf("filter %q not supported by mock", aws.StringValue(filter.Name))
+				}
+				found := false
+				for _, want := range filter.Values {
+					if aws.StringValue(want) == v {
+						found = true
+					}
+				}
+				if !found {
+					match = false
+				}
+			}
+			if match {
+				t := *tag
+				response.Tags = append(response.Tags, &t)
+			}
+		}
+	}
+	m.mutex.Unlock()
+
+	callback(response, true)
+	return nil
+}
@@ -0,0 +1,90 @@
+package mockautoscaling
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"time"
+)
+
+func (m *MockAutoscaling) CreateLaunchConfiguration(request *autoscaling.CreateLaunchConfigurationInput) (*autoscaling.CreateLaunchConfigurationOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.LaunchConfigurationName)
+	if m.launchConfigurations[name] != nil {
+		return nil, awserr.New("AlreadyExists", fmt.Sprintf("Launch Configuration by this name already exists: %q", name), nil)
+	}
+
+	lc := &autoscaling.LaunchConfiguration{
+		LaunchConfigurationName:  request.LaunchConfigurationName,
+		ImageId:                  request.ImageId,
+		InstanceType:             request.InstanceType,
+		KeyName:                  request.KeyName,
+		SecurityGroups:           request.SecurityGroups,
+		AssociatePublicIpAddress: request.AssociatePublicIpAddress,
+		BlockDeviceMappings:      request.BlockDeviceMappings,
+		IamInstanceProfile:       request.IamInstanceProfile,
+		SpotPrice:                request.SpotPrice,
+		UserData:                 request.UserData,
+		CreatedTime:              aws.Time(time.Now()),
+	}
+	if lc.UserData == nil {
+		// AWS returns an empty string rather than nil
+		lc.UserData = aws.String("")
+	}
+	m.launchConfigurations[name] = lc
+
+	return &autoscaling.CreateLaunchConfigurationOutput{}, nil
+}
+
+func (m *MockAutoscaling) DescribeLaunchConfigurations(request *autoscaling.DescribeLaunchConfigurationsInput) (*autoscaling.DescribeLaunchConfigurationsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response := &autoscaling.DescribeLaunchConfigurationsOutput{}
+	for name, lc := range m.launchConfigurations {
+		if len(request.LaunchConfigurationNames) != 0 {
+			found := false
+			for _, n := range request.LaunchConfigurationNames {
+				if aws.StringValue(n) == name {
+					found = true
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+		copy := *lc
+		response.LaunchConfigurations = append(response.LaunchConfigurations, &copy)
+	}
+	return response, nil
+}
+
+func (m *MockAutoscaling) DescribeLaunchConfigurationsPages(request *autoscaling.DescribeLaunchConfigurationsInput, callback func(*autoscaling.DescribeLaunchConfigurationsOutput, bool) bool) error {
+	// For the mock, we return everything as a single page
+	response, err := m.DescribeLaunchConfigurations(request)
+	if err != nil {
+		return err
+	}
+	callback(response, true)
+	return nil
+}
+
+func (m *MockAutoscaling) DeleteLaunchConfiguration(request *autoscaling.DeleteLaunchConfigurationInput) (*autoscaling.DeleteLaunchConfigurationOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.LaunchConfigurationName)
+	if m.launchConfigurations[name] == nil {
+		return nil, awserr.New("ValidationError", fmt.Sprintf("Launch configuration name not found: %q", name), nil)
+	}
+	for _, g := range m.groups {
+		if aws.StringValue(g.LaunchConfigurationName) == name {
+			return nil, awserr.New("ResourceInUse", fmt.Sprintf("Cannot delete launch configuration %q because it is attached to AutoScalingGroup %q", name, aws.StringValue(g.AutoScalingGroupName)), nil)
+		}
+	}
+	delete(m.launchConfigurations, name)
+	return &autoscaling.DeleteLaunchConfigurationOutput{}, nil
+}
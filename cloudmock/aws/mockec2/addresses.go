@@ -0,0 +1,79 @@
+package mockec2
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func (m *MockEC2) AllocateAddress(request *ec2.AllocateAddressInput) (*ec2.AllocateAddressOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := m.allocateID("eipalloc")
+	address := &ec2.Address{
+		AllocationId: aws.String(id),
+		Domain:       aws.String(ec2.DomainTypeVpc),
+		// Addresses from the documentation range (TEST-NET-3)
+		PublicIp: aws.String(fmt.Sprintf("203.0.113.%d", m.lastID%256)),
+	}
+	m.addresses[id] = address
+
+	return &ec2.AllocateAddressOutput{
+		AllocationId: address.AllocationId,
+		Domain:       address.Domain,
+		PublicIp:     address.PublicIp,
+	}, nil
+}
+
+func (m *MockEC2) DescribeAddresses(request *ec2.DescribeAddressesInput) (*ec2.DescribeAddressesOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response := &ec2.DescribeAddressesOutput{}
+	for id, address := range m.addresses {
+		if !containsID(request.AllocationIds, id) {
+			continue
+		}
+		if !containsID(request.PublicIps, aws.StringValue(address.PublicIp)) {
+			continue
+		}
+		match, err := matchFilters(request.Filters, nil, func(name string) (string, bool) {
+			switch name {
+			case "allocation-id":
+				return id, true
+			case "public-ip":
+				return aws.StringValue(address.PublicIp), true
+			case "domain":
+				return aws.StringValue(address.Domain), true
+			}
+			return "", false
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !match {
+			continue
+		}
+
+		copy := *address
+		response.Addresses = append(response.Addresses, &copy)
+	}
+
+	if len(request.AllocationIds) != 0 && len(response.Addresses) == 0 {
+		return nil, notFound("InvalidAllocationID.NotFound", aws.StringValue(request.AllocationIds[0]))
+	}
+	return response, nil
+}
+
+func (m *MockEC2) ReleaseAddress(request *ec2.ReleaseAddressInput) (*ec2.ReleaseAddressOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.AllocationId)
+	if m.addresses[id] == nil {
+		return nil, notFound("InvalidAllocationID.NotFound", id)
+	}
+	delete(m.addresses, id)
+	return &ec2.ReleaseAddressOutput{}, nil
+}
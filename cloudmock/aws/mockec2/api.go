@@ -0,0 +1,135 @@
+package mockec2
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"strings"
+	"sync"
+)
+
+// MockEC2 is an in-memory implementation of the subset of the EC2 API used by kops.
+// Methods that are not implemented will panic (through the embedded nil interface).
+type MockEC2 struct {
+	ec2iface.EC2API
+
+	mutex sync.Mutex
+
+	lastID int
+
+	// Images and AvailabilityZones are normally populated by the test
+	Images            []*ec2.Image
+	AvailabilityZones []*ec2.AvailabilityZone
+
+	tags []*ec2.TagDescription
+
+	vpcs             map[string]*vpcInfo
+	subnets          map[string]*ec2.Subnet
+	securityGroups   map[string]*ec2.SecurityGroup
+	internetGateways map[string]*ec2.InternetGateway
+	routeTables      map[string]*ec2.RouteTable
+	dhcpOptions      map[string]*ec2.DhcpOptions
+	keyPairs         map[string]*ec2.KeyPairInfo
+	volumes          map[string]*ec2.Volume
+	natGateways      map[string]*ec2.NatGateway
+	addresses        map[string]*ec2.Address
+}
+
+var _ ec2iface.EC2API = &MockEC2{}
+
+// NewMockEC2 builds an empty MockEC2, with the zones of the specified region available
+func NewMockEC2(region string, zones ...string) *MockEC2 {
+	m := &MockEC2{
+		vpcs:             make(map[string]*vpcInfo),
+		subnets:          make(map[string]*ec2.Subnet),
+		securityGroups:   make(map[string]*ec2.SecurityGroup),
+		internetGateways: make(map[string]*ec2.InternetGateway),
+		routeTables:      make(map[string]*ec2.RouteTable),
+		dhcpOptions:      make(map[string]*ec2.DhcpOptions),
+		keyPairs:         make(map[string]*ec2.KeyPairInfo),
+		volumes:          make(map[string]*ec2.Volume),
+		natGateways:      make(map[string]*ec2.NatGateway),
+		addresses:        make(map[string]*ec2.Address),
+	}
+	for _, zone := range zones {
+		m.AvailabilityZones = append(m.AvailabilityZones, &ec2.AvailabilityZone{
+			ZoneName:   aws.String(zone),
+			RegionName: aws.String(region),
+			State:      aws.String("available"),
+		})
+	}
+	return m
+}
+
+// allocateID returns a new id with the specified prefix, e.g. vpc-1
+// The mutex must be held.
+func (m *MockEC2) allocateID(prefix string) string {
+	m.lastID++
+	return fmt.Sprintf("%s-%d", prefix, m.lastID)
+}
+
+// notFound builds an error with the code EC2 would return for a missing resource
+func notFound(code string, id string) error {
+	return awserr.New(code, fmt.Sprintf("The ID %q does not exist", id), nil)
+}
+
+// matchFilters returns true if all the filters match.
+// matchAttribute is called for filters which are not tag filters; it should return false, error for unknown filter names
+func matchFilters(filters []*ec2.Filter, tags []*ec2.Tag, matchAttribute func(name string) (string, bool)) (bool, error) {
+	for _, filter := range filters {
+		name := aws.StringValue(filter.Name)
+
+		var values []string
+		if strings.HasPrefix(name, "tag:") {
+			key := name[len("tag:"):]
+			for _, tag := range tags {
+				if aws.StringValue(tag.Key) == key {
+					values = append(values, aws.StringValue(tag.Value))
+				}
+			}
+		} else if name == "tag-key" {
+			for _, tag := range tags {
+				values = append(values, aws.StringValue(tag.Key))
+			}
+		} else {
+			v, known := matchAttribute(name)
+			if !known {
+				return false, fmt.Errorf("filter %q not supported by mock", name)
+			}
+			values = append(values, v)
+		}
+
+		match := false
+		for _, want := range filter.Values {
+			for _, v := range values {
+				if v == aws.StringValue(want) {
+					match = true
+				}
+			}
+		}
+		if !match {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// noAttributes is a matchAttribute function for resources which only support tag filters
+func noAttributes(name string) (string, bool) {
+	return "", false
+}
+
+// containsID returns true if ids is empty or contains id
+func containsID(ids []*string, id string) bool {
+	if len(ids) == 0 {
+		return true
+	}
+	for _, s := range ids {
+		if aws.StringValue(s) == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package mockec2
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func (m *MockEC2) CreateDhcpOptions(request *ec2.CreateDhcpOptionsInput) (*ec2.CreateDhcpOptionsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := m.allocateID("dopt")
+	o := &ec2.DhcpOptions{
+		DhcpOptionsId: aws.String(id),
+	}
+	for _, c := range request.DhcpConfigurations {
+		configuration := &ec2.DhcpConfiguration{Key: c.Key}
+		for _, v := range c.Values {
+			configuration.Values = append(configuration.Values, &ec2.AttributeValue{Value: v})
+		}
+		o.DhcpConfigurations = append(o.DhcpConfigurations, configuration)
+	}
+	m.dhcpOptions[id] = o
+
+	copy := *o
+	return &ec2.CreateDhcpOptionsOutput{DhcpOptions: &copy}, nil
+}
+
+func (m *MockEC2) DescribeDhcpOptions(request *ec2.DescribeDhcpOptionsInput) (*ec2.DescribeDhcpOptionsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response := &ec2.DescribeDhcpOptionsOutput{}
+	for id, o := range m.dhcpOptions {
+		if !containsID(request.DhcpOptionsIds, id) {
+			continue
+		}
+		tags := m.getTags(id)
+		match, err := matchFilters(request.Filters, tags, func(name string) (string, bool) {
+			switch name {
+			case "dhcp-options-id":
+				return id, true
+			}
+			return "", false
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !match {
+			continue
+		}
+
+		copy := *o
+		copy.Tags = tags
+		response.DhcpOptions = append(response.DhcpOptions, &copy)
+	}
+
+	if len(request.DhcpOptionsIds) != 0 && len(response.DhcpOptions) == 0 {
+		return nil, notFound("InvalidDhcpOptionID.NotFound", aws.StringValue(request.DhcpOptionsIds[0]))
+	}
+	return response, nil
+}
+
+func (m *MockEC2) AssociateDhcpOptions(request *ec2.AssociateDhcpOptionsInput) (*ec2.AssociateDhcpOptionsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.DhcpOptionsId)
+	if m.dhcpOptions[id] == nil {
+		return nil, notFound("InvalidDhcpOptionID.NotFound", id)
+	}
+	vpcID := aws.StringValue(request.VpcId)
+	vpc := m.vpcs[vpcID]
+	if vpc == nil {
+		return nil, notFound("InvalidVpcID.NotFound", vpcID)
+	}
+
+	vpc.main.DhcpOptionsId = request.DhcpOptionsId
+	return &ec2.AssociateDhcpOptionsOutput{}, nil
+}
+
+func (m *MockEC2) DeleteDhcpOptions(request *ec2.DeleteDhcpOptionsInput) (*ec2.DeleteDhcpOptionsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.DhcpOptionsId)
+	if m.dhcpOptions[id] == nil {
+		return nil, notFound("InvalidDhcpOptionID.NotFound", id)
+	}
+	delete(m.dhcpOptions, id)
+	return &ec2.DeleteDhcpOptionsOutput{}, nil
+}
@@ -0,0 +1,78 @@
+package mockec2
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// AddImage registers an image which will be returned by DescribeImages
+func (m *MockEC2) AddImage(image *ec2.Image) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if image.ImageId == nil {
+		image.ImageId = aws.String(m.allocateID("ami"))
+	}
+	m.Images = append(m.Images, image)
+}
+
+func (m *MockEC2) DescribeImages(request *ec2.DescribeImagesInput) (*ec2.DescribeImagesOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response := &ec2.DescribeImagesOutput{}
+	for _, image := range m.Images {
+		if !containsID(request.ImageIds, aws.StringValue(image.ImageId)) {
+			continue
+		}
+		if len(request.Owners) != 0 {
+			// We don't model the current account, so "self" matches images without an owner
+			owner := aws.StringValue(image.OwnerId)
+			if owner == "" {
+				owner = "self"
+			}
+			if !containsID(request.Owners, owner) {
+				continue
+			}
+		}
+		match, err := matchFilters(request.Filters, image.Tags, func(name string) (string, bool) {
+			switch name {
+			case "name":
+				return aws.StringValue(image.Name), true
+			case "image-id":
+				return aws.StringValue(image.ImageId), true
+			}
+			return "", false
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !match {
+			continue
+		}
+
+		copy := *image
+		response.Images = append(response.Images, &copy)
+	}
+
+	if len(request.ImageIds) != 0 && len(response.Images) == 0 {
+		return nil, fmt.Errorf("image %q not found", aws.StringValue(request.ImageIds[0]))
+	}
+	return response, nil
+}
+
+func (m *MockEC2) DescribeAvailabilityZones(request *ec2.DescribeAvailabilityZonesInput) (*ec2.DescribeAvailabilityZonesOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response := &ec2.DescribeAvailabilityZonesOutput{}
+	for _, zone := range m.AvailabilityZones {
+		if !containsID(request.ZoneNames, aws.StringValue(zone.ZoneName)) {
+			continue
+		}
+		copy := *zone
+		response.AvailabilityZones = append(response.AvailabilityZones, &copy)
+	}
+	return response, nil
+}
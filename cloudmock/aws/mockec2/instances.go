@@ -0,0 +1,29 @@
+package mockec2
+
+import (
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// The mock does not launch instances (autoscaling groups are not "realized"), so there are never any instances
+
+func (m *MockEC2) DescribeInstances(request *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+	return &ec2.DescribeInstancesOutput{}, nil
+}
+
+func (m *MockEC2) DescribeInstancesPages(request *ec2.DescribeInstancesInput, callback func(*ec2.DescribeInstancesOutput, bool) bool) error {
+	response, err := m.DescribeInstances(request)
+	if err != nil {
+		return err
+	}
+	callback(response, true)
+	return nil
+}
+
+// TerminateInstances always succeeds; the instances of a realized autoscaling group are only known to the autoscaling mock
+func (m *MockEC2) TerminateInstances(request *ec2.TerminateInstancesInput) (*ec2.TerminateInstancesOutput, error) {
+	return &ec2.TerminateInstancesOutput{}, nil
+}
+
+func (m *MockEC2) DescribeAddresses(request *ec2.DescribeAddressesInput) (*ec2.DescribeAddressesOutput, error) {
+	return &ec2.DescribeAddressesOutput{}, nil
+}
@@ -0,0 +1,136 @@
+package mockec2
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func (m *MockEC2) CreateInternetGateway(request *ec2.CreateInternetGatewayInput) (*ec2.CreateInternetGatewayOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := m.allocateID("igw")
+	igw := &ec2.InternetGateway{
+		InternetGatewayId: aws.String(id),
+	}
+	m.internetGateways[id] = igw
+
+	copy := *igw
+	return &ec2.CreateInternetGatewayOutput{InternetGateway: &copy}, nil
+}
+
+func (m *MockEC2) DescribeInternetGateways(request *ec2.DescribeInternetGatewaysInput) (*ec2.DescribeInternetGatewaysOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response := &ec2.DescribeInternetGatewaysOutput{}
+	for id, igw := range m.internetGateways {
+		if !containsID(request.InternetGatewayIds, id) {
+			continue
+		}
+		tags := m.getTags(id)
+
+		var attachedVPCs []string
+		for _, attachment := range igw.Attachments {
+			attachedVPCs = append(attachedVPCs, aws.StringValue(attachment.VpcId))
+		}
+
+		match := true
+		var filters []*ec2.Filter
+		for _, filter := range request.Filters {
+			// attachment.vpc-id is multi-valued, so we handle it here
+			if aws.StringValue(filter.Name) == "attachment.vpc-id" {
+				found := false
+				for _, vpcID := range attachedVPCs {
+					if containsID(filter.Values, vpcID) {
+						found = true
+					}
+				}
+				if !found {
+					match = false
+				}
+				continue
+			}
+			filters = append(filters, filter)
+		}
+		if !match {
+			continue
+		}
+
+		match, err := matchFilters(filters, tags, func(name string) (string, bool) {
+			switch name {
+			case "internet-gateway-id":
+				return id, true
+			}
+			return "", false
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !match {
+			continue
+		}
+
+		copy := *igw
+		copy.Tags = tags
+		response.InternetGateways = append(response.InternetGateways, &copy)
+	}
+
+	if len(request.InternetGatewayIds) != 0 && len(response.InternetGateways) == 0 {
+		return nil, notFound("InvalidInternetGatewayID.NotFound", aws.StringValue(request.InternetGatewayIds[0]))
+	}
+	return response, nil
+}
+
+func (m *MockEC2) AttachInternetGateway(request *ec2.AttachInternetGatewayInput) (*ec2.AttachInternetGatewayOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.InternetGatewayId)
+	igw := m.internetGateways[id]
+	if igw == nil {
+		return nil, notFound("InvalidInternetGatewayID.NotFound", id)
+	}
+	vpcID := aws.StringValue(request.VpcId)
+	if m.vpcs[vpcID] == nil {
+		return nil, notFound("InvalidVpcID.NotFound", vpcID)
+	}
+
+	igw.Attachments = append(igw.Attachments, &ec2.InternetGatewayAttachment{
+		VpcId: request.VpcId,
+		State: aws.String(ec2.AttachmentStatusAttached),
+	})
+	return &ec2.AttachInternetGatewayOutput{}, nil
+}
+
+func (m *MockEC2) DetachInternetGateway(request *ec2.DetachInternetGatewayInput) (*ec2.DetachInternetGatewayOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.InternetGatewayId)
+	igw := m.internetGateways[id]
+	if igw == nil {
+		return nil, notFound("InvalidInternetGatewayID.NotFound", id)
+	}
+
+	var attachments []*ec2.InternetGatewayAttachment
+	for _, a := range igw.Attachments {
+		if aws.StringValue(a.VpcId) != aws.StringValue(request.VpcId) {
+			attachments = append(attachments, a)
+		}
+	}
+	igw.Attachments = attachments
+	return &ec2.DetachInternetGatewayOutput{}, nil
+}
+
+func (m *MockEC2) DeleteInternetGateway(request *ec2.DeleteInternetGatewayInput) (*ec2.DeleteInternetGatewayOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.InternetGatewayId)
+	if m.internetGateways[id] == nil {
+		return nil, notFound("InvalidInternetGatewayID.NotFound", id)
+	}
+	delete(m.internetGateways, id)
+	return &ec2.DeleteInternetGatewayOutput{}, nil
+}
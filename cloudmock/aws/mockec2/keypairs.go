@@ -0,0 +1,62 @@
+package mockec2
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+)
+
+func (m *MockEC2) ImportKeyPair(request *ec2.ImportKeyPairInput) (*ec2.ImportKeyPairOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.KeyName)
+	if m.keyPairs[name] != nil {
+		return nil, awserr.New("InvalidKeyPair.Duplicate", fmt.Sprintf("The keypair %q already exists", name), nil)
+	}
+
+	fingerprint, err := awsup.ComputeAWSKeyFingerprint(string(request.PublicKeyMaterial))
+	if err != nil {
+		return nil, awserr.New("InvalidKey.Format", err.Error(), nil)
+	}
+
+	kp := &ec2.KeyPairInfo{
+		KeyName:        aws.String(name),
+		KeyFingerprint: aws.String(fingerprint),
+	}
+	m.keyPairs[name] = kp
+
+	return &ec2.ImportKeyPairOutput{
+		KeyName:        kp.KeyName,
+		KeyFingerprint: kp.KeyFingerprint,
+	}, nil
+}
+
+func (m *MockEC2) DescribeKeyPairs(request *ec2.DescribeKeyPairsInput) (*ec2.DescribeKeyPairsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response := &ec2.DescribeKeyPairsOutput{}
+	for name, kp := range m.keyPairs {
+		if !containsID(request.KeyNames, name) {
+			continue
+		}
+		copy := *kp
+		response.KeyPairs = append(response.KeyPairs, &copy)
+	}
+
+	if len(request.KeyNames) != 0 && len(response.KeyPairs) == 0 {
+		return nil, awserr.New("InvalidKeyPair.NotFound", fmt.Sprintf("The key pair %q does not exist", aws.StringValue(request.KeyNames[0])), nil)
+	}
+	return response, nil
+}
+
+func (m *MockEC2) DeleteKeyPair(request *ec2.DeleteKeyPairInput) (*ec2.DeleteKeyPairOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.keyPairs, aws.StringValue(request.KeyName))
+	return &ec2.DeleteKeyPairOutput{}, nil
+}
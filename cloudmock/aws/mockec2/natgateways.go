@@ -0,0 +1,105 @@
+package mockec2
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"time"
+)
+
+// CreateNatGateway creates a NAT gateway, which is available immediately (rather than pending, as on EC2)
+func (m *MockEC2) CreateNatGateway(request *ec2.CreateNatGatewayInput) (*ec2.CreateNatGatewayOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	subnetID := aws.StringValue(request.SubnetId)
+	subnet := m.subnets[subnetID]
+	if subnet == nil {
+		return nil, notFound("InvalidSubnetID.NotFound", subnetID)
+	}
+	allocationID := aws.StringValue(request.AllocationId)
+	address := m.addresses[allocationID]
+	if address == nil {
+		return nil, notFound("InvalidAllocationID.NotFound", allocationID)
+	}
+
+	id := m.allocateID("nat")
+	ngw := &ec2.NatGateway{
+		NatGatewayId: aws.String(id),
+		SubnetId:     subnet.SubnetId,
+		VpcId:        subnet.VpcId,
+		State:        aws.String(ec2.NatGatewayStateAvailable),
+		CreateTime:   aws.Time(time.Now()),
+		NatGatewayAddresses: []*ec2.NatGatewayAddress{
+			{
+				AllocationId: address.AllocationId,
+				PublicIp:     address.PublicIp,
+			},
+		},
+	}
+	m.natGateways[id] = ngw
+
+	return &ec2.CreateNatGatewayOutput{NatGateway: copyNatGateway(ngw)}, nil
+}
+
+func (m *MockEC2) DescribeNatGateways(request *ec2.DescribeNatGatewaysInput) (*ec2.DescribeNatGatewaysOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response := &ec2.DescribeNatGatewaysOutput{}
+	for id, ngw := range m.natGateways {
+		if !containsID(request.NatGatewayIds, id) {
+			continue
+		}
+		// NAT gateways can't be tagged
+		match, err := matchFilters(request.Filter, nil, func(name string) (string, bool) {
+			switch name {
+			case "nat-gateway-id":
+				return id, true
+			case "state":
+				return aws.StringValue(ngw.State), true
+			case "subnet-id":
+				return aws.StringValue(ngw.SubnetId), true
+			case "vpc-id":
+				return aws.StringValue(ngw.VpcId), true
+			}
+			return "", false
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !match {
+			continue
+		}
+
+		response.NatGateways = append(response.NatGateways, copyNatGateway(ngw))
+	}
+
+	if len(request.NatGatewayIds) != 0 && len(response.NatGateways) == 0 {
+		return nil, notFound("NatGatewayNotFound", aws.StringValue(request.NatGatewayIds[0]))
+	}
+	return response, nil
+}
+
+// DeleteNatGateway marks the NAT gateway as deleted; as on EC2, it is still returned by DescribeNatGateways
+func (m *MockEC2) DeleteNatGateway(request *ec2.DeleteNatGatewayInput) (*ec2.DeleteNatGatewayOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.NatGatewayId)
+	ngw := m.natGateways[id]
+	if ngw == nil {
+		return nil, notFound("NatGatewayNotFound", id)
+	}
+	ngw.State = aws.String(ec2.NatGatewayStateDeleted)
+	return &ec2.DeleteNatGatewayOutput{NatGatewayId: ngw.NatGatewayId}, nil
+}
+
+func copyNatGateway(ngw *ec2.NatGateway) *ec2.NatGateway {
+	copy := *ngw
+	copy.NatGatewayAddresses = nil
+	for _, a := range ngw.NatGatewayAddresses {
+		address := *a
+		copy.NatGatewayAddresses = append(copy.NatGatewayAddresses, &address)
+	}
+	return &copy
+}
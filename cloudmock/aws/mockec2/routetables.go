@@ -0,0 +1,171 @@
+package mockec2
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func (m *MockEC2) CreateRouteTable(request *ec2.CreateRouteTableInput) (*ec2.CreateRouteTableOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	vpcID := aws.StringValue(request.VpcId)
+	vpc := m.vpcs[vpcID]
+	if vpc == nil {
+		return nil, notFound("InvalidVpcID.NotFound", vpcID)
+	}
+
+	id := m.allocateID("rtb")
+	rt := &ec2.RouteTable{
+		RouteTableId: aws.String(id),
+		VpcId:        request.VpcId,
+		Routes: []*ec2.Route{
+			{
+				DestinationCidrBlock: vpc.main.CidrBlock,
+				GatewayId:            aws.String("local"),
+				State:                aws.String(ec2.RouteStateActive),
+			},
+		},
+	}
+	m.routeTables[id] = rt
+
+	return &ec2.CreateRouteTableOutput{RouteTable: copyRouteTable(rt)}, nil
+}
+
+func (m *MockEC2) DescribeRouteTables(request *ec2.DescribeRouteTablesInput) (*ec2.DescribeRouteTablesOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response := &ec2.DescribeRouteTablesOutput{}
+	for id, rt := range m.routeTables {
+		if !containsID(request.RouteTableIds, id) {
+			continue
+		}
+		tags := m.getTags(id)
+		match, err := matchFilters(request.Filters, tags, func(name string) (string, bool) {
+			switch name {
+			case "route-table-id":
+				return id, true
+			case "vpc-id":
+				return aws.StringValue(rt.VpcId), true
+			}
+			return "", false
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !match {
+			continue
+		}
+
+		copy := copyRouteTable(rt)
+		copy.Tags = tags
+		response.RouteTables = append(response.RouteTables, copy)
+	}
+
+	if len(request.RouteTableIds) != 0 && len(response.RouteTables) == 0 {
+		return nil, notFound("InvalidRouteTableID.NotFound", aws.StringValue(request.RouteTableIds[0]))
+	}
+	return response, nil
+}
+
+func (m *MockEC2) DeleteRouteTable(request *ec2.DeleteRouteTableInput) (*ec2.DeleteRouteTableOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.RouteTableId)
+	if m.routeTables[id] == nil {
+		return nil, notFound("InvalidRouteTableID.NotFound", id)
+	}
+	delete(m.routeTables, id)
+	return &ec2.DeleteRouteTableOutput{}, nil
+}
+
+func (m *MockEC2) CreateRoute(request *ec2.CreateRouteInput) (*ec2.CreateRouteOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.RouteTableId)
+	rt := m.routeTables[id]
+	if rt == nil {
+		return nil, notFound("InvalidRouteTableID.NotFound", id)
+	}
+
+	for _, r := range rt.Routes {
+		if aws.StringValue(r.DestinationCidrBlock) == aws.StringValue(request.DestinationCidrBlock) {
+			return nil, awserr.New("RouteAlreadyExists", fmt.Sprintf("route for %q already exists", aws.StringValue(request.DestinationCidrBlock)), nil)
+		}
+	}
+
+	rt.Routes = append(rt.Routes, &ec2.Route{
+		DestinationCidrBlock: request.DestinationCidrBlock,
+		GatewayId:            request.GatewayId,
+		InstanceId:           request.InstanceId,
+		NatGatewayId:         request.NatGatewayId,
+		State:                aws.String(ec2.RouteStateActive),
+	})
+	return &ec2.CreateRouteOutput{Return: aws.Bool(true)}, nil
+}
+
+func (m *MockEC2) ReplaceRoute(request *ec2.ReplaceRouteInput) (*ec2.ReplaceRouteOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.RouteTableId)
+	rt := m.routeTables[id]
+	if rt == nil {
+		return nil, notFound("InvalidRouteTableID.NotFound", id)
+	}
+
+	for _, r := range rt.Routes {
+		if aws.StringValue(r.DestinationCidrBlock) == aws.StringValue(request.DestinationCidrBlock) {
+			r.GatewayId = request.GatewayId
+			r.InstanceId = request.InstanceId
+			r.NatGatewayId = request.NatGatewayId
+			r.State = aws.String(ec2.RouteStateActive)
+			return &ec2.ReplaceRouteOutput{}, nil
+		}
+	}
+	return nil, awserr.New("InvalidRoute.NotFound", fmt.Sprintf("no route for %q", aws.StringValue(request.DestinationCidrBlock)), nil)
+}
+
+func (m *MockEC2) AssociateRouteTable(request *ec2.AssociateRouteTableInput) (*ec2.AssociateRouteTableOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.RouteTableId)
+	rt := m.routeTables[id]
+	if rt == nil {
+		return nil, notFound("InvalidRouteTableID.NotFound", id)
+	}
+	subnetID := aws.StringValue(request.SubnetId)
+	if m.subnets[subnetID] == nil {
+		return nil, notFound("InvalidSubnetID.NotFound", subnetID)
+	}
+
+	associationID := m.allocateID("rtbassoc")
+	rt.Associations = append(rt.Associations, &ec2.RouteTableAssociation{
+		RouteTableAssociationId: aws.String(associationID),
+		RouteTableId:            rt.RouteTableId,
+		SubnetId:                request.SubnetId,
+		Main:                    aws.Bool(false),
+	})
+	return &ec2.AssociateRouteTableOutput{AssociationId: aws.String(associationID)}, nil
+}
+
+func copyRouteTable(rt *ec2.RouteTable) *ec2.RouteTable {
+	copy := *rt
+	copy.Routes = nil
+	for _, r := range rt.Routes {
+		route := *r
+		copy.Routes = append(copy.Routes, &route)
+	}
+	copy.Associations = nil
+	for _, a := range rt.Associations {
+		association := *a
+		copy.Associations = append(copy.Associations, &association)
+	}
+	return &copy
+}
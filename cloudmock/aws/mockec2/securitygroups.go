@@ -0,0 +1,198 @@
+package mockec2
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"reflect"
+)
+
+func (m *MockEC2) CreateSecurityGroup(request *ec2.CreateSecurityGroupInput) (*ec2.CreateSecurityGroupOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	vpcID := aws.StringValue(request.VpcId)
+	if m.vpcs[vpcID] == nil {
+		return nil, notFound("InvalidVpcID.NotFound", vpcID)
+	}
+	for _, sg := range m.securityGroups {
+		if aws.StringValue(sg.VpcId) == vpcID && aws.StringValue(sg.GroupName) == aws.StringValue(request.GroupName) {
+			return nil, awserr.New("InvalidGroup.Duplicate", fmt.Sprintf("The security group %q already exists for VPC %q", aws.StringValue(request.GroupName), vpcID), nil)
+		}
+	}
+
+	id := m.allocateID("sg")
+	sg := &ec2.SecurityGroup{
+		GroupId:     aws.String(id),
+		GroupName:   request.GroupName,
+		Description: request.Description,
+		VpcId:       request.VpcId,
+	}
+	// EC2 adds an allow-all egress rule to new VPC security groups
+	sg.IpPermissionsEgress = []*ec2.IpPermission{
+		{
+			IpProtocol: aws.String("-1"),
+			IpRanges:   []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}},
+		},
+	}
+	m.securityGroups[id] = sg
+
+	return &ec2.CreateSecurityGroupOutput{GroupId: aws.String(id)}, nil
+}
+
+func (m *MockEC2) DescribeSecurityGroups(request *ec2.DescribeSecurityGroupsInput) (*ec2.DescribeSecurityGroupsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response := &ec2.DescribeSecurityGroupsOutput{}
+	for id, sg := range m.securityGroups {
+		if !containsID(request.GroupIds, id) {
+			continue
+		}
+		tags := m.getTags(id)
+		match, err := matchFilters(request.Filters, tags, func(name string) (string, bool) {
+			switch name {
+			case "group-id":
+				return id, true
+			case "group-name":
+				return aws.StringValue(sg.GroupName), true
+			case "vpc-id":
+				return aws.StringValue(sg.VpcId), true
+			}
+			return "", false
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !match {
+			continue
+		}
+
+		copy := *sg
+		copy.IpPermissions = copyPermissions(sg.IpPermissions)
+		copy.IpPermissionsEgress = copyPermissions(sg.IpPermissionsEgress)
+		copy.Tags = tags
+		response.SecurityGroups = append(response.SecurityGroups, &copy)
+	}
+
+	if len(request.GroupIds) != 0 && len(response.SecurityGroups) == 0 {
+		return nil, notFound("InvalidGroup.NotFound", aws.StringValue(request.GroupIds[0]))
+	}
+	return response, nil
+}
+
+func (m *MockEC2) DeleteSecurityGroup(request *ec2.DeleteSecurityGroupInput) (*ec2.DeleteSecurityGroupOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.GroupId)
+	if m.securityGroups[id] == nil {
+		return nil, notFound("InvalidGroup.NotFound", id)
+	}
+	delete(m.securityGroups, id)
+	return &ec2.DeleteSecurityGroupOutput{}, nil
+}
+
+func (m *MockEC2) AuthorizeSecurityGroupIngress(request *ec2.AuthorizeSecurityGroupIngressInput) (*ec2.AuthorizeSecurityGroupIngressOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.GroupId)
+	sg := m.securityGroups[id]
+	if sg == nil {
+		return nil, notFound("InvalidGroup.NotFound", id)
+	}
+
+	permissions, err := addPermissions(sg.IpPermissions, request.IpPermissions)
+	if err != nil {
+		return nil, err
+	}
+	sg.IpPermissions = permissions
+	return &ec2.AuthorizeSecurityGroupIngressOutput{}, nil
+}
+
+func (m *MockEC2) AuthorizeSecurityGroupEgress(request *ec2.AuthorizeSecurityGroupEgressInput) (*ec2.AuthorizeSecurityGroupEgressOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.GroupId)
+	sg := m.securityGroups[id]
+	if sg == nil {
+		return nil, notFound("InvalidGroup.NotFound", id)
+	}
+
+	permissions, err := addPermissions(sg.IpPermissionsEgress, request.IpPermissions)
+	if err != nil {
+		return nil, err
+	}
+	sg.IpPermissionsEgress = permissions
+	return &ec2.AuthorizeSecurityGroupEgressOutput{}, nil
+}
+
+func (m *MockEC2) RevokeSecurityGroupIngress(request *ec2.RevokeSecurityGroupIngressInput) (*ec2.RevokeSecurityGroupIngressOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.GroupId)
+	sg := m.securityGroups[id]
+	if sg == nil {
+		return nil, notFound("InvalidGroup.NotFound", id)
+	}
+
+	sg.IpPermissions = removePermissions(sg.IpPermissions, request.IpPermissions)
+	return &ec2.RevokeSecurityGroupIngressOutput{}, nil
+}
+
+func (m *MockEC2) RevokeSecurityGroupEgress(request *ec2.RevokeSecurityGroupEgressInput) (*ec2.RevokeSecurityGroupEgressOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.GroupId)
+	sg := m.securityGroups[id]
+	if sg == nil {
+		return nil, notFound("InvalidGroup.NotFound", id)
+	}
+
+	sg.IpPermissionsEgress = removePermissions(sg.IpPermissionsEgress, request.IpPermissions)
+	return &ec2.RevokeSecurityGroupEgressOutput{}, nil
+}
+
+// addPermissions appends the permissions, failing if a permission already exists (as EC2 does)
+func addPermissions(existing []*ec2.IpPermission, add []*ec2.IpPermission) ([]*ec2.IpPermission, error) {
+	for _, p := range add {
+		for _, e := range existing {
+			if reflect.DeepEqual(e, p) {
+				return nil, awserr.New("InvalidPermission.Duplicate", "the specified rule already exists", nil)
+			}
+		}
+		copy := *p
+		existing = append(existing, &copy)
+	}
+	return existing, nil
+}
+
+func removePermissions(existing []*ec2.IpPermission, remove []*ec2.IpPermission) []*ec2.IpPermission {
+	var kept []*ec2.IpPermission
+	for _, e := range existing {
+		found := false
+		for _, p := range remove {
+			if reflect.DeepEqual(e, p) {
+				found = true
+			}
+		}
+		if !found {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+func copyPermissions(permissions []*ec2.IpPermission) []*ec2.IpPermission {
+	var copies []*ec2.IpPermission
+	for _, p := range permissions {
+		copy := *p
+		copies = append(copies, &copy)
+	}
+	return copies
+}
@@ -0,0 +1,80 @@
+package mockec2
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func (m *MockEC2) CreateSubnet(request *ec2.CreateSubnetInput) (*ec2.CreateSubnetOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	vpcID := aws.StringValue(request.VpcId)
+	if m.vpcs[vpcID] == nil {
+		return nil, notFound("InvalidVpcID.NotFound", vpcID)
+	}
+
+	id := m.allocateID("subnet")
+	subnet := &ec2.Subnet{
+		SubnetId:         aws.String(id),
+		VpcId:            request.VpcId,
+		CidrBlock:        request.CidrBlock,
+		AvailabilityZone: request.AvailabilityZone,
+		State:            aws.String(ec2.SubnetStateAvailable),
+	}
+	m.subnets[id] = subnet
+
+	copy := *subnet
+	return &ec2.CreateSubnetOutput{Subnet: &copy}, nil
+}
+
+func (m *MockEC2) DescribeSubnets(request *ec2.DescribeSubnetsInput) (*ec2.DescribeSubnetsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response := &ec2.DescribeSubnetsOutput{}
+	for id, subnet := range m.subnets {
+		if !containsID(request.SubnetIds, id) {
+			continue
+		}
+		tags := m.getTags(id)
+		match, err := matchFilters(request.Filters, tags, func(name string) (string, bool) {
+			switch name {
+			case "subnet-id":
+				return id, true
+			case "vpc-id":
+				return aws.StringValue(subnet.VpcId), true
+			case "availability-zone", "availabilityZone":
+				return aws.StringValue(subnet.AvailabilityZone), true
+			}
+			return "", false
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !match {
+			continue
+		}
+
+		copy := *subnet
+		copy.Tags = tags
+		response.Subnets = append(response.Subnets, &copy)
+	}
+
+	if len(request.SubnetIds) != 0 && len(response.Subnets) == 0 {
+		return nil, notFound("InvalidSubnetID.NotFound", aws.StringValue(request.SubnetIds[0]))
+	}
+	return response, nil
+}
+
+func (m *MockEC2) DeleteSubnet(request *ec2.DeleteSubnetInput) (*ec2.DeleteSubnetOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.SubnetId)
+	if m.subnets[id] == nil {
+		return nil, notFound("InvalidSubnetID.NotFound", id)
+	}
+	delete(m.subnets, id)
+	return &ec2.DeleteSubnetOutput{}, nil
+}
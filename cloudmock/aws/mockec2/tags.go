@@ -0,0 +1,170 @@
+package mockec2
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"strings"
+)
+
+// resourceType maps an id to the resource-type EC2 reports for tags
+func resourceType(resourceID string) string {
+	prefix := resourceID
+	if i := strings.Index(resourceID, "-"); i != -1 {
+		prefix = resourceID[:i]
+	}
+	switch prefix {
+	case "vpc":
+		return ec2.ResourceTypeVpc
+	case "subnet":
+		return ec2.ResourceTypeSubnet
+	case "sg":
+		return ec2.ResourceTypeSecurityGroup
+	case "igw":
+		return ec2.ResourceTypeInternetGateway
+	case "rtb":
+		return ec2.ResourceTypeRouteTable
+	case "dopt":
+		return ec2.ResourceTypeDhcpOptions
+	case "vol":
+		return ec2.ResourceTypeVolume
+	case "i":
+		return ec2.ResourceTypeInstance
+	case "ami":
+		return ec2.ResourceTypeImage
+	default:
+		return prefix
+	}
+}
+
+// getTags returns the tags for the resource.  The mutex must be held.
+func (m *MockEC2) getTags(resourceID string) []*ec2.Tag {
+	var tags []*ec2.Tag
+	for _, tag := range m.tags {
+		if aws.StringValue(tag.ResourceId) != resourceID {
+			continue
+		}
+		tags = append(tags, &ec2.Tag{
+			Key:   tag.Key,
+			Value: tag.Value,
+		})
+	}
+	return tags
+}
+
+// addTag sets a tag on a resource, replacing any existing value.  The mutex must be held.
+func (m *MockEC2) addTag(resourceID string, tag *ec2.Tag) {
+	key := aws.StringValue(tag.Key)
+	for _, t := range m.tags {
+		if aws.StringValue(t.ResourceId) == resourceID && aws.StringValue(t.Key) == key {
+			t.Value = tag.Value
+			return
+		}
+	}
+	m.tags = append(m.tags, &ec2.TagDescription{
+		ResourceId:   aws.String(resourceID),
+		ResourceType: aws.String(resourceType(resourceID)),
+		Key:          tag.Key,
+		Value:        tag.Value,
+	})
+}
+
+// hasResource returns true if the id is a known resource.  The mutex must be held.
+func (m *MockEC2) hasResource(resourceID string) bool {
+	switch resourceType(resourceID) {
+	case ec2.ResourceTypeVpc:
+		return m.vpcs[resourceID] != nil
+	case ec2.ResourceTypeSubnet:
+		return m.subnets[resourceID] != nil
+	case ec2.ResourceTypeSecurityGroup:
+		return m.securityGroups[resourceID] != nil
+	case ec2.ResourceTypeInternetGateway:
+		return m.internetGateways[resourceID] != nil
+	case ec2.ResourceTypeRouteTable:
+		return m.routeTables[resourceID] != nil
+	case ec2.ResourceTypeDhcpOptions:
+		return m.dhcpOptions[resourceID] != nil
+	case ec2.ResourceTypeVolume:
+		return m.volumes[resourceID] != nil
+	default:
+		return false
+	}
+}
+
+func (m *MockEC2) CreateTags(request *ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, resource := range request.Resources {
+		id := aws.StringValue(resource)
+		if !m.hasResource(id) {
+			return nil, notFound("InvalidID", id)
+		}
+		for _, tag := range request.Tags {
+			m.addTag(id, tag)
+		}
+	}
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+func (m *MockEC2) DeleteTags(request *ec2.DeleteTagsInput) (*ec2.DeleteTagsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var kept []*ec2.TagDescription
+	for _, t := range m.tags {
+		remove := false
+		for _, resource := range request.Resources {
+			if aws.StringValue(resource) != aws.StringValue(t.ResourceId) {
+				continue
+			}
+			for _, tag := range request.Tags {
+				if aws.StringValue(tag.Key) != aws.StringValue(t.Key) {
+					continue
+				}
+				if tag.Value != nil && aws.StringValue(tag.Value) != aws.StringValue(t.Value) {
+					continue
+				}
+				remove = true
+			}
+		}
+		if !remove {
+			kept = append(kept, t)
+		}
+	}
+	m.tags = kept
+	return &ec2.DeleteTagsOutput{}, nil
+}
+
+func (m *MockEC2) DescribeTags(request *ec2.DescribeTagsInput) (*ec2.DescribeTagsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response := &ec2.DescribeTagsOutput{}
+	for _, t := range m.tags {
+		match := true
+		for _, filter := range request.Filters {
+			var v string
+			switch aws.StringValue(filter.Name) {
+			case "resource-id":
+				v = aws.StringValue(t.ResourceId)
+			case "resource-type":
+				v = aws.StringValue(t.ResourceType)
+			case "key":
+				v = aws.StringValue(t.Key)
+			case "value":
+				v = aws.StringValue(t.Value)
+			default:
+				return nil, fmt.Errorf("filter %q not supported by mock", aws.StringValue(filter.Name))
+			}
+			if !containsID(filter.Values, v) {
+				match = false
+			}
+		}
+		if match {
+			copy := *t
+			response.Tags = append(response.Tags, &copy)
+		}
+	}
+	return response, nil
+}
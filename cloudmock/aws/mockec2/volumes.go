@@ -0,0 +1,95 @@
+package mockec2
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"time"
+)
+
+func (m *MockEC2) CreateVolume(request *ec2.CreateVolumeInput) (*ec2.Volume, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	volumeType := request.VolumeType
+	if volumeType == nil {
+		volumeType = aws.String(ec2.VolumeTypeStandard)
+	}
+
+	id := m.allocateID("vol")
+	v := &ec2.Volume{
+		VolumeId:         aws.String(id),
+		AvailabilityZone: request.AvailabilityZone,
+		Size:             request.Size,
+		VolumeType:       volumeType,
+		Iops:             request.Iops,
+		Encrypted:        aws.Bool(aws.BoolValue(request.Encrypted)),
+		SnapshotId:       request.SnapshotId,
+		State:            aws.String(ec2.VolumeStateAvailable),
+		CreateTime:       aws.Time(time.Now()),
+	}
+	m.volumes[id] = v
+
+	copy := *v
+	return &copy, nil
+}
+
+func (m *MockEC2) DescribeVolumes(request *ec2.DescribeVolumesInput) (*ec2.DescribeVolumesOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response := &ec2.DescribeVolumesOutput{}
+	for id, v := range m.volumes {
+		if !containsID(request.VolumeIds, id) {
+			continue
+		}
+		tags := m.getTags(id)
+		match, err := matchFilters(request.Filters, tags, func(name string) (string, bool) {
+			switch name {
+			case "volume-id":
+				return id, true
+			case "availability-zone":
+				return aws.StringValue(v.AvailabilityZone), true
+			case "status":
+				return aws.StringValue(v.State), true
+			}
+			return "", false
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !match {
+			continue
+		}
+
+		copy := *v
+		copy.Tags = tags
+		response.Volumes = append(response.Volumes, &copy)
+	}
+
+	if len(request.VolumeIds) != 0 && len(response.Volumes) == 0 {
+		return nil, notFound("InvalidVolume.NotFound", aws.StringValue(request.VolumeIds[0]))
+	}
+	return response, nil
+}
+
+func (m *MockEC2) DescribeVolumesPages(request *ec2.DescribeVolumesInput, callback func(*ec2.DescribeVolumesOutput, bool) bool) error {
+	// For the mock, we return everything as a single page
+	response, err := m.DescribeVolumes(request)
+	if err != nil {
+		return err
+	}
+	callback(response, true)
+	return nil
+}
+
+func (m *MockEC2) DeleteVolume(request *ec2.DeleteVolumeInput) (*ec2.DeleteVolumeOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.VolumeId)
+	if m.volumes[id] == nil {
+		return nil, notFound("InvalidVolume.NotFound", id)
+	}
+	delete(m.volumes, id)
+	return &ec2.DeleteVolumeOutput{}, nil
+}
@@ -0,0 +1,123 @@
+package mockec2
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+type vpcInfo struct {
+	main       ec2.Vpc
+	attributes ec2.DescribeVpcAttributeOutput
+}
+
+func (m *MockEC2) CreateVpc(request *ec2.CreateVpcInput) (*ec2.CreateVpcOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := m.allocateID("vpc")
+	vpc := &vpcInfo{
+		main: ec2.Vpc{
+			VpcId:           aws.String(id),
+			CidrBlock:       request.CidrBlock,
+			InstanceTenancy: request.InstanceTenancy,
+			IsDefault:       aws.Bool(false),
+			State:           aws.String(ec2.VpcStateAvailable),
+		},
+		attributes: ec2.DescribeVpcAttributeOutput{
+			EnableDnsSupport:   &ec2.AttributeBooleanValue{Value: aws.Bool(true)},
+			EnableDnsHostnames: &ec2.AttributeBooleanValue{Value: aws.Bool(false)},
+		},
+	}
+	m.vpcs[id] = vpc
+
+	copy := vpc.main
+	return &ec2.CreateVpcOutput{Vpc: &copy}, nil
+}
+
+func (m *MockEC2) DescribeVpcs(request *ec2.DescribeVpcsInput) (*ec2.DescribeVpcsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response := &ec2.DescribeVpcsOutput{}
+	for id, vpc := range m.vpcs {
+		if !containsID(request.VpcIds, id) {
+			continue
+		}
+		tags := m.getTags(id)
+		match, err := matchFilters(request.Filters, tags, func(name string) (string, bool) {
+			switch name {
+			case "vpc-id":
+				return id, true
+			case "cidr":
+				return aws.StringValue(vpc.main.CidrBlock), true
+			}
+			return "", false
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !match {
+			continue
+		}
+
+		copy := vpc.main
+		copy.Tags = tags
+		response.Vpcs = append(response.Vpcs, &copy)
+	}
+
+	if len(request.VpcIds) != 0 && len(response.Vpcs) == 0 {
+		return nil, notFound("InvalidVpcID.NotFound", aws.StringValue(request.VpcIds[0]))
+	}
+	return response, nil
+}
+
+func (m *MockEC2) DescribeVpcAttribute(request *ec2.DescribeVpcAttributeInput) (*ec2.DescribeVpcAttributeOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	vpcID := aws.StringValue(request.VpcId)
+	vpc := m.vpcs[vpcID]
+	if vpc == nil {
+		return nil, notFound("InvalidVpcID.NotFound", vpcID)
+	}
+
+	response := &ec2.DescribeVpcAttributeOutput{VpcId: request.VpcId}
+	switch aws.StringValue(request.Attribute) {
+	case ec2.VpcAttributeNameEnableDnsSupport:
+		response.EnableDnsSupport = vpc.attributes.EnableDnsSupport
+	case ec2.VpcAttributeNameEnableDnsHostnames:
+		response.EnableDnsHostnames = vpc.attributes.EnableDnsHostnames
+	}
+	return response, nil
+}
+
+func (m *MockEC2) ModifyVpcAttribute(request *ec2.ModifyVpcAttributeInput) (*ec2.ModifyVpcAttributeOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	vpcID := aws.StringValue(request.VpcId)
+	vpc := m.vpcs[vpcID]
+	if vpc == nil {
+		return nil, notFound("InvalidVpcID.NotFound", vpcID)
+	}
+
+	if request.EnableDnsSupport != nil {
+		vpc.attributes.EnableDnsSupport = request.EnableDnsSupport
+	}
+	if request.EnableDnsHostnames != nil {
+		vpc.attributes.EnableDnsHostnames = request.EnableDnsHostnames
+	}
+	return &ec2.ModifyVpcAttributeOutput{}, nil
+}
+
+func (m *MockEC2) DeleteVpc(request *ec2.DeleteVpcInput) (*ec2.DeleteVpcOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	vpcID := aws.StringValue(request.VpcId)
+	if m.vpcs[vpcID] == nil {
+		return nil, notFound("InvalidVpcID.NotFound", vpcID)
+	}
+	delete(m.vpcs, vpcID)
+	return &ec2.DeleteVpcOutput{}, nil
+}
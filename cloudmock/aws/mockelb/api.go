@@ -0,0 +1,182 @@
+package mockelb
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elb/elbiface"
+	"sync"
+)
+
+// MockELB is an in-memory implementation of the subset of the ELB API used by kops
+type MockELB struct {
+	elbiface.ELBAPI
+
+	mutex sync.Mutex
+
+	loadBalancers map[string]*loadBalancer
+}
+
+type loadBalancer struct {
+	description elb.LoadBalancerDescription
+	tags        map[string]string
+}
+
+var _ elbiface.ELBAPI = &MockELB{}
+
+func NewMockELB() *MockELB {
+	return &MockELB{
+		loadBalancers: make(map[string]*loadBalancer),
+	}
+}
+
+func loadBalancerNotFound(name string) error {
+	return awserr.New("LoadBalancerNotFound", fmt.Sprintf("There is no ACTIVE Load Balancer named %q", name), nil)
+}
+
+func (m *MockELB) CreateLoadBalancer(request *elb.CreateLoadBalancerInput) (*elb.CreateLoadBalancerOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.LoadBalancerName)
+	if m.loadBalancers[name] != nil {
+		return nil, awserr.New("DuplicateLoadBalancerName", fmt.Sprintf("Load Balancer named %q already exists", name), nil)
+	}
+
+	dnsName := name + ".elb.mock.amazonaws.com"
+	lb := &loadBalancer{
+		description: elb.LoadBalancerDescription{
+			LoadBalancerName:          request.LoadBalancerName,
+			DNSName:                   aws.String(dnsName),
+			CanonicalHostedZoneName:   aws.String(dnsName),
+			CanonicalHostedZoneNameID: aws.String("ZMOCKELB"),
+			Scheme:                    request.Scheme,
+			Subnets:                   request.Subnets,
+			SecurityGroups:            request.SecurityGroups,
+			AvailabilityZones:         request.AvailabilityZones,
+		},
+		tags: make(map[string]string),
+	}
+	if lb.description.Scheme == nil {
+		lb.description.Scheme = aws.String("internet-facing")
+	}
+	for _, listener := range request.Listeners {
+		lb.description.ListenerDescriptions = append(lb.description.ListenerDescriptions, &elb.ListenerDescription{Listener: listener})
+	}
+	for _, tag := range request.Tags {
+		lb.tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	m.loadBalancers[name] = lb
+
+	return &elb.CreateLoadBalancerOutput{DNSName: aws.String(dnsName)}, nil
+}
+
+func (m *MockELB) CreateLoadBalancerListeners(request *elb.CreateLoadBalancerListenersInput) (*elb.CreateLoadBalancerListenersOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.LoadBalancerName)
+	lb := m.loadBalancers[name]
+	if lb == nil {
+		return nil, loadBalancerNotFound(name)
+	}
+	for _, listener := range request.Listeners {
+		lb.description.ListenerDescriptions = append(lb.description.ListenerDescriptions, &elb.ListenerDescription{Listener: listener})
+	}
+	return &elb.CreateLoadBalancerListenersOutput{}, nil
+}
+
+func (m *MockELB) ConfigureHealthCheck(request *elb.ConfigureHealthCheckInput) (*elb.ConfigureHealthCheckOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.LoadBalancerName)
+	lb := m.loadBalancers[name]
+	if lb == nil {
+		return nil, loadBalancerNotFound(name)
+	}
+	lb.description.HealthCheck = request.HealthCheck
+	return &elb.ConfigureHealthCheckOutput{HealthCheck: request.HealthCheck}, nil
+}
+
+func (m *MockELB) DescribeLoadBalancers(request *elb.DescribeLoadBalancersInput) (*elb.DescribeLoadBalancersOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response := &elb.DescribeLoadBalancersOutput{}
+	if len(request.LoadBalancerNames) == 0 {
+		for _, lb := range m.loadBalancers {
+			copy := lb.description
+			response.LoadBalancerDescriptions = append(response.LoadBalancerDescriptions, &copy)
+		}
+		return response, nil
+	}
+
+	for _, n := range request.LoadBalancerNames {
+		name := aws.StringValue(n)
+		lb := m.loadBalancers[name]
+		if lb == nil {
+			return nil, loadBalancerNotFound(name)
+		}
+		copy := lb.description
+		response.LoadBalancerDescriptions = append(response.LoadBalancerDescriptions, &copy)
+	}
+	return response, nil
+}
+
+func (m *MockELB) DescribeLoadBalancersPages(request *elb.DescribeLoadBalancersInput, callback func(*elb.DescribeLoadBalancersOutput, bool) bool) error {
+	// For the mock, we return everything as a single page
+	response, err := m.DescribeLoadBalancers(request)
+	if err != nil {
+		return err
+	}
+	callback(response, true)
+	return nil
+}
+
+func (m *MockELB) DeleteLoadBalancer(request *elb.DeleteLoadBalancerInput) (*elb.DeleteLoadBalancerOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// Like ELB, deleting a missing load balancer is not an error
+	delete(m.loadBalancers, aws.StringValue(request.LoadBalancerName))
+	return &elb.DeleteLoadBalancerOutput{}, nil
+}
+
+func (m *MockELB) AddTags(request *elb.AddTagsInput) (*elb.AddTagsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, n := range request.LoadBalancerNames {
+		name := aws.StringValue(n)
+		lb := m.loadBalancers[name]
+		if lb == nil {
+			return nil, loadBalancerNotFound(name)
+		}
+		for _, tag := range request.Tags {
+			lb.tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+	}
+	return &elb.AddTagsOutput{}, nil
+}
+
+func (m *MockELB) DescribeTags(request *elb.DescribeTagsInput) (*elb.DescribeTagsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response := &elb.DescribeTagsOutput{}
+	for _, n := range request.LoadBalancerNames {
+		name := aws.StringValue(n)
+		lb := m.loadBalancers[name]
+		if lb == nil {
+			return nil, loadBalancerNotFound(name)
+		}
+		description := &elb.TagDescription{LoadBalancerName: aws.String(name)}
+		for k, v := range lb.tags {
+			description.Tags = append(description.Tags, &elb.Tag{Key: aws.String(k), Value: aws.String(v)})
+		}
+		response.TagDescriptions = append(response.TagDescriptions, description)
+	}
+	return response, nil
+}
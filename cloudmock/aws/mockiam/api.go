@@ -0,0 +1,45 @@
+package mockiam
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"sync"
+)
+
+// MockIAM is an in-memory implementation of the subset of the IAM API used by kops
+type MockIAM struct {
+	iamiface.IAMAPI
+
+	mutex sync.Mutex
+
+	lastID int
+
+	roles            map[string]*iam.Role
+	rolePolicies     []*rolePolicy
+	instanceProfiles map[string]*iam.InstanceProfile
+}
+
+var _ iamiface.IAMAPI = &MockIAM{}
+
+func NewMockIAM() *MockIAM {
+	return &MockIAM{
+		roles:            make(map[string]*iam.Role),
+		instanceProfiles: make(map[string]*iam.InstanceProfile),
+	}
+}
+
+// allocateID returns a new id with the specified prefix.  The mutex must be held.
+func (m *MockIAM) allocateID(prefix string) string {
+	m.lastID++
+	return fmt.Sprintf("%s%d", prefix, m.lastID)
+}
+
+func noSuchEntity(kind string, name string) error {
+	return awserr.New("NoSuchEntity", fmt.Sprintf("The %s with name %s cannot be found.", kind, name), nil)
+}
+
+func entityAlreadyExists(kind string, name string) error {
+	return awserr.New("EntityAlreadyExists", fmt.Sprintf("%s with name %s already exists.", kind, name), nil)
+}
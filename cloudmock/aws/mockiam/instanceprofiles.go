@@ -0,0 +1,113 @@
+package mockiam
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"time"
+)
+
+func (m *MockIAM) CreateInstanceProfile(request *iam.CreateInstanceProfileInput) (*iam.CreateInstanceProfileOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.InstanceProfileName)
+	if m.instanceProfiles[name] != nil {
+		return nil, entityAlreadyExists("Instance Profile", name)
+	}
+
+	path := request.Path
+	if path == nil {
+		path = aws.String("/")
+	}
+
+	ip := &iam.InstanceProfile{
+		InstanceProfileId:   aws.String(m.allocateID("AIPA")),
+		InstanceProfileName: request.InstanceProfileName,
+		Path:                path,
+		Arn:                 aws.String("arn:aws:iam::123456789012:instance-profile" + aws.StringValue(path) + name),
+		CreateDate:          aws.Time(time.Now()),
+	}
+	m.instanceProfiles[name] = ip
+
+	return &iam.CreateInstanceProfileOutput{InstanceProfile: copyInstanceProfile(ip)}, nil
+}
+
+func (m *MockIAM) GetInstanceProfile(request *iam.GetInstanceProfileInput) (*iam.GetInstanceProfileOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.InstanceProfileName)
+	ip := m.instanceProfiles[name]
+	if ip == nil {
+		return nil, noSuchEntity("instance profile", name)
+	}
+	return &iam.GetInstanceProfileOutput{InstanceProfile: copyInstanceProfile(ip)}, nil
+}
+
+func (m *MockIAM) AddRoleToInstanceProfile(request *iam.AddRoleToInstanceProfileInput) (*iam.AddRoleToInstanceProfileOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.InstanceProfileName)
+	ip := m.instanceProfiles[name]
+	if ip == nil {
+		return nil, noSuchEntity("instance profile", name)
+	}
+	roleName := aws.StringValue(request.RoleName)
+	role := m.roles[roleName]
+	if role == nil {
+		return nil, noSuchEntity("role", roleName)
+	}
+
+	// An instance profile can contain only one role
+	if len(ip.Roles) != 0 {
+		return nil, awserr.New("LimitExceeded", "Cannot exceed quota for InstanceSessionsPerInstanceProfile: 1", nil)
+	}
+
+	copy := *role
+	ip.Roles = append(ip.Roles, &copy)
+	return &iam.AddRoleToInstanceProfileOutput{}, nil
+}
+
+func (m *MockIAM) RemoveRoleFromInstanceProfile(request *iam.RemoveRoleFromInstanceProfileInput) (*iam.RemoveRoleFromInstanceProfileOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.InstanceProfileName)
+	ip := m.instanceProfiles[name]
+	if ip == nil {
+		return nil, noSuchEntity("instance profile", name)
+	}
+
+	var roles []*iam.Role
+	for _, r := range ip.Roles {
+		if aws.StringValue(r.RoleName) != aws.StringValue(request.RoleName) {
+			roles = append(roles, r)
+		}
+	}
+	ip.Roles = roles
+	return &iam.RemoveRoleFromInstanceProfileOutput{}, nil
+}
+
+func (m *MockIAM) DeleteInstanceProfile(request *iam.DeleteInstanceProfileInput) (*iam.DeleteInstanceProfileOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.InstanceProfileName)
+	if m.instanceProfiles[name] == nil {
+		return nil, noSuchEntity("instance profile", name)
+	}
+	delete(m.instanceProfiles, name)
+	return &iam.DeleteInstanceProfileOutput{}, nil
+}
+
+func copyInstanceProfile(ip *iam.InstanceProfile) *iam.InstanceProfile {
+	copy := *ip
+	copy.Roles = nil
+	for _, r := range ip.Roles {
+		role := *r
+		copy.Roles = append(copy.Roles, &role)
+	}
+	return &copy
+}
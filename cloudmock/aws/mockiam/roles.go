@@ -0,0 +1,148 @@
+package mockiam
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"net/url"
+	"time"
+)
+
+type rolePolicy struct {
+	RoleName       string
+	PolicyName     string
+	PolicyDocument string
+}
+
+func (m *MockIAM) CreateRole(request *iam.CreateRoleInput) (*iam.CreateRoleOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.RoleName)
+	if m.roles[name] != nil {
+		return nil, entityAlreadyExists("Role", name)
+	}
+
+	path := request.Path
+	if path == nil {
+		path = aws.String("/")
+	}
+
+	role := &iam.Role{
+		RoleId:     aws.String(m.allocateID("AROA")),
+		RoleName:   request.RoleName,
+		Path:       path,
+		Arn:        aws.String("arn:aws:iam::123456789012:role" + aws.StringValue(path) + name),
+		CreateDate: aws.Time(time.Now()),
+		// IAM returns the policy document URL-encoded
+		AssumeRolePolicyDocument: aws.String(url.QueryEscape(aws.StringValue(request.AssumeRolePolicyDocument))),
+	}
+	m.roles[name] = role
+
+	copy := *role
+	return &iam.CreateRoleOutput{Role: &copy}, nil
+}
+
+func (m *MockIAM) GetRole(request *iam.GetRoleInput) (*iam.GetRoleOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.RoleName)
+	role := m.roles[name]
+	if role == nil {
+		return nil, noSuchEntity("role", name)
+	}
+
+	copy := *role
+	return &iam.GetRoleOutput{Role: &copy}, nil
+}
+
+func (m *MockIAM) UpdateAssumeRolePolicy(request *iam.UpdateAssumeRolePolicyInput) (*iam.UpdateAssumeRolePolicyOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.RoleName)
+	role := m.roles[name]
+	if role == nil {
+		return nil, noSuchEntity("role", name)
+	}
+
+	role.AssumeRolePolicyDocument = aws.String(url.QueryEscape(aws.StringValue(request.PolicyDocument)))
+	return &iam.UpdateAssumeRolePolicyOutput{}, nil
+}
+
+func (m *MockIAM) DeleteRole(request *iam.DeleteRoleInput) (*iam.DeleteRoleOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.RoleName)
+	if m.roles[name] == nil {
+		return nil, noSuchEntity("role", name)
+	}
+	delete(m.roles, name)
+	return &iam.DeleteRoleOutput{}, nil
+}
+
+func (m *MockIAM) PutRolePolicy(request *iam.PutRolePolicyInput) (*iam.PutRolePolicyOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	roleName := aws.StringValue(request.RoleName)
+	if m.roles[roleName] == nil {
+		return nil, noSuchEntity("role", roleName)
+	}
+
+	policyName := aws.StringValue(request.PolicyName)
+	for _, p := range m.rolePolicies {
+		if p.RoleName == roleName && p.PolicyName == policyName {
+			p.PolicyDocument = aws.StringValue(request.PolicyDocument)
+			return &iam.PutRolePolicyOutput{}, nil
+		}
+	}
+
+	m.rolePolicies = append(m.rolePolicies, &rolePolicy{
+		RoleName:       roleName,
+		PolicyName:     policyName,
+		PolicyDocument: aws.StringValue(request.PolicyDocument),
+	})
+	return &iam.PutRolePolicyOutput{}, nil
+}
+
+func (m *MockIAM) GetRolePolicy(request *iam.GetRolePolicyInput) (*iam.GetRolePolicyOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	roleName := aws.StringValue(request.RoleName)
+	policyName := aws.StringValue(request.PolicyName)
+	for _, p := range m.rolePolicies {
+		if p.RoleName == roleName && p.PolicyName == policyName {
+			return &iam.GetRolePolicyOutput{
+				RoleName:       aws.String(p.RoleName),
+				PolicyName:     aws.String(p.PolicyName),
+				PolicyDocument: aws.String(url.QueryEscape(p.PolicyDocument)),
+			}, nil
+		}
+	}
+	return nil, noSuchEntity("role policy", policyName)
+}
+
+func (m *MockIAM) DeleteRolePolicy(request *iam.DeleteRolePolicyInput) (*iam.DeleteRolePolicyOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	roleName := aws.StringValue(request.RoleName)
+	policyName := aws.StringValue(request.PolicyName)
+	var kept []*rolePolicy
+	found := false
+	for _, p := range m.rolePolicies {
+		if p.RoleName == roleName && p.PolicyName == policyName {
+			found = true
+			continue
+		}
+		kept = append(kept, p)
+	}
+	if !found {
+		return nil, noSuchEntity("role policy", policyName)
+	}
+	m.rolePolicies = kept
+	return &iam.DeleteRolePolicyOutput{}, nil
+}
@@ -0,0 +1,206 @@
+package mockroute53
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MockRoute53 is an in-memory implementation of the subset of the Route53 API used by kops
+type MockRoute53 struct {
+	route53iface.Route53API
+
+	mutex sync.Mutex
+
+	lastID int
+
+	zones map[string]*zoneInfo
+}
+
+type zoneInfo struct {
+	hostedZone route53.HostedZone
+	records    []*route53.ResourceRecordSet
+}
+
+var _ route53iface.Route53API = &MockRoute53{}
+
+func NewMockRoute53() *MockRoute53 {
+	return &MockRoute53{
+		zones: make(map[string]*zoneInfo),
+	}
+}
+
+// findZone looks up a zone by id; both the bare id and the /hostedzone/ form are accepted.  The mutex must be held.
+func (m *MockRoute53) findZone(id string) *zoneInfo {
+	if !strings.HasPrefix(id, "/hostedzone/") {
+		id = "/hostedzone/" + id
+	}
+	return m.zones[id]
+}
+
+func noSuchHostedZone(id string) error {
+	return awserr.New("NoSuchHostedZone", fmt.Sprintf("No hosted zone found with ID: %s", id), nil)
+}
+
+// normalizeName returns the dns name in the fully-qualified form route53 uses
+func normalizeName(name string) string {
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	return name
+}
+
+// AddZone registers an existing hosted zone, returning its id
+func (m *MockRoute53) AddZone(name string) string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.addZone(name)
+}
+
+// addZone creates a zone.  The mutex must be held.
+func (m *MockRoute53) addZone(name string) string {
+	m.lastID++
+	id := fmt.Sprintf("/hostedzone/Z%d", m.lastID)
+	m.zones[id] = &zoneInfo{
+		hostedZone: route53.HostedZone{
+			Id:   aws.String(id),
+			Name: aws.String(normalizeName(name)),
+		},
+	}
+	return id
+}
+
+func (m *MockRoute53) CreateHostedZone(request *route53.CreateHostedZoneInput) (*route53.CreateHostedZoneOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := m.addZone(aws.StringValue(request.Name))
+	zone := m.zones[id]
+
+	copy := zone.hostedZone
+	return &route53.CreateHostedZoneOutput{
+		HostedZone: &copy,
+		ChangeInfo: &route53.ChangeInfo{
+			Id:          aws.String("/change/" + strings.TrimPrefix(id, "/hostedzone/")),
+			Status:      aws.String(route53.ChangeStatusInsync),
+			SubmittedAt: aws.Time(time.Now()),
+		},
+	}, nil
+}
+
+func (m *MockRoute53) ListHostedZonesByName(request *route53.ListHostedZonesByNameInput) (*route53.ListHostedZonesByNameOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var zones []*route53.HostedZone
+	for _, zone := range m.zones {
+		copy := zone.hostedZone
+		zones = append(zones, &copy)
+	}
+
+	// Zones are returned in order of name, starting at DNSName
+	sort.Sort(byName(zones))
+	start := normalizeName(aws.StringValue(request.DNSName))
+	response := &route53.ListHostedZonesByNameOutput{DNSName: request.DNSName}
+	for _, zone := range zones {
+		if request.DNSName != nil && aws.StringValue(zone.Name) < start {
+			continue
+		}
+		response.HostedZones = append(response.HostedZones, zone)
+	}
+	return response, nil
+}
+
+type byName []*route53.HostedZone
+
+func (a byName) Len() int      { return len(a) }
+func (a byName) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byName) Less(i, j int) bool {
+	return aws.StringValue(a[i].Name) < aws.StringValue(a[j].Name)
+}
+
+func (m *MockRoute53) ListResourceRecordSets(request *route53.ListResourceRecordSetsInput) (*route53.ListResourceRecordSetsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	zone := m.findZone(aws.StringValue(request.HostedZoneId))
+	if zone == nil {
+		return nil, noSuchHostedZone(aws.StringValue(request.HostedZoneId))
+	}
+
+	response := &route53.ListResourceRecordSetsOutput{}
+	for _, rr := range zone.records {
+		copy := *rr
+		response.ResourceRecordSets = append(response.ResourceRecordSets, &copy)
+	}
+	return response, nil
+}
+
+func (m *MockRoute53) ListResourceRecordSetsPages(request *route53.ListResourceRecordSetsInput, callback func(*route53.ListResourceRecordSetsOutput, bool) bool) error {
+	// For the mock, we return everything as a single page
+	response, err := m.ListResourceRecordSets(request)
+	if err != nil {
+		return err
+	}
+	callback(response, true)
+	return nil
+}
+
+func (m *MockRoute53) ChangeResourceRecordSets(request *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	zone := m.findZone(aws.StringValue(request.HostedZoneId))
+	if zone == nil {
+		return nil, noSuchHostedZone(aws.StringValue(request.HostedZoneId))
+	}
+
+	for _, change := range request.ChangeBatch.Changes {
+		rrs := *change.ResourceRecordSet
+		rrs.Name = aws.String(normalizeName(aws.StringValue(rrs.Name)))
+
+		index := -1
+		for i, existing := range zone.records {
+			if aws.StringValue(existing.Name) == aws.StringValue(rrs.Name) && aws.StringValue(existing.Type) == aws.StringValue(rrs.Type) {
+				index = i
+			}
+		}
+
+		switch aws.StringValue(change.Action) {
+		case route53.ChangeActionCreate:
+			if index != -1 {
+				return nil, awserr.New("InvalidChangeBatch", fmt.Sprintf("Tried to create resource record set %s type %s but it already exists", aws.StringValue(rrs.Name), aws.StringValue(rrs.Type)), nil)
+			}
+			zone.records = append(zone.records, &rrs)
+		case route53.ChangeActionUpsert:
+			if index != -1 {
+				zone.records[index] = &rrs
+			} else {
+				zone.records = append(zone.records, &rrs)
+			}
+		case route53.ChangeActionDelete:
+			if index == -1 {
+				return nil, awserr.New("InvalidChangeBatch", fmt.Sprintf("Tried to delete resource record set %s type %s but it was not found", aws.StringValue(rrs.Name), aws.StringValue(rrs.Type)), nil)
+			}
+			zone.records = append(zone.records[:index], zone.records[index+1:]...)
+		default:
+			return nil, fmt.Errorf("unknown change action %q", aws.StringValue(change.Action))
+		}
+	}
+
+	m.lastID++
+	return &route53.ChangeResourceRecordSetsOutput{
+		ChangeInfo: &route53.ChangeInfo{
+			Id:          aws.String(fmt.Sprintf("/change/C%d", m.lastID)),
+			Status:      aws.String(route53.ChangeStatusInsync),
+			SubmittedAt: aws.Time(time.Now()),
+		},
+	}, nil
+}
@@ -0,0 +1,341 @@
+package mockcompute
+
+import (
+	"encoding/json"
+	"fmt"
+	"google.golang.org/api/compute/v1"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// baseURL is the prefix of the selfLinks we generate; we use the real prefix so that the links parse as GCE URLs
+const baseURL = "https://www.googleapis.com/compute/v1/"
+
+// MockCompute is an in-process implementation of the subset of the GCE compute JSON API used by kops.
+// Resources are stored as the JSON objects that were sent, with the fields that GCE fills in added;
+// operations complete immediately.  Managed instance groups are kept at their target size with fake instances.
+type MockCompute struct {
+	mutex sync.Mutex
+
+	// resources holds the objects in each collection (e.g. "projects/p/zones/z/disks"), keyed by name
+	resources map[string]map[string]map[string]interface{}
+
+	lastID int
+
+	server *httptest.Server
+}
+
+func NewMockCompute() *MockCompute {
+	return &MockCompute{
+		resources: make(map[string]map[string]map[string]interface{}),
+	}
+}
+
+// Start runs the fake server, returning a compute client that talks to it
+func (m *MockCompute) Start() (*compute.Service, error) {
+	m.server = httptest.NewServer(m)
+	client, err := compute.New(http.DefaultClient)
+	if err != nil {
+		m.server.Close()
+		return nil, fmt.Errorf("error building compute client: %v", err)
+	}
+	client.BasePath = m.server.URL + "/compute/v1/projects/"
+	return client, nil
+}
+
+// Close stops the fake server
+func (m *MockCompute) Close() {
+	if m.server != nil {
+		m.server.Close()
+	}
+}
+
+// request is a parsed API request: the collection (and optionally the name) it addresses, and any custom verb
+type request struct {
+	project string
+	// scope is "global", "zones/<zone>" or "regions/<region>"
+	scope        string
+	resourceType string
+	name         string
+	verb         string
+}
+
+func (r *request) collection() string {
+	return "projects/" + r.project + "/" + r.scope + "/" + r.resourceType
+}
+
+func parseRequest(path string) (*request, error) {
+	tokens := strings.Split(strings.TrimPrefix(path, "/compute/v1/"), "/")
+	if len(tokens) < 3 || tokens[0] != "projects" {
+		return nil, fmt.Errorf("unknown path %q", path)
+	}
+	r := &request{project: tokens[1]}
+	tokens = tokens[2:]
+
+	switch tokens[0] {
+	case "global", "aggregated":
+		r.scope = tokens[0]
+		tokens = tokens[1:]
+	case "zones", "regions":
+		if len(tokens) < 2 {
+			return nil, fmt.Errorf("unknown path %q", path)
+		}
+		r.scope = tokens[0] + "/" + tokens[1]
+		tokens = tokens[2:]
+	default:
+		return nil, fmt.Errorf("unknown path %q", path)
+	}
+
+	if len(tokens) < 1 || len(tokens) > 3 {
+		return nil, fmt.Errorf("unknown path %q", path)
+	}
+	r.resourceType = tokens[0]
+	if len(tokens) > 1 {
+		r.name = tokens[1]
+	}
+	if len(tokens) > 2 {
+		r.verb = tokens[2]
+	}
+	return r, nil
+}
+
+func (m *MockCompute) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	req, err := parseRequest(r.URL.Path)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "notFound", err.Error())
+		return
+	}
+
+	if req.scope == "aggregated" {
+		if r.Method != "GET" {
+			writeError(w, http.StatusMethodNotAllowed, "badRequest", "unhandled method "+r.Method)
+			return
+		}
+		m.aggregatedList(w, req)
+		return
+	}
+
+	if req.name == "" {
+		switch r.Method {
+		case "GET":
+			m.list(w, req)
+		case "POST":
+			m.insert(w, r, req)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "badRequest", "unhandled method "+r.Method)
+		}
+		return
+	}
+
+	obj := m.resources[req.collection()][req.name]
+	if obj == nil {
+		writeError(w, http.StatusNotFound, "notFound", fmt.Sprintf("The resource '%s/%s' was not found", req.collection(), req.name))
+		return
+	}
+
+	if req.verb != "" {
+		if r.Method != "POST" {
+			writeError(w, http.StatusMethodNotAllowed, "badRequest", "unhandled method "+r.Method)
+			return
+		}
+		m.invoke(w, r, req, obj)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		writeJSON(w, obj)
+	case "PUT", "PATCH":
+		m.update(w, r, req, obj)
+	case "DELETE":
+		m.delete(w, req, obj)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "badRequest", "unhandled method "+r.Method)
+	}
+}
+
+func (m *MockCompute) list(w http.ResponseWriter, req *request) {
+	writeJSON(w, map[string]interface{}{
+		"items": m.sortedItems(req.collection()),
+	})
+}
+
+func (m *MockCompute) aggregatedList(w http.ResponseWriter, req *request) {
+	prefix := "projects/" + req.project + "/"
+	suffix := "/" + req.resourceType
+
+	items := make(map[string]interface{})
+	for collection := range m.resources {
+		if !strings.HasPrefix(collection, prefix) || !strings.HasSuffix(collection, suffix) {
+			continue
+		}
+		scope := strings.TrimSuffix(strings.TrimPrefix(collection, prefix), suffix)
+		if scope == "global" {
+			continue
+		}
+		items[scope] = map[string]interface{}{
+			req.resourceType: m.sortedItems(collection),
+		}
+	}
+	writeJSON(w, map[string]interface{}{
+		"items": items,
+	})
+}
+
+func (m *MockCompute) insert(w http.ResponseWriter, r *http.Request, req *request) {
+	obj, err := readJSON(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "badRequest", err.Error())
+		return
+	}
+	name, _ := obj["name"].(string)
+	if name == "" {
+		writeError(w, http.StatusBadRequest, "badRequest", "name is required")
+		return
+	}
+	if m.resources[req.collection()][name] != nil {
+		writeError(w, http.StatusConflict, "alreadyExists", fmt.Sprintf("The resource '%s/%s' already exists", req.collection(), name))
+		return
+	}
+
+	m.lastID++
+	obj["id"] = strconv.Itoa(m.lastID)
+	obj["creationTimestamp"] = time.Now().UTC().Format(time.RFC3339)
+	m.put(req.collection(), name, obj)
+
+	if req.resourceType == "instanceGroupManagers" {
+		m.realizeGroup(req, obj)
+	}
+
+	writeJSON(w, m.operation(req, "insert", obj))
+}
+
+func (m *MockCompute) update(w http.ResponseWriter, r *http.Request, req *request, existing map[string]interface{}) {
+	obj, err := readJSON(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "badRequest", err.Error())
+		return
+	}
+	for _, k := range []string{"id", "creationTimestamp"} {
+		obj[k] = existing[k]
+	}
+	obj["name"] = req.name
+	m.put(req.collection(), req.name, obj)
+
+	writeJSON(w, m.operation(req, "update", obj))
+}
+
+func (m *MockCompute) delete(w http.ResponseWriter, req *request, obj map[string]interface{}) {
+	delete(m.resources[req.collection()], req.name)
+
+	if req.resourceType == "instanceGroupManagers" {
+		for _, instance := range m.groupInstances(req, obj) {
+			delete(m.resources[instancesCollection(req)], instance["name"].(string))
+		}
+	}
+
+	writeJSON(w, m.operation(req, "delete", obj))
+}
+
+func (m *MockCompute) invoke(w http.ResponseWriter, r *http.Request, req *request, obj map[string]interface{}) {
+	if req.resourceType == "instanceGroupManagers" {
+		switch req.verb {
+		case "resize":
+			m.resize(w, r, req, obj)
+			return
+		case "setInstanceTemplate":
+			m.setInstanceTemplate(w, r, req, obj)
+			return
+		case "listManagedInstances":
+			m.listManagedInstances(w, req, obj)
+			return
+		case "deleteInstances":
+			m.deleteInstances(w, r, req, obj)
+			return
+		}
+	}
+	writeError(w, http.StatusBadRequest, "badRequest", fmt.Sprintf("unhandled verb %q on %s", req.verb, req.resourceType))
+}
+
+// put stores an object, filling in the fields that GCE sets
+func (m *MockCompute) put(collection string, name string, obj map[string]interface{}) {
+	tokens := strings.Split(collection, "/")
+	if tokens[2] == "zones" {
+		obj["zone"] = baseURL + strings.Join(tokens[:4], "/")
+	}
+	if tokens[2] == "regions" {
+		obj["region"] = baseURL + strings.Join(tokens[:4], "/")
+	}
+	obj["selfLink"] = baseURL + collection + "/" + name
+
+	if m.resources[collection] == nil {
+		m.resources[collection] = make(map[string]map[string]interface{})
+	}
+	m.resources[collection][name] = obj
+}
+
+// operation records a completed operation on the target object
+func (m *MockCompute) operation(req *request, operationType string, target map[string]interface{}) map[string]interface{} {
+	m.lastID++
+	name := "operation-" + strconv.Itoa(m.lastID)
+	op := map[string]interface{}{
+		"kind":          "compute#operation",
+		"name":          name,
+		"operationType": operationType,
+		"status":        "DONE",
+		"targetLink":    target["selfLink"],
+	}
+	m.put("projects/"+req.project+"/"+req.scope+"/operations", name, op)
+	return op
+}
+
+func (m *MockCompute) sortedItems(collection string) []interface{} {
+	var names []string
+	for name := range m.resources[collection] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	items := []interface{}{}
+	for _, name := range names {
+		items = append(items, m.resources[collection][name])
+	}
+	return items
+}
+
+func readJSON(r *http.Request) (map[string]interface{}, error) {
+	obj := make(map[string]interface{})
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&obj); err != nil {
+		return nil, fmt.Errorf("error parsing request body: %v", err)
+	}
+	return obj, nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, reason string, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    code,
+			"message": message,
+			"errors": []interface{}{
+				map[string]interface{}{"reason": reason, "message": message},
+			},
+		},
+	})
+}
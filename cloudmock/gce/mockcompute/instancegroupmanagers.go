@@ -0,0 +1,149 @@
+package mockcompute
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+func instancesCollection(req *request) string {
+	return "projects/" + req.project + "/" + req.scope + "/instances"
+}
+
+// groupInstances returns the instances created by the managed instance group, sorted by name
+func (m *MockCompute) groupInstances(req *request, mig map[string]interface{}) []map[string]interface{} {
+	var instances []map[string]interface{}
+	for _, item := range m.sortedItems(instancesCollection(req)) {
+		instance := item.(map[string]interface{})
+		if metadataValue(instance, "created-by") == mig["selfLink"] {
+			instances = append(instances, instance)
+		}
+	}
+	return instances
+}
+
+// realizeGroup creates or deletes instances so that the group is at its target size.
+// Instances record the template they were created from in their metadata, as on GCE.
+func (m *MockCompute) realizeGroup(req *request, mig map[string]interface{}) {
+	targetSize := intValue(mig["targetSize"])
+	instances := m.groupInstances(req, mig)
+
+	for i := len(instances); i < targetSize; i++ {
+		m.lastID++
+		name := fmt.Sprintf("%s-%04d", mig["baseInstanceName"], m.lastID)
+		m.put(instancesCollection(req), name, map[string]interface{}{
+			"name":   name,
+			"status": "RUNNING",
+			"metadata": map[string]interface{}{
+				"items": []interface{}{
+					map[string]interface{}{"key": "instance-template", "value": mig["instanceTemplate"]},
+					map[string]interface{}{"key": "created-by", "value": mig["selfLink"]},
+				},
+			},
+		})
+	}
+
+	for i := targetSize; i < len(instances); i++ {
+		delete(m.resources[instancesCollection(req)], instances[i]["name"].(string))
+	}
+}
+
+func (m *MockCompute) resize(w http.ResponseWriter, r *http.Request, req *request, mig map[string]interface{}) {
+	size, err := strconv.Atoi(r.URL.Query().Get("size"))
+	if err != nil || size < 0 {
+		writeError(w, http.StatusBadRequest, "badRequest", "invalid size")
+		return
+	}
+	mig["targetSize"] = json.Number(strconv.Itoa(size))
+	m.realizeGroup(req, mig)
+
+	writeJSON(w, m.operation(req, "compute.instanceGroupManagers.resize", mig))
+}
+
+func (m *MockCompute) setInstanceTemplate(w http.ResponseWriter, r *http.Request, req *request, mig map[string]interface{}) {
+	body, err := readJSON(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "badRequest", err.Error())
+		return
+	}
+	// Existing instances are not changed; only new instances are created from the new template
+	mig["instanceTemplate"] = body["instanceTemplate"]
+
+	writeJSON(w, m.operation(req, "compute.instanceGroupManagers.setInstanceTemplate", mig))
+}
+
+func (m *MockCompute) listManagedInstances(w http.ResponseWriter, req *request, mig map[string]interface{}) {
+	managedInstances := []interface{}{}
+	for _, instance := range m.groupInstances(req, mig) {
+		managedInstances = append(managedInstances, map[string]interface{}{
+			"instance":       instance["selfLink"],
+			"instanceStatus": instance["status"],
+			"currentAction":  "NONE",
+		})
+	}
+	writeJSON(w, map[string]interface{}{
+		"managedInstances": managedInstances,
+	})
+}
+
+func (m *MockCompute) deleteInstances(w http.ResponseWriter, r *http.Request, req *request, mig map[string]interface{}) {
+	body, err := readJSON(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "badRequest", err.Error())
+		return
+	}
+
+	members := make(map[interface{}]map[string]interface{})
+	for _, instance := range m.groupInstances(req, mig) {
+		members[instance["selfLink"]] = instance
+	}
+
+	urls, _ := body["instances"].([]interface{})
+	for _, url := range urls {
+		if members[url] == nil {
+			writeError(w, http.StatusBadRequest, "badRequest", fmt.Sprintf("instance %v is not a member of the group", url))
+			return
+		}
+	}
+	for _, url := range urls {
+		delete(m.resources[instancesCollection(req)], members[url]["name"].(string))
+	}
+
+	// Deleting instances reduces the target size, so that they are not recreated
+	targetSize := intValue(mig["targetSize"]) - len(urls)
+	mig["targetSize"] = json.Number(strconv.Itoa(targetSize))
+
+	writeJSON(w, m.operation(req, "compute.instanceGroupManagers.deleteInstances", mig))
+}
+
+// metadataValue returns the value of the metadata item with the key, or nil if not found
+func metadataValue(obj map[string]interface{}, key string) interface{} {
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	items, _ := metadata["items"].([]interface{})
+	for _, item := range items {
+		i, _ := item.(map[string]interface{})
+		if i["key"] == key {
+			return i["value"]
+		}
+	}
+	return nil
+}
+
+// intValue returns the value of an integer field, which may have been sent as a number or (for int64) a string
+func intValue(v interface{}) int {
+	var s string
+	switch v := v.(type) {
+	case json.Number:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return 0
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0
+	}
+	return n
+}
@@ -7,17 +7,17 @@ import (
 	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/cloudup"
 	"os"
-	"os/exec"
-	"path"
 	"strings"
 	"k8s.io/kops/upup/pkg/fi/vfs"
 	"k8s.io/kubernetes/pkg/util/sets"
 	"k8s.io/kops/upup/pkg/fi/utils"
 	"fmt"
+	"time"
 )
 
 type CreateClusterCmd struct {
 	DryRun            bool
+	Yes               bool
 	Target            string
 	ModelsBaseDir     string
 	Models            string
@@ -38,6 +38,26 @@ type CreateClusterCmd struct {
 	VPCID             string
 	NetworkCIDR       string
 	DNSZone           string
+
+	// Topology is the network topology: public or private
+	Topology string
+	// Bastion creates a bastion instance group, for SSH access to a private cluster
+	Bastion bool
+
+	// Networking is the pod network provider: kubenet, external or weave
+	Networking string
+
+	// SSHAccess and APIAccess are comma-separated lists of CIDRs allowed to reach SSH and the kubernetes API
+	SSHAccess string
+	APIAccess string
+
+	Output string
+
+	// AllowLocalEncryptionKey allows a state store encrypted with a local key (which must then be in the image)
+	AllowLocalEncryptionKey bool
+
+	MaxConcurrentTasks int
+	TaskTimeout        time.Duration
 }
 
 var createCluster CreateClusterCmd
@@ -57,17 +77,11 @@ func init() {
 
 	createCmd.AddCommand(cmd)
 
-	executableLocation, err := exec.LookPath(os.Args[0])
-	if err != nil {
-		glog.Fatalf("Cannot determine location of kops tool: %q.  Please report this problem!", os.Args[0])
-	}
-
-	modelsBaseDirDefault := path.Join(path.Dir(executableLocation), "models")
-
 	cmd.Flags().BoolVar(&createCluster.DryRun, "dryrun", false, "Don't create cloud resources; just show what would be done")
+	cmd.Flags().BoolVar(&createCluster.Yes, "yes", false, "Delete cloud resources that have been removed from the configuration (otherwise they are only listed)")
 	cmd.Flags().StringVar(&createCluster.Target, "target", "direct", "Target - direct, terraform")
 	//configFile := cmd.Flags().StringVar(&createCluster., "conf", "", "Configuration file to load")
-	cmd.Flags().StringVar(&createCluster.ModelsBaseDir, "modeldir", modelsBaseDirDefault, "Source directory where models are stored")
+	cmd.Flags().StringVar(&createCluster.ModelsBaseDir, "modeldir", defaultModelsBaseDir(), "Source directory where models are stored")
 	cmd.Flags().StringVar(&createCluster.Models, "model", "config,proto,cloudup", "Models to apply (separate multiple models with commas)")
 	cmd.Flags().StringVar(&createCluster.NodeModel, "nodemodel", "nodeup", "Model to use for node configuration")
 
@@ -96,8 +110,22 @@ func init() {
 
 	cmd.Flags().StringVar(&createCluster.Image, "image", "", "Image to use")
 
+	cmd.Flags().StringVar(&createCluster.Topology, "topology", "", "Network topology for the cluster: public or private (default public)")
+	cmd.Flags().BoolVar(&createCluster.Bastion, "bastion", false, "Create a bastion instance group (requires --topology=private)")
+
+	cmd.Flags().StringVar(&createCluster.Networking, "networking", "", "Pod networking: kubenet, external or weave (default kubenet, or weave with --topology=private)")
+
+	cmd.Flags().StringVar(&createCluster.SSHAccess, "ssh-access", "", "CIDRs allowed to SSH to the cluster, separated by commas (defaults to 0.0.0.0/0)")
+	cmd.Flags().StringVar(&createCluster.APIAccess, "api-access", "", "CIDRs allowed to reach the kubernetes API, separated by commas (defaults to 0.0.0.0/0)")
+
 	cmd.Flags().StringVar(&createCluster.DNSZone, "dns-zone", "", "DNS hosted zone to use (defaults to last two components of cluster name)")
 	cmd.Flags().StringVar(&createCluster.OutDir, "out", "", "Path to write any local output")
+	cmd.Flags().StringVarP(&createCluster.Output, "output", "o", "text", "Format of the dryrun report: text, json or yaml")
+
+	cmd.Flags().BoolVar(&createCluster.AllowLocalEncryptionKey, "allow-local-encryption-key", false, "Allow secrets encrypted with a local key; the key must already be in the image at "+fi.NodeLocalKeyFile)
+
+	cmd.Flags().IntVar(&createCluster.MaxConcurrentTasks, "max-concurrent-tasks", 10, "Maximum number of tasks to run in parallel")
+	cmd.Flags().DurationVar(&createCluster.TaskTimeout, "task-timeout", cloudup.DefaultMaxTaskDuration, "Maximum time a single task may take before it is treated as failed")
 }
 
 var EtcdClusters = []string{"main", "events"}
@@ -109,6 +137,14 @@ func (c *CreateClusterCmd) Run() error {
 		c.Target = "dryrun"
 	}
 
+	dryRunFormat, err := fi.ParseDryRunFormat(c.Output)
+	if err != nil {
+		return err
+	}
+	if dryRunFormat != fi.DryRunFormatText && c.Target != "dryrun" {
+		return fmt.Errorf("--output=%s is only supported with --target=dryrun", c.Output)
+	}
+
 	stateStoreLocation := rootCommand.stateLocation
 	if stateStoreLocation == "" {
 		return fmt.Errorf("--state is required")
@@ -146,7 +182,7 @@ func (c *CreateClusterCmd) Run() error {
 			existingZones[zone.Name] = zone
 		}
 
-		for _, zone := range parseZoneList(c.Zones) {
+		for _, zone := range parseCommaSeparatedList(c.Zones) {
 			if existingZones[zone] == nil {
 				cluster.Spec.Zones = append(cluster.Spec.Zones, &api.ClusterZoneSpec{
 					Name: zone,
@@ -161,10 +197,13 @@ func (c *CreateClusterCmd) Run() error {
 
 	var masters []*api.InstanceGroup
 	var nodes []*api.InstanceGroup
+	var bastions []*api.InstanceGroup
 
 	for _, group := range instanceGroups {
 		if group.IsMaster() {
 			masters = append(masters, group)
+		} else if group.IsBastion() {
+			bastions = append(bastions, group)
 		} else {
 			nodes = append(nodes, group)
 		}
@@ -187,7 +226,7 @@ func (c *CreateClusterCmd) Run() error {
 		}
 	} else {
 		if len(masters) == 0 {
-			for _, zone := range parseZoneList(c.MasterZones) {
+			for _, zone := range parseCommaSeparatedList(c.MasterZones) {
 				g := &api.InstanceGroup{}
 				g.Spec.Role = api.InstanceGroupRoleMaster
 				g.Spec.Zones = []string{zone}
@@ -239,6 +278,49 @@ func (c *CreateClusterCmd) Run() error {
 		nodes = append(nodes, g)
 	}
 
+	switch c.Topology {
+	case "":
+		// Keep the existing topology (defaulted to public)
+	case api.TopologyPublic, api.TopologyPrivate:
+		cluster.Spec.Topology = &api.TopologySpec{Type: c.Topology}
+	default:
+		return fmt.Errorf("invalid topology %q (expected %s or %s)", c.Topology, api.TopologyPublic, api.TopologyPrivate)
+	}
+
+	switch c.Networking {
+	case "":
+		// Keep the existing networking (defaulted to kubenet, or weave with a private topology)
+	case "kubenet":
+		cluster.Spec.Networking = &api.NetworkingSpec{Kubenet: &api.KubenetNetworkingSpec{}}
+	case "external":
+		cluster.Spec.Networking = &api.NetworkingSpec{External: &api.ExternalNetworkingSpec{}}
+	case "weave":
+		cluster.Spec.Networking = &api.NetworkingSpec{Weave: &api.WeaveNetworkingSpec{}}
+	default:
+		return fmt.Errorf("invalid networking %q (expected kubenet, external or weave)", c.Networking)
+	}
+
+	if cluster.IsTopologyPrivate() && cluster.Spec.Networking != nil && cluster.Spec.Networking.Kubenet != nil {
+		return fmt.Errorf("kubenet networking is not supported with --topology=%s; use --networking=weave or --networking=external", api.TopologyPrivate)
+	}
+
+	if c.Bastion {
+		if !cluster.IsTopologyPrivate() {
+			return fmt.Errorf("--bastion requires --topology=%s", api.TopologyPrivate)
+		}
+
+		// The bastion is created after the etcd configuration, so it doesn't influence the etcd zones
+		if len(bastions) == 0 {
+			g := &api.InstanceGroup{}
+			g.Spec.Role = api.InstanceGroupRoleBastion
+			g.Spec.MinSize = fi.Int(1)
+			g.Spec.MaxSize = fi.Int(1)
+			g.Name = "bastions"
+			instanceGroups = append(instanceGroups, g)
+			bastions = append(bastions, g)
+		}
+	}
+
 	if c.NodeSize != "" {
 		for _, group := range nodes {
 			group.Spec.MachineType = c.NodeSize
@@ -268,6 +350,13 @@ func (c *CreateClusterCmd) Run() error {
 		cluster.Spec.DNSZone = c.DNSZone
 	}
 
+	if c.SSHAccess != "" {
+		cluster.Spec.SSHAccess = parseCommaSeparatedList(c.SSHAccess)
+	}
+	if c.APIAccess != "" {
+		cluster.Spec.KubernetesAPIAccess = parseCommaSeparatedList(c.APIAccess)
+	}
+
 	if c.Cloud != "" {
 		cluster.Spec.CloudProvider = c.Cloud
 	}
@@ -337,6 +426,11 @@ func (c *CreateClusterCmd) Run() error {
 		SSHPublicKey:   c.SSHPublicKey,
 		OutDir:         c.OutDir,
 	}
+	cmd.DryRunFormat = dryRunFormat
+	cmd.DeleteRemovedResources = c.Yes
+	cmd.AllowLocalEncryptionKey = c.AllowLocalEncryptionKey
+	cmd.RunTasksOptions.MaxConcurrency = c.MaxConcurrentTasks
+	cmd.RunTasksOptions.MaxTaskDuration = c.TaskTimeout
 	//if *configFile != "" {
 	//	//confFile := path.Join(cmd.StateDir, "kubernetes.yaml")
 	//	err := cmd.LoadConfig(configFile)
@@ -349,7 +443,7 @@ func (c *CreateClusterCmd) Run() error {
 	return cmd.Run()
 }
 
-func parseZoneList(s string) []string {
+func parseCommaSeparatedList(s string) []string {
 	var filtered []string
 	for _, v := range strings.Split(s, ",") {
 		v = strings.TrimSpace(v)
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi"
+	"os"
+)
+
+type CreateInstanceGroupCmd struct {
+	Role string
+}
+
+var createInstanceGroup CreateInstanceGroupCmd
+
+func init() {
+	cmd := &cobra.Command{
+		Use:     "instancegroup",
+		Aliases: []string{"instancegroups", "ig"},
+		Short:   "Create instancegroup",
+		Long:    `Create an instancegroup configuration.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := createInstanceGroup.Run(args)
+			if err != nil {
+				glog.Exitf("%v", err)
+			}
+		},
+	}
+
+	createCmd.AddCommand(cmd)
+
+	cmd.Flags().StringVar(&createInstanceGroup.Role, "role", string(api.InstanceGroupRoleNode), "Role of the instances: Node, Master or Bastion")
+}
+
+func (c *CreateInstanceGroupCmd) Run(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("Specify name of instance group to create")
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("Can only create one instance group at a time!")
+	}
+	groupName := args[0]
+
+	stateStore, err := rootCommand.StateStore()
+	if err != nil {
+		return err
+	}
+
+	cluster, instanceGroups, err := api.ReadConfig(stateStore)
+	if err != nil {
+		return fmt.Errorf("error reading configuration: %v", err)
+	}
+
+	for _, g := range instanceGroups {
+		if g.Name == groupName {
+			return fmt.Errorf("InstanceGroup %q already exists", groupName)
+		}
+	}
+
+	group := &api.InstanceGroup{}
+	group.Name = groupName
+	group.Spec.Role = api.InstanceGroupRole(c.Role)
+	group.Spec.MinSize = fi.Int(2)
+	group.Spec.MaxSize = fi.Int(2)
+	for _, z := range cluster.Spec.Zones {
+		group.Spec.Zones = append(group.Spec.Zones, z.Name)
+	}
+
+	err = group.Validate(cluster)
+	if err != nil {
+		return err
+	}
+
+	edited, err := editInstanceGroup(group)
+	if err != nil {
+		return err
+	}
+	if edited == nil {
+		// The defaults were accepted unchanged
+		edited = group
+	}
+
+	if edited.Name != groupName {
+		return fmt.Errorf("cannot change InstanceGroup name")
+	}
+
+	err = edited.Validate(cluster)
+	if err != nil {
+		return err
+	}
+
+	err = api.CreateInstanceGroup(stateStore, edited)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Created InstanceGroup %q; run kops update cluster to create the cloud resources\n", groupName)
+	return nil
+}
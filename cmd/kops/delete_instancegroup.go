@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/kops/upup/pkg/api"
+)
+
+type DeleteInstanceGroupCmd struct {
+	Yes bool
+}
+
+var deleteInstanceGroup DeleteInstanceGroupCmd
+
+func init() {
+	cmd := &cobra.Command{
+		Use:     "instancegroup",
+		Aliases: []string{"instancegroups", "ig"},
+		Short:   "Delete instancegroup",
+		Long:    `Deletes an instancegroup configuration.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := deleteInstanceGroup.Run(args)
+			if err != nil {
+				glog.Exitf("%v", err)
+			}
+		},
+	}
+
+	deleteCmd.AddCommand(cmd)
+
+	cmd.Flags().BoolVar(&deleteInstanceGroup.Yes, "yes", false, "Delete without confirmation")
+}
+
+func (c *DeleteInstanceGroupCmd) Run(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("Specify name of instance group to delete")
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("Can only delete one instance group at a time!")
+	}
+	groupName := args[0]
+
+	stateStore, err := rootCommand.StateStore()
+	if err != nil {
+		return err
+	}
+
+	group, err := api.ReadInstanceGroup(stateStore, groupName)
+	if err != nil {
+		return err
+	}
+	if group == nil {
+		return fmt.Errorf("InstanceGroup %q not found", groupName)
+	}
+
+	if group.Spec.Role == api.InstanceGroupRoleMaster {
+		return fmt.Errorf("InstanceGroup %q contains masters, and cannot be deleted", groupName)
+	}
+
+	if !c.Yes {
+		return fmt.Errorf("Must specify --yes to delete")
+	}
+
+	err = api.DeleteInstanceGroup(stateStore, groupName)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nInstanceGroup %q deleted; run kops update cluster to delete the cloud resources\n", groupName)
+	return nil
+}
@@ -6,14 +6,21 @@ import (
 	"bytes"
 	"github.com/golang/glog"
 	"github.com/spf13/cobra"
+	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup"
+	"k8s.io/kops/upup/pkg/fi/utils"
 	"k8s.io/kubernetes/pkg/kubectl/cmd/util/editor"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 var editorEnvs = []string{"KUBE_EDITOR", "EDITOR"}
 
 type EditClusterCmd struct {
+	ModelsBaseDir string
+	Models        string
 }
 
 var editClusterCmd EditClusterCmd
@@ -32,6 +39,9 @@ func init() {
 	}
 
 	editCmd.AddCommand(cmd)
+
+	cmd.Flags().StringVar(&editClusterCmd.ModelsBaseDir, "modeldir", defaultModelsBaseDir(), "Source directory where models are stored")
+	cmd.Flags().StringVar(&editClusterCmd.Models, "model", "config,proto,cloudup", "Models used to build the complete cluster spec (separate multiple models with commas)")
 }
 
 func (c *EditClusterCmd) Run() error {
@@ -40,10 +50,10 @@ func (c *EditClusterCmd) Run() error {
 		return err
 	}
 
-	//cluster, _, err := api.ReadConfig(stateStore)
-	//if err != nil {
-	//	return fmt.Errorf("error reading configuration: %v", err)
-	//}
+	_, instanceGroups, err := api.ReadConfig(stateStore)
+	if err != nil {
+		return fmt.Errorf("error reading configuration: %v", err)
+	}
 
 	var (
 		edit = editor.NewDefaultEditor(editorEnvs)
@@ -56,26 +66,102 @@ func (c *EditClusterCmd) Run() error {
 		return fmt.Errorf("error reading config file: %v", err)
 	}
 
-	// launch the editor
-	edited, file, err := edit.LaunchTempFile(fmt.Sprintf("%s-edit-", filepath.Base(os.Args[0])), ext, bytes.NewReader(raw))
-	defer func() {
+	// On error we re-open the editor, with the error shown in a comment at the top of the file
+	contents := raw
+	for {
+		// launch the editor
+		edited, file, err := edit.LaunchTempFile(fmt.Sprintf("%s-edit-", filepath.Base(os.Args[0])), ext, bytes.NewReader(contents))
 		if file != "" {
 			os.Remove(file)
 		}
-	}()
+		if err != nil {
+			return fmt.Errorf("error launching editor: %v", err)
+		}
+
+		if bytes.Equal(edited, raw) || len(bytes.TrimSpace(stripComments(edited))) == 0 {
+			fmt.Fprintln(os.Stderr, "Edit cancelled, no changes made.")
+			return nil
+		}
+
+		cluster, validationErr := c.parseAndValidate(stateStore, edited, instanceGroups)
+		if validationErr != nil {
+			if bytes.Equal(edited, contents) {
+				// The user saved without fixing the problem; give up
+				return fmt.Errorf("edit cancelled, configuration is invalid: %v", validationErr)
+			}
+			contents = addErrorHeader(stripComments(edited), validationErr)
+			continue
+		}
+
+		err = stateStore.WriteConfig("config", cluster)
+		if err != nil {
+			return fmt.Errorf("error writing config file: %v", err)
+		}
+		return nil
+	}
+}
+
+// parseAndValidate parses the edited configuration, and checks that we could build a valid complete spec from it.
+// It returns the cluster with assignments performed, which is the configuration that should be stored.
+func (c *EditClusterCmd) parseAndValidate(stateStore fi.StateStore, data []byte, instanceGroups []*api.InstanceGroup) (*api.Cluster, error) {
+	cluster := &api.Cluster{}
+	err := utils.YamlUnmarshal(data, cluster)
 	if err != nil {
-		return fmt.Errorf("error launching editor: %v", err)
+		return nil, fmt.Errorf("error parsing config: %v", err)
 	}
 
-	if bytes.Equal(edited, raw) {
-		fmt.Fprintln(os.Stderr, "Edit cancelled, no changes made.")
-		return nil
+	if cluster.Name != rootCommand.clusterName {
+		return nil, fmt.Errorf("cannot change the cluster name (from %q to %q)", rootCommand.clusterName, cluster.Name)
 	}
 
-	err = stateStore.VFSPath().Join("config").WriteFile(edited)
+	err = cluster.PerformAssignments()
 	if err != nil {
-		return fmt.Errorf("error writing config file: %v", err)
+		return nil, fmt.Errorf("error populating configuration: %v", err)
 	}
 
-	return nil
+	// Building the complete spec modifies the cluster, so we validate a copy
+	validateCluster := &api.Cluster{}
+	utils.JsonMergeStruct(validateCluster, cluster)
+
+	cmd := &cloudup.CreateClusterCmd{
+		Cluster:        validateCluster,
+		InstanceGroups: instanceGroups,
+		ModelStore:     c.ModelsBaseDir,
+		Models:         strings.Split(c.Models, ","),
+		StateStore:     stateStore,
+		ValidateOnly:   true,
+	}
+	err = cmd.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	return cluster, nil
+}
+
+// stripComments removes the lines that begin with '#', as we add for errors
+func stripComments(data []byte) []byte {
+	var b bytes.Buffer
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("#")) {
+			continue
+		}
+		b.Write(line)
+	}
+	return b.Bytes()
+}
+
+// addErrorHeader adds the error as a comment header, in the same way that kubectl edit does
+func addErrorHeader(data []byte, err error) []byte {
+	var b bytes.Buffer
+	b.WriteString("# Please edit the object below. Lines beginning with a '#' will be ignored,\n")
+	b.WriteString("# and an empty file will abort the edit. If an error occurs while saving this file will be\n")
+	b.WriteString("# reopened with the relevant failures.\n")
+	b.WriteString("#\n")
+	for _, line := range strings.Split(err.Error(), "\n") {
+		b.WriteString("# " + line + "\n")
+	}
+	b.WriteString("#\n")
+	b.Write(data)
+	return b.Bytes()
 }
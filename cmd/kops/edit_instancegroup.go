@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+
+	"bytes"
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi/utils"
+	"k8s.io/kubernetes/pkg/kubectl/cmd/util/editor"
+	"os"
+	"path/filepath"
+)
+
+type EditInstanceGroupCmd struct {
+}
+
+var editInstanceGroupCmd EditInstanceGroupCmd
+
+func init() {
+	cmd := &cobra.Command{
+		Use:     "instancegroup",
+		Aliases: []string{"instancegroups", "ig"},
+		Short:   "Edit instancegroup",
+		Long:    `Edit an instancegroup configuration.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := editInstanceGroupCmd.Run(args)
+			if err != nil {
+				glog.Exitf("%v", err)
+			}
+		},
+	}
+
+	editCmd.AddCommand(cmd)
+}
+
+func (c *EditInstanceGroupCmd) Run(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("Specify name of instance group to edit")
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("Can only edit one instance group at a time!")
+	}
+	groupName := args[0]
+
+	stateStore, err := rootCommand.StateStore()
+	if err != nil {
+		return err
+	}
+
+	cluster, _, err := api.ReadConfig(stateStore)
+	if err != nil {
+		return fmt.Errorf("error reading configuration: %v", err)
+	}
+
+	group, err := api.ReadInstanceGroup(stateStore, groupName)
+	if err != nil {
+		return err
+	}
+	if group == nil {
+		return fmt.Errorf("InstanceGroup %q not found", groupName)
+	}
+
+	edited, err := editInstanceGroup(group)
+	if err != nil {
+		return err
+	}
+	if edited == nil {
+		fmt.Fprintln(os.Stderr, "Edit cancelled, no changes made.")
+		return nil
+	}
+
+	if edited.Name != group.Name {
+		return fmt.Errorf("cannot change InstanceGroup name")
+	}
+
+	err = edited.Validate(cluster)
+	if err != nil {
+		return err
+	}
+
+	return api.UpdateInstanceGroup(stateStore, edited)
+}
+
+// editInstanceGroup opens the InstanceGroup in the user's editor, and parses the result.
+// It returns nil if no changes were made.
+func editInstanceGroup(group *api.InstanceGroup) (*api.InstanceGroup, error) {
+	var (
+		edit = editor.NewDefaultEditor(editorEnvs)
+	)
+
+	ext := "yaml"
+
+	raw, err := utils.YamlMarshal(group)
+	if err != nil {
+		return nil, fmt.Errorf("error serializing InstanceGroup: %v", err)
+	}
+
+	// launch the editor
+	edited, file, err := edit.LaunchTempFile(fmt.Sprintf("%s-edit-", filepath.Base(os.Args[0])), ext, bytes.NewReader(raw))
+	defer func() {
+		if file != "" {
+			os.Remove(file)
+		}
+	}()
+	if err != nil {
+		return nil, fmt.Errorf("error launching editor: %v", err)
+	}
+
+	if bytes.Equal(edited, raw) {
+		return nil, nil
+	}
+
+	newGroup := &api.InstanceGroup{}
+	err = utils.YamlUnmarshal(edited, newGroup)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing InstanceGroup: %v", err)
+	}
+	return newGroup, nil
+}
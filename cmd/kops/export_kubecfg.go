@@ -92,7 +92,8 @@ func (c *ExportKubecfgCommand) Run() error {
 		return err
 	}
 
-	if b.CACert, err = c.copyCertificate(fi.CertificateId_CA); err != nil {
+	// We export all the CA certificates, so that kubectl trusts the master during a CA rotation
+	if b.CACert, err = c.copyCertificatePool(fi.CertificateId_CA); err != nil {
 		return err
 	}
 
@@ -129,6 +130,21 @@ func (c *ExportKubecfgCommand) copyCertificate(id string) (string, error) {
 	return p, nil
 }
 
+func (c *ExportKubecfgCommand) copyCertificatePool(id string) (string, error) {
+	p := path.Join(c.tmpdir, id+".crt")
+	pool, err := c.caStore.CertificatePool(id)
+	if err != nil {
+		return "", fmt.Errorf("error fetching certificates %q: %v", id, err)
+	}
+
+	_, err = writeFile(p, pool)
+	if err != nil {
+		return "", fmt.Errorf("error writing certificates %q: %v", id, err)
+	}
+
+	return p, nil
+}
+
 func (c *ExportKubecfgCommand) copyPrivateKey(id string) (string, error) {
 	p := path.Join(c.tmpdir, id+".key")
 	cert, err := c.caStore.PrivateKey(id)
@@ -11,9 +11,11 @@ import (
 	"os"
 	"reflect"
 	"text/tabwriter"
+	"time"
 )
 
 type GetClustersCmd struct {
+	History bool
 }
 
 var getClustersCmd GetClustersCmd
@@ -33,9 +35,15 @@ func init() {
 	}
 
 	getCmd.AddCommand(cmd)
+
+	cmd.Flags().BoolVar(&getClustersCmd.History, "history", false, "List the revisions of the cluster configuration")
 }
 
 func (c *GetClustersCmd) Run() error {
+	if c.History {
+		return c.runHistory()
+	}
+
 	clusterNames, err := rootCommand.ListClusters()
 	if err != nil {
 		return err
@@ -68,6 +76,55 @@ func (c *GetClustersCmd) Run() error {
 	return WriteTable(clusters, columns, fields)
 }
 
+// runHistory lists the revisions of the configuration of the cluster specified by --name
+func (c *GetClustersCmd) runHistory() error {
+	stateStore, err := rootCommand.StateStore()
+	if err != nil {
+		return err
+	}
+
+	revisions, err := api.ListConfigRevisions(stateStore)
+	if err != nil {
+		return err
+	}
+	if len(revisions) == 0 {
+		return nil
+	}
+
+	columns := []string{}
+	fields := []func(*fi.Revision) string{}
+
+	columns = append(columns, "REVISION")
+	fields = append(fields, func(r *fi.Revision) string {
+		return r.ID
+	})
+
+	columns = append(columns, "TIME")
+	fields = append(fields, func(r *fi.Revision) string {
+		return r.Timestamp.Format(time.RFC3339)
+	})
+
+	columns = append(columns, "AUTHOR")
+	fields = append(fields, func(r *fi.Revision) string {
+		return r.Author
+	})
+
+	columns = append(columns, "PATH")
+	fields = append(fields, func(r *fi.Revision) string {
+		return r.Path
+	})
+
+	columns = append(columns, "CHANGE")
+	fields = append(fields, func(r *fi.Revision) string {
+		if r.Deleted {
+			return "deleted"
+		}
+		return "updated"
+	})
+
+	return WriteTable(revisions, columns, fields)
+}
+
 func WriteTable(items interface{}, columns []string, fields interface{}) error {
 	itemsValue := reflect.ValueOf(items)
 	if itemsValue.Kind() != reflect.Slice {
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/kops/upup/pkg/api"
+	"strconv"
+	"strings"
+)
+
+type GetInstanceGroupsCmd struct {
+}
+
+var getInstanceGroupsCmd GetInstanceGroupsCmd
+
+func init() {
+	cmd := &cobra.Command{
+		Use:     "instancegroups",
+		Aliases: []string{"instancegroup", "ig"},
+		Short:   "get instancegroups",
+		Long:    `List or get InstanceGroups.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := getInstanceGroupsCmd.Run(args)
+			if err != nil {
+				glog.Exitf("%v", err)
+			}
+		},
+	}
+
+	getCmd.AddCommand(cmd)
+}
+
+func (c *GetInstanceGroupsCmd) Run(args []string) error {
+	stateStore, err := rootCommand.StateStore()
+	if err != nil {
+		return err
+	}
+
+	_, instanceGroups, err := api.ReadConfig(stateStore)
+	if err != nil {
+		return fmt.Errorf("error reading configuration: %v", err)
+	}
+
+	if len(args) != 0 {
+		byName := make(map[string]*api.InstanceGroup)
+		for _, g := range instanceGroups {
+			byName[g.Name] = g
+		}
+
+		var selected []*api.InstanceGroup
+		for _, name := range args {
+			g := byName[name]
+			if g == nil {
+				return fmt.Errorf("InstanceGroup %q not found", name)
+			}
+			selected = append(selected, g)
+		}
+		instanceGroups = selected
+	}
+
+	if len(instanceGroups) == 0 {
+		return nil
+	}
+
+	columns := []string{}
+	fields := []func(*api.InstanceGroup) string{}
+
+	columns = append(columns, "NAME")
+	fields = append(fields, func(g *api.InstanceGroup) string {
+		return g.Name
+	})
+
+	columns = append(columns, "ROLE")
+	fields = append(fields, func(g *api.InstanceGroup) string {
+		return string(g.Spec.Role)
+	})
+
+	columns = append(columns, "MACHINETYPE")
+	fields = append(fields, func(g *api.InstanceGroup) string {
+		return g.Spec.MachineType
+	})
+
+	columns = append(columns, "MIN")
+	fields = append(fields, func(g *api.InstanceGroup) string {
+		return intPointerToString(g.Spec.MinSize)
+	})
+
+	columns = append(columns, "MAX")
+	fields = append(fields, func(g *api.InstanceGroup) string {
+		return intPointerToString(g.Spec.MaxSize)
+	})
+
+	columns = append(columns, "MAXPRICE")
+	fields = append(fields, func(g *api.InstanceGroup) string {
+		return stringPointerToString(g.Spec.MaxPrice)
+	})
+
+	columns = append(columns, "ZONES")
+	fields = append(fields, func(g *api.InstanceGroup) string {
+		return strings.Join(g.Spec.Zones, ",")
+	})
+
+	return WriteTable(instanceGroups, columns, fields)
+}
+
+func stringPointerToString(v *string) string {
+	if v == nil {
+		return "-"
+	}
+	return *v
+}
+
+func intPointerToString(v *int) string {
+	if v == nil {
+		return "-"
+	}
+	return strconv.Itoa(*v)
+}
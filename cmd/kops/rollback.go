@@ -0,0 +1,16 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// rollbackCmd represents the rollback command
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "rollback clusters",
+	Long:  `Rollback clusters`,
+}
+
+func init() {
+	rootCommand.AddCommand(rollbackCmd)
+}
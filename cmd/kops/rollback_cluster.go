@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/kops/upup/pkg/api"
+)
+
+type RollbackClusterCmd struct {
+	To string
+}
+
+var rollbackCluster RollbackClusterCmd
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "cluster",
+		Short: "Rollback cluster",
+		Long:  `Restores the cluster configuration and instancegroups to an earlier revision.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := rollbackCluster.Run()
+			if err != nil {
+				glog.Exitf("%v", err)
+			}
+		},
+	}
+
+	rollbackCmd.AddCommand(cmd)
+
+	cmd.Flags().StringVar(&rollbackCluster.To, "to", "", "Revision to restore (see kops get cluster --history)")
+}
+
+func (c *RollbackClusterCmd) Run() error {
+	if c.To == "" {
+		return fmt.Errorf("--to is required")
+	}
+
+	stateStore, err := rootCommand.StateStore()
+	if err != nil {
+		return err
+	}
+
+	err = api.RollbackConfig(stateStore, c.To)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nConfiguration restored to revision %s; run kops update cluster to apply it\n", c.To)
+	return nil
+}
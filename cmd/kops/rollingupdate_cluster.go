@@ -6,16 +6,27 @@ import (
 	"bytes"
 	"github.com/golang/glog"
 	"github.com/spf13/cobra"
-	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi/cloudup"
 	"k8s.io/kops/upup/pkg/kutil"
 	"os"
 	"text/tabwriter"
+	"time"
 )
 
 type RollingUpdateClusterCmd struct {
 	Yes    bool
 	Region string
 
+	Interval     time.Duration
+	BatchSize    int
+	Surge        int
+	ReadyTimeout time.Duration
+
+	InstanceGroups []string
+	Force          bool
+	CloudOnly      bool
+
 	cobraCommand *cobra.Command
 }
 
@@ -34,6 +45,16 @@ func init() {
 	cmd.Flags().BoolVar(&rollingupdateCluster.Yes, "yes", false, "Rollingupdate without confirmation")
 
 	cmd.Flags().StringVar(&rollingupdateCluster.Region, "region", "", "region")
+	cmd.Flags().MarkDeprecated("region", "the region is now read from the cluster configuration")
+
+	cmd.Flags().DurationVar(&rollingupdateCluster.Interval, "interval", 0, "Time to wait after each batch of instances is replaced")
+	cmd.Flags().IntVar(&rollingupdateCluster.BatchSize, "batch-size", 1, "Number of instances in each node instance group to replace at once")
+	cmd.Flags().IntVar(&rollingupdateCluster.Surge, "surge", 0, "Number of extra instances each node instance group may launch, so replacements start before nodes are drained")
+	cmd.Flags().DurationVar(&rollingupdateCluster.ReadyTimeout, "ready-timeout", 15*time.Minute, "Maximum time to wait for replacements to become ready, and for the cluster to validate")
+
+	cmd.Flags().StringSliceVar(&rollingupdateCluster.InstanceGroups, "instance-group", nil, "Instance groups to update (defaults to all)")
+	cmd.Flags().BoolVar(&rollingupdateCluster.Force, "force", false, "Replace all instances, even if they are already up to date")
+	cmd.Flags().BoolVar(&rollingupdateCluster.CloudOnly, "cloudonly", false, "Replace instances without draining nodes or checking the cluster (e.g. if the cluster is unreachable)")
 
 	cmd.Run = func(cmd *cobra.Command, args []string) {
 		err := rollingupdateCluster.Run()
@@ -44,25 +65,43 @@ func init() {
 }
 
 func (c *RollingUpdateClusterCmd) Run() error {
-	if c.Region == "" {
-		return fmt.Errorf("--region is required")
+	if c.BatchSize < 1 {
+		return fmt.Errorf("--batch-size must be at least 1")
 	}
-	clusterName := rootCommand.clusterName
-	if clusterName == "" {
-		return fmt.Errorf("--name is required")
+	if c.Surge < 0 {
+		return fmt.Errorf("--surge must not be negative")
 	}
 
-	tags := map[string]string{"KubernetesCluster": clusterName}
-	cloud, err := awsup.NewAWSCloud(c.Region, tags)
+	stateStore, err := rootCommand.StateStore()
 	if err != nil {
-		return fmt.Errorf("error initializing AWS client: %v", err)
+		return err
+	}
+
+	cluster, _, err := api.ReadConfig(stateStore)
+	if err != nil {
+		return fmt.Errorf("error reading configuration: %v", err)
+	}
+	clusterName := cluster.Name
+
+	cloud, err := cloudup.BuildCloud(cluster)
+	if err != nil {
+		return err
 	}
 
 	d := &kutil.RollingUpdateCluster{}
 
 	d.ClusterName = clusterName
-	d.Region = c.Region
 	d.Cloud = cloud
+	if !c.CloudOnly {
+		d.K8sClient = &kutil.Kubectl{Context: clusterName}
+	}
+	d.Interval = c.Interval
+	d.BatchSize = c.BatchSize
+	d.Surge = c.Surge
+	d.ReadyTimeout = c.ReadyTimeout
+	d.InstanceGroups = c.InstanceGroups
+	d.Force = c.Force
+	d.CloudOnly = c.CloudOnly
 
 	nodesets, err := d.ListNodesets()
 	if err != nil {
@@ -4,7 +4,10 @@ import (
 	goflag "flag"
 	"fmt"
 	"os"
+	"os/exec"
+	"path"
 
+	"github.com/golang/glog"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"k8s.io/kops/upup/pkg/fi"
@@ -157,3 +160,13 @@ func (c *RootCmd) CA() (fi.CAStore, error) {
 	}
 	return s.CA(), nil
 }
+
+// defaultModelsBaseDir returns the models directory alongside the kops executable
+func defaultModelsBaseDir() string {
+	executableLocation, err := exec.LookPath(os.Args[0])
+	if err != nil {
+		glog.Fatalf("Cannot determine location of kops tool: %q.  Please report this problem!", os.Args[0])
+	}
+
+	return path.Join(path.Dir(executableLocation), "models")
+}
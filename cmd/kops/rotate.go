@@ -0,0 +1,16 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// rotateCmd represents the rotate command
+var rotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "rotate keys & certificates",
+	Long:  `Rotate the CA and keypairs of a cluster`,
+}
+
+func init() {
+	rootCommand.AddCommand(rotateCmd)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/kops/upup/pkg/fi"
+)
+
+type RotateCACmd struct {
+	Yes bool
+}
+
+var rotateCA RotateCACmd
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "ca",
+		Short: "Rotate CA",
+		Long: `Replaces the cluster CA, re-issuing all the certificates it has signed.
+
+The rotation happens in three phases, and the cluster must be updated between each:
+ 1. the new CA is created, and published alongside the old CA so that both are trusted
+ 2. the new CA becomes the primary CA, and all certificates are re-issued
+ 3. the old CA and the certificates it signed are removed
+
+Run the command again (after updating the cluster) to advance to the next phase.
+Without --yes, the current phase and the next step are shown.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := rotateCA.Run()
+			if err != nil {
+				glog.Exitf("%v", err)
+			}
+		},
+	}
+
+	rotateCmd.AddCommand(cmd)
+
+	cmd.Flags().BoolVar(&rotateCA.Yes, "yes", false, "Advance the rotation to the next phase")
+}
+
+func (c *RotateCACmd) Run() error {
+	caStore, err := rootCommand.CA()
+	if err != nil {
+		return err
+	}
+
+	return runRotation(caStore, fi.CertificateId_CA, c.Yes)
+}
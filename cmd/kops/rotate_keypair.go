@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/kops/upup/pkg/fi"
+)
+
+type RotateKeypairCmd struct {
+	Yes bool
+}
+
+var rotateKeypair RotateKeypairCmd
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "keypair ID",
+		Short: "Rotate keypair",
+		Long: `Issues a new keypair, which replaces the existing keypair after the cluster is updated.
+
+The rotation happens in phases; run the command again (after updating the cluster) to advance to the next phase.
+Without --yes, the current phase and the next step are shown.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := rotateKeypair.Run(args)
+			if err != nil {
+				glog.Exitf("%v", err)
+			}
+		},
+	}
+
+	rotateCmd.AddCommand(cmd)
+
+	cmd.Flags().BoolVar(&rotateKeypair.Yes, "yes", false, "Advance the rotation to the next phase")
+}
+
+func (c *RotateKeypairCmd) Run(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("Specify id of keypair to rotate")
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("Can only rotate one keypair at a time!")
+	}
+	id := args[0]
+
+	if id == fi.CertificateId_CA {
+		return fmt.Errorf("use kops rotate ca to rotate the CA")
+	}
+
+	caStore, err := rootCommand.CA()
+	if err != nil {
+		return err
+	}
+
+	return runRotation(caStore, id, c.Yes)
+}
+
+// runRotation shows the progress of a rotation, or (if yes is set) advances it to the next phase
+func runRotation(caStore fi.CAStore, id string, yes bool) error {
+	rotation, err := caStore.FindRotation(id)
+	if err != nil {
+		return err
+	}
+
+	if !yes {
+		if rotation == nil {
+			fmt.Printf("\nNo rotation of %q is in progress\n", id)
+		} else {
+			fmt.Printf("\nRotation of %q is in phase %q\n", id, rotation.Phase)
+		}
+		fmt.Printf("Next step: %s\n", describeNextRotationStep(id, rotation))
+		fmt.Printf("\nMust specify --yes to advance the rotation\n")
+		return nil
+	}
+
+	rotation, err = caStore.RotateKeypair(id)
+	if err != nil {
+		return fmt.Errorf("error rotating %q (it is safe to run this command again to resume): %v", id, err)
+	}
+
+	if rotation == nil {
+		fmt.Printf("\nRotation of %q is complete; the old keypair has been removed\n", id)
+		fmt.Printf("Run kops update cluster --yes, followed by kops rolling-update cluster --yes, so that it is no longer trusted\n")
+		return nil
+	}
+
+	fmt.Printf("\nRotation of %q is now in phase %q\n", id, rotation.Phase)
+	switch rotation.Phase {
+	case fi.RotationPhaseBundled:
+		fmt.Printf("The new CA has been published alongside the old CA.\n")
+		fmt.Printf("Run kops update cluster --yes and kops rolling-update cluster --yes, so that all instances trust both CAs.\n")
+	case fi.RotationPhaseIssued:
+		fmt.Printf("New certificates have been issued.\n")
+		fmt.Printf("Run kops update cluster --yes and kops rolling-update cluster --yes, so that all instances use them")
+		if id == fi.CertificateId_CA {
+			fmt.Printf(", and kops export kubecfg to update your kubeconfig")
+		}
+		fmt.Printf(".\n")
+	}
+	fmt.Printf("Then run this command again with --yes to continue: %s\n", describeNextRotationStep(id, rotation))
+	return nil
+}
+
+func describeNextRotationStep(id string, rotation *fi.KeyRotation) string {
+	phase := fi.RotationPhase("")
+	if rotation != nil {
+		phase = rotation.Phase
+	}
+
+	switch phase {
+	case "":
+		if id == fi.CertificateId_CA {
+			return "create a new CA, and publish it alongside the old CA"
+		}
+		return "issue a new keypair"
+	case fi.RotationPhaseBundling:
+		return "finish creating the new CA"
+	case fi.RotationPhaseIssuing:
+		return "finish issuing the new keypair"
+	case fi.RotationPhaseBundled:
+		return "make the new CA the primary CA, and re-issue all certificates"
+	case fi.RotationPhasePromoting:
+		return "finish re-issuing certificates"
+	case fi.RotationPhaseIssued:
+		return "remove the old keypair"
+	default:
+		return fmt.Sprintf("unknown phase %q", phase)
+	}
+}
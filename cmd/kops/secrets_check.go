@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+
+	"bytes"
+	"encoding/json"
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/kops/upup/pkg/fi"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type CheckSecretsCommand struct {
+	Threshold time.Duration
+	Output    string
+	Renew     bool
+}
+
+var checkSecretsCommand CheckSecretsCommand
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check certificates",
+		Long: `Reports the expiry, alternate names and issuer chain of every certificate, and exits with an error
+if any certificate expires within the threshold or is not signed by the CA.
+
+With --renew, certificates that expire within the threshold are re-issued with the same subject and alternate names;
+run kops update cluster and kops rolling-update cluster afterwards so that instances pick them up.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := checkSecretsCommand.Run()
+			if err != nil {
+				glog.Exitf("%v", err)
+			}
+		},
+	}
+
+	secretsCmd.AddCommand(cmd)
+
+	cmd.Flags().DurationVar(&checkSecretsCommand.Threshold, "threshold", 30*24*time.Hour, "Report certificates that expire within this duration")
+	cmd.Flags().StringVarP(&checkSecretsCommand.Output, "output", "o", "table", "Output format: table or json")
+	cmd.Flags().BoolVar(&checkSecretsCommand.Renew, "renew", false, "Re-issue certificates that expire within the threshold")
+}
+
+func (c *CheckSecretsCommand) Run() error {
+	if c.Output != "table" && c.Output != "json" {
+		return fmt.Errorf("unknown output format %q (expected table or json)", c.Output)
+	}
+
+	caStore, err := rootCommand.CA()
+	if err != nil {
+		return err
+	}
+
+	statuses, err := fi.CheckCertificates(caStore, time.Now(), c.Threshold)
+	if err != nil {
+		return err
+	}
+
+	if c.Renew {
+		renewed := 0
+		for _, s := range statuses {
+			if !s.ExpiresSoon {
+				continue
+			}
+			if s.IsCA {
+				glog.Warningf("CA certificate %q expires at %s; use kops rotate ca to replace it", s.ID, s.NotAfter)
+				continue
+			}
+			if _, err := caStore.RenewKeypair(s.ID); err != nil {
+				return fmt.Errorf("error renewing %q: %v", s.ID, err)
+			}
+			renewed++
+		}
+
+		if renewed != 0 {
+			statuses, err = fi.CheckCertificates(caStore, time.Now(), c.Threshold)
+			if err != nil {
+				return err
+			}
+			defer fmt.Fprintf(os.Stderr, "\nRenewed %d certificates; run kops update cluster and kops rolling-update cluster to apply them\n", renewed)
+		}
+	}
+
+	switch c.Output {
+	case "json":
+		data, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshalling to json: %v", err)
+		}
+		_, err = os.Stdout.Write(append(data, '\n'))
+		if err != nil {
+			return fmt.Errorf("error writing to output: %v", err)
+		}
+
+	default:
+		if err := writeCertificateStatusTable(statuses); err != nil {
+			return err
+		}
+	}
+
+	var problems []string
+	for _, s := range statuses {
+		if s.NeedsAttention() {
+			problems = append(problems, s.ID)
+		}
+	}
+	if len(problems) != 0 {
+		return fmt.Errorf("certificates need attention: %s", strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+func writeCertificateStatusTable(statuses []*fi.CertificateStatus) error {
+	var b bytes.Buffer
+	w := new(tabwriter.Writer)
+
+	// Format in tab-separated columns with a tab stop of 8.
+	w.Init(os.Stdout, 0, 8, 1, '\t', 0)
+	fmt.Fprintf(&b, "ID\tSUBJECT\tNOTAFTER\tALTERNATENAMES\tSTATUS\n")
+	for _, s := range statuses {
+		var status []string
+		if s.ExpiresSoon {
+			status = append(status, "expiring")
+		}
+		if s.ChainError != "" {
+			status = append(status, "untrusted: "+s.ChainError)
+		}
+		if len(status) == 0 {
+			status = append(status, "ok")
+		}
+
+		fmt.Fprintf(&b, "%s\t%s\t%s\t%s\t%s\n", s.ID, s.Subject, s.NotAfter.Format(time.RFC3339), strings.Join(s.AlternateNames, ","), strings.Join(status, "; "))
+	}
+
+	_, err := w.Write(b.Bytes())
+	if err != nil {
+		return fmt.Errorf("error writing to output: %v", err)
+	}
+	return w.Flush()
+}
@@ -6,6 +6,8 @@ import (
 	"crypto/x509"
 	"github.com/golang/glog"
 	"github.com/spf13/cobra"
+	"io/ioutil"
+	"k8s.io/kops/upup/pkg/fi"
 	"net"
 	"strings"
 )
@@ -17,6 +19,9 @@ type CreateSecretsCommand struct {
 	Usage          string
 	Subject        string
 	AlternateNames []string
+
+	CertFile string
+	KeyFile  string
 }
 
 var createSecretsCommand CreateSecretsCommand
@@ -25,9 +30,12 @@ func init() {
 	cmd := &cobra.Command{
 		Use:   "create",
 		Short: "Create secrets",
-		Long:  `Create secrets.`,
+		Long: `Create secrets.
+
+An existing certificate and private key can be imported with --cert and --key; use type ca to import your own root
+or intermediate CA (which then signs new certificates), or type keypair to import a certificate signed by the CA.`,
 		Run: func(cmd *cobra.Command, args []string) {
-			err := createSecretsCommand.Run()
+			err := createSecretsCommand.Run(args)
 			if err != nil {
 				glog.Exitf("%v", err)
 			}
@@ -41,9 +49,28 @@ func init() {
 	cmd.Flags().StringVarP(&createSecretsCommand.Usage, "usage", "", "", "Usage of secret (for SSL certificate)")
 	cmd.Flags().StringVarP(&createSecretsCommand.Subject, "subject", "", "", "Subject (for SSL certificate)")
 	cmd.Flags().StringSliceVarP(&createSecretsCommand.AlternateNames, "san", "", nil, "Alternate name (for SSL certificate)")
+	cmd.Flags().StringVar(&createSecretsCommand.CertFile, "cert", "", "Path to PEM certificate to import (for keypair or ca)")
+	cmd.Flags().StringVar(&createSecretsCommand.KeyFile, "key", "", "Path to PEM private key to import (for keypair or ca)")
 }
 
-func (cmd *CreateSecretsCommand) Run() error {
+func (cmd *CreateSecretsCommand) Run(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Can only create one secret at a time!")
+	}
+	if len(args) == 1 {
+		if cmd.Type != "" && cmd.Type != args[0] {
+			return fmt.Errorf("type was specified as both %q and %q", args[0], cmd.Type)
+		}
+		cmd.Type = args[0]
+	}
+
+	if cmd.Type == "ca" {
+		if cmd.Id != "" && cmd.Id != fi.CertificateId_CA {
+			return fmt.Errorf("id cannot be specified when importing a CA")
+		}
+		cmd.Id = fi.CertificateId_CA
+	}
+
 	if cmd.Id == "" {
 		return fmt.Errorf("id is required")
 	}
@@ -52,6 +79,13 @@ func (cmd *CreateSecretsCommand) Run() error {
 		return fmt.Errorf("type is required")
 	}
 
+	if cmd.CertFile != "" || cmd.KeyFile != "" {
+		if cmd.Type != "keypair" && cmd.Type != "ca" {
+			return fmt.Errorf("--cert and --key can only be used with type keypair or ca")
+		}
+		return cmd.importKeypair()
+	}
+
 	// TODO: Prompt before replacing?
 	// TODO: Keep history?
 
@@ -72,6 +106,9 @@ func (cmd *CreateSecretsCommand) Run() error {
 			return nil
 		}
 
+	case "ca":
+		return fmt.Errorf("--cert and --key are required to import a CA (use kops rotate ca to replace the CA with a new one)")
+
 	case "keypair":
 		// TODO: Create a rotate command which keeps the same values?
 		// Or just do it here a "replace" action - existing=fail, replace or rotate
@@ -140,3 +177,47 @@ func (cmd *CreateSecretsCommand) Run() error {
 		return fmt.Errorf("secret type not known: %q", cmd.Type)
 	}
 }
+
+// importKeypair imports an existing certificate and private key into the CA store
+func (cmd *CreateSecretsCommand) importKeypair() error {
+	if cmd.CertFile == "" {
+		return fmt.Errorf("--cert is required")
+	}
+	if cmd.KeyFile == "" {
+		return fmt.Errorf("--key is required")
+	}
+
+	certData, err := ioutil.ReadFile(cmd.CertFile)
+	if err != nil {
+		return fmt.Errorf("error reading certificate %q: %v", cmd.CertFile, err)
+	}
+	cert, err := fi.LoadPEMCertificate(certData)
+	if err != nil {
+		return fmt.Errorf("error parsing certificate %q: %v", cmd.CertFile, err)
+	}
+
+	keyData, err := ioutil.ReadFile(cmd.KeyFile)
+	if err != nil {
+		return fmt.Errorf("error reading private key %q: %v", cmd.KeyFile, err)
+	}
+	privateKey, err := fi.ParsePEMPrivateKey(keyData)
+	if err != nil {
+		return fmt.Errorf("error parsing private key %q: %v", cmd.KeyFile, err)
+	}
+
+	caStore, err := rootCommand.CA()
+	if err != nil {
+		return err
+	}
+
+	err = caStore.ImportKeypair(cmd.Id, cert, privateKey)
+	if err != nil {
+		return fmt.Errorf("error importing keypair: %v", err)
+	}
+
+	if cmd.Id == fi.CertificateId_CA {
+		fmt.Printf("\nImported CA; it will sign new certificates, and the previous CA remains trusted\n")
+		fmt.Printf("Use kops rotate keypair <id> to re-issue existing certificates with the new CA, then run kops update cluster\n")
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+	"os"
+)
+
+type EncryptSecretsCommand struct {
+	Provider  string
+	KeyFile   string
+	KMSKey    string
+	KMSRegion string
+}
+
+var encryptSecretsCommand EncryptSecretsCommand
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "encrypt",
+		Short: "Encrypt secrets & keys",
+		Long: `Encrypts the secrets and private keys in the state store, re-encrypting any existing entries.
+
+Use --provider=local to encrypt with a key file (which is generated if it does not exist),
+--provider=kms to encrypt with an AWS KMS key, or --provider=none to store the entries in plaintext.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := encryptSecretsCommand.Run()
+			if err != nil {
+				glog.Exitf("%v", err)
+			}
+		},
+	}
+
+	secretsCmd.AddCommand(cmd)
+
+	cmd.Flags().StringVar(&encryptSecretsCommand.Provider, "provider", "", "Key provider to use: local, kms or none")
+	cmd.Flags().StringVar(&encryptSecretsCommand.KeyFile, "key-file", fi.DefaultLocalKeyFile(), "Key file for the local provider")
+	cmd.Flags().StringVar(&encryptSecretsCommand.KMSKey, "kms-key", "", "KMS key id, ARN or alias for the kms provider")
+	cmd.Flags().StringVar(&encryptSecretsCommand.KMSRegion, "kms-region", "", "Region of the KMS key")
+}
+
+func (c *EncryptSecretsCommand) Run() error {
+	if rootCommand.stateLocation == "" {
+		return fmt.Errorf("--state is required")
+	}
+	if rootCommand.clusterName == "" {
+		return fmt.Errorf("--name is required")
+	}
+
+	config, provider, err := c.buildProvider()
+	if err != nil {
+		return err
+	}
+
+	statePath, err := vfs.Context.BuildVfsPath(rootCommand.stateLocation)
+	if err != nil {
+		return fmt.Errorf("error building state store path: %v", err)
+	}
+	base := statePath.Join(rootCommand.clusterName)
+
+	// We don't build the StateStore, because that fails if we can't decrypt the CA key,
+	// and we want to be able to resume an interrupted migration
+	currentConfig, err := fi.ReadEncryptionConfig(base)
+	if err != nil {
+		return err
+	}
+	current, err := fi.NewEncryptionForConfig(currentConfig, fi.DefaultLocalKeyFile())
+	if err != nil {
+		glog.Warningf("unable to build the current key provider; only entries already encrypted with the new key can be read: %v", err)
+		current = nil
+	}
+
+	count, err := fi.ReEncryptStateStore(base, current, config, provider)
+	if err != nil {
+		return fmt.Errorf("error re-encrypting state store (completed %d entries; it is safe to run this command again): %v", count, err)
+	}
+
+	fmt.Printf("\nRe-encrypted %d secrets and private keys\n", count)
+	fmt.Printf("Run kops update cluster to update the cluster configuration, so that nodes can decrypt them\n")
+	if config != nil && config.Provider == fi.KeyProviderLocal {
+		fmt.Printf("kops does not copy the key to instances: nodes can only decrypt if the key is baked into the image at %s\n", fi.NodeLocalKeyFile)
+		fmt.Printf("Otherwise use --provider=kms\n")
+		if c.KeyFile != fi.DefaultLocalKeyFile() {
+			fmt.Printf("Set KOPS_ENCRYPTION_KEY_FILE=%s so that kops uses this key\n", c.KeyFile)
+		}
+	}
+	return nil
+}
+
+// buildProvider builds the EncryptionConfig and KeyProvider from the flags
+func (c *EncryptSecretsCommand) buildProvider() (*fi.EncryptionConfig, fi.KeyProvider, error) {
+	switch c.Provider {
+	case "":
+		return nil, nil, fmt.Errorf("--provider is required")
+
+	case "none":
+		return nil, nil, nil
+
+	case fi.KeyProviderLocal:
+		if c.KeyFile == "" {
+			return nil, nil, fmt.Errorf("--key-file is required")
+		}
+		var provider *fi.LocalKeyProvider
+		_, err := os.Stat(c.KeyFile)
+		if os.IsNotExist(err) {
+			glog.Infof("Generating new encryption key in %q", c.KeyFile)
+			provider, err = fi.GenerateLocalKeyFile(c.KeyFile)
+		} else {
+			provider, err = fi.LoadLocalKeyProvider(c.KeyFile)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		config := &fi.EncryptionConfig{
+			Provider:       fi.KeyProviderLocal,
+			KeyFingerprint: provider.Fingerprint(),
+		}
+		return config, provider, nil
+
+	case fi.KeyProviderKMS:
+		if c.KMSKey == "" {
+			return nil, nil, fmt.Errorf("--kms-key is required")
+		}
+		if c.KMSRegion == "" {
+			return nil, nil, fmt.Errorf("--kms-region is required")
+		}
+		client := kms.New(session.New(), aws.NewConfig().WithRegion(c.KMSRegion))
+		keyARN, err := fi.ResolveKMSKeyARN(client, c.KMSKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		config := &fi.EncryptionConfig{
+			Provider:  fi.KeyProviderKMS,
+			KMSKeyARN: keyARN,
+			KMSRegion: c.KMSRegion,
+		}
+		return config, fi.NewKMSKeyProvider(client, keyARN), nil
+
+	default:
+		return nil, nil, fmt.Errorf("unknown provider %q (expected local, kms or none)", c.Provider)
+	}
+}
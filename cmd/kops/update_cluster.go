@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup"
+	"k8s.io/kops/upup/pkg/fi/utils"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+	"os"
+	"strings"
+	"time"
+)
+
+type UpdateClusterCmd struct {
+	Yes           bool
+	Target        string
+	ModelsBaseDir string
+	Models        string
+	NodeModel     string
+	SSHPublicKey  string
+	OutDir        string
+	Output        string
+
+	// AllowLocalEncryptionKey allows a state store encrypted with a local key (which must then be in the image)
+	AllowLocalEncryptionKey bool
+
+	MaxConcurrentTasks int
+	TaskTimeout        time.Duration
+}
+
+var updateCluster UpdateClusterCmd
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "cluster",
+		Short: "Update cluster",
+		Long:  `Updates a k8s cluster to match the stored configuration.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := updateCluster.Run()
+			if err != nil {
+				glog.Exitf("%v", err)
+			}
+		},
+	}
+
+	updateCmd.AddCommand(cmd)
+
+	cmd.Flags().BoolVar(&updateCluster.Yes, "yes", false, "Actually create cloud resources")
+	cmd.Flags().StringVar(&updateCluster.Target, "target", "direct", "Target - direct, terraform, dryrun")
+	cmd.Flags().StringVar(&updateCluster.ModelsBaseDir, "modeldir", defaultModelsBaseDir(), "Source directory where models are stored")
+	cmd.Flags().StringVar(&updateCluster.Models, "model", "config,proto,cloudup", "Models to apply (separate multiple models with commas)")
+	cmd.Flags().StringVar(&updateCluster.NodeModel, "nodemodel", "nodeup", "Model to use for node configuration")
+	cmd.Flags().StringVar(&updateCluster.SSHPublicKey, "ssh-public-key", "~/.ssh/id_rsa.pub", "SSH public key to use")
+	cmd.Flags().StringVar(&updateCluster.OutDir, "out", "", "Path to write any local output")
+	cmd.Flags().StringVarP(&updateCluster.Output, "output", "o", "text", "Format of the preview: text, json or yaml")
+
+	cmd.Flags().BoolVar(&updateCluster.AllowLocalEncryptionKey, "allow-local-encryption-key", false, "Allow secrets encrypted with a local key; the key must already be in the image at "+fi.NodeLocalKeyFile)
+
+	cmd.Flags().IntVar(&updateCluster.MaxConcurrentTasks, "max-concurrent-tasks", 10, "Maximum number of tasks to run in parallel")
+	cmd.Flags().DurationVar(&updateCluster.TaskTimeout, "task-timeout", cloudup.DefaultMaxTaskDuration, "Maximum time a single task may take before it is treated as failed")
+}
+
+func (c *UpdateClusterCmd) Run() error {
+	target := c.Target
+	switch target {
+	case "direct":
+		// Without --yes we only preview the changes
+		if !c.Yes {
+			target = "dryrun"
+		}
+	case "terraform", "dryrun":
+		// These targets don't change any cloud resources
+	default:
+		return fmt.Errorf("unsupported target %q (valid values: direct, terraform, dryrun)", c.Target)
+	}
+	isDryrun := target == "dryrun"
+
+	dryRunFormat, err := fi.ParseDryRunFormat(c.Output)
+	if err != nil {
+		return err
+	}
+	if dryRunFormat != fi.DryRunFormatText && !isDryrun {
+		return fmt.Errorf("--output=%s is only supported when previewing changes", c.Output)
+	}
+
+	if rootCommand.stateLocation == "" {
+		return fmt.Errorf("--state is required")
+	}
+
+	clusterName := rootCommand.clusterName
+	if clusterName == "" {
+		return fmt.Errorf("--name is required")
+	}
+
+	statePath, err := vfs.Context.BuildVfsPath(rootCommand.stateLocation)
+	if err != nil {
+		return fmt.Errorf("error building state location: %v", err)
+	}
+
+	stateStore, err := fi.NewVFSStateStore(statePath, clusterName, isDryrun)
+	if err != nil {
+		return fmt.Errorf("error building state store: %v", err)
+	}
+
+	cluster, instanceGroups, err := api.ReadConfig(stateStore)
+	if err != nil {
+		return fmt.Errorf("error reading configuration: %v", err)
+	}
+
+	if cluster.Name == "" {
+		cluster.Name = clusterName
+	} else if cluster.Name != clusterName {
+		return fmt.Errorf("cluster name in configuration (%q) does not match --name (%q)", cluster.Name, clusterName)
+	}
+
+	// Fill in any values that have been removed by editing; these are not written back
+	err = cluster.PerformAssignments()
+	if err != nil {
+		return fmt.Errorf("error populating configuration: %v", err)
+	}
+	err = api.PerformAssignmentsInstanceGroups(instanceGroups)
+	if err != nil {
+		return fmt.Errorf("error populating configuration: %v", err)
+	}
+
+	if c.OutDir == "" {
+		c.OutDir = "out"
+	}
+
+	sshPublicKey := c.SSHPublicKey
+	if sshPublicKey != "" {
+		sshPublicKey = utils.ExpandPath(sshPublicKey)
+	}
+
+	cmd := &cloudup.CreateClusterCmd{
+		Cluster:        cluster,
+		InstanceGroups: instanceGroups,
+		ModelStore:     c.ModelsBaseDir,
+		Models:         strings.Split(c.Models, ","),
+		StateStore:     stateStore,
+		Target:         target,
+		NodeModel:      c.NodeModel,
+		SSHPublicKey:   sshPublicKey,
+		OutDir:         c.OutDir,
+		DryRunFormat:   dryRunFormat,
+
+		// Without --yes we preview the changes, which lists any removed resources
+		DeleteRemovedResources: c.Yes,
+
+		AllowLocalEncryptionKey: c.AllowLocalEncryptionKey,
+	}
+	cmd.RunTasksOptions.MaxConcurrency = c.MaxConcurrentTasks
+	cmd.RunTasksOptions.MaxTaskDuration = c.TaskTimeout
+
+	err = cmd.Run()
+	if err != nil {
+		return err
+	}
+
+	if c.Target == "direct" && !c.Yes {
+		// Written to stderr, so as not to corrupt json / yaml output
+		fmt.Fprintf(os.Stderr, "\nMust specify --yes to apply changes\n")
+	}
+
+	return nil
+}
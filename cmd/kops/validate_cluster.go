@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi/cloudup"
+	"k8s.io/kops/upup/pkg/kutil"
+	"os"
+)
+
+type ValidateClusterCmd struct {
+	Output string
+}
+
+var validateCluster ValidateClusterCmd
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "cluster",
+		Short: "Validate cluster",
+		Long:  `Checks that a k8s cluster is up: instance groups are at their expected size, every instance is a ready node, and the master components & kube-dns are running.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := validateCluster.Run()
+			if err != nil {
+				glog.Exitf("%v", err)
+			}
+		},
+	}
+
+	validateCmd.AddCommand(cmd)
+
+	cmd.Flags().StringVarP(&validateCluster.Output, "output", "o", "table", "Output format: table or json")
+}
+
+func (c *ValidateClusterCmd) Run() error {
+	if c.Output != "table" && c.Output != "json" {
+		return fmt.Errorf("unknown --output %q (expected table or json)", c.Output)
+	}
+
+	stateStore, err := rootCommand.StateStore()
+	if err != nil {
+		return err
+	}
+
+	cluster, instanceGroups, err := api.ReadConfig(stateStore)
+	if err != nil {
+		return fmt.Errorf("error reading configuration: %v", err)
+	}
+
+	cloud, err := cloudup.BuildCloud(cluster)
+	if err != nil {
+		return err
+	}
+
+	v := &kutil.ValidateCluster{
+		ClusterName:    cluster.Name,
+		Cloud:          cloud,
+		InstanceGroups: instanceGroups,
+		K8sClient:      &kutil.Kubectl{Context: cluster.Name},
+	}
+
+	result, err := v.Validate()
+	if err != nil {
+		return err
+	}
+
+	switch c.Output {
+	case "json":
+		b, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshalling result: %v", err)
+		}
+		_, err = os.Stdout.Write(append(b, '\n'))
+		if err != nil {
+			return fmt.Errorf("error writing to output: %v", err)
+		}
+
+	default:
+		columns := []string{}
+		fields := []func(*kutil.ValidationCheck) string{}
+
+		columns = append(columns, "KIND")
+		fields = append(fields, func(v *kutil.ValidationCheck) string {
+			return v.Kind
+		})
+
+		columns = append(columns, "NAME")
+		fields = append(fields, func(v *kutil.ValidationCheck) string {
+			return v.Name
+		})
+
+		columns = append(columns, "STATUS")
+		fields = append(fields, func(v *kutil.ValidationCheck) string {
+			if v.Passed {
+				return "PASS"
+			}
+			return "FAIL"
+		})
+
+		columns = append(columns, "MESSAGE")
+		fields = append(fields, func(v *kutil.ValidationCheck) string {
+			return v.Message
+		})
+
+		err = WriteTable(result.Checks, columns, fields)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !result.Passed {
+		return fmt.Errorf("cluster %q did not pass validation", cluster.Name)
+	}
+	return nil
+}
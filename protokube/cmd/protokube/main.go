@@ -26,17 +26,46 @@ func main() {
 	clusterID := ""
 	flag.StringVar(&clusterID, "cluster-id", clusterID, "Cluster ID")
 
+	cloud := "aws"
+	flag.StringVar(&cloud, "cloud", cloud, "CloudProvider we are using (aws or gce)")
+
 	flag.Set("logtostderr", "true")
 	flag.Parse()
 
-	volumes, err := protokube.NewAWSVolumes()
-	if err != nil {
-		glog.Errorf("Error initializing AWS: %q", err)
+	var volumes protokube.Volumes
+	var clusterIDFromCloud string
+	var internalIP net.IP
+	var gceProject string
+
+	switch cloud {
+	case "aws":
+		awsVolumes, err := protokube.NewAWSVolumes()
+		if err != nil {
+			glog.Errorf("Error initializing AWS: %q", err)
+			os.Exit(1)
+		}
+		volumes = awsVolumes
+		clusterIDFromCloud = awsVolumes.ClusterID()
+		internalIP = awsVolumes.InternalIP()
+
+	case "gce":
+		gceVolumes, err := protokube.NewGCEVolumes()
+		if err != nil {
+			glog.Errorf("Error initializing GCE: %q", err)
+			os.Exit(1)
+		}
+		volumes = gceVolumes
+		clusterIDFromCloud = gceVolumes.ClusterID()
+		internalIP = gceVolumes.InternalIP()
+		gceProject = gceVolumes.Project()
+
+	default:
+		glog.Errorf("Unknown cloud %q", cloud)
 		os.Exit(1)
 	}
 
 	if clusterID == "" {
-		clusterID = volumes.ClusterID()
+		clusterID = clusterIDFromCloud
 		if clusterID == "" {
 			glog.Errorf("cluster-id is required (cannot be determined from cloud)")
 			os.Exit(1)
@@ -68,9 +97,14 @@ func main() {
 	//	glog.Errorf("Error finding internal IP: %q", err)
 	//	os.Exit(1)
 	//}
-	internalIP := volumes.InternalIP()
 
-	dns, err := protokube.NewRoute53DNSProvider(dnsZoneName)
+	var dns protokube.DNSProvider
+	var err error
+	if cloud == "gce" {
+		dns, err = protokube.NewGoogleCloudDNSProvider(gceProject, dnsZoneName)
+	} else {
+		dns, err = protokube.NewRoute53DNSProvider(dnsZoneName)
+	}
 	if err != nil {
 		glog.Errorf("Error initializing DNS: %q", err)
 		os.Exit(1)
@@ -0,0 +1,145 @@
+package protokube
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/dns/v1"
+	"reflect"
+	"strings"
+	"time"
+)
+
+type GoogleCloudDNSProvider struct {
+	client  *dns.Service
+	project string
+
+	zoneName string
+	zone     *dns.ManagedZone
+}
+
+func NewGoogleCloudDNSProvider(project string, zoneName string) (*GoogleCloudDNSProvider, error) {
+	if zoneName == "" {
+		return nil, fmt.Errorf("zone name is required")
+	}
+
+	p := &GoogleCloudDNSProvider{
+		project:  project,
+		zoneName: zoneName,
+	}
+
+	ctx := context.Background()
+	client, err := google.DefaultClient(ctx, dns.NdevClouddnsReadwriteScope)
+	if err != nil {
+		return nil, fmt.Errorf("error building google API client: %v", err)
+	}
+	p.client, err = dns.New(client)
+	if err != nil {
+		return nil, fmt.Errorf("error building DNS API client: %v", err)
+	}
+
+	return p, nil
+}
+
+func (p *GoogleCloudDNSProvider) getZone() (*dns.ManagedZone, error) {
+	if p.zone != nil {
+		return p.zone, nil
+	}
+
+	findZone := p.zoneName
+	if !strings.HasSuffix(findZone, ".") {
+		findZone += "."
+	}
+
+	response, err := p.client.ManagedZones.List(p.project).DnsName(findZone).Do()
+	if err != nil {
+		return nil, fmt.Errorf("error querying for DNS ManagedZones %q: %v", findZone, err)
+	}
+
+	var zones []*dns.ManagedZone
+	for _, zone := range response.ManagedZones {
+		if zone.DnsName == findZone {
+			zones = append(zones, zone)
+		}
+	}
+	if len(zones) == 0 {
+		return nil, fmt.Errorf("DNS ManagedZone %q not found", findZone)
+	}
+	if len(zones) != 1 {
+		return nil, fmt.Errorf("found multiple managed zones matched name %q", findZone)
+	}
+
+	p.zone = zones[0]
+
+	return p.zone, nil
+}
+
+func (p *GoogleCloudDNSProvider) findResourceRecord(zoneName string, fqdn string, recordType string) (*dns.ResourceRecordSet, error) {
+	response, err := p.client.ResourceRecordSets.List(p.project, zoneName).Name(fqdn).Type(recordType).Do()
+	if err != nil {
+		return nil, fmt.Errorf("error listing DNS ResourceRecords: %v", err)
+	}
+
+	for _, rrs := range response.Rrsets {
+		if rrs.Name == fqdn && rrs.Type == recordType {
+			return rrs, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *GoogleCloudDNSProvider) Set(fqdn string, recordType string, value string, ttl time.Duration) error {
+	zone, err := p.getZone()
+	if err != nil {
+		return err
+	}
+
+	// Cloud DNS names are always fully qualified
+	if !strings.HasSuffix(fqdn, ".") {
+		fqdn += "."
+	}
+
+	existing, err := p.findResourceRecord(zone.Name, fqdn, recordType)
+	if err != nil {
+		return err
+	}
+
+	rrs := &dns.ResourceRecordSet{
+		Kind:    "dns#resourceRecordSet",
+		Name:    fqdn,
+		Type:    recordType,
+		Ttl:     int64(ttl.Seconds()),
+		Rrdatas: []string{value},
+	}
+
+	change := &dns.Change{
+		Additions: []*dns.ResourceRecordSet{rrs},
+	}
+
+	if existing != nil {
+		if reflect.DeepEqual(rrs.Rrdatas, existing.Rrdatas) && rrs.Ttl == existing.Ttl {
+			glog.V(2).Infof("DNS %q %s record already set to %q", fqdn, recordType, value)
+			return nil
+		}
+
+		glog.Infof("ResourceRecordSet change:")
+		glog.Infof("Existing: %v", DebugString(existing))
+		glog.Infof("Desired:  %v", DebugString(rrs))
+
+		// Cloud DNS has no upsert; the existing record must be deleted in the same change
+		change.Deletions = []*dns.ResourceRecordSet{existing}
+	}
+
+	glog.V(2).Infof("Updating DNS record %q", fqdn)
+	glog.V(4).Infof("Cloud DNS change: %s", DebugString(change))
+
+	response, err := p.client.Changes.Create(p.project, zone.Name, change).Do()
+	if err != nil {
+		return fmt.Errorf("error creating DNS change: %v", err)
+	}
+
+	glog.V(2).Infof("Change id is %q", response.Id)
+
+	return nil
+}
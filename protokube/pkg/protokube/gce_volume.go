@@ -0,0 +1,277 @@
+package protokube
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/cloud/compute/metadata"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The labels on the etcd disks; GCE labels only allow [a-z0-9_-], so these are the AWS tags with the
+// values encoded (see decodeGCELabel).  They must match the labels set by kops (in the gce package).
+const GCELabelClusterName = "k8s-io-cluster-name"
+const GCELabelRoleMaster = "k8s-io-role-master"
+const GCELabelEtcdClusterPrefix = "k8s-io-etcd-"
+
+type GCEVolumes struct {
+	compute *compute.Service
+
+	project      string
+	zone         string
+	clusterName  string
+	instanceName string
+	internalIP   net.IP
+}
+
+var _ Volumes = &GCEVolumes{}
+
+func NewGCEVolumes() (*GCEVolumes, error) {
+	a := &GCEVolumes{}
+
+	ctx := context.Background()
+	client, err := google.DefaultClient(ctx, compute.ComputeScope)
+	if err != nil {
+		return nil, fmt.Errorf("error building google API client: %v", err)
+	}
+	a.compute, err = compute.New(client)
+	if err != nil {
+		return nil, fmt.Errorf("error building compute API client: %v", err)
+	}
+
+	err = a.discoverMetadata()
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (a *GCEVolumes) ClusterID() string {
+	return a.clusterName
+}
+
+func (a *GCEVolumes) InternalIP() net.IP {
+	return a.internalIP
+}
+
+func (a *GCEVolumes) Project() string {
+	return a.project
+}
+
+func (a *GCEVolumes) discoverMetadata() error {
+	var err error
+
+	a.project, err = metadata.ProjectID()
+	if err != nil {
+		return fmt.Errorf("error querying GCE metadata service (for project): %v", err)
+	}
+
+	a.zone, err = metadata.Zone()
+	if err != nil {
+		return fmt.Errorf("error querying GCE metadata service (for zone): %v", err)
+	}
+
+	a.instanceName, err = metadata.InstanceName()
+	if err != nil {
+		return fmt.Errorf("error querying GCE metadata service (for instance name): %v", err)
+	}
+
+	internalIP, err := metadata.InternalIP()
+	if err != nil {
+		return fmt.Errorf("error querying GCE metadata service (for internal IP): %v", err)
+	}
+	a.internalIP = net.ParseIP(internalIP)
+	if a.internalIP == nil {
+		return fmt.Errorf("Internal IP not found on this instance (%q)", a.instanceName)
+	}
+
+	// kops sets the cluster-name attribute on the instance templates
+	clusterName, err := metadata.InstanceAttributeValue("cluster-name")
+	if err != nil {
+		return fmt.Errorf("error querying GCE metadata service (for cluster-name): %v", err)
+	}
+	a.clusterName = strings.TrimSpace(clusterName)
+	if a.clusterName == "" {
+		return fmt.Errorf("cluster-name metadata not found on this instance (%q)", a.instanceName)
+	}
+
+	return nil
+}
+
+func (a *GCEVolumes) FindVolumes() ([]*Volume, error) {
+	clusterLabel := encodeGCELabel(a.clusterName)
+
+	var volumes []*Volume
+	err := a.compute.Disks.List(a.project, a.zone).Pages(context.Background(), func(page *compute.DiskList) error {
+		for _, d := range page.Items {
+			if d.Labels[GCELabelClusterName] != clusterLabel {
+				continue
+			}
+			if _, found := d.Labels[GCELabelRoleMaster]; !found {
+				continue
+			}
+
+			vol, err := a.buildVolume(d)
+			if err != nil {
+				// Fail safe
+				glog.Warningf("skipping volume %q: %v", d.Name, err)
+				continue
+			}
+			volumes = append(volumes, vol)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error querying for GCE disks: %v", err)
+	}
+	return volumes, nil
+}
+
+func (a *GCEVolumes) buildVolume(d *compute.Disk) (*Volume, error) {
+	vol := &Volume{
+		ID: d.Name,
+		Info: VolumeInfo{
+			Description: d.Name,
+		},
+		Status: d.Status,
+	}
+
+	for _, user := range d.Users {
+		instanceName := lastComponent(user)
+		vol.AttachedTo = instanceName
+		if instanceName == a.instanceName {
+			vol.LocalDevice = gceDevicePath(d.Name)
+		}
+	}
+
+	for k, v := range d.Labels {
+		switch k {
+		case GCELabelClusterName, GCELabelRoleMaster:
+		// Ignore
+		default:
+			if strings.HasPrefix(k, GCELabelEtcdClusterPrefix) {
+				etcdClusterName, err := decodeGCELabel(k[len(GCELabelEtcdClusterPrefix):])
+				if err != nil {
+					return nil, fmt.Errorf("error decoding etcd cluster label %q: %v", k, err)
+				}
+				value, err := decodeGCELabel(v)
+				if err != nil {
+					return nil, fmt.Errorf("error decoding etcd cluster label %q: %v", k, err)
+				}
+				spec, err := ParseEtcdClusterSpec(etcdClusterName, value)
+				if err != nil {
+					return nil, fmt.Errorf("error parsing etcd cluster label %q: %v", k, err)
+				}
+				vol.Info.EtcdClusters = append(vol.Info.EtcdClusters, spec)
+			} else {
+				glog.Warningf("unknown label on volume %q: %s=%s", d.Name, k, v)
+			}
+		}
+	}
+
+	return vol, nil
+}
+
+// gceDevicePath is where a disk appears when it is attached with its name as the device name
+func gceDevicePath(diskName string) string {
+	return "/dev/disk/by-id/google-" + diskName
+}
+
+// AttachVolume attaches the specified volume to this instance, setting LocalDevice if successful
+func (a *GCEVolumes) AttachVolume(volume *Volume) error {
+	diskName := volume.ID
+
+	if volume.LocalDevice == "" {
+		disk, err := a.compute.Disks.Get(a.project, a.zone, diskName).Do()
+		if err != nil {
+			return fmt.Errorf("error getting GCE disk %q: %v", diskName, err)
+		}
+
+		attachedDisk := &compute.AttachedDisk{
+			DeviceName: diskName,
+			Source:     disk.SelfLink,
+			Mode:       "READ_WRITE",
+			Type:       "PERSISTENT",
+		}
+
+		op, err := a.compute.Instances.AttachDisk(a.project, a.zone, a.instanceName, attachedDisk).Do()
+		if err != nil {
+			return fmt.Errorf("error attaching GCE disk %q: %v", diskName, err)
+		}
+		glog.V(2).Infof("AttachDisk request returned operation %q", op.Name)
+	}
+
+	// Wait (forever) for the disk to attach, or to be attached to another instance
+	for {
+		disk, err := a.compute.Disks.Get(a.project, a.zone, diskName).Do()
+		if err != nil {
+			return fmt.Errorf("error getting GCE disk %q: %v", diskName, err)
+		}
+
+		v, err := a.buildVolume(disk)
+		if err != nil {
+			return err
+		}
+
+		if v.AttachedTo != "" {
+			if v.AttachedTo == a.instanceName {
+				volume.LocalDevice = v.LocalDevice
+				return nil
+			}
+			return fmt.Errorf("Unable to attach disk %q, was attached to %q", diskName, v.AttachedTo)
+		}
+
+		glog.V(2).Infof("Waiting for disk %q to be attached (currently %q)", diskName, v.Status)
+		time.Sleep(10 * time.Second)
+	}
+}
+
+func lastComponent(s string) string {
+	lastSlash := strings.LastIndex(s, "/")
+	if lastSlash != -1 {
+		s = s[lastSlash+1:]
+	}
+	return s
+}
+
+// encodeGCELabel & decodeGCELabel match the encoding used by kops: characters other than [a-z0-9-]
+// are written as an underscore followed by two hex digits.  Both copies are tested against the same vectors.
+func encodeGCELabel(s string) string {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' {
+			b = append(b, c)
+		} else {
+			b = append(b, []byte(fmt.Sprintf("_%02x", c))...)
+		}
+	}
+	return string(b)
+}
+
+func decodeGCELabel(s string) (string, error) {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '_' {
+			b = append(b, c)
+			continue
+		}
+		if i+2 >= len(s) {
+			return "", fmt.Errorf("invalid escape in label %q", s)
+		}
+		v, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("invalid escape in label %q", s)
+		}
+		b = append(b, byte(v))
+		i += 2
+	}
+	return string(b), nil
+}
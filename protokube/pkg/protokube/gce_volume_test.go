@@ -0,0 +1,42 @@
+package protokube
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// labelTestVectors are the vectors for the kops copy of the encoding, so that the two can't drift apart
+const labelTestVectors = "../../../upup/pkg/fi/cloudup/gce/testdata/labels.txt"
+
+func TestGCELabelEncoding(t *testing.T) {
+	data, err := ioutil.ReadFile(labelTestVectors)
+	if err != nil {
+		t.Fatalf("error reading %s: %v", labelTestVectors, err)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		tokens := strings.Split(line, "\t")
+		if len(tokens) == 1 {
+			if _, err := decodeGCELabel(tokens[0]); err == nil {
+				t.Errorf("expected error decoding %q", tokens[0])
+			}
+			continue
+		}
+
+		decoded, encoded := tokens[0], tokens[1]
+		if actual := encodeGCELabel(decoded); actual != encoded {
+			t.Errorf("encodeGCELabel(%q): expected %q, got %q", decoded, encoded, actual)
+		}
+		actual, err := decodeGCELabel(encoded)
+		if err != nil {
+			t.Errorf("error decoding %q: %v", encoded, err)
+		} else if actual != decoded {
+			t.Errorf("decodeGCELabel(%q): expected %q, got %q", encoded, decoded, actual)
+		}
+	}
+}
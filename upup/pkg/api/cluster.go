@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"github.com/golang/glog"
+	"k8s.io/kops/upup/pkg/fi"
 	k8sapi "k8s.io/kubernetes/pkg/api"
 	"k8s.io/kubernetes/pkg/api/unversioned"
 	"net"
@@ -45,6 +46,9 @@ type ClusterSpec struct {
 	MasterPublicName string `json:"masterPublicName,omitempty"`
 	// MasterInternalName is the internal DNS name for the master nodes
 	MasterInternalName string `json:"masterInternalName,omitempty"`
+	// APILoadBalancer places a load balancer in front of all the masters, for access to the kubernetes API;
+	// MasterPublicName then points at the load balancer.  It defaults to true with a private topology.
+	APILoadBalancer *bool `json:"apiLoadBalancer,omitempty"`
 
 	// The CIDR used for the AWS VPC / GCE Network, or otherwise allocated to k8s
 	// This is a real CIDR, not the internal k8s network
@@ -53,12 +57,22 @@ type ClusterSpec struct {
 	// NetworkID is an identifier of a network, if we want to reuse/share an existing network (e.g. an AWS VPC)
 	NetworkID string `json:"networkID,omitempty"`
 
+	// Topology controls whether instances are placed in public subnets, or in private subnets behind NAT gateways
+	Topology *TopologySpec `json:"topology,omitempty"`
+
+	// SSHAccess is the list of CIDRs that can SSH to the instances (or to the bastion, with a private topology)
+	SSHAccess []string `json:"sshAccess,omitempty"`
+	// KubernetesAPIAccess is the list of CIDRs that can reach the kubernetes API (on the masters or the API ELB)
+	KubernetesAPIAccess []string `json:"kubernetesApiAccess,omitempty"`
+
 	// SecretStore is the VFS path to where secrets are stored
 	SecretStore string `json:"secretStore,omitempty"`
 	// KeyStore is the VFS path to where SSL keys and certificates are stored
 	KeyStore string `json:"keyStore,omitempty"`
 	// ConfigStore is the VFS path to where the configuration (CloudConfig, NodeSetConfig etc) is stored
 	ConfigStore string `json:"configStore,omitempty"`
+	// Encryption describes how secrets and private keys in the SecretStore and KeyStore are encrypted
+	Encryption *fi.EncryptionConfig `json:"encryption,omitempty"`
 
 	// DNSZone is the DNS zone we should use when configuring DNS
 	// This is because some clouds let us define a managed zone foo.bar, and then have
@@ -88,8 +102,9 @@ type ClusterSpec struct {
 	// It cannot overlap ServiceClusterIPRange
 	NonMasqueradeCIDR string `json:"nonMasqueradeCIDR,omitempty"`
 
-	//NetworkProvider               string `json:",omitempty"`
-	//
+	// Networking configures the pod network: kubenet (the default), an external CNI plugin, or an overlay
+	Networking *NetworkingSpec `json:"networking,omitempty"`
+
 	//HairpinMode                   string `json:",omitempty"`
 	//
 	//OpencontrailTag               string `json:",omitempty"`
@@ -232,6 +247,53 @@ type EtcdMemberSpec struct {
 type ClusterZoneSpec struct {
 	Name string `json:"name,omitempty"`
 	CIDR string `json:"cidr,omitempty"`
+
+	// UtilityCIDR is the CIDR of the public subnet holding the NAT gateway and load balancers, in a private topology
+	UtilityCIDR string `json:"utilityCIDR,omitempty"`
+}
+
+const (
+	// TopologyPublic places instances in public subnets, with public IPs
+	TopologyPublic = "public"
+	// TopologyPrivate places instances in private subnets, reaching the internet through a NAT gateway per zone
+	TopologyPrivate = "private"
+)
+
+type TopologySpec struct {
+	// Type is the network topology: public (the default) or private
+	Type string `json:"type,omitempty"`
+
+	// BastionPublicName is the DNS name for the bastion ELB, which is the SSH entrypoint in a private topology
+	BastionPublicName string `json:"bastionPublicName,omitempty"`
+}
+
+// NetworkingSpec selects the pod network provider; exactly one field should be set
+type NetworkingSpec struct {
+	// Kubenet uses the kubelet's cbr0 bridge, with cloud routes to each node's pod CIDR
+	Kubenet *KubenetNetworkingSpec `json:"kubenet,omitempty"`
+	// External uses a CNI plugin that is installed & configured outside of kops
+	External *ExternalNetworkingSpec `json:"external,omitempty"`
+	// Weave uses the weave overlay network, installed as an addon
+	Weave *WeaveNetworkingSpec `json:"weave,omitempty"`
+}
+
+type KubenetNetworkingSpec struct {
+}
+
+type ExternalNetworkingSpec struct {
+}
+
+type WeaveNetworkingSpec struct {
+}
+
+// UsesCNI returns true if the kubelet should be configured with --network-plugin=cni
+func (n *NetworkingSpec) UsesCNI() bool {
+	return n.External != nil || n.Weave != nil
+}
+
+// UsesCloudRoutes returns true if pod traffic is routed by the cloud (which requires source/dest checks to be disabled)
+func (n *NetworkingSpec) UsesCloudRoutes() bool {
+	return n.Kubenet != nil || n.External != nil
 }
 
 //type NodeUpConfig struct {
@@ -257,6 +319,36 @@ func (c *Cluster) PerformAssignments() error {
 		c.Spec.NonMasqueradeCIDR = "100.64.0.0/10"
 	}
 
+	if c.Spec.Topology == nil {
+		c.Spec.Topology = &TopologySpec{Type: TopologyPublic}
+	}
+	if c.Spec.Topology.Type == "" {
+		c.Spec.Topology.Type = TopologyPublic
+	}
+
+	if c.Spec.Networking == nil {
+		if c.IsTopologyPrivate() {
+			// kubenet is not supported with a private topology (see validation), so default to an overlay
+			c.Spec.Networking = &NetworkingSpec{Weave: &WeaveNetworkingSpec{}}
+		} else {
+			c.Spec.Networking = &NetworkingSpec{Kubenet: &KubenetNetworkingSpec{}}
+		}
+	}
+
+	// Masters in private subnets can only be reached through a load balancer
+	if c.Spec.APILoadBalancer == nil && c.IsTopologyPrivate() {
+		t := true
+		c.Spec.APILoadBalancer = &t
+	}
+
+	// Access is open to the world unless restricted
+	if len(c.Spec.SSHAccess) == 0 {
+		c.Spec.SSHAccess = []string{"0.0.0.0/0"}
+	}
+	if len(c.Spec.KubernetesAPIAccess) == 0 {
+		c.Spec.KubernetesAPIAccess = []string{"0.0.0.0/0"}
+	}
+
 	for _, zone := range c.Spec.Zones {
 		err := zone.performAssignments(c)
 		if err != nil {
@@ -277,10 +369,52 @@ func (z *ClusterZoneSpec) performAssignments(c *Cluster) error {
 		z.CIDR = cidr
 	}
 
+	if c.IsTopologyPrivate() && z.UtilityCIDR == "" {
+		cidr, err := z.assignUtilityCIDR(c)
+		if err != nil {
+			return err
+		}
+		glog.Infof("Assigned utility CIDR %s to zone %s", cidr, z.Name)
+		z.UtilityCIDR = cidr
+	}
+
 	return nil
 }
 
 func (z *ClusterZoneSpec) assignCIDR(c *Cluster) (string, error) {
+	index, err := z.zoneIndex(c)
+	if err != nil {
+		return "", err
+	}
+
+	// We assume a maximum of 8 subnets per network
+	// TODO: Does this make sense on GCE?
+	// TODO: Should we limit this to say 1000 IPs per subnet? (any reason to?)
+	return splitNetworkCIDR(c, index%8, 3)
+}
+
+// assignUtilityCIDR allocates the utility subnets from the lowest of the 8 zone ranges, which zones do not use
+// (unless there are more than 7 zones): we divide it into a further 8 subnets.
+func (z *ClusterZoneSpec) assignUtilityCIDR(c *Cluster) (string, error) {
+	for _, other := range c.Spec.Zones {
+		index, err := other.zoneIndex(c)
+		if err != nil {
+			return "", err
+		}
+		if index%8 == 0 {
+			return "", fmt.Errorf("cannot assign a utility CIDR, because zone %q uses the utility range; set the utilityCIDR for the zones manually", other.Name)
+		}
+	}
+
+	index, err := z.zoneIndex(c)
+	if err != nil {
+		return "", err
+	}
+	return splitNetworkCIDR(c, index%8, 6)
+}
+
+// zoneIndex returns a stable index for the zone, which we use to allocate non-overlapping CIDRs
+func (z *ClusterZoneSpec) zoneIndex(c *Cluster) (int, error) {
 	// TODO: We probably could query for the existing subnets & allocate appropriately
 	// for now we'll require users to set CIDRs themselves
 
@@ -290,38 +424,31 @@ func (z *ClusterZoneSpec) assignCIDR(c *Cluster) (string, error) {
 		lastCharMap[lastChar] = true
 	}
 
-	index := -1
-
 	if len(lastCharMap) == len(c.Spec.Zones) {
 		// Last char of zones are unique (GCE, AWS)
 		// At least on AWS, we also want 'a' to be 1, so that we don't collide with the lowest range,
 		// because kube-up uses that range
-		index = int(z.Name[len(z.Name)-1])
-	} else {
-		glog.Warningf("Last char of zone names not unique")
-
-		for i, nodeZone := range c.Spec.Zones {
-			if nodeZone.Name == z.Name {
-				index = i
-				break
-			}
-		}
-		if index == -1 {
-			return "", fmt.Errorf("zone not configured: %q", z.Name)
+		return int(z.Name[len(z.Name)-1]), nil
+	}
+
+	glog.Warningf("Last char of zone names not unique")
+
+	for i, nodeZone := range c.Spec.Zones {
+		if nodeZone.Name == z.Name {
+			return i, nil
 		}
 	}
+	return -1, fmt.Errorf("zone not configured: %q", z.Name)
+}
 
+// splitNetworkCIDR returns the index'th subnet of the NetworkCIDR, when it is divided into 2^extraBits subnets
+func splitNetworkCIDR(c *Cluster, index int, extraBits int) (string, error) {
 	_, cidr, err := net.ParseCIDR(c.Spec.NetworkCIDR)
 	if err != nil {
 		return "", fmt.Errorf("Invalid NetworkCIDR: %q", c.Spec.NetworkCIDR)
 	}
 	networkLength, _ := cidr.Mask.Size()
-
-	// We assume a maximum of 8 subnets per network
-	// TODO: Does this make sense on GCE?
-	// TODO: Should we limit this to say 1000 IPs per subnet? (any reason to?)
-	index = index % 8
-	networkLength += 3
+	networkLength += extraBits
 
 	ip4 := cidr.IP.To4()
 	if ip4 != nil {
@@ -330,7 +457,7 @@ func (z *ClusterZoneSpec) assignCIDR(c *Cluster) (string, error) {
 		subnetIP := make(net.IP, len(ip4))
 		binary.BigEndian.PutUint32(subnetIP, n)
 		subnetCIDR := subnetIP.String() + "/" + strconv.Itoa(networkLength)
-		glog.V(2).Infof("Computed CIDR for subnet in zone %q as %q", z.Name, subnetCIDR)
+		glog.V(2).Infof("Computed CIDR for subnet %d as %q", index, subnetCIDR)
 		return subnetCIDR, nil
 	}
 
@@ -342,6 +469,16 @@ func (c *Cluster) SharedVPC() bool {
 	return c.Spec.NetworkID != ""
 }
 
+// UseAPILoadBalancer returns true if the masters are behind a load balancer
+func (c *Cluster) UseAPILoadBalancer() bool {
+	return c.Spec.APILoadBalancer != nil && *c.Spec.APILoadBalancer
+}
+
+// IsTopologyPrivate returns true if instances are placed in private subnets
+func (c *Cluster) IsTopologyPrivate() bool {
+	return c.Spec.Topology != nil && c.Spec.Topology.Type == TopologyPrivate
+}
+
 // CloudPermissions holds IAM-style permissions
 type CloudPermissions struct {
 	Permissions []*CloudPermission `json:"permissions,omitempty"`
@@ -378,6 +515,31 @@ func (p *CloudPermissions) S3Buckets() []string {
 	return buckets
 }
 
+// AddGCSBucket adds a google cloud storage bucket if it does not already exist
+func (p *CloudPermissions) AddGCSBucket(bucket string) {
+	for _, p := range p.Permissions {
+		if p.Resource == "gs://"+bucket {
+			return
+		}
+	}
+
+	p.Permissions = append(p.Permissions, &CloudPermission{
+		Resource: "gs://" + bucket,
+	})
+}
+
+// GCSBuckets returns each of the google cloud storage buckets in the permission
+func (p *CloudPermissions) GCSBuckets() []string {
+	var buckets []string
+	for _, p := range p.Permissions {
+		if strings.HasPrefix(p.Resource, "gs://") {
+			buckets = append(buckets, strings.TrimPrefix(p.Resource, "gs://"))
+		}
+	}
+
+	return buckets
+}
+
 //
 //// findImage finds the default image
 //func (c*NodeSetConfig) resolveImage() error {
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestPerformAssignments_PrivateTopology(t *testing.T) {
+	cluster := &Cluster{}
+	cluster.Spec.Topology = &TopologySpec{Type: TopologyPrivate}
+	cluster.Spec.Zones = []*ClusterZoneSpec{{Name: "us-east-1a"}, {Name: "us-east-1b"}}
+
+	err := cluster.PerformAssignments()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string][2]string{
+		"us-east-1a": {"172.20.32.0/19", "172.20.4.0/22"},
+		"us-east-1b": {"172.20.64.0/19", "172.20.8.0/22"},
+	}
+	for _, z := range cluster.Spec.Zones {
+		if z.CIDR != expected[z.Name][0] || z.UtilityCIDR != expected[z.Name][1] {
+			t.Errorf("unexpected CIDRs for zone %q: %q %q", z.Name, z.CIDR, z.UtilityCIDR)
+		}
+	}
+
+	if !cluster.UseAPILoadBalancer() {
+		t.Errorf("expected APILoadBalancer to default to true for a private topology")
+	}
+	if cluster.Spec.Networking == nil || cluster.Spec.Networking.Weave == nil {
+		t.Errorf("expected networking to default to weave for a private topology, was %v", cluster.Spec.Networking)
+	}
+}
+
+func TestPerformAssignments_DefaultTopology(t *testing.T) {
+	cluster := &Cluster{}
+	cluster.Spec.Zones = []*ClusterZoneSpec{{Name: "us-east-1a"}}
+
+	err := cluster.PerformAssignments()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cluster.Spec.Topology == nil || cluster.Spec.Topology.Type != TopologyPublic {
+		t.Fatalf("expected topology to default to public, was %v", cluster.Spec.Topology)
+	}
+	if cluster.Spec.Zones[0].UtilityCIDR != "" {
+		t.Fatalf("did not expect a utility CIDR for a public topology")
+	}
+}
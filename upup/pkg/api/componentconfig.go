@@ -145,12 +145,12 @@ type KubeletConfig struct {
 	//LowDiskSpaceThresholdMB int32 `json:"lowDiskSpaceThresholdMB"`
 	//// How frequently to calculate and cache volume disk usage for all pods
 	//VolumeStatsAggPeriod unversioned.Duration `json:"volumeStatsAggPeriod"`
-	//// networkPluginName is the name of the network plugin to be invoked for
-	//// various events in kubelet/pod lifecycle
-	//NetworkPluginName string `json:"networkPluginName"`
-	//// networkPluginDir is the full path of the directory in which to search
-	//// for network plugins
-	//NetworkPluginDir string `json:"networkPluginDir"`
+	// networkPluginName is the name of the network plugin to be invoked for
+	// various events in kubelet/pod lifecycle
+	NetworkPluginName string `json:"networkPluginName,omitempty" flag:"network-plugin"`
+	// networkPluginDir is the full path of the directory in which to search
+	// for network plugins
+	NetworkPluginDir string `json:"networkPluginDir,omitempty" flag:"network-plugin-dir"`
 	//// volumePluginDir is the full path of the directory in which to search
 	//// for additional third party volume plugins
 	//VolumePluginDir string `json:"volumePluginDir"`
@@ -252,8 +252,10 @@ type KubeletConfig struct {
 	//// nodeIP is IP address of the node. If set, kubelet will use this IP
 	//// address for the node.
 	//NodeIP string `json:"nodeIP,omitempty"`
-	//// nodeLabels to add when registering the node in the cluster.
-	//NodeLabels map[string]string `json:"nodeLabels"`
+	// nodeLabels to add when registering the node in the cluster.
+	NodeLabels map[string]string `json:"nodeLabels,omitempty" flag:"node-labels"`
+	// registerWithTaints are the taints (key=value:effect) the node has when it registers
+	RegisterWithTaints []string `json:"registerWithTaints,omitempty" flag:"register-with-taints"`
 	// nonMasqueradeCIDR configures masquerading: traffic to IPs outside this range will use IP masquerade.
 	NonMasqueradeCIDR string `json:"nonMasqueradeCIDR,omitempty" flag:"non-masquerade-cidr"`
 	//// enable gathering custom metrics.
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"fmt"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/utils"
+	"strings"
+)
+
+// isUserConfigPath returns true for the configuration files that the user edits (and that we roll back),
+// as opposed to those we generate from them
+func isUserConfigPath(p string) bool {
+	return p == "config" || strings.HasPrefix(p, "instancegroup/")
+}
+
+// ListConfigRevisions returns the revisions of the cluster configuration and instance groups, oldest first
+func ListConfigRevisions(stateStore fi.StateStore) ([]*fi.Revision, error) {
+	all, err := stateStore.ListRevisions()
+	if err != nil {
+		return nil, fmt.Errorf("error listing revisions: %v", err)
+	}
+
+	var revisions []*fi.Revision
+	for _, r := range all {
+		if isUserConfigPath(r.Path) {
+			revisions = append(revisions, r)
+		}
+	}
+	return revisions, nil
+}
+
+// RollbackConfig restores the cluster configuration and instance groups to their state immediately after the specified revision.
+// The rollback is itself recorded as new revisions, so it can be undone.
+func RollbackConfig(stateStore fi.StateStore, revisionID string) error {
+	revisions, err := ListConfigRevisions(stateStore)
+	if err != nil {
+		return err
+	}
+
+	// Replay the revisions up to the target, to find the state of each file at that point
+	found := false
+	target := make(map[string]*fi.Revision)
+	for _, r := range revisions {
+		target[r.Path] = r
+		if r.ID == revisionID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("revision %q not found", revisionID)
+	}
+
+	config := target["config"]
+	if config == nil || config.Deleted {
+		return fmt.Errorf("cluster configuration did not exist at revision %q", revisionID)
+	}
+
+	cluster := &Cluster{}
+	err = utils.YamlUnmarshal([]byte(config.Data), cluster)
+	if err != nil {
+		return fmt.Errorf("error parsing cluster configuration from revision %q: %v", config.ID, err)
+	}
+
+	var groups []*InstanceGroup
+	for p, r := range target {
+		if p == "config" || r.Deleted {
+			continue
+		}
+		group := &InstanceGroup{}
+		err = utils.YamlUnmarshal([]byte(r.Data), group)
+		if err != nil {
+			return fmt.Errorf("error parsing instancegroup configuration from revision %q: %v", r.ID, err)
+		}
+		groups = append(groups, group)
+	}
+
+	// Remove any instance groups that were created after the revision
+	keep := make(map[string]bool)
+	for _, g := range groups {
+		keep[g.Name] = true
+	}
+	existing, err := stateStore.ListChildren("instancegroup")
+	if err != nil {
+		return fmt.Errorf("error listing instancegroups in state store: %v", err)
+	}
+	for _, name := range existing {
+		if keep[name] {
+			continue
+		}
+		err = stateStore.DeleteConfig("instancegroup/" + name)
+		if err != nil {
+			return fmt.Errorf("error deleting instancegroup configuration %q: %v", name, err)
+		}
+	}
+
+	return WriteConfig(stateStore, cluster, groups)
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+	"testing"
+)
+
+func TestRollbackConfig(t *testing.T) {
+	memfs := vfs.NewMemFSContext()
+	stateStore, err := fi.NewVFSStateStore(vfs.NewMemFSPath(memfs, "state"), "history.example.com", false)
+	if err != nil {
+		t.Fatalf("error building state store: %v", err)
+	}
+
+	cluster := &Cluster{}
+	cluster.Name = "history.example.com"
+	cluster.Spec.KubernetesVersion = "1.3.5"
+
+	nodes := &InstanceGroup{}
+	nodes.Name = "nodes"
+	nodes.Spec.Role = InstanceGroupRoleNode
+
+	err = WriteConfig(stateStore, cluster, []*InstanceGroup{nodes})
+	if err != nil {
+		t.Fatalf("error writing config: %v", err)
+	}
+
+	revisions, err := ListConfigRevisions(stateStore)
+	if err != nil {
+		t.Fatalf("error listing revisions: %v", err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("expected 2 revisions after initial write, got %d", len(revisions))
+	}
+	initialRevision := revisions[len(revisions)-1].ID
+
+	// Rewriting the same configuration should not record a revision
+	err = WriteConfig(stateStore, cluster, []*InstanceGroup{nodes})
+	if err != nil {
+		t.Fatalf("error writing config: %v", err)
+	}
+
+	cluster.Spec.KubernetesVersion = "1.4.0"
+	extra := &InstanceGroup{}
+	extra.Name = "extra"
+	extra.Spec.Role = InstanceGroupRoleNode
+	err = WriteConfig(stateStore, cluster, []*InstanceGroup{nodes, extra})
+	if err != nil {
+		t.Fatalf("error writing config: %v", err)
+	}
+	err = DeleteInstanceGroup(stateStore, "nodes")
+	if err != nil {
+		t.Fatalf("error deleting instancegroup: %v", err)
+	}
+
+	revisions, err = ListConfigRevisions(stateStore)
+	if err != nil {
+		t.Fatalf("error listing revisions: %v", err)
+	}
+	if len(revisions) != 5 {
+		t.Fatalf("expected 5 revisions, got %d", len(revisions))
+	}
+
+	err = RollbackConfig(stateStore, initialRevision)
+	if err != nil {
+		t.Fatalf("error rolling back: %v", err)
+	}
+
+	restored, groups, err := ReadConfig(stateStore)
+	if err != nil {
+		t.Fatalf("error reading config: %v", err)
+	}
+	if restored.Spec.KubernetesVersion != "1.3.5" {
+		t.Fatalf("expected KubernetesVersion to be restored, got %q", restored.Spec.KubernetesVersion)
+	}
+	if len(groups) != 1 || groups[0].Name != "nodes" {
+		t.Fatalf("expected only the nodes instancegroup after rollback, got %v", groups)
+	}
+
+	err = RollbackConfig(stateStore, "unknown")
+	if err == nil {
+		t.Fatalf("expected error rolling back to unknown revision")
+	}
+}
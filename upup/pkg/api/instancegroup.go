@@ -5,9 +5,11 @@ import (
 	"github.com/golang/glog"
 	k8sapi "k8s.io/kubernetes/pkg/api"
 	"k8s.io/kubernetes/pkg/api/unversioned"
+	"strconv"
+	"strings"
 )
 
-// InstanceGroup represents a group of instances (either nodes or masters) with the same configuration
+// InstanceGroup represents a group of instances (nodes, masters or bastions) with the same configuration
 type InstanceGroup struct {
 	unversioned.TypeMeta `json:",inline"`
 	k8sapi.ObjectMeta    `json:"metadata,omitempty"`
@@ -19,8 +21,9 @@ type InstanceGroup struct {
 type InstanceGroupRole string
 
 const (
-	InstanceGroupRoleMaster InstanceGroupRole = "Master"
-	InstanceGroupRoleNode   InstanceGroupRole = "Node"
+	InstanceGroupRoleMaster  InstanceGroupRole = "Master"
+	InstanceGroupRoleNode    InstanceGroupRole = "Node"
+	InstanceGroupRoleBastion InstanceGroupRole = "Bastion"
 )
 
 type InstanceGroupSpec struct {
@@ -36,6 +39,16 @@ type InstanceGroupSpec struct {
 	//NodeTag            string `json:",omitempty"`
 
 	Zones []string `json:"zones,omitempty"`
+
+	// MaxPrice makes this a group of spot instances, bidding up to this hourly price (in USD)
+	MaxPrice *string `json:"maxPrice,omitempty"`
+
+	// NodeLabels are the kubernetes labels for the nodes in this group
+	NodeLabels map[string]string `json:"nodeLabels,omitempty"`
+	// Taints are registered on the nodes in this group, written as key=value:effect
+	Taints []string `json:"taints,omitempty"`
+	// CloudLabels are extra tags for the cloud resources of this group (the autoscaling group and its instances)
+	CloudLabels map[string]string `json:"cloudLabels,omitempty"`
 }
 
 // PerformAssignmentsInstanceGroups populates InstanceGroups with default values
@@ -65,11 +78,112 @@ func PerformAssignmentsInstanceGroups(groups []*InstanceGroup) error {
 	return nil
 }
 
+// Validate checks that the InstanceGroup is well-formed, and consistent with the cluster
+func (g *InstanceGroup) Validate(cluster *Cluster) error {
+	if g.Name == "" {
+		return fmt.Errorf("InstanceGroup did not have a Name")
+	}
+	if strings.Contains(g.Name, "/") {
+		return fmt.Errorf("InstanceGroup Name %q must not contain '/'", g.Name)
+	}
+
+	switch g.Spec.Role {
+	case InstanceGroupRoleMaster, InstanceGroupRoleNode:
+	case InstanceGroupRoleBastion:
+		if !cluster.IsTopologyPrivate() {
+			return fmt.Errorf("InstanceGroup %q is a Bastion, but bastions are only supported with a private topology", g.Name)
+		}
+	case "":
+		return fmt.Errorf("InstanceGroup %q did not have a Role", g.Name)
+	default:
+		return fmt.Errorf("InstanceGroup %q had an invalid Role: %q", g.Name, g.Spec.Role)
+	}
+
+	if g.Spec.MinSize != nil && *g.Spec.MinSize < 0 {
+		return fmt.Errorf("InstanceGroup %q had a negative MinSize", g.Name)
+	}
+	if g.Spec.MaxSize != nil && *g.Spec.MaxSize < 0 {
+		return fmt.Errorf("InstanceGroup %q had a negative MaxSize", g.Name)
+	}
+	if g.Spec.MinSize != nil && g.Spec.MaxSize != nil && *g.Spec.MinSize > *g.Spec.MaxSize {
+		return fmt.Errorf("InstanceGroup %q had MinSize %d greater than MaxSize %d", g.Name, *g.Spec.MinSize, *g.Spec.MaxSize)
+	}
+
+	if g.Spec.MaxPrice != nil {
+		if g.Spec.Role != InstanceGroupRoleNode {
+			return fmt.Errorf("InstanceGroup %q sets MaxPrice, but spot instances are only supported for Node instance groups", g.Name)
+		}
+		price, err := strconv.ParseFloat(*g.Spec.MaxPrice, 64)
+		if err != nil || price <= 0 {
+			return fmt.Errorf("InstanceGroup %q had an invalid MaxPrice %q", g.Name, *g.Spec.MaxPrice)
+		}
+	}
+
+	for k := range g.Spec.NodeLabels {
+		if k == "" {
+			return fmt.Errorf("InstanceGroup %q had a NodeLabel with an empty key", g.Name)
+		}
+	}
+	for _, taint := range g.Spec.Taints {
+		err := validateTaint(taint)
+		if err != nil {
+			return fmt.Errorf("InstanceGroup %q had an invalid Taint %q: %v", g.Name, taint, err)
+		}
+	}
+	for k := range g.Spec.CloudLabels {
+		if reservedCloudLabels[k] || strings.HasPrefix(k, "k8s.io/") {
+			return fmt.Errorf("InstanceGroup %q had CloudLabel %q, which is reserved for use by kops", g.Name, k)
+		}
+	}
+
+	clusterZones := make(map[string]bool)
+	for _, z := range cluster.Spec.Zones {
+		clusterZones[z.Name] = true
+	}
+	for _, z := range g.Spec.Zones {
+		if !clusterZones[z] {
+			return fmt.Errorf("InstanceGroup %q is configured in zone %q, which is not a zone of the cluster", g.Name, z)
+		}
+	}
+
+	return nil
+}
+
+// reservedCloudLabels are tags that kops sets itself, and which cannot be overridden by CloudLabels
+var reservedCloudLabels = map[string]bool{
+	"Name":              true,
+	"KubernetesCluster": true,
+}
+
+// validateTaint checks that a taint is of the form key=value:effect
+func validateTaint(taint string) error {
+	colon := strings.LastIndex(taint, ":")
+	if colon == -1 {
+		return fmt.Errorf("expected key=value:effect")
+	}
+	keyValue, effect := taint[:colon], taint[colon+1:]
+	switch effect {
+	case "NoSchedule", "PreferNoSchedule":
+	default:
+		return fmt.Errorf("effect must be NoSchedule or PreferNoSchedule")
+	}
+	tokens := strings.SplitN(keyValue, "=", 2)
+	if tokens[0] == "" {
+		return fmt.Errorf("key must not be empty")
+	}
+	return nil
+}
+
+// IsBastion returns true if the group is a bastion, which is the SSH entrypoint to a private topology
+func (g *InstanceGroup) IsBastion() bool {
+	return g.Spec.Role == InstanceGroupRoleBastion
+}
+
 func (g *InstanceGroup) IsMaster() bool {
 	switch g.Spec.Role {
 	case InstanceGroupRoleMaster:
 		return true
-	case InstanceGroupRoleNode:
+	case InstanceGroupRoleNode, InstanceGroupRoleBastion:
 		return false
 
 	default:
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInstanceGroupValidate(t *testing.T) {
+	cluster := &Cluster{}
+	cluster.Spec.Zones = []*ClusterZoneSpec{{Name: "us-east-1a"}, {Name: "us-east-1b"}}
+
+	one, two := 1, 2
+
+	grid := []struct {
+		Name        string
+		Role        InstanceGroupRole
+		MinSize     *int
+		MaxSize     *int
+		Zones       []string
+		MaxPrice    string
+		Taints      []string
+		CloudLabels map[string]string
+		Error       string
+	}{
+		{Name: "nodes", Role: InstanceGroupRoleNode, MinSize: &one, MaxSize: &two, Zones: []string{"us-east-1a"}},
+		{Name: "", Role: InstanceGroupRoleNode, Error: "did not have a Name"},
+		{Name: "nodes", Role: "", Error: "did not have a Role"},
+		{Name: "nodes", Role: "Worker", Error: "invalid Role"},
+		{Name: "nodes", Role: InstanceGroupRoleNode, MinSize: &two, MaxSize: &one, Error: "greater than MaxSize"},
+		{Name: "nodes", Role: InstanceGroupRoleNode, Zones: []string{"us-east-1c"}, Error: "not a zone of the cluster"},
+		{Name: "bastions", Role: InstanceGroupRoleBastion, Error: "only supported with a private topology"},
+		{Name: "nodes", Role: InstanceGroupRoleNode, MaxPrice: "0.05"},
+		{Name: "nodes", Role: InstanceGroupRoleNode, MaxPrice: "cheap", Error: "invalid MaxPrice"},
+		{Name: "master", Role: InstanceGroupRoleMaster, MaxPrice: "0.05", Error: "only supported for Node instance groups"},
+		{Name: "nodes", Role: InstanceGroupRoleNode, Taints: []string{"dedicated=gpu:NoSchedule", "spot:PreferNoSchedule"}},
+		{Name: "nodes", Role: InstanceGroupRoleNode, Taints: []string{"dedicated=gpu"}, Error: "invalid Taint"},
+		{Name: "nodes", Role: InstanceGroupRoleNode, Taints: []string{"=gpu:NoSchedule"}, Error: "invalid Taint"},
+		{Name: "nodes", Role: InstanceGroupRoleNode, CloudLabels: map[string]string{"team": "ml"}},
+		{Name: "nodes", Role: InstanceGroupRoleNode, CloudLabels: map[string]string{"KubernetesCluster": "other"}, Error: "reserved"},
+	}
+	for _, g := range grid {
+		group := &InstanceGroup{}
+		group.Name = g.Name
+		group.Spec.Role = g.Role
+		group.Spec.MinSize = g.MinSize
+		group.Spec.MaxSize = g.MaxSize
+		group.Spec.Zones = g.Zones
+		if g.MaxPrice != "" {
+			group.Spec.MaxPrice = &g.MaxPrice
+		}
+		group.Spec.Taints = g.Taints
+		group.Spec.CloudLabels = g.CloudLabels
+
+		err := group.Validate(cluster)
+		if g.Error == "" {
+			if err != nil {
+				t.Errorf("unexpected error validating %+v: %v", g, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), g.Error) {
+			t.Errorf("expected error containing %q validating %+v, got %v", g.Error, g, err)
+		}
+	}
+}
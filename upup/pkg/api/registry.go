@@ -11,36 +11,112 @@ import (
 
 func WriteConfig(stateStore fi.StateStore, cluster *Cluster, groups []*InstanceGroup) error {
 	// Check for instancegroup Name duplicates before writing
-	{
-		names := map[string]bool{}
-		for i, ns := range groups {
-			if ns.Name == "" {
-				return fmt.Errorf("InstanceGroup #%d did not have a Name", i+1)
-			}
-			if names[ns.Name] {
-				return fmt.Errorf("Duplicate InstanceGroup Name found: %q", ns.Name)
-			}
-			names[ns.Name] = true
-		}
+	err := validateInstanceGroupNames(groups)
+	if err != nil {
+		return err
 	}
 	if cluster.CreationTimestamp.IsZero() {
 		cluster.CreationTimestamp = unversioned.NewTime(time.Now().UTC())
 	}
-	err := stateStore.WriteConfig("config", cluster)
+	err = stateStore.WriteConfig("config", cluster)
 	if err != nil {
 		return fmt.Errorf("error writing updated cluster configuration: %v", err)
 	}
 
 	for _, ns := range groups {
-		if ns.CreationTimestamp.IsZero() {
-			ns.CreationTimestamp = unversioned.NewTime(time.Now().UTC())
+		err = writeInstanceGroup(stateStore, ns)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateInstanceGroupNames checks that every InstanceGroup has a Name, and that the Names are unique
+func validateInstanceGroupNames(groups []*InstanceGroup) error {
+	names := map[string]bool{}
+	for i, ns := range groups {
+		if ns.Name == "" {
+			return fmt.Errorf("InstanceGroup #%d did not have a Name", i+1)
+		}
+		if names[ns.Name] {
+			return fmt.Errorf("Duplicate InstanceGroup Name found: %q", ns.Name)
 		}
-		err = stateStore.WriteConfig("instancegroup/"+ns.Name, ns)
+		names[ns.Name] = true
+	}
+	return nil
+}
+
+func writeInstanceGroup(stateStore fi.StateStore, group *InstanceGroup) error {
+	if group.CreationTimestamp.IsZero() {
+		group.CreationTimestamp = unversioned.NewTime(time.Now().UTC())
+	}
+	err := stateStore.WriteConfig("instancegroup/"+group.Name, group)
+	if err != nil {
+		return fmt.Errorf("error writing updated instancegroup configuration: %v", err)
+	}
+	return nil
+}
+
+// ReadInstanceGroup reads a single InstanceGroup, returning nil if it is not found
+func ReadInstanceGroup(stateStore fi.StateStore, name string) (*InstanceGroup, error) {
+	names, err := stateStore.ListChildren("instancegroup")
+	if err != nil {
+		return nil, fmt.Errorf("error listing instancegroups in state store: %v", err)
+	}
+	for _, n := range names {
+		if n != name {
+			continue
+		}
+		group := &InstanceGroup{}
+		err = stateStore.ReadConfig("instancegroup/"+name, group)
 		if err != nil {
-			return fmt.Errorf("error writing updated instancegroup configuration: %v", err)
+			return nil, fmt.Errorf("error reading instancegroup configuration %q: %v", name, err)
 		}
+		return group, nil
+	}
+	return nil, nil
+}
+
+// CreateInstanceGroup writes a new InstanceGroup, checking that its Name is not already in use
+func CreateInstanceGroup(stateStore fi.StateStore, group *InstanceGroup) error {
+	_, groups, err := ReadConfig(stateStore)
+	if err != nil {
+		return err
+	}
+	err = validateInstanceGroupNames(append(groups, group))
+	if err != nil {
+		return err
+	}
+	return writeInstanceGroup(stateStore, group)
+}
+
+// UpdateInstanceGroup writes an existing InstanceGroup
+func UpdateInstanceGroup(stateStore fi.StateStore, group *InstanceGroup) error {
+	existing, err := ReadInstanceGroup(stateStore, group.Name)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return fmt.Errorf("InstanceGroup %q not found", group.Name)
 	}
+	return writeInstanceGroup(stateStore, group)
+}
 
+// DeleteInstanceGroup removes an InstanceGroup from the state store; it does not delete any cloud resources
+func DeleteInstanceGroup(stateStore fi.StateStore, name string) error {
+	existing, err := ReadInstanceGroup(stateStore, name)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return fmt.Errorf("InstanceGroup %q not found", name)
+	}
+	err = stateStore.DeleteConfig("instancegroup/" + name)
+	if err != nil {
+		return fmt.Errorf("error deleting instancegroup configuration %q: %v", name, err)
+	}
 	return nil
 }
 
@@ -79,7 +155,7 @@ func DeleteConfig(stateStore fi.StateStore) error {
 		if err != nil {
 			return err
 		}
-		if relativePath == "config" || relativePath == "cluster.spec" {
+		if relativePath == "config" || relativePath == "cluster.spec" || relativePath == fi.PathEncryption {
 			continue
 		}
 		if strings.HasPrefix(relativePath, "pki/") {
@@ -91,6 +167,9 @@ func DeleteConfig(stateStore fi.StateStore) error {
 		if strings.HasPrefix(relativePath, "instancegroup/") {
 			continue
 		}
+		if strings.HasPrefix(relativePath, fi.PathHistory+"/") {
+			continue
+		}
 
 		return fmt.Errorf("refusing to delete: unknown file found: %s", path)
 	}
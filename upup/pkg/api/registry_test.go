@@ -0,0 +1,49 @@
+package api
+
+import (
+	"bytes"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+	"testing"
+)
+
+func TestDeleteConfig_EncryptedStateStore(t *testing.T) {
+	memfs := vfs.NewMemFSContext()
+	stateStore, err := fi.NewVFSStateStore(vfs.NewMemFSPath(memfs, "state"), "delete.example.com", false)
+	if err != nil {
+		t.Fatalf("error building state store: %v", err)
+	}
+
+	cluster := &Cluster{}
+	cluster.Name = "delete.example.com"
+	nodes := &InstanceGroup{}
+	nodes.Name = "nodes"
+	nodes.Spec.Role = InstanceGroupRoleNode
+	if err := WriteConfig(stateStore, cluster, []*InstanceGroup{nodes}); err != nil {
+		t.Fatalf("error writing config: %v", err)
+	}
+	if _, _, err := stateStore.Secrets().GetOrCreateSecret("admin"); err != nil {
+		t.Fatalf("error creating secret: %v", err)
+	}
+
+	key, err := fi.NewLocalKeyProvider(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatalf("error building local provider: %v", err)
+	}
+	config := &fi.EncryptionConfig{Provider: fi.KeyProviderLocal, KeyFingerprint: key.Fingerprint()}
+	if _, err := fi.ReEncryptStateStore(stateStore.VFSPath(), nil, config, key); err != nil {
+		t.Fatalf("error encrypting state store: %v", err)
+	}
+
+	if err := DeleteConfig(stateStore); err != nil {
+		t.Fatalf("error deleting config: %v", err)
+	}
+
+	paths, err := stateStore.VFSPath().ReadTree()
+	if err != nil {
+		t.Fatalf("error listing state store: %v", err)
+	}
+	if len(paths) != 0 {
+		t.Fatalf("expected state store to be empty after delete, found %v", paths)
+	}
+}
@@ -165,6 +165,124 @@ func (c *Cluster) Validate() error {
 		}
 	}
 
+	// Check the topology
+	if c.Spec.Topology != nil {
+		switch c.Spec.Topology.Type {
+		case "", TopologyPublic:
+		case TopologyPrivate:
+			if c.Spec.CloudProvider != "" && c.Spec.CloudProvider != "aws" {
+				return fmt.Errorf("Topology %q is not supported on CloudProvider %q", c.Spec.Topology.Type, c.Spec.CloudProvider)
+			}
+		default:
+			return fmt.Errorf("Topology had an invalid Type: %q (expected public or private)", c.Spec.Topology.Type)
+		}
+	}
+
+	// Check the utility subnets
+	if c.IsTopologyPrivate() {
+		var cidrs []*net.IPNet
+		for _, z := range c.Spec.Zones {
+			_, zoneCIDR, err := net.ParseCIDR(z.CIDR)
+			if err != nil {
+				return fmt.Errorf("Zone %q had an invalid CIDR: %q", z.Name, z.CIDR)
+			}
+			cidrs = append(cidrs, zoneCIDR)
+		}
+
+		for _, z := range c.Spec.Zones {
+			if z.UtilityCIDR == "" {
+				return fmt.Errorf("Zone %q did not have a UtilityCIDR set", z.Name)
+			}
+
+			_, utilityCIDR, err := net.ParseCIDR(z.UtilityCIDR)
+			if err != nil {
+				return fmt.Errorf("Zone %q had an invalid UtilityCIDR: %q", z.Name, z.UtilityCIDR)
+			}
+
+			if !isSubnet(networkCIDR, utilityCIDR) {
+				return fmt.Errorf("Zone %q had a UtilityCIDR %q that was not a subnet of the NetworkCIDR %q", z.Name, z.UtilityCIDR, c.Spec.NetworkCIDR)
+			}
+
+			for _, other := range cidrs {
+				if subnetsOverlap(utilityCIDR, other) {
+					return fmt.Errorf("Zone %q had a UtilityCIDR %q that overlaps with zone CIDR %q", z.Name, z.UtilityCIDR, other)
+				}
+			}
+			cidrs = append(cidrs, utilityCIDR)
+		}
+	}
+
+	if c.UseAPILoadBalancer() {
+		if c.Spec.CloudProvider != "" && c.Spec.CloudProvider != "aws" {
+			return fmt.Errorf("APILoadBalancer is not supported on CloudProvider %q", c.Spec.CloudProvider)
+		}
+	} else if c.IsTopologyPrivate() {
+		return fmt.Errorf("APILoadBalancer is required with a private topology, as the masters do not have public IPs")
+	}
+
+	if err := c.validateNetworking(); err != nil {
+		return err
+	}
+
+	// Check the access lists
+	if err := validateAccessCIDRs("SSHAccess", c.Spec.SSHAccess); err != nil {
+		return err
+	}
+	if err := validateAccessCIDRs("KubernetesAPIAccess", c.Spec.KubernetesAPIAccess); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateNetworking checks that exactly one networking provider is set, and that it supports the topology
+func (c *Cluster) validateNetworking() error {
+	if c.Spec.Networking == nil {
+		return nil
+	}
+
+	count := 0
+	if c.Spec.Networking.Kubenet != nil {
+		count++
+	}
+	if c.Spec.Networking.External != nil {
+		count++
+	}
+	if c.Spec.Networking.Weave != nil {
+		count++
+	}
+	if count != 1 {
+		return fmt.Errorf("Networking must specify exactly one of kubenet, external or weave")
+	}
+
+	// kubenet relies on the kubernetes route controller, which only manages a single route table for the cluster;
+	// a private topology has a route table per zone, so pod routes would be missing from the private subnets
+	if c.Spec.Networking.Kubenet != nil && c.IsTopologyPrivate() {
+		return fmt.Errorf("kubenet networking is not supported with a private topology; use external or weave networking")
+	}
+	return nil
+}
+
+// validateAccessCIDRs checks that the CIDRs are valid IPv4 CIDRs, written in canonical form
+// (the cloud normalizes them, so otherwise we would never find the rules we created), without duplicates
+func validateAccessCIDRs(field string, cidrs []string) error {
+	seen := make(map[string]bool)
+	for _, cidr := range cidrs {
+		ip, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("%s contained an invalid CIDR: %q", field, cidr)
+		}
+		if ip.To4() == nil {
+			return fmt.Errorf("%s contained a CIDR that is not IPv4: %q", field, cidr)
+		}
+		if ipNet.String() != cidr {
+			return fmt.Errorf("%s contained CIDR %q, which should be written as %q", field, cidr, ipNet.String())
+		}
+		if seen[cidr] {
+			return fmt.Errorf("%s contained a duplicate CIDR: %q", field, cidr)
+		}
+		seen[cidr] = true
+	}
 	return nil
 }
 
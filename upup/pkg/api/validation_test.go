@@ -0,0 +1,61 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateAccessCIDRs(t *testing.T) {
+	grid := []struct {
+		CIDRs []string
+		Error string
+	}{
+		{CIDRs: []string{"0.0.0.0/0"}},
+		{CIDRs: []string{"10.0.0.0/8", "192.168.1.1/32"}},
+		{CIDRs: []string{"10.0.0.0"}, Error: "invalid CIDR"},
+		{CIDRs: []string{"10.1.2.3/8"}, Error: "should be written as \"10.0.0.0/8\""},
+		{CIDRs: []string{"2001:db8::/32"}, Error: "not IPv4"},
+		{CIDRs: []string{"10.0.0.0/8", "10.0.0.0/8"}, Error: "duplicate"},
+	}
+	for _, g := range grid {
+		err := validateAccessCIDRs("SSHAccess", g.CIDRs)
+		if g.Error == "" {
+			if err != nil {
+				t.Errorf("unexpected error validating %v: %v", g.CIDRs, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), g.Error) {
+			t.Errorf("expected error containing %q validating %v, got %v", g.Error, g.CIDRs, err)
+		}
+	}
+}
+
+func TestValidateNetworking(t *testing.T) {
+	grid := []struct {
+		Topology   string
+		Networking *NetworkingSpec
+		Error      string
+	}{
+		{Topology: TopologyPublic, Networking: &NetworkingSpec{Kubenet: &KubenetNetworkingSpec{}}},
+		{Topology: TopologyPrivate, Networking: &NetworkingSpec{Weave: &WeaveNetworkingSpec{}}},
+		{Topology: TopologyPrivate, Networking: &NetworkingSpec{External: &ExternalNetworkingSpec{}}},
+		{Topology: TopologyPrivate, Networking: &NetworkingSpec{Kubenet: &KubenetNetworkingSpec{}}, Error: "not supported with a private topology"},
+		{Topology: TopologyPublic, Networking: &NetworkingSpec{}, Error: "exactly one"},
+	}
+	for _, g := range grid {
+		cluster := &Cluster{}
+		cluster.Spec.Topology = &TopologySpec{Type: g.Topology}
+		cluster.Spec.Networking = g.Networking
+		err := cluster.validateNetworking()
+		if g.Error == "" {
+			if err != nil {
+				t.Errorf("unexpected error validating %s networking %v: %v", g.Topology, g.Networking, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), g.Error) {
+			t.Errorf("expected error containing %q validating %s networking %v, got %v", g.Error, g.Topology, g.Networking, err)
+		}
+	}
+}
@@ -87,6 +87,16 @@ type CAStore interface {
 
 	// AddCert adds an alternative certificate to the pool (primarily useful for CAs)
 	AddCert(id string, cert *Certificate) error
+	// ImportKeypair stores an existing certificate and private key as the primary keypair
+	ImportKeypair(id string, cert *Certificate, privateKey *PrivateKey) error
+
+	// FindRotation returns the rotation in progress for the keypair, or nil if there is none
+	FindRotation(id string) (*KeyRotation, error)
+	// RotateKeypair advances the rotation of the keypair by one phase; it returns nil when the rotation is complete
+	RotateKeypair(id string) (*KeyRotation, error)
+
+	// RenewKeypair issues a new certificate for the keypair, with the same subject and alternate names
+	RenewKeypair(id string) (*Certificate, error)
 }
 
 func (c *Certificate) AsString() (string, error) {
@@ -332,17 +342,26 @@ func (c *CertificatePool) AsString() (string, error) {
 	}
 
 	var data bytes.Buffer
+	_, err := c.WriteTo(&data)
+	if err != nil {
+		return "", err
+	}
+	return data.String(), nil
+}
+
+// WriteTo writes all the certificates in the pool, primary first, as a PEM bundle
+func (c *CertificatePool) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	certs := c.Secondary
 	if c.Primary != nil {
-		_, err := c.Primary.WriteTo(&data)
-		if err != nil {
-			return "", fmt.Errorf("error writing SSL certificate: %v", err)
-		}
+		certs = append([]*Certificate{c.Primary}, certs...)
 	}
-	for _, cert := range c.Secondary {
-		_, err := cert.WriteTo(&data)
+	for _, cert := range certs {
+		n, err := cert.WriteTo(w)
+		total += n
 		if err != nil {
-			return "", fmt.Errorf("error writing SSL certificate: %v", err)
+			return total, fmt.Errorf("error writing SSL certificate: %v", err)
 		}
 	}
-	return data.String(), nil
+	return total, nil
 }
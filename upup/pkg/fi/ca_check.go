@@ -0,0 +1,113 @@
+package fi
+
+import (
+	"crypto/x509"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// CertificateStatus reports on the validity of the primary certificate of a keypair
+type CertificateStatus struct {
+	ID             string    `json:"id"`
+	Subject        string    `json:"subject"`
+	Issuer         string    `json:"issuer"`
+	AlternateNames []string  `json:"alternateNames,omitempty"`
+	IsCA           bool      `json:"ca"`
+	NotBefore      time.Time `json:"notBefore"`
+	NotAfter       time.Time `json:"notAfter"`
+
+	// ChainError is set if the certificate does not verify against the CA certificates
+	ChainError string `json:"chainError,omitempty"`
+	// ExpiresSoon is true if the certificate has expired, or will expire within the threshold
+	ExpiresSoon bool `json:"expiresSoon"`
+}
+
+// NeedsAttention returns true if the certificate is expiring or does not chain to the CA
+func (s *CertificateStatus) NeedsAttention() bool {
+	return s.ExpiresSoon || s.ChainError != ""
+}
+
+// CheckCertificates reports on the primary certificate of every keypair in the CA store,
+// flagging those that expire before now + threshold
+func CheckCertificates(caStore CAStore, now time.Time, threshold time.Duration) ([]*CertificateStatus, error) {
+	ids, err := caStore.List()
+	if err != nil {
+		return nil, fmt.Errorf("error listing CA store items: %v", err)
+	}
+	sort.Strings(ids)
+
+	caPool, err := caStore.CertificatePool(CertificateId_CA)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CA certificates: %v", err)
+	}
+	roots := x509.NewCertPool()
+	if caPool.Primary != nil {
+		roots.AddCert(caPool.Primary.Certificate)
+	}
+	for _, ca := range caPool.Secondary {
+		roots.AddCert(ca.Certificate)
+	}
+
+	var statuses []*CertificateStatus
+	for _, id := range ids {
+		cert, err := caStore.FindCert(id)
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving cert %q: %v", id, err)
+		}
+		if cert == nil {
+			continue
+		}
+
+		s := &CertificateStatus{
+			ID:          id,
+			Subject:     cert.Certificate.Subject.CommonName,
+			Issuer:      cert.Certificate.Issuer.CommonName,
+			IsCA:        cert.IsCA,
+			NotBefore:   cert.Certificate.NotBefore,
+			NotAfter:    cert.Certificate.NotAfter,
+			ExpiresSoon: now.Add(threshold).After(cert.Certificate.NotAfter),
+		}
+		s.AlternateNames = append(s.AlternateNames, cert.Certificate.DNSNames...)
+		s.AlternateNames = append(s.AlternateNames, cert.Certificate.EmailAddresses...)
+		for _, ip := range cert.Certificate.IPAddresses {
+			s.AlternateNames = append(s.AlternateNames, ip.String())
+		}
+		sort.Strings(s.AlternateNames)
+
+		// We verify as of NotBefore, so that expiry is reported separately from a broken chain
+		opts := x509.VerifyOptions{
+			Roots:       roots,
+			CurrentTime: cert.Certificate.NotBefore,
+			KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		}
+		if _, err := cert.Certificate.Verify(opts); err != nil {
+			s.ChainError = err.Error()
+		}
+
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// RenewKeypair issues a new certificate (and private key) for the keypair, with the same subject and alternate names,
+// signed by the primary CA.  The existing certificate remains in the pool until it is removed.
+func (c *VFSCAStore) RenewKeypair(id string) (*Certificate, error) {
+	if id == CertificateId_CA {
+		return nil, fmt.Errorf("the CA cannot be renewed; use kops rotate ca instead")
+	}
+
+	cert, err := c.FindCert(id)
+	if err != nil {
+		return nil, err
+	}
+	if cert == nil {
+		return nil, fmt.Errorf("keypair %q not found", id)
+	}
+
+	err = c.reissueKeypair(id, cert)
+	if err != nil {
+		return nil, err
+	}
+	return c.FindCert(id)
+}
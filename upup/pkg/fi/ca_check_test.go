@@ -0,0 +1,60 @@
+package fi
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+	"testing"
+	"time"
+)
+
+func TestCheckCertificates_Renew(t *testing.T) {
+	store, err := NewVFSCAStore(vfs.NewMemFSPath(vfs.NewMemFSContext(), "pki"), false, nil)
+	if err != nil {
+		t.Fatalf("error building CA store: %v", err)
+	}
+
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "kubernetes-master"},
+		DNSNames:              []string{"api.example.com"},
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		BasicConstraintsValid: true,
+	}
+	if _, _, err := store.CreateKeypair("master", template); err != nil {
+		t.Fatalf("error creating keypair: %v", err)
+	}
+
+	statuses, err := CheckCertificates(store, time.Now(), 7*24*time.Hour)
+	if err != nil {
+		t.Fatalf("error checking certificates: %v", err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("expected statuses for ca and master, got %d", len(statuses))
+	}
+	for _, s := range statuses {
+		if s.ChainError != "" {
+			t.Fatalf("unexpected chain error for %q: %s", s.ID, s.ChainError)
+		}
+		if s.ExpiresSoon != (s.ID == "master") {
+			t.Fatalf("unexpected expiry status for %q: %v", s.ID, s.ExpiresSoon)
+		}
+	}
+
+	renewed, err := store.RenewKeypair("master")
+	if err != nil {
+		t.Fatalf("error renewing keypair: %v", err)
+	}
+	if renewed.Subject.CommonName != "kubernetes-master" || len(renewed.Certificate.DNSNames) != 1 || renewed.Certificate.DNSNames[0] != "api.example.com" {
+		t.Fatalf("renewed certificate did not keep subject and alternate names: %v %v", renewed.Subject, renewed.Certificate.DNSNames)
+	}
+
+	statuses, err = CheckCertificates(store, time.Now(), 7*24*time.Hour)
+	if err != nil {
+		t.Fatalf("error checking certificates: %v", err)
+	}
+	for _, s := range statuses {
+		if s.NeedsAttention() {
+			t.Fatalf("expected %q to be valid after renewal: %v", s.ID, s)
+		}
+	}
+}
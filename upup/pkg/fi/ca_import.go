@@ -0,0 +1,119 @@
+package fi
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"github.com/golang/glog"
+)
+
+// ValidateKeypair checks that the private key is the key for the certificate
+func ValidateKeypair(cert *Certificate, privateKey *PrivateKey) error {
+	if cert == nil || cert.Certificate == nil {
+		return fmt.Errorf("certificate is required")
+	}
+	if privateKey == nil || privateKey.Key == nil {
+		return fmt.Errorf("private key is required")
+	}
+
+	rsaPrivateKey, ok := privateKey.Key.(*rsa.PrivateKey)
+	if !ok {
+		return fmt.Errorf("unsupported private key type %T (only RSA keys are supported)", privateKey.Key)
+	}
+	rsaPublicKey, ok := cert.Certificate.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("unsupported certificate public key type %T (only RSA keys are supported)", cert.Certificate.PublicKey)
+	}
+	if rsaPublicKey.N.Cmp(rsaPrivateKey.N) != 0 || rsaPublicKey.E != rsaPrivateKey.E {
+		return fmt.Errorf("private key does not match certificate")
+	}
+	return nil
+}
+
+// ImportKeypair stores an existing certificate and private key, which become the primary keypair for the id.
+// A certificate must be signed by one of our CA certificates; a CA may be a root or an intermediate.
+// When the CA is replaced, the previous CA certificates remain in the pool, so existing certificates stay trusted.
+func (c *VFSCAStore) ImportKeypair(id string, cert *Certificate, privateKey *PrivateKey) error {
+	if err := ValidateKeypair(cert, privateKey); err != nil {
+		return err
+	}
+
+	if id == CertificateId_CA {
+		if !cert.Certificate.IsCA || !cert.Certificate.BasicConstraintsValid {
+			return fmt.Errorf("certificate is not a CA certificate")
+		}
+	} else {
+		if err := c.verifyChain(cert); err != nil {
+			return err
+		}
+	}
+
+	glog.Infof("Importing keypair %q", id)
+
+	serial := c.buildSerial()
+	if id == CertificateId_CA && c.caCertificates != nil {
+		// The primary CA is the one with the highest serial, so check before we store anything
+		for _, s := range c.caCertificates.serials() {
+			existing, err := parseSerial(s)
+			if err != nil {
+				return err
+			}
+			if existing.Cmp(serial) >= 0 {
+				return fmt.Errorf("imported CA would not become the primary CA (existing CA serial %s is not older)", s)
+			}
+		}
+	}
+
+	err := c.storePrivateKey(privateKey, c.buildPrivateKeyPath(id, serial))
+	if err != nil {
+		return err
+	}
+	err = c.storeCertificate(cert, c.buildCertificatePath(id, serial))
+	if err != nil {
+		c.removeImportedSerial(id, serial.Text(10))
+		return err
+	}
+
+	if id == CertificateId_CA {
+		err = c.loadCA()
+		if err == nil && c.caCertificates.primary != serial.Text(10) {
+			err = fmt.Errorf("imported CA did not become the primary CA")
+		}
+		if err != nil {
+			c.removeImportedSerial(id, serial.Text(10))
+			if loadErr := c.loadCA(); loadErr != nil {
+				glog.Warningf("error reloading CA after failed import: %v", loadErr)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// removeImportedSerial removes a partially imported keypair; failures are only logged,
+// because we are already returning the error that caused the rollback
+func (c *VFSCAStore) removeImportedSerial(id string, serial string) {
+	if err := c.removeSerials(id, []string{serial}); err != nil {
+		glog.Warningf("error removing partially imported keypair %q: %v", id, err)
+	}
+}
+
+// verifyChain checks that the certificate is signed by one of our CA certificates
+func (c *VFSCAStore) verifyChain(cert *Certificate) error {
+	if c.caCertificates == nil {
+		return fmt.Errorf("CA certificate not found")
+	}
+
+	roots := x509.NewCertPool()
+	for _, ca := range c.caCertificates.certificates {
+		roots.AddCert(ca.Certificate)
+	}
+	opts := x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	if _, err := cert.Certificate.Verify(opts); err != nil {
+		return fmt.Errorf("certificate is not signed by the cluster CA (import the issuing CA with kops secrets create ca first): %v", err)
+	}
+	return nil
+}
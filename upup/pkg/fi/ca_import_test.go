@@ -0,0 +1,133 @@
+package fi
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+	"testing"
+	"time"
+)
+
+func generateTestKey(t *testing.T) *PrivateKey {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	return &PrivateKey{Key: rsaKey}
+}
+
+func TestImportKeypair(t *testing.T) {
+	store, err := NewVFSCAStore(vfs.NewMemFSPath(vfs.NewMemFSContext(), "pki"), false, nil)
+	if err != nil {
+		t.Fatalf("error building CA store: %v", err)
+	}
+
+	// A corporate CA, which is not yet trusted
+	corporateKey := generateTestKey(t)
+	corporateCA, err := SignNewCertificate(corporateKey, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "corporate-ca"},
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("error creating CA: %v", err)
+	}
+
+	masterKey := generateTestKey(t)
+	master, err := SignNewCertificate(masterKey, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "kubernetes-master"},
+		DNSNames:              []string{"api.example.com"},
+		BasicConstraintsValid: true,
+	}, corporateCA.Certificate, corporateKey)
+	if err != nil {
+		t.Fatalf("error creating certificate: %v", err)
+	}
+
+	if err := store.ImportKeypair("master", master, generateTestKey(t)); err == nil {
+		t.Fatalf("expected error importing a certificate with the wrong key")
+	}
+	if err := store.ImportKeypair("master", master, masterKey); err == nil {
+		t.Fatalf("expected error importing a certificate not signed by the CA")
+	}
+
+	if err := store.ImportKeypair(CertificateId_CA, corporateCA, corporateKey); err != nil {
+		t.Fatalf("error importing CA: %v", err)
+	}
+	if err := store.ImportKeypair("master", master, masterKey); err != nil {
+		t.Fatalf("error importing certificate: %v", err)
+	}
+
+	cert, err := store.Cert("master")
+	if err != nil {
+		t.Fatalf("error reading certificate: %v", err)
+	}
+	if !cert.Certificate.Equal(master.Certificate) {
+		t.Fatalf("imported certificate was not the primary certificate")
+	}
+
+	// New certificates are signed by the imported CA
+	issued, _, err := store.CreateKeypair("kubecfg", &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "kubecfg"},
+		BasicConstraintsValid: true,
+	})
+	if err != nil {
+		t.Fatalf("error creating keypair: %v", err)
+	}
+	if err := issued.Certificate.CheckSignatureFrom(corporateCA.Certificate); err != nil {
+		t.Fatalf("expected new certificate to be signed by imported CA: %v", err)
+	}
+}
+
+func TestImportKeypair_CANotPrimary(t *testing.T) {
+	store, err := NewVFSCAStore(vfs.NewMemFSPath(vfs.NewMemFSContext(), "pki"), false, nil)
+	if err != nil {
+		t.Fatalf("error building CA store: %v", err)
+	}
+
+	buildCA := func(name string) (*Certificate, *PrivateKey) {
+		key := generateTestKey(t)
+		cert, err := SignNewCertificate(key, &x509.Certificate{
+			Subject:               pkix.Name{CommonName: name},
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}, nil, nil)
+		if err != nil {
+			t.Fatalf("error creating CA: %v", err)
+		}
+		return cert, key
+	}
+
+	// A CA with a serial from the future stays the primary CA, so an import cannot replace it
+	futureCA, futureKey := buildCA("future-ca")
+	serial := buildSerial(time.Now().Add(24 * time.Hour).UnixNano())
+	if err := store.storePrivateKey(futureKey, store.buildPrivateKeyPath(CertificateId_CA, serial)); err != nil {
+		t.Fatalf("error storing private key: %v", err)
+	}
+	if err := store.storeCertificate(futureCA, store.buildCertificatePath(CertificateId_CA, serial)); err != nil {
+		t.Fatalf("error storing certificate: %v", err)
+	}
+	if err := store.loadCA(); err != nil {
+		t.Fatalf("error loading CA: %v", err)
+	}
+	before, err := store.CertificatePool(CertificateId_CA)
+	if err != nil {
+		t.Fatalf("error reading CA pool: %v", err)
+	}
+
+	importedCA, importedKey := buildCA("corporate-ca")
+	if err := store.ImportKeypair(CertificateId_CA, importedCA, importedKey); err == nil {
+		t.Fatalf("expected error importing a CA that would not become the primary CA")
+	}
+
+	after, err := store.CertificatePool(CertificateId_CA)
+	if err != nil {
+		t.Fatalf("error reading CA pool: %v", err)
+	}
+	if len(after.Secondary) != len(before.Secondary) || !after.Primary.Certificate.Equal(futureCA.Certificate) {
+		t.Fatalf("expected the CA pool to be unchanged after a failed import")
+	}
+}
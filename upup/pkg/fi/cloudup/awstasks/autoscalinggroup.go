@@ -14,6 +14,13 @@ import (
 	"strings"
 )
 
+// TagManagedTags is the tag in which we record the keys of the tags that kops sets on an autoscaling group,
+// so that when a key is removed from the model we only delete tags that we set, and not tags set by someone else
+const TagManagedTags = "k8s.io/kops/managed-tags"
+
+// maxTagValueLength is the maximum length of an autoscaling group tag value
+const maxTagValueLength = 256
+
 //go:generate fitask -type=AutoscalingGroup
 type AutoscalingGroup struct {
 	Name *string
@@ -32,6 +39,12 @@ func (e *AutoscalingGroup) CompareWithID() *string {
 	return e.Name
 }
 
+var _ fi.HasCloudResourceID = &AutoscalingGroup{}
+
+func (e *AutoscalingGroup) CloudResourceID() (string, string) {
+	return "autoscaling-group", fi.StringValue(e.Name)
+}
+
 func findAutoscalingGroup(cloud *awsup.AWSCloud, name string) (*autoscaling.Group, error) {
 	request := &autoscaling.DescribeAutoScalingGroupsInput{
 		AutoScalingGroupNames: []*string{&name},
@@ -89,9 +102,30 @@ func (e *AutoscalingGroup) Find(c *fi.Context) (*AutoscalingGroup, error) {
 	}
 
 	if len(g.Tags) != 0 {
-		actual.Tags = make(map[string]string)
+		allTags := make(map[string]string)
 		for _, tag := range g.Tags {
-			actual.Tags[*tag.Key] = *tag.Value
+			allTags[*tag.Key] = *tag.Value
+		}
+
+		// We only consider the tags we set now, or set previously; other tags belong to someone else
+		managed := make(map[string]bool)
+		for k := range e.Tags {
+			managed[k] = true
+		}
+		for _, k := range parseManagedTags(allTags[TagManagedTags]) {
+			managed[k] = true
+		}
+
+		actual.Tags = make(map[string]string)
+		for k, v := range allTags {
+			if managed[k] {
+				actual.Tags[k] = v
+			}
+		}
+
+		// The record of our tags isn't in the model, so we only report it when it is out of date
+		if allTags[TagManagedTags] != buildManagedTags(e.Tags) {
+			actual.Tags[TagManagedTags] = allTags[TagManagedTags]
 		}
 	}
 
@@ -118,6 +152,9 @@ func (s *AutoscalingGroup) CheckChanges(a, e, changes *AutoscalingGroup) error {
 			return fi.RequiredField("Name")
 		}
 	}
+	if len(buildManagedTags(e.Tags)) > maxTagValueLength {
+		return fmt.Errorf("too many tags on AutoscalingGroup %q: the tag keys must fit in %d characters", fi.StringValue(e.Name), maxTagValueLength)
+	}
 	return nil
 }
 
@@ -126,9 +163,30 @@ func (e *AutoscalingGroup) buildTags(cloud fi.Cloud) map[string]string {
 	for k, v := range e.Tags {
 		tags[k] = v
 	}
+	tags[TagManagedTags] = buildManagedTags(e.Tags)
 	return tags
 }
 
+// buildManagedTags builds the value of the TagManagedTags tag: the sorted keys of the tags, separated by commas
+func buildManagedTags(tags map[string]string) string {
+	var keys []string
+	for k := range tags {
+		if k != TagManagedTags {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+// parseManagedTags parses the value of the TagManagedTags tag
+func parseManagedTags(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
 func (_ *AutoscalingGroup) RenderAWS(t *awsup.AWSAPITarget, a, e, changes *AutoscalingGroup) error {
 	if a == nil {
 		glog.V(2).Infof("Creating autoscaling Group with Name:%q", *e.Name)
@@ -145,16 +203,7 @@ func (_ *AutoscalingGroup) RenderAWS(t *awsup.AWSAPITarget, a, e, changes *Autos
 		}
 		request.VPCZoneIdentifier = aws.String(strings.Join(subnetIDs, ","))
 
-		tags := []*autoscaling.Tag{}
-		for k, v := range e.buildTags(t.Cloud) {
-			tags = append(tags, &autoscaling.Tag{
-				Key:          aws.String(k),
-				Value:        aws.String(v),
-				ResourceId:   e.Name,
-				ResourceType: aws.String("auto-scaling-group"),
-			})
-		}
-		request.Tags = tags
+		request.Tags = e.autoscalingTags(e.buildTags(t.Cloud))
 
 		_, err := t.Cloud.Autoscaling.CreateAutoScalingGroup(request)
 		if err != nil {
@@ -177,6 +226,13 @@ func (_ *AutoscalingGroup) RenderAWS(t *awsup.AWSAPITarget, a, e, changes *Autos
 			request.MaxSize = e.MaxSize
 			changes.MaxSize = nil
 		}
+		if changes.Tags != nil {
+			err := e.updateTags(t.Cloud, a.Tags)
+			if err != nil {
+				return err
+			}
+			changes.Tags = nil
+		}
 		if changes.Subnets != nil {
 			var subnetIDs []string
 			for _, s := range e.Subnets {
@@ -199,11 +255,68 @@ func (_ *AutoscalingGroup) RenderAWS(t *awsup.AWSAPITarget, a, e, changes *Autos
 		}
 	}
 
-	// TODO: Use PropagateAtLaunch = false for tagging?
-
 	return nil // We have
 }
 
+// autoscalingTags builds the ASG tags for the map, which are propagated to the instances (as with terraform)
+func (e *AutoscalingGroup) autoscalingTags(tags map[string]string) []*autoscaling.Tag {
+	var awsTags []*autoscaling.Tag
+	for k, v := range tags {
+		awsTags = append(awsTags, &autoscaling.Tag{
+			Key:               aws.String(k),
+			Value:             aws.String(v),
+			ResourceId:        e.Name,
+			ResourceType:      aws.String("auto-scaling-group"),
+			PropagateAtLaunch: aws.Bool(true),
+		})
+	}
+	return awsTags
+}
+
+// updateTags sets the tags on an existing ASG, removing any that are no longer in the model.
+// actualTags only holds the tags that kops set (see Find), so tags set by others are left alone.
+func (e *AutoscalingGroup) updateTags(cloud *awsup.AWSCloud, actualTags map[string]string) error {
+	expected := e.buildTags(cloud)
+
+	set := make(map[string]string)
+	for k, v := range expected {
+		if actual, found := actualTags[k]; !found || actual != v {
+			set[k] = v
+		}
+	}
+	if len(set) != 0 {
+		glog.V(2).Infof("Setting tags on autoscaling group %s: %v", *e.Name, set)
+		_, err := cloud.Autoscaling.CreateOrUpdateTags(&autoscaling.CreateOrUpdateTagsInput{
+			Tags: e.autoscalingTags(set),
+		})
+		if err != nil {
+			return fmt.Errorf("error setting tags on AutoscalingGroup: %v", err)
+		}
+	}
+
+	var remove []*autoscaling.Tag
+	for k := range actualTags {
+		if _, found := expected[k]; !found {
+			remove = append(remove, &autoscaling.Tag{
+				Key:          aws.String(k),
+				ResourceId:   e.Name,
+				ResourceType: aws.String("auto-scaling-group"),
+			})
+		}
+	}
+	if len(remove) != 0 {
+		glog.V(2).Infof("Removing tags from autoscaling group %s: %v", *e.Name, remove)
+		_, err := cloud.Autoscaling.DeleteTags(&autoscaling.DeleteTagsInput{
+			Tags: remove,
+		})
+		if err != nil {
+			return fmt.Errorf("error removing tags from AutoscalingGroup: %v", err)
+		}
+	}
+
+	return nil
+}
+
 type terraformASGTag struct {
 	Key               *string `json:"key"`
 	Value             *string `json:"value"`
@@ -80,9 +80,21 @@ func (e *DNSName) Find(c *fi.Context) (*DNSName, error) {
 	actual.Name = e.Name
 	actual.ResourceType = e.ResourceType
 
+	// We only report the target if it matches; otherwise the record is UPSERTed to point at the load balancer
+	if found.AliasTarget != nil && e.TargetLoadBalancer != nil {
+		if normalizeDNSName(aws.StringValue(found.AliasTarget.DNSName)) == normalizeDNSName(fi.StringValue(e.TargetLoadBalancer.DNSName)) {
+			actual.TargetLoadBalancer = e.TargetLoadBalancer
+		}
+	}
+
 	return actual, nil
 }
 
+// normalizeDNSName returns the name in lower case without a trailing dot, as route53 returns alias targets
+func normalizeDNSName(s string) string {
+	return strings.ToLower(strings.TrimSuffix(s, "."))
+}
+
 func (e *DNSName) Run(c *fi.Context) error {
 	return fi.DefaultDeltaRunMethod(e, c)
 }
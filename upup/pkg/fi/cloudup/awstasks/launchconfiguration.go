@@ -10,6 +10,7 @@ import (
 	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
 	"k8s.io/kops/upup/pkg/fi/cloudup/terraform"
+	"strconv"
 	"strings"
 )
 
@@ -27,6 +28,9 @@ type LaunchConfiguration struct {
 	BlockDeviceMappings map[string]*BlockDeviceMapping
 	IAMInstanceProfile  *IAMInstanceProfile
 
+	// SpotPrice is set to the max bid for spot instances; if not set we use on-demand instances
+	SpotPrice *string
+
 	ID *string
 }
 
@@ -81,6 +85,10 @@ func (e *LaunchConfiguration) Find(c *fi.Context) (*LaunchConfiguration, error)
 		SSHKey:             &SSHKey{Name: lc.KeyName},
 		AssociatePublicIP:  lc.AssociatePublicIpAddress,
 		IAMInstanceProfile: &IAMInstanceProfile{Name: lc.IamInstanceProfile},
+		SpotPrice:          lc.SpotPrice,
+	}
+	if aws.StringValue(actual.SpotPrice) == "" {
+		actual.SpotPrice = nil
 	}
 
 	securityGroups := []*SecurityGroup{}
@@ -94,11 +102,14 @@ func (e *LaunchConfiguration) Find(c *fi.Context) (*LaunchConfiguration, error)
 		deviceName, bdm := BlockDeviceMappingFromAutoscaling(b)
 		actual.BlockDeviceMappings[deviceName] = bdm
 	}
-	userData, err := base64.StdEncoding.DecodeString(*lc.UserData)
-	if err != nil {
-		return nil, fmt.Errorf("error decoding UserData: %v", err)
+	// UserData is optional (e.g. bastions don't run nodeup)
+	if aws.StringValue(lc.UserData) != "" {
+		userData, err := base64.StdEncoding.DecodeString(*lc.UserData)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding UserData: %v", err)
+		}
+		actual.UserData = fi.WrapResource(fi.NewStringResource(string(userData)))
 	}
-	actual.UserData = fi.WrapResource(fi.NewStringResource(string(userData)))
 
 	// Avoid spurious changes on ImageId
 	if e.ImageID != nil && actual.ImageID != nil && *actual.ImageID != *e.ImageID {
@@ -113,6 +124,15 @@ func (e *LaunchConfiguration) Find(c *fi.Context) (*LaunchConfiguration, error)
 		}
 	}
 
+	// Avoid spurious changes when the price is written differently (e.g. 0.05 vs 0.050)
+	if e.SpotPrice != nil && actual.SpotPrice != nil && *e.SpotPrice != *actual.SpotPrice {
+		expected, err1 := strconv.ParseFloat(*e.SpotPrice, 64)
+		found, err2 := strconv.ParseFloat(*actual.SpotPrice, 64)
+		if err1 == nil && err2 == nil && expected == found {
+			actual.SpotPrice = e.SpotPrice
+		}
+	}
+
 	if e.ID == nil {
 		e.ID = actual.ID
 	}
@@ -193,6 +213,7 @@ func (_ *LaunchConfiguration) RenderAWS(t *awsup.AWSAPITarget, a, e, changes *La
 	}
 	request.SecurityGroups = securityGroupIDs
 	request.AssociatePublicIpAddress = e.AssociatePublicIP
+	request.SpotPrice = e.SpotPrice
 	if e.BlockDeviceMappings != nil {
 		request.BlockDeviceMappings = []*autoscaling.BlockDeviceMapping{}
 		for device, bdm := range e.BlockDeviceMappings {
@@ -229,6 +250,7 @@ type terraformLaunchConfiguration struct {
 	IAMInstanceProfile       *terraform.Literal      `json:"iam_instance_profile,omitempty"`
 	SecurityGroups           []*terraform.Literal    `json:"security_groups,omitempty"`
 	AssociatePublicIpAddress *bool                   `json:"associate_public_ip_address,omitempty"`
+	SpotPrice                *string                 `json:"spot_price,omitempty"`
 	UserData                 *terraform.Literal      `json:"user_data,omitempty"`
 	EphemeralBlockDevice     []*terraformBlockDevice `json:"ephemeral_block_device,omitempty"`
 	Lifecycle                *terraformLifecycle     `json:"lifecycle,omitempty"`
@@ -268,6 +290,7 @@ func (_ *LaunchConfiguration) RenderTerraform(t *terraform.TerraformTarget, a, e
 		tf.SecurityGroups = append(tf.SecurityGroups, sg.TerraformLink())
 	}
 	tf.AssociatePublicIpAddress = e.AssociatePublicIP
+	tf.SpotPrice = e.SpotPrice
 
 	if e.BlockDeviceMappings != nil {
 		tf.EphemeralBlockDevice = []*terraformBlockDevice{}
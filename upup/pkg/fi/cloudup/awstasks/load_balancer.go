@@ -35,6 +35,27 @@ func (e *LoadBalancer) CompareWithID() *string {
 	return e.ID
 }
 
+var _ fi.HasAddress = &LoadBalancer{}
+
+// FindAddress returns the DNS name of the ELB, so that it can be added to the master certificate
+func (e *LoadBalancer) FindAddress(context *fi.Context) (*string, error) {
+	cloud := context.Cloud.(*awsup.AWSCloud)
+
+	elbName := fi.StringValue(e.ID)
+	if elbName == "" {
+		elbName = fi.StringValue(e.Name)
+	}
+
+	lb, err := findELB(cloud, elbName)
+	if err != nil {
+		return nil, err
+	}
+	if lb == nil {
+		return nil, nil
+	}
+	return lb.DNSName, nil
+}
+
 type LoadBalancerListener struct {
 	InstancePort int
 }
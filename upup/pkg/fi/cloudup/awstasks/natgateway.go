@@ -0,0 +1,198 @@
+package awstasks
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/golang/glog"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/upup/pkg/fi/cloudup/terraform"
+)
+
+// NatGatewayAvailableTimeout is how long we wait for a new NAT gateway to become available
+const NatGatewayAvailableTimeout = 10 * time.Minute
+
+//go:generate fitask -type=NatGateway
+type NatGateway struct {
+	Name *string
+	ID   *string
+
+	// Subnet is the (public) subnet in which the NAT gateway is placed
+	Subnet    *Subnet
+	ElasticIP *ElasticIP
+}
+
+var _ fi.CompareWithID = &NatGateway{}
+
+func (e *NatGateway) CompareWithID() *string {
+	return e.ID
+}
+
+func (e *NatGateway) Find(c *fi.Context) (*NatGateway, error) {
+	cloud := c.Cloud.(*awsup.AWSCloud)
+
+	// NAT gateways can't be tagged, so we find them by their elastic IP, which is tagged on the subnet.
+	// Other NAT gateways may share the subnet (in a shared VPC), so the subnet alone is not enough.
+	request := &ec2.DescribeNatGatewaysInput{}
+	allocationID := ""
+	if e.ID != nil {
+		request.NatGatewayIds = []*string{e.ID}
+	} else {
+		if e.Subnet == nil || e.Subnet.ID == nil || e.ElasticIP == nil || e.ElasticIP.ID == nil {
+			return nil, nil
+		}
+		allocationID = *e.ElasticIP.ID
+		request.Filter = []*ec2.Filter{
+			awsup.NewEC2Filter("subnet-id", *e.Subnet.ID),
+			awsup.NewEC2Filter("state", ec2.NatGatewayStatePending, ec2.NatGatewayStateAvailable),
+		}
+	}
+
+	response, err := cloud.EC2.DescribeNatGateways(request)
+	if err != nil {
+		return nil, fmt.Errorf("error listing NatGateways: %v", err)
+	}
+	if response == nil {
+		return nil, nil
+	}
+
+	var matches []*ec2.NatGateway
+	for _, ngw := range response.NatGateways {
+		if allocationID == "" || natGatewayHasAllocation(ngw, allocationID) {
+			matches = append(matches, ngw)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	if len(matches) != 1 {
+		return nil, fmt.Errorf("found multiple NatGateways using elastic IP %q", allocationID)
+	}
+	ngw := matches[0]
+
+	actual := &NatGateway{
+		Name:   e.Name,
+		ID:     ngw.NatGatewayId,
+		Subnet: &Subnet{ID: ngw.SubnetId},
+	}
+	for _, address := range ngw.NatGatewayAddresses {
+		if address.AllocationId != nil {
+			actual.ElasticIP = &ElasticIP{ID: address.AllocationId}
+		}
+	}
+
+	glog.V(2).Infof("found matching NatGateway %q", *actual.ID)
+	e.ID = actual.ID
+
+	return actual, nil
+}
+
+// natGatewayHasAllocation returns true if the NAT gateway uses the elastic IP with the specified allocation id
+func natGatewayHasAllocation(ngw *ec2.NatGateway, allocationID string) bool {
+	for _, address := range ngw.NatGatewayAddresses {
+		if aws.StringValue(address.AllocationId) == allocationID {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *NatGateway) Run(c *fi.Context) error {
+	return fi.DefaultDeltaRunMethod(e, c)
+}
+
+func (s *NatGateway) CheckChanges(a, e, changes *NatGateway) error {
+	if a == nil {
+		if e.Subnet == nil {
+			return fi.RequiredField("Subnet")
+		}
+		if e.ElasticIP == nil {
+			return fi.RequiredField("ElasticIP")
+		}
+	}
+
+	if a != nil {
+		if changes.Subnet != nil {
+			return fi.CannotChangeField("Subnet")
+		}
+		if changes.ElasticIP != nil {
+			return fi.CannotChangeField("ElasticIP")
+		}
+	}
+	return nil
+}
+
+func (_ *NatGateway) RenderAWS(t *awsup.AWSAPITarget, a, e, changes *NatGateway) error {
+	if a == nil {
+		glog.V(2).Infof("Creating NatGateway in subnet %q", fi.StringValue(e.Subnet.ID))
+
+		request := &ec2.CreateNatGatewayInput{
+			SubnetId:     checkNotNil(e.Subnet.ID),
+			AllocationId: checkNotNil(e.ElasticIP.ID),
+		}
+
+		response, err := t.Cloud.EC2.CreateNatGateway(request)
+		if err != nil {
+			return fmt.Errorf("error creating NatGateway: %v", err)
+		}
+
+		e.ID = response.NatGateway.NatGatewayId
+	}
+
+	// Routes to a NAT gateway are blackholed until it is available
+	return waitForNatGatewayAvailable(t.Cloud, *e.ID)
+}
+
+func waitForNatGatewayAvailable(cloud *awsup.AWSCloud, id string) error {
+	deadline := time.Now().Add(NatGatewayAvailableTimeout)
+	for {
+		request := &ec2.DescribeNatGatewaysInput{
+			NatGatewayIds: []*string{aws.String(id)},
+		}
+		response, err := cloud.EC2.DescribeNatGateways(request)
+		if err != nil {
+			return fmt.Errorf("error describing NatGateway %q: %v", id, err)
+		}
+		if len(response.NatGateways) != 1 {
+			return fmt.Errorf("NatGateway %q not found", id)
+		}
+
+		ngw := response.NatGateways[0]
+		state := aws.StringValue(ngw.State)
+		switch state {
+		case ec2.NatGatewayStateAvailable:
+			return nil
+		case ec2.NatGatewayStatePending:
+			// continue waiting
+		default:
+			return fmt.Errorf("NatGateway %q is in unexpected state %q: %s", id, state, aws.StringValue(ngw.FailureMessage))
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timeout waiting for NatGateway %q to become available", id)
+		}
+		glog.V(2).Infof("Waiting for NatGateway %q to become available", id)
+		time.Sleep(10 * time.Second)
+	}
+}
+
+type terraformNatGateway struct {
+	AllocationID *terraform.Literal `json:"allocation_id"`
+	SubnetID     *terraform.Literal `json:"subnet_id"`
+}
+
+func (_ *NatGateway) RenderTerraform(t *terraform.TerraformTarget, a, e, changes *NatGateway) error {
+	tf := &terraformNatGateway{
+		AllocationID: e.ElasticIP.TerraformLink(),
+		SubnetID:     e.Subnet.TerraformLink(),
+	}
+
+	return t.RenderResource("aws_nat_gateway", *e.Name, tf)
+}
+
+func (e *NatGateway) TerraformLink() *terraform.Literal {
+	return terraform.LiteralProperty("aws_nat_gateway", *e.Name, "id")
+}
@@ -17,6 +17,7 @@ type Route struct {
 
 	RouteTable      *RouteTable
 	InternetGateway *InternetGateway
+	NatGateway      *NatGateway
 	Instance        *Instance
 	CIDR            *string
 }
@@ -63,12 +64,16 @@ func (e *Route) Find(c *fi.Context) (*Route, error) {
 			if r.InstanceId != nil {
 				actual.Instance = &Instance{ID: r.InstanceId}
 			}
+			if r.NatGatewayId != nil {
+				actual.NatGateway = &NatGateway{ID: r.NatGatewayId}
+			}
 
 			if aws.StringValue(r.State) == "blackhole" {
 				glog.V(2).Infof("found route is a blackhole route")
 				// These should be nil anyway, but just in case...
 				actual.Instance = nil
 				actual.InternetGateway = nil
+				actual.NatGateway = nil
 			}
 
 			glog.V(2).Infof("found route matching cidr %s", *e.CIDR)
@@ -96,14 +101,17 @@ func (s *Route) CheckChanges(a, e, changes *Route) error {
 		if e.InternetGateway != nil {
 			targetCount++
 		}
+		if e.NatGateway != nil {
+			targetCount++
+		}
 		if e.Instance != nil {
 			targetCount++
 		}
 		if targetCount == 0 {
-			return fmt.Errorf("InternetGateway or Instance is required")
+			return fmt.Errorf("InternetGateway, NatGateway or Instance is required")
 		}
 		if targetCount != 1 {
-			return fmt.Errorf("Cannot set both InternetGateway and Instance")
+			return fmt.Errorf("Can only set one of InternetGateway, NatGateway or Instance")
 		}
 	}
 
@@ -128,6 +136,10 @@ func (_ *Route) RenderAWS(t *awsup.AWSAPITarget, a, e, changes *Route) error {
 			request.GatewayId = checkNotNil(e.InternetGateway.ID)
 		}
 
+		if e.NatGateway != nil {
+			request.NatGatewayId = checkNotNil(e.NatGateway.ID)
+		}
+
 		if e.Instance != nil {
 			request.InstanceId = checkNotNil(e.Instance.ID)
 		}
@@ -151,6 +163,10 @@ func (_ *Route) RenderAWS(t *awsup.AWSAPITarget, a, e, changes *Route) error {
 			request.GatewayId = checkNotNil(e.InternetGateway.ID)
 		}
 
+		if e.NatGateway != nil {
+			request.NatGatewayId = checkNotNil(e.NatGateway.ID)
+		}
+
 		if e.Instance != nil {
 			request.InstanceId = checkNotNil(e.Instance.ID)
 		}
@@ -177,6 +193,7 @@ type terraformRoute struct {
 	RouteTableID      *terraform.Literal `json:"route_table_id"`
 	CIDR              *string            `json:"destination_cidr_block,omitempty"`
 	InternetGatewayID *terraform.Literal `json:"gateway_id,omitempty"`
+	NatGatewayID      *terraform.Literal `json:"nat_gateway_id,omitempty"`
 	InstanceID        *terraform.Literal `json:"instance_id,omitempty"`
 }
 
@@ -190,6 +207,10 @@ func (_ *Route) RenderTerraform(t *terraform.TerraformTarget, a, e, changes *Rou
 		tf.InternetGatewayID = e.InternetGateway.TerraformLink()
 	}
 
+	if e.NatGateway != nil {
+		tf.NatGatewayID = e.NatGateway.TerraformLink()
+	}
+
 	if e.Instance != nil {
 		tf.InstanceID = e.Instance.TerraformLink()
 	}
@@ -3,6 +3,7 @@ package awstasks
 import (
 	"fmt"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/golang/glog"
 	"k8s.io/kops/upup/pkg/fi"
@@ -110,6 +111,131 @@ func (_ *SecurityGroup) RenderAWS(t *awsup.AWSAPITarget, a, e, changes *Security
 	return t.AddAWSTags(*e.ID, t.Cloud.BuildTags(e.Name))
 }
 
+var _ fi.ProducesDeletions = &SecurityGroup{}
+
+// managedAccessPorts are the ports of the CIDR access rules that kops manages (SSH and the kubernetes API)
+var managedAccessPorts = map[int64]bool{22: true, 443: true}
+
+// FindDeletions finds the CIDR ingress rules of the security group that no longer match a SecurityGroupRule,
+// for example when a CIDR is removed from an access list.  Only TCP rules for managedAccessPorts are considered,
+// so rules added by hand for other ports survive.  Egress rules and rules for source groups are left alone:
+// kubernetes adds rules to the node security group for the ELBs of services.
+func (e *SecurityGroup) FindDeletions(c *fi.Context) ([]fi.Deletion, error) {
+	if e.ID == nil {
+		return nil, nil
+	}
+	cloud := c.Cloud.(*awsup.AWSCloud)
+
+	request := &ec2.DescribeSecurityGroupsInput{
+		GroupIds: []*string{e.ID},
+	}
+	response, err := cloud.EC2.DescribeSecurityGroups(request)
+	if err != nil {
+		return nil, fmt.Errorf("error describing SecurityGroup %q: %v", *e.ID, err)
+	}
+	if response == nil || len(response.SecurityGroups) != 1 {
+		return nil, nil
+	}
+	sg := response.SecurityGroups[0]
+
+	var rules []*SecurityGroupRule
+	for _, task := range c.AllTasks() {
+		rule, ok := task.(*SecurityGroupRule)
+		if !ok || fi.BoolValue(rule.Egress) || rule.SecurityGroup == nil {
+			continue
+		}
+		if fi.StringValue(rule.SecurityGroup.ID) == *e.ID {
+			rules = append(rules, rule)
+		}
+	}
+
+	var deletions []fi.Deletion
+	for _, permission := range sg.IpPermissions {
+		if aws.StringValue(permission.IpProtocol) != "tcp" {
+			continue
+		}
+		port := aws.Int64Value(permission.FromPort)
+		if !managedAccessPorts[port] || aws.Int64Value(permission.ToPort) != port {
+			continue
+		}
+
+		// A permission can hold several CIDRs; we consider each separately
+		for _, ipRange := range permission.IpRanges {
+			p := &ec2.IpPermission{
+				IpProtocol: permission.IpProtocol,
+				FromPort:   permission.FromPort,
+				ToPort:     permission.ToPort,
+				IpRanges:   []*ec2.IpRange{ipRange},
+			}
+			if !matchesAnyRule(p, rules) {
+				deletions = append(deletions, &deleteSecurityGroupIngress{GroupID: e.ID, Permission: p})
+			}
+		}
+	}
+	return deletions, nil
+}
+
+// matchesAnyRule checks if the permission (which has a single CIDR) is one of the rules
+func matchesAnyRule(p *ec2.IpPermission, rules []*SecurityGroupRule) bool {
+	for _, rule := range rules {
+		protocol := "-1" // Wildcard
+		if rule.Protocol != nil {
+			protocol = *rule.Protocol
+		}
+		if aws.StringValue(p.IpProtocol) != protocol {
+			continue
+		}
+		if aws.Int64Value(p.FromPort) != aws.Int64Value(rule.FromPort) || aws.Int64Value(p.ToPort) != aws.Int64Value(rule.ToPort) {
+			continue
+		}
+		if rule.CIDR != nil && aws.StringValue(p.IpRanges[0].CidrIp) == *rule.CIDR {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteSecurityGroupIngress revokes an ingress permission that is no longer in the model
+type deleteSecurityGroupIngress struct {
+	GroupID    *string
+	Permission *ec2.IpPermission
+}
+
+var _ fi.Deletion = &deleteSecurityGroupIngress{}
+
+func (d *deleteSecurityGroupIngress) TaskName() string {
+	return "security-group-rule"
+}
+
+func (d *deleteSecurityGroupIngress) Item() string {
+	p := d.Permission
+	s := aws.StringValue(d.GroupID) + ":" + aws.StringValue(p.IpProtocol)
+	if p.FromPort != nil || p.ToPort != nil {
+		s += fmt.Sprintf(":%d-%d", aws.Int64Value(p.FromPort), aws.Int64Value(p.ToPort))
+	}
+	for _, ipRange := range p.IpRanges {
+		s += ":" + aws.StringValue(ipRange.CidrIp)
+	}
+	return s
+}
+
+func (d *deleteSecurityGroupIngress) Delete(t fi.Target) error {
+	awsTarget, ok := t.(*awsup.AWSAPITarget)
+	if !ok {
+		return fmt.Errorf("unexpected target type for deletion: %T", t)
+	}
+
+	request := &ec2.RevokeSecurityGroupIngressInput{
+		GroupId:       d.GroupID,
+		IpPermissions: []*ec2.IpPermission{d.Permission},
+	}
+	_, err := awsTarget.Cloud.EC2.RevokeSecurityGroupIngress(request)
+	if err != nil {
+		return fmt.Errorf("error revoking SecurityGroupIngress: %v", err)
+	}
+	return nil
+}
+
 type terraformSecurityGroup struct {
 	Name        *string            `json:"name"`
 	VPCID       *terraform.Literal `json:"vpc_id"`
@@ -26,9 +26,34 @@ func (e *Subnet) CompareWithID() *string {
 	return e.ID
 }
 
+var _ fi.HasCloudResourceID = &Subnet{}
+
+func (e *Subnet) CloudResourceID() (string, string) {
+	return "subnet", fi.StringValue(e.ID)
+}
+
+var _ TaggableResource = &Subnet{}
+
+func (e *Subnet) FindResourceID(c fi.Cloud) (*string, error) {
+	actual, err := e.find(c.(*awsup.AWSCloud))
+	if err != nil {
+		return nil, fmt.Errorf("error querying for Subnet: %v", err)
+	}
+	if actual == nil {
+		return nil, nil
+	}
+	return actual.ID, nil
+}
+
 func (e *Subnet) Find(c *fi.Context) (*Subnet, error) {
-	cloud := c.Cloud.(*awsup.AWSCloud)
+	actual, err := e.find(c.Cloud.(*awsup.AWSCloud))
+	if actual != nil && err == nil {
+		e.ID = actual.ID
+	}
+	return actual, err
+}
 
+func (e *Subnet) find(cloud *awsup.AWSCloud) (*Subnet, error) {
 	request := &ec2.DescribeSubnetsInput{}
 	if e.ID != nil {
 		request.SubnetIds = []*string{e.ID}
@@ -58,7 +83,6 @@ func (e *Subnet) Find(c *fi.Context) (*Subnet, error) {
 	}
 
 	glog.V(2).Infof("found matching subnet %q", *actual.ID)
-	e.ID = actual.ID
 
 	return actual, nil
 }
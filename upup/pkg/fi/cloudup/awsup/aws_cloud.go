@@ -6,10 +6,15 @@ import (
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elb/elbiface"
 	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
 	"github.com/golang/glog"
 	"k8s.io/kops/upup/pkg/fi"
 	"strings"
@@ -22,11 +27,11 @@ const MaxCreateTagsAttempts = 60
 const TagClusterName = "KubernetesCluster"
 
 type AWSCloud struct {
-	EC2         *ec2.EC2
-	IAM         *iam.IAM
-	ELB         *elb.ELB
-	Autoscaling *autoscaling.AutoScaling
-	Route53     *route53.Route53
+	EC2         ec2iface.EC2API
+	IAM         iamiface.IAMAPI
+	ELB         elbiface.ELBAPI
+	Autoscaling autoscalingiface.AutoScalingAPI
+	Route53     route53iface.Route53API
 
 	Region string
 
@@ -65,6 +70,16 @@ func NewEC2Filter(name string, values ...string) *ec2.Filter {
 	return filter
 }
 
+// WithTags returns a copy of the cloud, sharing the service clients, which uses the specified tags
+func (c *AWSCloud) WithTags(tags map[string]string) *AWSCloud {
+	clone := *c
+	clone.tags = make(map[string]string)
+	for k, v := range tags {
+		clone.tags[k] = v
+	}
+	return &clone
+}
+
 func (c *AWSCloud) Tags() map[string]string {
 	// Defensive copy
 	tags := make(map[string]string)
@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"github.com/golang/glog"
+	"io"
 	"io/ioutil"
 	"k8s.io/kops/upup/pkg/api"
 	"k8s.io/kops/upup/pkg/fi"
@@ -17,15 +18,27 @@ import (
 	"k8s.io/kops/upup/pkg/fi/loader"
 	"k8s.io/kops/upup/pkg/fi/utils"
 	"k8s.io/kops/upup/pkg/fi/vfs"
+	"k8s.io/kops/upup/pkg/kutil"
 	"net"
 	"os"
 	"path"
+	"sort"
 	"strings"
+	"time"
 )
 
+// DefaultCNIAsset holds the standard CNI plugins (bridge, host-local, loopback etc), installed when the networking uses CNI
+const DefaultCNIAsset = "https://storage.googleapis.com/kubernetes-release/network-plugins/cni-8a936732094c0941e1543ef5d292a1f4fffa1ac5.tar.gz"
+
 const DefaultNodeTypeAWS = "t2.medium"
 const DefaultNodeTypeGCE = "n1-standard-2"
 
+// DefaultBastionTypeAWS is the default MachineType for bastions; they only need to relay SSH
+const DefaultBastionTypeAWS = "t2.micro"
+
+// DefaultMaxTaskDuration is the time a single cloud task may run, if RunTasksOptions does not set MaxTaskDuration
+const DefaultMaxTaskDuration = 10 * time.Minute
+
 // Path for completed cluster spec in the state store
 const PathClusterCompleted = "cluster.spec"
 
@@ -70,6 +83,29 @@ type CreateClusterCmd struct {
 
 	// Assets is a list of sources for files (primarily when not using everything containerized)
 	Assets []string
+
+	// DryRunOutput is where the dryrun target writes its report; defaults to stdout
+	DryRunOutput io.Writer
+	// DryRunFormat is the format of the dryrun report (text, json or yaml)
+	DryRunFormat fi.DryRunFormat
+
+	// Cloud is the cloud to run against; if nil it is built from the cluster spec
+	Cloud fi.Cloud
+
+	// RunTasksOptions configures the concurrency, timeouts and retries of the task executor
+	RunTasksOptions fi.RunTasksOptions
+
+	// ValidateOnly stops once the completed cluster spec has been built and validated,
+	// before any tasks are built and before anything is written to the StateStore
+	ValidateOnly bool
+
+	// DeleteRemovedResources confirms that the direct target may delete cloud objects that have been removed
+	// from the model; otherwise they are only listed
+	DeleteRemovedResources bool
+
+	// AllowLocalEncryptionKey allows a state store encrypted with a local key, which kops does not copy to instances;
+	// it should only be set if the image already has the key at fi.NodeLocalKeyFile
+	AllowLocalEncryptionKey bool
 }
 
 func (c *CreateClusterCmd) LoadConfig(configFile string) error {
@@ -85,9 +121,9 @@ func (c *CreateClusterCmd) LoadConfig(configFile string) error {
 }
 
 func (c *CreateClusterCmd) Run() error {
-	// TODO: Make these configurable?
+	// TODO: Make this configurable?
 	useMasterASG := true
-	useMasterLB := false
+	useMasterLB := c.Cluster.UseAPILoadBalancer()
 
 	//// We (currently) have to use protokube with ASGs
 	//useProtokube := useMasterASG
@@ -233,16 +269,7 @@ func (c *CreateClusterCmd) Run() error {
 	if vfs.IsClusterReadable(secretStore.VFSPath()) {
 		vfsPath := secretStore.VFSPath()
 		c.Cluster.Spec.SecretStore = vfsPath.Path()
-		if s3Path, ok := vfsPath.(*vfs.S3Path); ok {
-			if c.Cluster.Spec.MasterPermissions == nil {
-				c.Cluster.Spec.MasterPermissions = &api.CloudPermissions{}
-			}
-			c.Cluster.Spec.MasterPermissions.AddS3Bucket(s3Path.Bucket())
-			if c.Cluster.Spec.NodePermissions == nil {
-				c.Cluster.Spec.NodePermissions = &api.CloudPermissions{}
-			}
-			c.Cluster.Spec.NodePermissions.AddS3Bucket(s3Path.Bucket())
-		}
+		c.addBucketPermissions(vfsPath)
 	} else {
 		// We could implement this approach, but it seems better to get all clouds using cluster-readable storage
 		return fmt.Errorf("secrets path is not cluster readable: %v", secretStore.VFSPath())
@@ -251,21 +278,21 @@ func (c *CreateClusterCmd) Run() error {
 	if vfs.IsClusterReadable(keyStore.VFSPath()) {
 		vfsPath := keyStore.VFSPath()
 		c.Cluster.Spec.KeyStore = vfsPath.Path()
-		if s3Path, ok := vfsPath.(*vfs.S3Path); ok {
-			if c.Cluster.Spec.MasterPermissions == nil {
-				c.Cluster.Spec.MasterPermissions = &api.CloudPermissions{}
-			}
-			c.Cluster.Spec.MasterPermissions.AddS3Bucket(s3Path.Bucket())
-			if c.Cluster.Spec.NodePermissions == nil {
-				c.Cluster.Spec.NodePermissions = &api.CloudPermissions{}
-			}
-			c.Cluster.Spec.NodePermissions.AddS3Bucket(s3Path.Bucket())
-		}
+		c.addBucketPermissions(vfsPath)
 	} else {
 		// We could implement this approach, but it seems better to get all clouds using cluster-readable storage
 		return fmt.Errorf("keyStore path is not cluster readable: %v", keyStore.VFSPath())
 	}
 
+	c.Cluster.Spec.Encryption = c.StateStore.EncryptionConfig()
+	if c.Cluster.Spec.Encryption != nil && c.Cluster.Spec.Encryption.Provider == fi.KeyProviderLocal {
+		if !c.AllowLocalEncryptionKey && !c.ValidateOnly {
+			return fmt.Errorf("secrets are encrypted with a local key, which kops does not copy to instances, so the masters and nodes could not decrypt them; "+
+				"re-encrypt with `kops secrets encrypt --provider=kms`, or pass --allow-local-encryption-key if your image has the key at %s", fi.NodeLocalKeyFile)
+		}
+		glog.Warningf("Secrets are encrypted with a local key, which kops does not copy to instances; nodes can only decrypt them if the key is in the image at %s", fi.NodeLocalKeyFile)
+	}
+
 	if vfs.IsClusterReadable(c.StateStore.VFSPath()) {
 		c.Cluster.Spec.ConfigStore = c.StateStore.VFSPath().Path()
 	} else {
@@ -308,6 +335,11 @@ func (c *CreateClusterCmd) Run() error {
 		// TODO: Verify assets exist, get the hash (that will check that KubernetesVersion is valid)
 
 		c.Assets = append(c.Assets, defaultKubeletAsset, defaultKubectlAsset)
+
+		if c.Cluster.Spec.Networking != nil && c.Cluster.Spec.Networking.UsesCNI() {
+			glog.Infof("Adding default CNI asset: %s", DefaultCNIAsset)
+			c.Assets = append(c.Assets, DefaultCNIAsset)
+		}
 	}
 
 	if c.NodeUpSource == "" {
@@ -346,14 +378,30 @@ func (c *CreateClusterCmd) Run() error {
 		tags["_master_dns"] = struct{}{}
 	}
 
+	if c.Cluster.IsTopologyPrivate() {
+		tags["_topology_private"] = struct{}{}
+	}
+
+	networkingTags, err := buildNetworkingTags(c.Cluster.Spec.Networking)
+	if err != nil {
+		return err
+	}
+	for _, tag := range networkingTags {
+		tags[tag] = struct{}{}
+		c.NodeUpTags = append(c.NodeUpTags, tag)
+	}
+
 	l.AddTypes(map[string]interface{}{
 		"keypair": &fitasks.Keypair{},
 		"secret":  &fitasks.Secret{},
 	})
 
-	cloud, err := BuildCloud(c.Cluster)
-	if err != nil {
-		return err
+	cloud := c.Cloud
+	if cloud == nil {
+		cloud, err = BuildCloud(c.Cluster)
+		if err != nil {
+			return err
+		}
 	}
 
 	region := ""
@@ -366,7 +414,6 @@ func (c *CreateClusterCmd) Run() error {
 			region = gceCloud.Region
 			project = gceCloud.Project
 
-			glog.Fatalf("GCE is (probably) not working currently - please ping @justinsb for cleanup")
 			tags["_gce"] = struct{}{}
 			c.NodeUpTags = append(c.NodeUpTags, "_gce")
 
@@ -375,10 +422,30 @@ func (c *CreateClusterCmd) Run() error {
 				"instance":             &gcetasks.Instance{},
 				"instanceTemplate":     &gcetasks.InstanceTemplate{},
 				"network":              &gcetasks.Network{},
+				"subnet":               &gcetasks.Subnet{},
 				"managedInstanceGroup": &gcetasks.ManagedInstanceGroup{},
 				"firewallRule":         &gcetasks.FirewallRule{},
 				"ipAddress":            &gcetasks.IPAddress{},
 			})
+
+			// GCE resource names can't contain dots, so we name them from the SafeClusterName
+			l.TemplateFunctions["SafeClusterName"] = func() string {
+				return gce.SafeClusterName(clusterName)
+			}
+			l.TemplateFunctions["MasterTag"] = func() string {
+				return gce.MasterTag(clusterName)
+			}
+			l.TemplateFunctions["InstanceGroupManagerName"] = func(ig *api.InstanceGroup, zone string) string {
+				return gce.InstanceGroupManagerName(ig.Name, zone, clusterName)
+			}
+			// We check the length here, so that a long name fails before we have created anything
+			l.TemplateFunctions["InstanceTemplateName"] = func(ig *api.InstanceGroup) (string, error) {
+				name := ig.Name + "-" + gce.SafeClusterName(clusterName)
+				if err := gcetasks.ValidateInstanceTemplateName(name); err != nil {
+					return "", fmt.Errorf("InstanceGroup %q: %v; use a shorter instance group or cluster name", ig.Name, err)
+				}
+				return name, nil
+			}
 		}
 
 	case "aws":
@@ -407,6 +474,7 @@ func (c *CreateClusterCmd) Run() error {
 				// VPC / Networking
 				"dhcpOptions":           &awstasks.DHCPOptions{},
 				"internetGateway":       &awstasks.InternetGateway{},
+				"natGateway":            &awstasks.NatGateway{},
 				"route":                 &awstasks.Route{},
 				"routeTable":            &awstasks.RouteTable{},
 				"routeTableAssociation": &awstasks.RouteTableAssociation{},
@@ -430,7 +498,7 @@ func (c *CreateClusterCmd) Run() error {
 				"dnsZone": &awstasks.DNSZone{},
 			})
 
-			if c.SSHPublicKey == "" {
+			if c.SSHPublicKey == "" && !c.ValidateOnly {
 				return fmt.Errorf("SSH public key must be specified when running with AWS")
 			}
 
@@ -486,6 +554,8 @@ func (c *CreateClusterCmd) Run() error {
 	}
 	l.TemplateFunctions["NodeSets"] = c.populateNodeSets
 	l.TemplateFunctions["Masters"] = c.populateMasters
+	l.TemplateFunctions["MasterZones"] = c.populateMasterZones
+	l.TemplateFunctions["Bastions"] = c.populateBastions
 	//l.TemplateFunctions["NodeUp"] = c.populateNodeUpConfig
 	l.TemplateFunctions["NodeUpSource"] = func() string {
 		return c.NodeUpSource
@@ -496,6 +566,24 @@ func (c *CreateClusterCmd) Run() error {
 	l.TemplateFunctions["ClusterLocation"] = func() string {
 		return c.StateStore.VFSPath().Join(PathClusterCompleted).Path()
 	}
+	// The args for the nodeup config are the node's tags (which start with _), and the name of its instance group
+	l.TemplateFunctions["NodeUpArgsTags"] = func(args []string) []string {
+		var tags []string
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "_") {
+				tags = append(tags, arg)
+			}
+		}
+		return tags
+	}
+	l.TemplateFunctions["InstanceGroupLocation"] = func(args []string) string {
+		for _, arg := range args {
+			if !strings.HasPrefix(arg, "_") {
+				return c.StateStore.VFSPath().Join("instancegroup", arg).Path()
+			}
+		}
+		return ""
+	}
 	l.TemplateFunctions["Assets"] = func() []string {
 		return c.Assets
 	}
@@ -540,6 +628,10 @@ func (c *CreateClusterCmd) Run() error {
 		return fmt.Errorf("Completed cluster failed validation: %v", err)
 	}
 
+	if c.ValidateOnly {
+		return nil
+	}
+
 	taskMap, err := l.BuildTasks(c.ModelStore, c.Models)
 	if err != nil {
 		return fmt.Errorf("error building tasks: %v", err)
@@ -569,7 +661,11 @@ func (c *CreateClusterCmd) Run() error {
 		target = terraform.NewTerraformTarget(cloud, region, project, outDir)
 
 	case "dryrun":
-		target = fi.NewDryRunTarget(os.Stdout)
+		out := c.DryRunOutput
+		if out == nil {
+			out = os.Stdout
+		}
+		target = fi.NewDryRunTarget(out, c.DryRunFormat)
 	default:
 		return fmt.Errorf("unsupported target type %q", c.Target)
 	}
@@ -580,11 +676,23 @@ func (c *CreateClusterCmd) Run() error {
 	}
 	defer context.Close()
 
-	err = context.RunTasks(taskMap)
+	runTasksOptions := c.RunTasksOptions
+	if runTasksOptions.MaxTaskDuration == 0 {
+		runTasksOptions.MaxTaskDuration = DefaultMaxTaskDuration
+	}
+	err = context.RunTasks(taskMap, runTasksOptions)
 	if err != nil {
 		return fmt.Errorf("error running tasks: %v", err)
 	}
 
+	// Terraform manages its own deletions, by comparing with its state
+	if c.Target == "direct" || c.Target == "dryrun" {
+		err = c.deleteRemovedResources(context, cloud, clusterName, region, taskMap)
+		if err != nil {
+			return err
+		}
+	}
+
 	err = target.Finish(taskMap)
 	if err != nil {
 		return fmt.Errorf("error closing target: %v", err)
@@ -593,11 +701,93 @@ func (c *CreateClusterCmd) Run() error {
 	return nil
 }
 
+// deleteRemovedResources deletes the cloud objects that have been removed from the model.
+// On a dry-run target they are only reported, and on the direct target they are listed, and only deleted
+// if DeleteRemovedResources is set.
+func (c *CreateClusterCmd) deleteRemovedResources(context *fi.Context, cloud fi.Cloud, clusterName string, region string, taskMap map[string]fi.Task) error {
+	deletions, err := context.FindDeletions()
+	if err != nil {
+		return err
+	}
+	err = context.RunDeletions(deletions)
+	if err != nil {
+		return fmt.Errorf("error deleting resources: %v", err)
+	}
+
+	if _, ok := cloud.(*awsup.AWSCloud); !ok {
+		glog.V(2).Infof("Orphaned resource detection not implemented for %T", cloud)
+		return nil
+	}
+
+	orphans, err := kutil.FindOrphanedResources(cloud, clusterName, taskMap)
+	if err != nil {
+		return fmt.Errorf("error finding orphaned resources: %v", err)
+	}
+	if len(orphans) == 0 {
+		return nil
+	}
+
+	if _, ok := context.Target.(*fi.DryRunTarget); ok {
+		return context.RunDeletions(kutil.AsDeletions(cloud, orphans))
+	}
+
+	// Deleting can't be undone, so the user must see what we will delete, and confirm it
+	var keys []string
+	for k := range orphans {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fmt.Printf("\nResources that have been removed from the configuration:\n")
+	for _, k := range keys {
+		t := orphans[k]
+		fmt.Printf("  %s\t%s\t%s\n", t.Type, t.ID, t.Name)
+	}
+	if !c.DeleteRemovedResources {
+		fmt.Printf("\nNot deleting them until confirmed with --yes\n")
+		return nil
+	}
+
+	d := &kutil.DeleteCluster{
+		ClusterName: clusterName,
+		Region:      region,
+		Cloud:       cloud,
+	}
+	err = d.DeleteResources(orphans)
+	if err != nil {
+		return fmt.Errorf("error deleting orphaned resources: %v", err)
+	}
+	return nil
+}
+
+// addBucketPermissions grants the masters and nodes access to the bucket holding vfsPath
+func (c *CreateClusterCmd) addBucketPermissions(vfsPath vfs.Path) {
+	switch p := vfsPath.(type) {
+	case *vfs.S3Path:
+		c.ensurePermissions()
+		c.Cluster.Spec.MasterPermissions.AddS3Bucket(p.Bucket())
+		c.Cluster.Spec.NodePermissions.AddS3Bucket(p.Bucket())
+	case *vfs.GSPath:
+		// On GCE, read access is granted by the storage-ro scope on the instances
+		c.ensurePermissions()
+		c.Cluster.Spec.MasterPermissions.AddGCSBucket(p.Bucket())
+		c.Cluster.Spec.NodePermissions.AddGCSBucket(p.Bucket())
+	}
+}
+
+func (c *CreateClusterCmd) ensurePermissions() {
+	if c.Cluster.Spec.MasterPermissions == nil {
+		c.Cluster.Spec.MasterPermissions = &api.CloudPermissions{}
+	}
+	if c.Cluster.Spec.NodePermissions == nil {
+		c.Cluster.Spec.NodePermissions = &api.CloudPermissions{}
+	}
+}
+
 // populateNodeSets returns the NodeSets with values populated from defaults or top-level config
 func (c *CreateClusterCmd) populateNodeSets() ([]*api.InstanceGroup, error) {
 	var results []*api.InstanceGroup
 	for _, src := range c.InstanceGroups {
-		if src.IsMaster() {
+		if src.IsMaster() || src.IsBastion() {
 			continue
 		}
 		n := &api.InstanceGroup{}
@@ -621,6 +811,42 @@ func (c *CreateClusterCmd) populateNodeSets() ([]*api.InstanceGroup, error) {
 	return results, nil
 }
 
+// buildNetworkingTags returns the tags for the networking provider, which apply to both cloudup and nodeup
+func buildNetworkingTags(networking *api.NetworkingSpec) ([]string, error) {
+	if networking == nil || networking.Kubenet != nil {
+		return []string{"_networking_kubenet"}, nil
+	}
+	if networking.External != nil {
+		return []string{"_networking_cni", "_networking_external"}, nil
+	}
+	if networking.Weave != nil {
+		return []string{"_networking_cni", "_networking_weave"}, nil
+	}
+	return nil, fmt.Errorf("no networking provider was specified")
+}
+
+// populateMasterZones returns the (sorted) zones in which there are masters
+func (c *CreateClusterCmd) populateMasterZones() ([]string, error) {
+	masters, err := c.populateMasters()
+	if err != nil {
+		return nil, err
+	}
+
+	zones := make(map[string]bool)
+	for _, m := range masters {
+		for _, z := range m.Spec.Zones {
+			zones[z] = true
+		}
+	}
+
+	var results []string
+	for z := range zones {
+		results = append(results, z)
+	}
+	sort.Strings(results)
+	return results, nil
+}
+
 // populateMasters returns the Masters with values populated from defaults or top-level config
 func (c *CreateClusterCmd) populateMasters() ([]*api.InstanceGroup, error) {
 	var results []*api.InstanceGroup
@@ -649,6 +875,38 @@ func (c *CreateClusterCmd) populateMasters() ([]*api.InstanceGroup, error) {
 	return results, nil
 }
 
+// populateBastions returns the Bastions with values populated from defaults or top-level config
+func (c *CreateClusterCmd) populateBastions() ([]*api.InstanceGroup, error) {
+	var results []*api.InstanceGroup
+	for _, src := range c.InstanceGroups {
+		if !src.IsBastion() {
+			continue
+		}
+		b := &api.InstanceGroup{}
+		*b = *src
+
+		if b.Spec.MachineType == "" {
+			if c.Cluster.Spec.CloudProvider == "aws" {
+				b.Spec.MachineType = DefaultBastionTypeAWS
+			} else {
+				b.Spec.MachineType = c.defaultMachineType()
+			}
+		}
+
+		if b.Spec.Image == "" {
+			b.Spec.Image = c.defaultImage()
+		}
+
+		if len(b.Spec.Zones) == 0 {
+			for _, z := range c.Cluster.Spec.Zones {
+				b.Spec.Zones = append(b.Spec.Zones, z.Name)
+			}
+		}
+		results = append(results, b)
+	}
+	return results, nil
+}
+
 //// populateNodeUpConfig returns the NodeUpConfig with values populated from defaults or top-level config
 //func (c*CreateClusterCmd) populateNodeUpConfig() (*nodeup.NodeConfig, error) {
 //	conf := &nodeup.NodeConfig{}
@@ -678,6 +936,8 @@ func (c *CreateClusterCmd) defaultImage() string {
 	switch cluster.Spec.CloudProvider {
 	case "aws":
 		return "282335181503/k8s-1.3-debian-jessie-amd64-hvm-ebs-2016-06-18"
+	case "gce":
+		return "debian-cloud/debian-8-jessie-v20160329"
 	default:
 		glog.V(2).Infof("Cannot set default Image for CloudProvider=%q", cluster.Spec.CloudProvider)
 		return ""
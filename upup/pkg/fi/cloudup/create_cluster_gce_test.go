@@ -0,0 +1,136 @@
+package cloudup
+
+import (
+	"bytes"
+	"io/ioutil"
+	"k8s.io/kops/cloudmock/gce/mockcompute"
+	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi/cloudup/gce"
+	"path"
+	"strings"
+	"testing"
+)
+
+const testGCEProject = "testproject"
+const testGCERegion = "us-test1"
+const testGCEZone = "us-test1-a"
+
+// newMockGCECloud builds a GCE cloud for testGCEProject, backed by the fake compute API;
+// the returned function stops the fake API
+func newMockGCECloud(t *testing.T) (*gce.GCECloud, func()) {
+	mock := mockcompute.NewMockCompute()
+	client, err := mock.Start()
+	if err != nil {
+		t.Fatalf("error starting mock compute API: %v", err)
+	}
+	cloud := &gce.GCECloud{
+		Compute: client,
+		Region:  testGCERegion,
+		Project: testGCEProject,
+	}
+	return cloud, mock.Close
+}
+
+// onGCE changes the minimal cluster to run in testGCEZone
+func onGCE(cluster *api.Cluster, instanceGroups []*api.InstanceGroup) {
+	cluster.Spec.CloudProvider = "gce"
+	cluster.Spec.Project = testGCEProject
+	cluster.Spec.Zones = []*api.ClusterZoneSpec{{Name: testGCEZone}}
+	for _, etcd := range cluster.Spec.EtcdClusters {
+		etcd.Members = []*api.EtcdMemberSpec{{Name: testGCEZone, Zone: testGCEZone}}
+	}
+	for _, ig := range instanceGroups {
+		if ig.Spec.Role == api.InstanceGroupRoleMaster {
+			ig.Name = "master-" + testGCEZone
+			ig.Spec.Zones = []string{testGCEZone}
+		}
+	}
+}
+
+// TestCreateCluster_GCE creates a cluster against the fake compute API, checking the etcd disks and
+// instance groups, and that running again would not make any changes
+func TestCreateCluster_GCE(t *testing.T) {
+	tc, cleanup := newTestCluster(t)
+	defer cleanup()
+	cloud, cleanupGCE := newMockGCECloud(t)
+	defer cleanupGCE()
+	tc.Cloud = cloud
+	client := cloud.Compute
+
+	tc.runCreateCluster(createClusterOptions{Customize: onGCE})
+
+	disk, err := client.Disks.Get(testGCEProject, testGCEZone, "us-test1-a-etcd-main-minimal-example-com").Do()
+	if err != nil {
+		t.Fatalf("error getting etcd disk: %v", err)
+	}
+	if disk.Labels[gce.LabelClusterName] != gce.EncodeGCELabel("minimal.example.com") {
+		t.Fatalf("unexpected cluster label on etcd disk: %v", disk.Labels)
+	}
+	if _, found := disk.Labels[gce.LabelRoleMaster]; !found {
+		t.Fatalf("expected master role label on etcd disk: %v", disk.Labels)
+	}
+	spec, err := gce.DecodeGCELabel(disk.Labels[gce.LabelEtcdClusterPrefix+"main"])
+	if err != nil {
+		t.Fatalf("error decoding etcd label: %v", err)
+	}
+	if spec != "us-test1-a/us-test1-a" {
+		t.Fatalf("unexpected etcd cluster spec on disk: %q", spec)
+	}
+
+	master, err := client.InstanceGroupManagers.Get(testGCEProject, testGCEZone, "master-us-test1-a-us-test1-a-minimal-example-com").Do()
+	if err != nil {
+		t.Fatalf("error getting master instance group: %v", err)
+	}
+	if master.TargetSize != 1 {
+		t.Fatalf("expected master instance group to have size 1, got %d", master.TargetSize)
+	}
+	template, err := client.InstanceTemplates.Get(testGCEProject, gce.LastComponent(master.InstanceTemplate)).Do()
+	if err != nil {
+		t.Fatalf("error getting master instance template: %v", err)
+	}
+	if template.Properties.Tags == nil || len(template.Properties.Tags.Items) != 1 || template.Properties.Tags.Items[0] != gce.MasterTag("minimal.example.com") {
+		t.Fatalf("unexpected tags on master instance template: %v", template.Properties.Tags)
+	}
+
+	nodes, err := client.InstanceGroupManagers.Get(testGCEProject, testGCEZone, "nodes-us-test1-a-minimal-example-com").Do()
+	if err != nil {
+		t.Fatalf("error getting nodes instance group: %v", err)
+	}
+	if nodes.TargetSize != 2 {
+		t.Fatalf("expected nodes instance group to have size 2, got %d", nodes.TargetSize)
+	}
+
+	var report bytes.Buffer
+	tc.runCreateCluster(createClusterOptions{Target: "dryrun", DryRunOutput: &report, Customize: onGCE})
+	if report.Len() != 0 {
+		t.Fatalf("expected no changes on second run, got:\n%s", report.String())
+	}
+}
+
+// TestCreateCluster_GCETerraform checks that the terraform output for GCE uses the google resources
+func TestCreateCluster_GCETerraform(t *testing.T) {
+	tc, cleanup := newTestCluster(t)
+	defer cleanup()
+	cloud, cleanupGCE := newMockGCECloud(t)
+	defer cleanupGCE()
+	tc.Cloud = cloud
+
+	tc.runCreateCluster(createClusterOptions{Target: "terraform", Customize: onGCE})
+
+	tf, err := ioutil.ReadFile(path.Join(tc.tmpdir, "terraform", "kubernetes.tf"))
+	if err != nil {
+		t.Fatalf("error reading terraform output: %v", err)
+	}
+	for _, resourceType := range []string{
+		"google_compute_network",
+		"google_compute_subnetwork",
+		"google_compute_firewall",
+		"google_compute_disk",
+		"google_compute_instance_template",
+		"google_compute_instance_group_manager",
+	} {
+		if !strings.Contains(string(tf), resourceType) {
+			t.Errorf("expected terraform output to contain %s resources", resourceType)
+		}
+	}
+}
@@ -0,0 +1,752 @@
+package cloudup
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"io/ioutil"
+	"k8s.io/kops/cloudmock/aws/mockautoscaling"
+	"k8s.io/kops/cloudmock/aws/mockec2"
+	"k8s.io/kops/cloudmock/aws/mockelb"
+	"k8s.io/kops/cloudmock/aws/mockiam"
+	"k8s.io/kops/cloudmock/aws/mockroute53"
+	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+const testRegion = "us-test-1"
+const testZone = "us-test-1a"
+
+// newMockAWSCloud builds an in-memory AWS cloud in testRegion, for the minimal cluster
+func newMockAWSCloud() *awsup.AWSCloud {
+	mockEC2 := mockec2.NewMockEC2(testRegion, testZone)
+	mockEC2.AddImage(&ec2.Image{
+		Name:    aws.String("k8s-1.3-debian-jessie-amd64-hvm-ebs-2016-06-18"),
+		OwnerId: aws.String("282335181503"),
+	})
+
+	cloud := &awsup.AWSCloud{
+		EC2:         mockEC2,
+		IAM:         mockiam.NewMockIAM(),
+		ELB:         mockelb.NewMockELB(),
+		Autoscaling: mockautoscaling.NewMockAutoscaling(),
+		Route53:     mockroute53.NewMockRoute53(),
+		Region:      testRegion,
+	}
+	return cloud.WithTags(map[string]string{awsup.TagClusterName: "minimal.example.com"})
+}
+
+// writeSSHPublicKey generates an SSH public key, writing it to a file in dir
+func writeSSHPublicKey(t *testing.T, dir string) string {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("error generating SSH key: %v", err)
+	}
+	publicKey, err := ssh.NewPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatalf("error building SSH public key: %v", err)
+	}
+	p := path.Join(dir, "id_rsa.pub")
+	err = ioutil.WriteFile(p, ssh.MarshalAuthorizedKey(publicKey), 0644)
+	if err != nil {
+		t.Fatalf("error writing SSH public key: %v", err)
+	}
+	return p
+}
+
+func buildMinimalCluster() (*api.Cluster, []*api.InstanceGroup) {
+	cluster := &api.Cluster{}
+	cluster.Name = "minimal.example.com"
+	cluster.Spec.CloudProvider = "aws"
+	cluster.Spec.KubernetesVersion = "1.3.5"
+	cluster.Spec.Zones = []*api.ClusterZoneSpec{{Name: testZone}}
+	for _, name := range []string{"main", "events"} {
+		cluster.Spec.EtcdClusters = append(cluster.Spec.EtcdClusters, &api.EtcdClusterSpec{
+			Name:    name,
+			Members: []*api.EtcdMemberSpec{{Name: testZone, Zone: testZone}},
+		})
+	}
+
+	master := &api.InstanceGroup{}
+	master.Name = "master-" + testZone
+	master.Spec.Role = api.InstanceGroupRoleMaster
+	master.Spec.Zones = []string{testZone}
+	master.Spec.MinSize = fi.Int(1)
+	master.Spec.MaxSize = fi.Int(1)
+
+	nodes := &api.InstanceGroup{}
+	nodes.Name = "nodes"
+	nodes.Spec.Role = api.InstanceGroupRoleNode
+
+	return cluster, []*api.InstanceGroup{master, nodes}
+}
+
+// testCluster holds what a test needs to run CreateClusterCmd for the minimal cluster against a mock cloud
+type testCluster struct {
+	t *testing.T
+
+	// Cloud is the cloud CreateClusterCmd runs against; it is AWSCloud unless a test replaces it
+	Cloud      fi.Cloud
+	AWSCloud   *awsup.AWSCloud
+	StateStore fi.StateStore
+
+	tmpdir       string
+	sshPublicKey string
+}
+
+// newTestCluster builds a mock AWS cloud and an empty in-memory state store; the returned function cleans up
+func newTestCluster(t *testing.T) (*testCluster, func()) {
+	tc := &testCluster{t: t}
+
+	tc.AWSCloud = newMockAWSCloud()
+	tc.Cloud = tc.AWSCloud
+
+	tmpdir, err := ioutil.TempDir("", "test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	tc.tmpdir = tmpdir
+	tc.sshPublicKey = writeSSHPublicKey(t, tmpdir)
+
+	memfs := vfs.NewMemFSContext()
+	memfs.MarkClusterReadable()
+	tc.StateStore, err = fi.NewVFSStateStore(vfs.NewMemFSPath(memfs, "state"), "minimal.example.com", false)
+	if err != nil {
+		t.Fatalf("error building state store: %v", err)
+	}
+
+	cleanup := func() {
+		os.RemoveAll(tmpdir)
+	}
+	return tc, cleanup
+}
+
+// createClusterOptions are the options for a test run of CreateClusterCmd
+type createClusterOptions struct {
+	// Target is the CreateClusterCmd target; defaults to direct
+	Target string
+	// DryRunOutput receives the report when Target is dryrun
+	DryRunOutput io.Writer
+	// Customize (if not nil) can change the cluster & instance groups before they are populated
+	Customize func(cluster *api.Cluster, instanceGroups []*api.InstanceGroup)
+	// DeleteRemovedResources confirms the deletion of resources that have been removed from the model
+	DeleteRemovedResources bool
+}
+
+// runCreateCluster runs CreateClusterCmd for the minimal cluster, failing the test on error
+func (tc *testCluster) runCreateCluster(options createClusterOptions) {
+	t := tc.t
+
+	target := options.Target
+	if target == "" {
+		target = "direct"
+	}
+
+	cluster, instanceGroups := buildMinimalCluster()
+	if options.Customize != nil {
+		options.Customize(cluster, instanceGroups)
+	}
+	err := cluster.PerformAssignments()
+	if err != nil {
+		t.Fatalf("error populating configuration: %v", err)
+	}
+	err = api.PerformAssignmentsInstanceGroups(instanceGroups)
+	if err != nil {
+		t.Fatalf("error populating configuration: %v", err)
+	}
+
+	cmd := &CreateClusterCmd{
+		Cluster:        cluster,
+		InstanceGroups: instanceGroups,
+		ModelStore:     "../../../models",
+		Models:         []string{"config", "proto", "cloudup"},
+		NodeModel:      "nodeup",
+		StateStore:     tc.StateStore,
+		Target:         target,
+		SSHPublicKey:   tc.sshPublicKey,
+		OutDir:         tc.tmpdir,
+		DryRunOutput:   options.DryRunOutput,
+		Cloud:          tc.Cloud,
+
+		DeleteRemovedResources: options.DeleteRemovedResources,
+	}
+	err = cmd.Run()
+	if err != nil {
+		t.Fatalf("error running CreateClusterCmd (target=%s): %v", target, err)
+	}
+}
+
+// idempotencyTests are the variations of the minimal cluster that TestCreateCluster_Idempotent creates
+var idempotencyTests = []struct {
+	Name      string
+	Customize func(cluster *api.Cluster, instanceGroups []*api.InstanceGroup)
+}{
+	{Name: "minimal"},
+	{Name: "private topology", Customize: usePrivateTopology},
+	{Name: "restricted SSH access", Customize: restrictSSH},
+	{Name: "API load balancer", Customize: useLoadBalancer},
+	{Name: "weave networking", Customize: useWeave},
+	{Name: "spot price", Customize: withMaxPrice("0.05")},
+	{Name: "cloud labels", Customize: withCloudLabels(map[string]string{"team": "a"})},
+}
+
+// TestCreateCluster_Idempotent creates each variation of the minimal cluster against the mock AWS cloud,
+// and checks that running again would not make any changes
+func TestCreateCluster_Idempotent(t *testing.T) {
+	for _, test := range idempotencyTests {
+		tc, cleanup := newTestCluster(t)
+
+		tc.runCreateCluster(createClusterOptions{Customize: test.Customize})
+
+		var report bytes.Buffer
+		tc.runCreateCluster(createClusterOptions{Target: "dryrun", DryRunOutput: &report, Customize: test.Customize})
+		cleanup()
+
+		if report.Len() != 0 {
+			t.Errorf("%s: expected no changes on second run, got:\n%s", test.Name, report.String())
+		}
+	}
+}
+
+// TestCreateCluster_ReportsOrphanedResources checks that an object tagged as owned by the cluster,
+// but which is not in the model, is reported for deletion on a dry-run
+func TestCreateCluster_ReportsOrphanedResources(t *testing.T) {
+	tc, cleanup := newTestCluster(t)
+	defer cleanup()
+	cloud := tc.AWSCloud
+
+	tc.runCreateCluster(createClusterOptions{})
+	subnetID := createOrphanedSubnet(t, cloud)
+
+	var report bytes.Buffer
+	tc.runCreateCluster(createClusterOptions{Target: "dryrun", DryRunOutput: &report})
+	expected := "Will delete resources:\n  subnet\t" + subnetID + "\n"
+	if report.String() != expected {
+		t.Fatalf("unexpected dry-run report; expected:\n%s\ngot:\n%s", expected, report.String())
+	}
+}
+
+// TestCreateCluster_ConfirmsDeletingOrphanedResources checks that the direct target only deletes an orphaned
+// object once the deletion has been confirmed
+func TestCreateCluster_ConfirmsDeletingOrphanedResources(t *testing.T) {
+	tc, cleanup := newTestCluster(t)
+	defer cleanup()
+	cloud := tc.AWSCloud
+
+	tc.runCreateCluster(createClusterOptions{})
+	subnetID := createOrphanedSubnet(t, cloud)
+
+	tc.runCreateCluster(createClusterOptions{})
+	subnets, err := cloud.EC2.DescribeSubnets(&ec2.DescribeSubnetsInput{SubnetIds: []*string{aws.String(subnetID)}})
+	if err != nil || len(subnets.Subnets) != 1 {
+		t.Fatalf("expected the orphaned subnet to be kept without confirmation, got %v (err=%v)", subnets, err)
+	}
+
+	tc.runCreateCluster(createClusterOptions{DeleteRemovedResources: true})
+	subnets, err = cloud.EC2.DescribeSubnets(&ec2.DescribeSubnetsInput{SubnetIds: []*string{aws.String(subnetID)}})
+	if err == nil && len(subnets.Subnets) != 0 {
+		t.Fatalf("expected the orphaned subnet to be deleted, got %v", subnets)
+	}
+}
+
+// createOrphanedSubnet creates a subnet in the cluster VPC which is tagged as owned by the cluster, but is not in the
+// model, returning its id
+func createOrphanedSubnet(t *testing.T, cloud *awsup.AWSCloud) string {
+	vpcs, err := cloud.EC2.DescribeVpcs(&ec2.DescribeVpcsInput{})
+	if err != nil || len(vpcs.Vpcs) != 1 {
+		t.Fatalf("expected a single VPC, got %v (err=%v)", vpcs, err)
+	}
+	subnet, err := cloud.EC2.CreateSubnet(&ec2.CreateSubnetInput{
+		VpcId:            vpcs.Vpcs[0].VpcId,
+		CidrBlock:        aws.String("172.20.240.0/24"),
+		AvailabilityZone: aws.String(testZone),
+	})
+	if err != nil {
+		t.Fatalf("error creating subnet: %v", err)
+	}
+	_, err = cloud.EC2.CreateTags(&ec2.CreateTagsInput{
+		Resources: []*string{subnet.Subnet.SubnetId},
+		Tags: []*ec2.Tag{
+			{Key: aws.String(awsup.TagClusterName), Value: aws.String("minimal.example.com")},
+		},
+	})
+	if err != nil {
+		t.Fatalf("error tagging subnet: %v", err)
+	}
+	return aws.StringValue(subnet.Subnet.SubnetId)
+}
+
+// TestCreateCluster_KeepsServiceLoadBalancers checks that the ELB and security group that kubernetes creates for a
+// LoadBalancer service, which carry the cluster tag but are not created by kops, are not deleted as orphans
+func TestCreateCluster_KeepsServiceLoadBalancers(t *testing.T) {
+	tc, cleanup := newTestCluster(t)
+	defer cleanup()
+	cloud := tc.AWSCloud
+
+	tc.runCreateCluster(createClusterOptions{})
+
+	vpcs, err := cloud.EC2.DescribeVpcs(&ec2.DescribeVpcsInput{})
+	if err != nil || len(vpcs.Vpcs) != 1 {
+		t.Fatalf("expected a single VPC, got %v (err=%v)", vpcs, err)
+	}
+	clusterTag := &ec2.Tag{Key: aws.String(awsup.TagClusterName), Value: aws.String("minimal.example.com")}
+
+	sg, err := cloud.EC2.CreateSecurityGroup(&ec2.CreateSecurityGroupInput{
+		GroupName:   aws.String("k8s-elb-a0123456789"),
+		Description: aws.String("Security group for Kubernetes ELB a0123456789 (default/web)"),
+		VpcId:       vpcs.Vpcs[0].VpcId,
+	})
+	if err != nil {
+		t.Fatalf("error creating security group: %v", err)
+	}
+	_, err = cloud.EC2.CreateTags(&ec2.CreateTagsInput{
+		Resources: []*string{sg.GroupId},
+		Tags:      []*ec2.Tag{clusterTag},
+	})
+	if err != nil {
+		t.Fatalf("error tagging security group: %v", err)
+	}
+
+	_, err = cloud.ELB.CreateLoadBalancer(&elb.CreateLoadBalancerInput{
+		LoadBalancerName: aws.String("a0123456789"),
+		SecurityGroups:   []*string{sg.GroupId},
+		Tags: []*elb.Tag{
+			{Key: clusterTag.Key, Value: clusterTag.Value},
+			{Key: aws.String("kubernetes.io/service-name"), Value: aws.String("default/web")},
+		},
+	})
+	if err != nil {
+		t.Fatalf("error creating load balancer: %v", err)
+	}
+
+	tc.runCreateCluster(createClusterOptions{})
+
+	lbs, err := cloud.ELB.DescribeLoadBalancers(&elb.DescribeLoadBalancersInput{
+		LoadBalancerNames: []*string{aws.String("a0123456789")},
+	})
+	if err != nil || len(lbs.LoadBalancerDescriptions) != 1 {
+		t.Fatalf("expected service load balancer to survive, got %v (err=%v)", lbs, err)
+	}
+	groups, err := cloud.EC2.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
+		GroupIds: []*string{sg.GroupId},
+	})
+	if err != nil || len(groups.SecurityGroups) != 1 {
+		t.Fatalf("expected service security group to survive, got %v (err=%v)", groups, err)
+	}
+}
+
+// usePrivateTopology changes the minimal cluster to use a private topology (with the default networking, weave)
+func usePrivateTopology(cluster *api.Cluster, instanceGroups []*api.InstanceGroup) {
+	cluster.Spec.Topology = &api.TopologySpec{Type: api.TopologyPrivate}
+}
+
+// TestCreateCluster_PrivateTopology creates a cluster with a private topology, checking that the private subnet
+// routes outbound traffic through the NAT gateway in the utility subnet, and that the instances have no public IP
+func TestCreateCluster_PrivateTopology(t *testing.T) {
+	tc, cleanup := newTestCluster(t)
+	defer cleanup()
+	cloud := tc.AWSCloud
+
+	tc.runCreateCluster(createClusterOptions{Customize: usePrivateTopology})
+
+	subnets, err := cloud.EC2.DescribeSubnets(&ec2.DescribeSubnetsInput{})
+	if err != nil {
+		t.Fatalf("error listing subnets: %v", err)
+	}
+	subnetIDs := make(map[string]string)
+	for _, subnet := range subnets.Subnets {
+		for _, tag := range subnet.Tags {
+			if aws.StringValue(tag.Key) == "Name" {
+				subnetIDs[aws.StringValue(tag.Value)] = aws.StringValue(subnet.SubnetId)
+			}
+		}
+	}
+	privateSubnet := subnetIDs[testZone+".minimal.example.com"]
+	utilitySubnet := subnetIDs["utility-"+testZone+".minimal.example.com"]
+	if privateSubnet == "" || utilitySubnet == "" {
+		t.Fatalf("expected private and utility subnets, got %v", subnetIDs)
+	}
+
+	ngws, err := cloud.EC2.DescribeNatGateways(&ec2.DescribeNatGatewaysInput{})
+	if err != nil {
+		t.Fatalf("error listing NAT gateways: %v", err)
+	}
+	if len(ngws.NatGateways) != 1 || aws.StringValue(ngws.NatGateways[0].SubnetId) != utilitySubnet {
+		t.Fatalf("expected a single NAT gateway in the utility subnet, got %v", ngws.NatGateways)
+	}
+	ngwID := aws.StringValue(ngws.NatGateways[0].NatGatewayId)
+
+	routeTables, err := cloud.EC2.DescribeRouteTables(&ec2.DescribeRouteTablesInput{})
+	if err != nil {
+		t.Fatalf("error listing route tables: %v", err)
+	}
+	routedThroughNAT := false
+	for _, rt := range routeTables.RouteTables {
+		associated := false
+		for _, a := range rt.Associations {
+			if aws.StringValue(a.SubnetId) == privateSubnet {
+				associated = true
+			}
+		}
+		if !associated {
+			continue
+		}
+		for _, r := range rt.Routes {
+			if aws.StringValue(r.DestinationCidrBlock) == "0.0.0.0/0" && aws.StringValue(r.NatGatewayId) == ngwID {
+				routedThroughNAT = true
+			}
+		}
+	}
+	if !routedThroughNAT {
+		t.Fatalf("expected the private subnet to route 0.0.0.0/0 through NAT gateway %s", ngwID)
+	}
+
+	lcs, err := cloud.Autoscaling.DescribeLaunchConfigurations(&autoscaling.DescribeLaunchConfigurationsInput{})
+	if err != nil {
+		t.Fatalf("error listing launch configurations: %v", err)
+	}
+	// One for the master and one for the nodes
+	if len(lcs.LaunchConfigurations) != 2 {
+		t.Fatalf("expected 2 launch configurations, got %d", len(lcs.LaunchConfigurations))
+	}
+	for _, lc := range lcs.LaunchConfigurations {
+		if aws.BoolValue(lc.AssociatePublicIpAddress) {
+			t.Fatalf("expected launch configuration %q not to associate a public IP", aws.StringValue(lc.LaunchConfigurationName))
+		}
+	}
+}
+
+// TestCreateCluster_PrivateTopologySharedSubnet checks that a NAT gateway created by someone else in the utility subnet
+// (as can happen in a shared VPC) is not mistaken for the cluster's NAT gateway
+func TestCreateCluster_PrivateTopologySharedSubnet(t *testing.T) {
+	tc, cleanup := newTestCluster(t)
+	defer cleanup()
+	cloud := tc.AWSCloud
+
+	tc.runCreateCluster(createClusterOptions{Customize: usePrivateTopology})
+
+	ngws, err := cloud.EC2.DescribeNatGateways(&ec2.DescribeNatGatewaysInput{})
+	if err != nil {
+		t.Fatalf("error listing NAT gateways: %v", err)
+	}
+	if len(ngws.NatGateways) != 1 {
+		t.Fatalf("expected a single NAT gateway, got %v", ngws.NatGateways)
+	}
+	utilitySubnet := ngws.NatGateways[0].SubnetId
+
+	address, err := cloud.EC2.AllocateAddress(&ec2.AllocateAddressInput{Domain: aws.String("vpc")})
+	if err != nil {
+		t.Fatalf("error allocating address: %v", err)
+	}
+	_, err = cloud.EC2.CreateNatGateway(&ec2.CreateNatGatewayInput{SubnetId: utilitySubnet, AllocationId: address.AllocationId})
+	if err != nil {
+		t.Fatalf("error creating NAT gateway: %v", err)
+	}
+
+	var report bytes.Buffer
+	tc.runCreateCluster(createClusterOptions{Target: "dryrun", DryRunOutput: &report, Customize: usePrivateTopology})
+	if report.Len() != 0 {
+		t.Fatalf("expected no changes with another NAT gateway in the utility subnet, got:\n%s", report.String())
+	}
+}
+
+// restrictSSH changes the minimal cluster to only allow SSH from 10.0.0.0/8
+func restrictSSH(cluster *api.Cluster, instanceGroups []*api.InstanceGroup) {
+	cluster.Spec.SSHAccess = []string{"10.0.0.0/8"}
+}
+
+// TestCreateCluster_ReconcilesSSHAccess checks that changing SSHAccess replaces the SSH rules,
+// removing the rule for the CIDR that is no longer in the list, but leaving rules for other ports alone
+func TestCreateCluster_ReconcilesSSHAccess(t *testing.T) {
+	tc, cleanup := newTestCluster(t)
+	defer cleanup()
+	cloud := tc.AWSCloud
+
+	tc.runCreateCluster(createClusterOptions{})
+
+	// A rule added by hand, for a port kops does not manage
+	groups, err := cloud.EC2.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{})
+	if err != nil || len(groups.SecurityGroups) == 0 {
+		t.Fatalf("error listing security groups: %v", err)
+	}
+	handAdded := groups.SecurityGroups[0].GroupId
+	_, err = cloud.EC2.AuthorizeSecurityGroupIngress(&ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId: handAdded,
+		IpPermissions: []*ec2.IpPermission{
+			{
+				IpProtocol: aws.String("tcp"),
+				FromPort:   aws.Int64(8080),
+				ToPort:     aws.Int64(8080),
+				IpRanges:   []*ec2.IpRange{{CidrIp: aws.String("192.0.2.0/24")}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("error adding ingress rule: %v", err)
+	}
+
+	tc.runCreateCluster(createClusterOptions{Customize: restrictSSH})
+
+	groups, err = cloud.EC2.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{})
+	if err != nil {
+		t.Fatalf("error listing security groups: %v", err)
+	}
+	for _, sg := range groups.SecurityGroups {
+		var sshCIDRs []string
+		keptHandAdded := false
+		for _, p := range sg.IpPermissions {
+			if aws.Int64Value(p.FromPort) == 8080 {
+				keptHandAdded = true
+			}
+			if aws.Int64Value(p.FromPort) != 22 {
+				continue
+			}
+			for _, r := range p.IpRanges {
+				sshCIDRs = append(sshCIDRs, aws.StringValue(r.CidrIp))
+			}
+		}
+		if len(sshCIDRs) != 1 || sshCIDRs[0] != "10.0.0.0/8" {
+			t.Fatalf("expected SSH to %s to be allowed only from 10.0.0.0/8, got %v", aws.StringValue(sg.GroupName), sshCIDRs)
+		}
+		if aws.StringValue(sg.GroupId) == aws.StringValue(handAdded) && !keptHandAdded {
+			t.Fatalf("expected the hand-added rule on %s to survive", aws.StringValue(sg.GroupName))
+		}
+	}
+}
+
+// useLoadBalancer changes the minimal cluster to put the masters behind an API load balancer
+func useLoadBalancer(cluster *api.Cluster, instanceGroups []*api.InstanceGroup) {
+	cluster.Spec.APILoadBalancer = fi.Bool(true)
+}
+
+// TestCreateCluster_APILoadBalancer checks that the masters are attached to the API ELB,
+// and that the ELB name is in the master certificate
+func TestCreateCluster_APILoadBalancer(t *testing.T) {
+	tc, cleanup := newTestCluster(t)
+	defer cleanup()
+	cloud := tc.AWSCloud
+
+	tc.runCreateCluster(createClusterOptions{Customize: useLoadBalancer})
+
+	elbName := "master-minimal-example-com"
+	lbs, err := cloud.ELB.DescribeLoadBalancers(&elb.DescribeLoadBalancersInput{
+		LoadBalancerNames: []*string{aws.String(elbName)},
+	})
+	if err != nil {
+		t.Fatalf("error listing ELBs: %v", err)
+	}
+	if len(lbs.LoadBalancerDescriptions) != 1 {
+		t.Fatalf("expected one ELB named %q, found %d", elbName, len(lbs.LoadBalancerDescriptions))
+	}
+	elbDNSName := aws.StringValue(lbs.LoadBalancerDescriptions[0].DNSName)
+
+	groups, err := cloud.Autoscaling.DescribeAutoScalingGroups(&autoscaling.DescribeAutoScalingGroupsInput{
+		AutoScalingGroupNames: []*string{aws.String("master-" + testZone + ".masters.minimal.example.com")},
+	})
+	if err != nil {
+		t.Fatalf("error listing autoscaling groups: %v", err)
+	}
+	if len(groups.AutoScalingGroups) != 1 {
+		t.Fatalf("expected one master autoscaling group, found %d", len(groups.AutoScalingGroups))
+	}
+	attached := groups.AutoScalingGroups[0].LoadBalancerNames
+	if len(attached) != 1 || aws.StringValue(attached[0]) != elbName {
+		t.Fatalf("expected master autoscaling group to be attached to %q, was %v", elbName, aws.StringValueSlice(attached))
+	}
+
+	cert, err := tc.StateStore.CA().FindCert("master")
+	if err != nil {
+		t.Fatalf("error reading master certificate: %v", err)
+	}
+	if cert == nil {
+		t.Fatalf("master certificate not found")
+	}
+	found := false
+	for _, name := range cert.Certificate.DNSNames {
+		if name == elbDNSName {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected %q in master certificate names, was %v", elbDNSName, cert.Certificate.DNSNames)
+	}
+}
+
+// useWeave changes the minimal cluster to use weave networking
+func useWeave(cluster *api.Cluster, instanceGroups []*api.InstanceGroup) {
+	cluster.Spec.Networking = &api.NetworkingSpec{Weave: &api.WeaveNetworkingSpec{}}
+}
+
+// TestCreateCluster_WeaveNetworking checks that weave networking configures the kubelet for CNI,
+// and turns off cloud routes
+func TestCreateCluster_WeaveNetworking(t *testing.T) {
+	tc, cleanup := newTestCluster(t)
+	defer cleanup()
+
+	var report bytes.Buffer
+	tc.runCreateCluster(createClusterOptions{Target: "dryrun", DryRunOutput: &report, Customize: useWeave})
+
+	completed := &api.Cluster{}
+	err := tc.StateStore.ReadConfig(PathClusterCompleted, completed)
+	if err != nil {
+		t.Fatalf("error reading completed cluster spec: %v", err)
+	}
+
+	kubelet := completed.Spec.Kubelet
+	if kubelet.NetworkPluginName != "cni" || kubelet.ConfigureCBR0 != nil {
+		t.Fatalf("expected kubelet to use the cni network plugin, was %s", fi.DebugAsJsonString(kubelet))
+	}
+	if completed.Spec.Docker.Bridge != "" {
+		t.Fatalf("did not expect docker bridge to be set, was %q", completed.Spec.Docker.Bridge)
+	}
+	configureCloudRoutes := completed.Spec.KubeControllerManager.ConfigureCloudRoutes
+	if configureCloudRoutes == nil || *configureCloudRoutes {
+		t.Fatalf("expected cloud routes to be disabled for weave")
+	}
+}
+
+// withMaxPrice changes the minimal cluster to run the nodes as spot instances, bidding price
+func withMaxPrice(price string) func(*api.Cluster, []*api.InstanceGroup) {
+	return func(cluster *api.Cluster, instanceGroups []*api.InstanceGroup) {
+		for _, g := range instanceGroups {
+			if g.Spec.Role == api.InstanceGroupRoleNode {
+				g.Spec.MaxPrice = fi.String(price)
+			}
+		}
+	}
+}
+
+// TestCreateCluster_SpotPrice checks that the maxPrice of an instance group is set on the launch configuration,
+// and that changing it is reported as a change
+func TestCreateCluster_SpotPrice(t *testing.T) {
+	tc, cleanup := newTestCluster(t)
+	defer cleanup()
+	cloud := tc.AWSCloud
+
+	tc.runCreateCluster(createClusterOptions{Customize: withMaxPrice("0.05")})
+
+	lcs, err := cloud.Autoscaling.DescribeLaunchConfigurations(&autoscaling.DescribeLaunchConfigurationsInput{})
+	if err != nil {
+		t.Fatalf("error listing launch configurations: %v", err)
+	}
+	// One for the master and one for the nodes
+	if len(lcs.LaunchConfigurations) != 2 {
+		t.Fatalf("expected 2 launch configurations, got %d", len(lcs.LaunchConfigurations))
+	}
+	spotPrices := make(map[string]string)
+	for _, lc := range lcs.LaunchConfigurations {
+		spotPrices[aws.StringValue(lc.LaunchConfigurationName)] = aws.StringValue(lc.SpotPrice)
+	}
+	for name, price := range spotPrices {
+		expected := ""
+		if strings.HasPrefix(name, "nodes.") {
+			expected = "0.05"
+		}
+		if price != expected {
+			t.Fatalf("expected SpotPrice %q for launch configuration %q, was %q", expected, name, price)
+		}
+	}
+
+	var report bytes.Buffer
+	tc.runCreateCluster(createClusterOptions{Target: "dryrun", DryRunOutput: &report, Customize: withMaxPrice("0.050")})
+	if report.Len() != 0 {
+		t.Fatalf("expected no changes when the price is written differently, got:\n%s", report.String())
+	}
+
+	tc.runCreateCluster(createClusterOptions{Target: "dryrun", DryRunOutput: &report, Customize: withMaxPrice("0.06")})
+	if !strings.Contains(report.String(), "SpotPrice") {
+		t.Fatalf("expected a change to SpotPrice, got:\n%s", report.String())
+	}
+}
+
+// withCloudLabels changes the minimal cluster to set labels as the cloudLabels of the nodes
+func withCloudLabels(labels map[string]string) func(*api.Cluster, []*api.InstanceGroup) {
+	return func(cluster *api.Cluster, instanceGroups []*api.InstanceGroup) {
+		for _, g := range instanceGroups {
+			if g.Spec.Role == api.InstanceGroupRoleNode {
+				g.Spec.CloudLabels = labels
+			}
+		}
+	}
+}
+
+// TestCreateCluster_CloudLabels checks that instance group cloudLabels are set as tags on the autoscaling groups,
+// and that changed or removed labels are reconciled on an existing cluster
+func TestCreateCluster_CloudLabels(t *testing.T) {
+	tc, cleanup := newTestCluster(t)
+	defer cleanup()
+	cloud := tc.AWSCloud
+
+	nodeTags := func() map[string]string {
+		groups, err := cloud.Autoscaling.DescribeAutoScalingGroups(&autoscaling.DescribeAutoScalingGroupsInput{})
+		if err != nil {
+			t.Fatalf("error listing autoscaling groups: %v", err)
+		}
+		for _, g := range groups.AutoScalingGroups {
+			if aws.StringValue(g.AutoScalingGroupName) != "nodes.minimal.example.com" {
+				continue
+			}
+			tags := make(map[string]string)
+			for _, tag := range g.Tags {
+				if !aws.BoolValue(tag.PropagateAtLaunch) {
+					t.Fatalf("expected tag %q to be propagated at launch", aws.StringValue(tag.Key))
+				}
+				tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+			return tags
+		}
+		t.Fatalf("autoscaling group for nodes not found")
+		return nil
+	}
+
+	tc.runCreateCluster(createClusterOptions{Customize: withCloudLabels(map[string]string{"team": "a", "owner": "ops"})})
+	tags := nodeTags()
+	if tags["team"] != "a" || tags["owner"] != "ops" || tags["k8s.io/role"] != "node" {
+		t.Fatalf("unexpected tags on nodes autoscaling group: %v", tags)
+	}
+
+	// A tag set by someone else is not ours to remove
+	_, err := cloud.Autoscaling.CreateOrUpdateTags(&autoscaling.CreateOrUpdateTagsInput{
+		Tags: []*autoscaling.Tag{{
+			Key:               aws.String("cost-center"),
+			Value:             aws.String("1234"),
+			ResourceId:        aws.String("nodes.minimal.example.com"),
+			ResourceType:      aws.String("auto-scaling-group"),
+			PropagateAtLaunch: aws.Bool(true),
+		}},
+	})
+	if err != nil {
+		t.Fatalf("error tagging autoscaling group: %v", err)
+	}
+
+	tc.runCreateCluster(createClusterOptions{Customize: withCloudLabels(map[string]string{"team": "b"})})
+	tags = nodeTags()
+	if tags["team"] != "b" {
+		t.Fatalf("expected team tag to be updated, got %v", tags)
+	}
+	if _, found := tags["owner"]; found {
+		t.Fatalf("expected owner tag to be removed, got %v", tags)
+	}
+	if tags["cost-center"] != "1234" {
+		t.Fatalf("expected tag set outside kops to be kept, got %v", tags)
+	}
+
+	// Nor does it cause a change on every run
+	var report bytes.Buffer
+	tc.runCreateCluster(createClusterOptions{Target: "dryrun", DryRunOutput: &report, Customize: withCloudLabels(map[string]string{"team": "b"})})
+	if report.Len() != 0 {
+		t.Fatalf("expected no changes, got:\n%s", report.String())
+	}
+}
@@ -10,6 +10,7 @@ type GoogleCloudURL struct {
 	Type    string
 	Name    string
 	Global  bool
+	Region  string
 	Zone    string
 }
 
@@ -21,6 +22,9 @@ func (u *GoogleCloudURL) BuildURL() string {
 	if u.Global {
 		url += "global/"
 	}
+	if u.Region != "" {
+		url += "regions/" + u.Region + "/"
+	}
 	if u.Zone != "" {
 		url += "zones/" + u.Zone + "/"
 	}
@@ -55,6 +59,12 @@ func ParseGoogleCloudURL(u string) (*GoogleCloudURL, error) {
 				return nil, fmt.Errorf("invalid google cloud URL (unexpected projects): %q", u)
 			}
 			parsed.Project = tokens[pos]
+		} else if t == "regions" {
+			pos++
+			if pos >= len(tokens) {
+				return nil, fmt.Errorf("invalid google cloud URL (unexpected regions): %q", u)
+			}
+			parsed.Region = tokens[pos]
 		} else if t == "zones" {
 			pos++
 			if pos >= len(tokens) {
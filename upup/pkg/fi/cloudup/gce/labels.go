@@ -0,0 +1,58 @@
+package gce
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// GCE labels can only contain lowercase letters, digits, dashes & underscores, so we can't use the AWS tag names
+
+// MaxLabelLength is the maximum length of a GCE label key or value, which is also the maximum length of a resource name
+const MaxLabelLength = 63
+
+// LabelClusterName is the label holding the (safe) name of the cluster that owns a resource
+const LabelClusterName = "k8s-io-cluster-name"
+
+// LabelRoleMaster is the label on resources that should only be used by masters, such as the etcd disks
+const LabelRoleMaster = "k8s-io-role-master"
+
+// LabelEtcdClusterPrefix is the prefix of the label holding the etcd cluster membership of a disk; the suffix is
+// the name of the etcd cluster, and the value is the encoded "<myname>/<allnames>" spec
+const LabelEtcdClusterPrefix = "k8s-io-etcd-"
+
+// EncodeGCELabel encodes a string so that it is a valid label value, escaping any other characters
+// as an underscore followed by the two hex digits of the character
+func EncodeGCELabel(s string) string {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' {
+			b = append(b, c)
+		} else {
+			b = append(b, []byte(fmt.Sprintf("_%02x", c))...)
+		}
+	}
+	return string(b)
+}
+
+// DecodeGCELabel reverses EncodeGCELabel
+func DecodeGCELabel(s string) (string, error) {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '_' {
+			b = append(b, c)
+			continue
+		}
+		if i+2 >= len(s) {
+			return "", fmt.Errorf("invalid escape in label %q", s)
+		}
+		v, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("invalid escape in label %q", s)
+		}
+		b = append(b, byte(v))
+		i += 2
+	}
+	return string(b), nil
+}
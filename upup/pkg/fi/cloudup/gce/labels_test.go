@@ -0,0 +1,42 @@
+package gce
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// labelTestVectors is shared with the copy of the encoding in protokube, so that the two can't drift apart
+const labelTestVectors = "testdata/labels.txt"
+
+func TestGCELabelEncoding(t *testing.T) {
+	data, err := ioutil.ReadFile(labelTestVectors)
+	if err != nil {
+		t.Fatalf("error reading %s: %v", labelTestVectors, err)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		tokens := strings.Split(line, "\t")
+		if len(tokens) == 1 {
+			if _, err := DecodeGCELabel(tokens[0]); err == nil {
+				t.Errorf("expected error decoding %q", tokens[0])
+			}
+			continue
+		}
+
+		decoded, encoded := tokens[0], tokens[1]
+		if actual := EncodeGCELabel(decoded); actual != encoded {
+			t.Errorf("EncodeGCELabel(%q): expected %q, got %q", decoded, encoded, actual)
+		}
+		actual, err := DecodeGCELabel(encoded)
+		if err != nil {
+			t.Errorf("error decoding %q: %v", encoded, err)
+		} else if actual != decoded {
+			t.Errorf("DecodeGCELabel(%q): expected %q, got %q", encoded, decoded, actual)
+		}
+	}
+}
@@ -1,7 +1,12 @@
 package gce
 
 import (
+	"fmt"
+	"github.com/golang/glog"
+	"google.golang.org/api/compute/v1"
 	"google.golang.org/api/googleapi"
+	"strings"
+	"time"
 )
 
 func IsNotFound(err error) bool {
@@ -28,3 +33,75 @@ func IsNotReady(err error) bool {
 	}
 	return false
 }
+
+// SafeClusterName returns the cluster name in a form that can be used in GCE resource names, which cannot contain dots
+func SafeClusterName(clusterName string) string {
+	return strings.Replace(clusterName, ".", "-", -1)
+}
+
+// InstanceGroupManagerName is the name of the managed instance group for an instance group in a zone
+func InstanceGroupManagerName(instanceGroupName string, zone string, clusterName string) string {
+	return instanceGroupName + "-" + zone + "-" + SafeClusterName(clusterName)
+}
+
+// MasterTag is the network tag on the masters of the cluster, which also identifies their instance templates
+func MasterTag(clusterName string) string {
+	return SafeClusterName(clusterName) + "-k8s-master"
+}
+
+// LastComponent returns the name from a resource URL (the part after the last slash)
+func LastComponent(s string) string {
+	lastSlash := strings.LastIndex(s, "/")
+	if lastSlash != -1 {
+		s = s[lastSlash+1:]
+	}
+	return s
+}
+
+// WaitForOp waits for a zonal, regional or global operation to complete, returning an error if the operation failed
+func WaitForOp(c *compute.Service, project string, op *compute.Operation) error {
+	zone := LastComponent(op.Zone)
+	region := LastComponent(op.Region)
+	var status *compute.Operation
+	for {
+		var err error
+		if zone != "" {
+			status, err = c.ZoneOperations.Get(project, zone, op.Name).Do()
+		} else if region != "" {
+			status, err = c.RegionOperations.Get(project, region, op.Name).Do()
+		} else {
+			status, err = c.GlobalOperations.Get(project, op.Name).Do()
+		}
+		if err != nil {
+			return fmt.Errorf("error fetching operation status: %v", err)
+		}
+		done := false
+		switch status.Status {
+		case "DONE":
+			done = true
+		case "PENDING", "RUNNING":
+			glog.V(4).Infof("operation status=%v", status.Status)
+		}
+
+		if done {
+			break
+		}
+
+		// TODO: Exponential backoff or similar
+		time.Sleep(1 * time.Second)
+	}
+
+	if status.Error != nil {
+		for _, e := range status.Error.Errors {
+			glog.Warningf("operation failed with error: %v", e)
+		}
+
+		return fmt.Errorf("operation failed: %v", status.Error.Errors[0].Message)
+	}
+
+	if status.Warnings != nil {
+		glog.Warningf("operation completed with warnings: %v", status.Warnings)
+	}
+
+	return nil
+}
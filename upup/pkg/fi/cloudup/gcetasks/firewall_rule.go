@@ -20,6 +20,27 @@ type FirewallRule struct {
 	Allowed      []string
 }
 
+// accessRuleSeparator separates the family of a firewall rule generated from an access list from the CIDR it allows.
+// The rules of a family are reconciled as a set, by Network.FindDeletions.
+const accessRuleSeparator = "-cidr-"
+
+// AccessRuleName builds the name of the firewall rule in the family base that allows cidr
+func AccessRuleName(base string, cidr string) string {
+	name := base + accessRuleSeparator + cidr
+	name = strings.Replace(name, ".", "-", -1)
+	name = strings.Replace(name, "/", "-", -1)
+	return name
+}
+
+// accessRuleFamily returns the family of a firewall rule built by AccessRuleName, or "" for other rules
+func accessRuleFamily(name string) string {
+	i := strings.LastIndex(name, accessRuleSeparator)
+	if i == -1 {
+		return ""
+	}
+	return name[:i]
+}
+
 var _ fi.CompareWithID = &FirewallRule{}
 
 func (e *FirewallRule) CompareWithID() *string {
@@ -117,14 +138,20 @@ func (_ *FirewallRule) RenderGCE(t *gce.GCEAPITarget, a, e, changes *FirewallRul
 	}
 
 	if a == nil {
-		_, err := t.Cloud.Compute.Firewalls.Insert(t.Cloud.Project, firewall).Do()
+		op, err := t.Cloud.Compute.Firewalls.Insert(t.Cloud.Project, firewall).Do()
 		if err != nil {
 			return fmt.Errorf("error creating FirewallRule: %v", err)
 		}
+		if err := gce.WaitForOp(t.Cloud.Compute, t.Cloud.Project, op); err != nil {
+			return fmt.Errorf("error creating FirewallRule: %v", err)
+		}
 	} else {
-		_, err := t.Cloud.Compute.Firewalls.Update(t.Cloud.Project, *e.Name, firewall).Do()
+		op, err := t.Cloud.Compute.Firewalls.Update(t.Cloud.Project, *e.Name, firewall).Do()
 		if err != nil {
-			return fmt.Errorf("error creating FirewallRule: %v", err)
+			return fmt.Errorf("error updating FirewallRule: %v", err)
+		}
+		if err := gce.WaitForOp(t.Cloud.Compute, t.Cloud.Project, op); err != nil {
+			return fmt.Errorf("error updating FirewallRule: %v", err)
 		}
 	}
 
@@ -167,6 +194,7 @@ func (_ *FirewallRule) RenderTerraform(t *terraform.TerraformTarget, a, e, chang
 	}
 	tf := &terraformFirewall{
 		Name:         g.Name,
+		SourceTags:   g.SourceTags,
 		SourceRanges: g.SourceRanges,
 		TargetTags:   g.TargetTags,
 		Allowed:      allowed,
@@ -10,7 +10,6 @@ import (
 	"k8s.io/kops/upup/pkg/fi/cloudup/terraform"
 	"reflect"
 	"strings"
-	"time"
 )
 
 var scopeAliases map[string]string
@@ -30,7 +29,7 @@ type Instance struct {
 
 	Scopes []string
 
-	Metadata    map[string]fi.Resource
+	Metadata    map[string]*fi.ResourceHolder
 	Zone        *string
 	MachineType *string
 
@@ -121,13 +120,13 @@ func (e *Instance) Find(c *fi.Context) (*Instance, error) {
 	}
 
 	if r.Metadata != nil {
-		actual.Metadata = make(map[string]fi.Resource)
+		actual.Metadata = make(map[string]*fi.ResourceHolder)
 		for _, i := range r.Metadata.Items {
 			if i.Value == nil {
 				glog.Warningf("ignoring GCE instance metadata entry with nil-value: %q", i.Key)
 				continue
 			}
-			actual.Metadata[i.Key] = fi.NewStringResource(*i.Value)
+			actual.Metadata[i.Key] = fi.WrapResource(fi.NewStringResource(*i.Value))
 		}
 		actual.metadataFingerprint = r.Metadata.Fingerprint
 	}
@@ -159,6 +158,8 @@ func expandScopeAlias(s string) string {
 		s = "https://www.googleapis.com/auth/monitoring.write"
 	case "logging-write":
 		s = "https://www.googleapis.com/auth/logging.write"
+	case "dns-rw":
+		s = "https://www.googleapis.com/auth/ndev.clouddns.readwrite"
 	}
 	return s
 }
@@ -172,6 +173,7 @@ func init() {
 		"monitoring":       "https://www.googleapis.com/auth/monitoring",
 		"monitoring-write": "https://www.googleapis.com/auth/monitoring.write",
 		"logging-write":    "https://www.googleapis.com/auth/logging.write",
+		"dns-rw":           "https://www.googleapis.com/auth/ndev.clouddns.readwrite",
 	}
 }
 
@@ -348,7 +350,7 @@ func (_ *Instance) RenderGCE(t *gce.GCEAPITarget, a, e, changes *Instance) error
 				return fmt.Errorf("error setting metadata on instance: %v", err)
 			}
 
-			err = waitCompletion(cloud.Compute, project, op)
+			err = gce.WaitForOp(cloud.Compute, project, op)
 			if err != nil {
 				return fmt.Errorf("error setting metadata on instance: %v", err)
 			}
@@ -365,46 +367,6 @@ func (_ *Instance) RenderGCE(t *gce.GCEAPITarget, a, e, changes *Instance) error
 	return nil
 }
 
-func waitCompletion(c *compute.Service, project string, op *compute.Operation) error {
-	zone := lastComponent(op.Zone)
-	var status *compute.Operation
-	for {
-		var err error
-		status, err = c.ZoneOperations.Get(project, zone, op.Name).Do()
-		if err != nil {
-			return fmt.Errorf("error fetching operation status: %v", err)
-		}
-		done := false
-		switch status.Status {
-		case "DONE":
-			done = true
-		case "PENDING", "RUNNING":
-			glog.V(4).Infof("operation status=%v", status.Status)
-		}
-
-		if done {
-			break
-		}
-
-		// TODO: Exponential backoff or similar
-		time.Sleep(1 * time.Second)
-	}
-
-	if status.Error != nil {
-		for _, e := range status.Error.Errors {
-			glog.Warningf("operation failed with error: %v", e)
-		}
-
-		return fmt.Errorf("operation failed: %v", status.Error.Errors[0].Message)
-	}
-
-	if status.Warnings != nil {
-		glog.Warningf("operation completed with warnings: %v", status.Warnings)
-	}
-
-	return nil
-}
-
 func BuildMachineTypeURL(project, zone, name string) string {
 	return fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/zones/%s/machineTypes/%s", project, zone, name)
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/golang/glog"
+	"golang.org/x/net/context"
 	"google.golang.org/api/compute/v1"
 	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/cloudup/gce"
@@ -27,29 +28,61 @@ type InstanceTemplate struct {
 
 	Scopes []string
 
-	Metadata    map[string]fi.Resource
+	Metadata    map[string]*fi.ResourceHolder
 	MachineType *string
+
+	// ID is the name of the current version of the template
+	ID *string
 }
 
 var _ fi.CompareWithID = &InstanceTemplate{}
 
+// instanceTemplateNameSuffix has the length of the "-<timestamp>" we append to the name of each version
+const instanceTemplateNameSuffix = "-20060102150405"
+
+// ValidateInstanceTemplateName checks that the versions of an instance template named name will fit in a GCE resource name
+func ValidateInstanceTemplateName(name string) error {
+	if len(name)+len(instanceTemplateNameSuffix) > gce.MaxLabelLength {
+		return fmt.Errorf("InstanceTemplate name %q is too long: it must be at most %d characters", name, gce.MaxLabelLength-len(instanceTemplateNameSuffix))
+	}
+	return nil
+}
+
 func (e *InstanceTemplate) CompareWithID() *string {
-	return e.Name
+	return e.ID
 }
 
 func (e *InstanceTemplate) Find(c *fi.Context) (*InstanceTemplate, error) {
 	cloud := c.Cloud.(*gce.GCECloud)
 
-	r, err := cloud.Compute.InstanceTemplates.Get(cloud.Project, *e.Name).Do()
-	if err != nil {
-		if gce.IsNotFound(err) {
-			return nil, nil
+	// Instance templates can't be changed, so we create a new one (named with a timestamp) on every change
+	prefix := *e.Name + "-"
+
+	var r *compute.InstanceTemplate
+	err := cloud.Compute.InstanceTemplates.List(cloud.Project).Pages(context.Background(), func(page *compute.InstanceTemplateList) error {
+		for _, t := range page.Items {
+			// Skip templates of other groups whose names share the prefix
+			if !strings.HasPrefix(t.Name, prefix) || strings.Contains(t.Name[len(prefix):], "-") {
+				continue
+			}
+			if r == nil || t.CreationTimestamp > r.CreationTimestamp {
+				r = t
+			}
 		}
+		return nil
+	})
+	if err != nil {
 		return nil, fmt.Errorf("error listing InstanceTemplates: %v", err)
 	}
+	if r == nil {
+		return nil, nil
+	}
+
+	glog.V(2).Infof("found existing InstanceTemplate: %q", r.Name)
 
 	actual := &InstanceTemplate{}
-	actual.Name = &r.Name
+	actual.Name = e.Name
+	actual.ID = &r.Name
 
 	p := r.Properties
 
@@ -73,6 +106,9 @@ func (e *InstanceTemplate) Find(c *fi.Context) (*InstanceTemplate, error) {
 	if len(p.NetworkInterfaces) != 0 {
 		ni := p.NetworkInterfaces[0]
 		actual.Network = &Network{Name: fi.String(lastComponent(ni.Network))}
+		if ni.Subnetwork != "" {
+			actual.Subnet = &Subnet{Name: fi.String(lastComponent(ni.Subnetwork))}
+		}
 	}
 
 	for _, serviceAccount := range p.ServiceAccounts {
@@ -104,12 +140,16 @@ func (e *InstanceTemplate) Find(c *fi.Context) (*InstanceTemplate, error) {
 	//}
 
 	if p.Metadata != nil {
-		actual.Metadata = make(map[string]fi.Resource)
+		actual.Metadata = make(map[string]*fi.ResourceHolder)
 		for _, meta := range p.Metadata.Items {
-			actual.Metadata[meta.Key] = fi.NewStringResource(*meta.Value)
+			actual.Metadata[meta.Key] = fi.WrapResource(fi.NewStringResource(fi.StringValue(meta.Value)))
 		}
 	}
 
+	if e.ID == nil {
+		e.ID = actual.ID
+	}
+
 	return actual, nil
 }
 
@@ -118,6 +158,17 @@ func (e *InstanceTemplate) Run(c *fi.Context) error {
 }
 
 func (_ *InstanceTemplate) CheckChanges(a, e, changes *InstanceTemplate) error {
+	if e.BootDiskImage == nil {
+		return fi.RequiredField("BootDiskImage")
+	}
+	if e.MachineType == nil {
+		return fi.RequiredField("MachineType")
+	}
+	if e.Name != nil {
+		if err := ValidateInstanceTemplateName(*e.Name); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -140,8 +191,6 @@ func (e *InstanceTemplate) mapToGCE(project string) (*compute.InstanceTemplate,
 		}
 	}
 
-	glog.Infof("We should be using NVME for GCE")
-
 	var disks []*compute.AttachedDisk
 	disks = append(disks, &compute.AttachedDisk{
 		InitializeParams: &compute.AttachedDiskInitializeParams{
@@ -173,7 +222,7 @@ func (e *InstanceTemplate) mapToGCE(project string) (*compute.InstanceTemplate,
 		Network: e.Network.URL(project),
 	}
 	if e.Subnet != nil {
-		ni.Subnetwork = *e.Subnet.Name
+		ni.Subnetwork = e.Subnet.URL(project)
 	}
 	networkInterfaces = append(networkInterfaces, ni)
 
@@ -206,7 +255,7 @@ func (e *InstanceTemplate) mapToGCE(project string) (*compute.InstanceTemplate,
 	i := &compute.InstanceTemplate{
 		Name: *e.Name,
 		Properties: &compute.InstanceProperties{
-			CanIpForward: *e.CanIPForward,
+			CanIpForward: fi.BoolValue(e.CanIPForward),
 
 			Disks: disks,
 
@@ -237,22 +286,27 @@ func (_ *InstanceTemplate) RenderGCE(t *gce.GCEAPITarget, a, e, changes *Instanc
 		return err
 	}
 
-	if a == nil {
-		_, err := t.Cloud.Compute.InstanceTemplates.Insert(t.Cloud.Project, i).Do()
-		if err != nil {
-			return fmt.Errorf("error creating InstanceTemplate: %v", err)
-		}
-	} else {
-		// TODO: Make error again
-		glog.Errorf("Cannot apply changes to InstanceTemplate: %v", changes)
-		//return fmt.Errorf("Cannot apply changes to InstanceTemplate: %v", changes)
+	// We always create a new version; the managed instance groups are then pointed at it
+	i.Name = *e.Name + "-" + fi.BuildTimestampString()
+	glog.V(2).Infof("Creating InstanceTemplate %q", i.Name)
+
+	op, err := t.Cloud.Compute.InstanceTemplates.Insert(project, i).Do()
+	if err != nil {
+		return fmt.Errorf("error creating InstanceTemplate: %v", err)
+	}
+	err = gce.WaitForOp(t.Cloud.Compute, project, op)
+	if err != nil {
+		return fmt.Errorf("error creating InstanceTemplate: %v", err)
 	}
 
+	e.ID = fi.String(i.Name)
+
 	return nil
 }
 
 type terraformInstanceTemplate struct {
-	Name                  string                       `json:"name"`
+	Name                  string                       `json:"name,omitempty"`
+	NamePrefix            string                       `json:"name_prefix,omitempty"`
 	CanIPForward          bool                         `json:"can_ip_forward"`
 	MachineType           string                       `json:"machine_type,omitempty"`
 	ServiceAccount        *terraformServiceAccount     `json:"service_account,omitempty"`
@@ -263,6 +317,9 @@ type terraformInstanceTemplate struct {
 	MetadataStartupScript string                       `json:"metadata_startup_script,omitempty"`
 	Tags                  []string                     `json:"tags,omitempty"`
 
+	// Only for instance templates:
+	Lifecycle *terraformLifecycle `json:"lifecycle,omitempty"`
+
 	// Only for instances:
 	Zone string `json:"zone,omitempty"`
 }
@@ -372,7 +429,7 @@ func (_ *InstanceTemplate) RenderTerraform(t *terraform.TerraformTarget, a, e, c
 	}
 
 	tf := &terraformInstanceTemplate{
-		Name:         i.Name,
+		NamePrefix:   i.Name + "-",
 		CanIPForward: i.Properties.CanIpForward,
 		//Description: i.Properties.Description,
 		MachineType: i.Properties.MachineType,
@@ -410,9 +467,16 @@ func (_ *InstanceTemplate) RenderTerraform(t *terraform.TerraformTarget, a, e, c
 		}
 	}
 
+	// So that we can update templates
+	tf.Lifecycle = &terraformLifecycle{CreateBeforeDestroy: fi.Bool(true)}
+
 	return t.RenderResource("google_compute_instance_template", i.Name, tf)
 }
 
+type terraformLifecycle struct {
+	CreateBeforeDestroy *bool `json:"create_before_destroy,omitempty"`
+}
+
 func (i *InstanceTemplate) TerraformLink() *terraform.Literal {
 	return terraform.LiteralSelfLink("google_compute_instance_template", *i.Name)
 }
@@ -82,7 +82,11 @@ func (_ *IPAddress) RenderGCE(t *gce.GCEAPITarget, a, e, changes *IPAddress) err
 	if a == nil {
 		glog.Infof("GCE creating address: %q", addr.Name)
 
-		_, err := t.Cloud.Compute.Addresses.Insert(t.Cloud.Project, t.Cloud.Region, addr).Do()
+		op, err := t.Cloud.Compute.Addresses.Insert(t.Cloud.Project, t.Cloud.Region, addr).Do()
+		if err != nil {
+			return fmt.Errorf("error creating IPAddress: %v", err)
+		}
+		err = gce.WaitForOp(t.Cloud.Compute, t.Cloud.Project, op)
 		if err != nil {
 			return fmt.Errorf("error creating IPAddress: %v", err)
 		}
@@ -43,7 +43,7 @@ func (e *ManagedInstanceGroup) Find(c *fi.Context) (*ManagedInstanceGroup, error
 	actual.Zone = fi.String(lastComponent(r.Zone))
 	actual.BaseInstanceName = &r.BaseInstanceName
 	actual.TargetSize = &r.TargetSize
-	actual.InstanceTemplate = &InstanceTemplate{Name: fi.String(lastComponent(r.InstanceTemplate))}
+	actual.InstanceTemplate = &InstanceTemplate{ID: fi.String(lastComponent(r.InstanceTemplate))}
 
 	return actual, nil
 }
@@ -53,6 +53,14 @@ func (e *ManagedInstanceGroup) Run(c *fi.Context) error {
 }
 
 func (_ *ManagedInstanceGroup) CheckChanges(a, e, changes *ManagedInstanceGroup) error {
+	if a != nil {
+		if changes.Zone != nil {
+			return fi.CannotChangeField("Zone")
+		}
+		if changes.BaseInstanceName != nil {
+			return fi.CannotChangeField("BaseInstanceName")
+		}
+	}
 	return nil
 }
 
@@ -63,17 +71,19 @@ func BuildInstanceTemplateURL(project, name string) string {
 func (_ *ManagedInstanceGroup) RenderGCE(t *gce.GCEAPITarget, a, e, changes *ManagedInstanceGroup) error {
 	project := t.Cloud.Project
 
-	i := &compute.InstanceGroupManager{
-		Name:             *e.Name,
-		Zone:             *e.Zone,
-		BaseInstanceName: *e.BaseInstanceName,
-		TargetSize:       *e.TargetSize,
-		InstanceTemplate: BuildInstanceTemplateURL(project, *e.InstanceTemplate.Name),
-	}
+	instanceTemplateURL := BuildInstanceTemplateURL(project, *e.InstanceTemplate.ID)
 
 	if a == nil {
+		i := &compute.InstanceGroupManager{
+			Name:             *e.Name,
+			Zone:             *e.Zone,
+			BaseInstanceName: *e.BaseInstanceName,
+			TargetSize:       *e.TargetSize,
+			InstanceTemplate: instanceTemplateURL,
+		}
+
 		for {
-			_, err := t.Cloud.Compute.InstanceGroupManagers.Insert(t.Cloud.Project, *e.Zone, i).Do()
+			op, err := t.Cloud.Compute.InstanceGroupManagers.Insert(project, *e.Zone, i).Do()
 			if err != nil {
 				if gce.IsNotReady(err) {
 					glog.Infof("Found resourceNotReady error - sleeping before retry: %v", err)
@@ -81,12 +91,42 @@ func (_ *ManagedInstanceGroup) RenderGCE(t *gce.GCEAPITarget, a, e, changes *Man
 					continue
 				}
 				return fmt.Errorf("error creating ManagedInstanceGroup: %v", err)
-			} else {
-				break
 			}
+			err = gce.WaitForOp(t.Cloud.Compute, project, op)
+			if err != nil {
+				return fmt.Errorf("error creating ManagedInstanceGroup: %v", err)
+			}
+			break
+		}
+		return nil
+	}
+
+	// Existing instances keep running from the old template; they are replaced by a rolling update
+	if changes.InstanceTemplate != nil {
+		glog.V(2).Infof("Updating InstanceTemplate of ManagedInstanceGroup %q to %q", *e.Name, *e.InstanceTemplate.ID)
+		request := &compute.InstanceGroupManagersSetInstanceTemplateRequest{
+			InstanceTemplate: instanceTemplateURL,
+		}
+		op, err := t.Cloud.Compute.InstanceGroupManagers.SetInstanceTemplate(project, *e.Zone, *e.Name, request).Do()
+		if err != nil {
+			return fmt.Errorf("error setting InstanceTemplate on ManagedInstanceGroup: %v", err)
+		}
+		err = gce.WaitForOp(t.Cloud.Compute, project, op)
+		if err != nil {
+			return fmt.Errorf("error setting InstanceTemplate on ManagedInstanceGroup: %v", err)
+		}
+	}
+
+	if changes.TargetSize != nil {
+		glog.V(2).Infof("Resizing ManagedInstanceGroup %q to %d", *e.Name, *e.TargetSize)
+		op, err := t.Cloud.Compute.InstanceGroupManagers.Resize(project, *e.Zone, *e.Name, *e.TargetSize).Do()
+		if err != nil {
+			return fmt.Errorf("error resizing ManagedInstanceGroup: %v", err)
+		}
+		err = gce.WaitForOp(t.Cloud.Compute, project, op)
+		if err != nil {
+			return fmt.Errorf("error resizing ManagedInstanceGroup: %v", err)
 		}
-	} else {
-		return fmt.Errorf("Cannot apply changes to ManagedInstanceGroup: %v", changes)
 	}
 
 	return nil
@@ -98,6 +138,7 @@ type terraformInstanceGroupManager struct {
 	BaseInstanceName *string            `json:"base_instance_name"`
 	InstanceTemplate *terraform.Literal `json:"instance_template"`
 	TargetSize       *int64             `json:"target_size"`
+	UpdateStrategy   *string            `json:"update_strategy,omitempty"`
 }
 
 func (_ *ManagedInstanceGroup) RenderTerraform(t *terraform.TerraformTarget, a, e, changes *ManagedInstanceGroup) error {
@@ -107,6 +148,8 @@ func (_ *ManagedInstanceGroup) RenderTerraform(t *terraform.TerraformTarget, a,
 		BaseInstanceName: e.BaseInstanceName,
 		InstanceTemplate: e.InstanceTemplate.TerraformLink(),
 		TargetSize:       e.TargetSize,
+		// Don't let terraform restart every instance when the template changes; kops rolling-update replaces them
+		UpdateStrategy: fi.String("NONE"),
 	}
 
 	return t.RenderResource("google_compute_instance_group_manager", *e.Name, tf)
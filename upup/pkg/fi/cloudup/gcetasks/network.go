@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/golang/glog"
+	"golang.org/x/net/context"
 	"google.golang.org/api/compute/v1"
 	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/cloudup/gce"
@@ -13,6 +14,7 @@ import (
 //go:generate fitask -type=Network
 type Network struct {
 	Name *string
+	// CIDR is set for a legacy network; if not set the network is created in custom subnet mode, and has a Subnet per region
 	CIDR *string
 }
 
@@ -35,7 +37,9 @@ func (e *Network) Find(c *fi.Context) (*Network, error) {
 
 	actual := &Network{}
 	actual.Name = &r.Name
-	actual.CIDR = &r.IPv4Range
+	if r.IPv4Range != "" {
+		actual.CIDR = &r.IPv4Range
+	}
 
 	if r.SelfLink != e.URL(cloud.Project) {
 		glog.Warningf("SelfLink did not match URL: %q vs %q", r.SelfLink, e.URL(cloud.Project))
@@ -64,23 +68,23 @@ func (_ *Network) CheckChanges(a, e, changes *Network) error {
 
 func (_ *Network) RenderGCE(t *gce.GCEAPITarget, a, e, changes *Network) error {
 	if a == nil {
-		glog.V(2).Infof("Creating Network with CIDR: %q", *e.CIDR)
-
 		network := &compute.Network{
-			IPv4Range: *e.CIDR,
-
-			//// AutoCreateSubnetworks: When set to true, the network is created in
-			//// "auto subnet mode". When set to false, the network is in "custom
-			//// subnet mode".
-			////
-			//// In "auto subnet mode", a newly created network is assigned the
-			//// default CIDR of 10.128.0.0/9 and it automatically creates one
-			//// subnetwork per region.
-			//AutoCreateSubnetworks bool `json:"autoCreateSubnetworks,omitempty"`
-
 			Name: *e.Name,
 		}
-		_, err := t.Cloud.Compute.Networks.Insert(t.Cloud.Project, network).Do()
+		if e.CIDR != nil {
+			glog.V(2).Infof("Creating legacy Network with CIDR: %q", *e.CIDR)
+			network.IPv4Range = *e.CIDR
+		} else {
+			glog.V(2).Infof("Creating Network %q in custom subnet mode", *e.Name)
+			// AutoCreateSubnetworks must be sent explicitly, as false is the zero value
+			network.ForceSendFields = []string{"AutoCreateSubnetworks"}
+		}
+
+		op, err := t.Cloud.Compute.Networks.Insert(t.Cloud.Project, network).Do()
+		if err != nil {
+			return fmt.Errorf("error creating Network: %v", err)
+		}
+		err = gce.WaitForOp(t.Cloud.Compute, t.Cloud.Project, op)
 		if err != nil {
 			return fmt.Errorf("error creating Network: %v", err)
 		}
@@ -89,17 +93,89 @@ func (_ *Network) RenderGCE(t *gce.GCEAPITarget, a, e, changes *Network) error {
 	return nil
 }
 
+var _ fi.ProducesDeletions = &Network{}
+
+// FindDeletions finds the firewall rules on the network that were generated from an access list,
+// but whose CIDR has since been removed from the list.  Other firewall rules are left alone.
+func (e *Network) FindDeletions(c *fi.Context) ([]fi.Deletion, error) {
+	cloud := c.Cloud.(*gce.GCECloud)
+
+	expected := make(map[string]bool)
+	families := make(map[string]bool)
+	for _, task := range c.AllTasks() {
+		rule, ok := task.(*FirewallRule)
+		if !ok || rule.Network == nil || fi.StringValue(rule.Network.Name) != *e.Name {
+			continue
+		}
+		name := fi.StringValue(rule.Name)
+		expected[name] = true
+		if family := accessRuleFamily(name); family != "" {
+			families[family] = true
+		}
+	}
+	if len(families) == 0 {
+		return nil, nil
+	}
+
+	var deletions []fi.Deletion
+	err := cloud.Compute.Firewalls.List(cloud.Project).Pages(context.Background(), func(page *compute.FirewallList) error {
+		for _, r := range page.Items {
+			if lastComponent(r.Network) != *e.Name || expected[r.Name] {
+				continue
+			}
+			if families[accessRuleFamily(r.Name)] {
+				deletions = append(deletions, &deleteFirewallRule{Name: r.Name})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing FirewallRules: %v", err)
+	}
+	return deletions, nil
+}
+
+// deleteFirewallRule deletes a firewall rule that is no longer in the model
+type deleteFirewallRule struct {
+	Name string
+}
+
+var _ fi.Deletion = &deleteFirewallRule{}
+
+func (d *deleteFirewallRule) TaskName() string {
+	return "firewall-rule"
+}
+
+func (d *deleteFirewallRule) Item() string {
+	return d.Name
+}
+
+func (d *deleteFirewallRule) Delete(t fi.Target) error {
+	gceTarget, ok := t.(*gce.GCEAPITarget)
+	if !ok {
+		return fmt.Errorf("unexpected target type for deletion: %T", t)
+	}
+
+	_, err := gceTarget.Cloud.Compute.Firewalls.Delete(gceTarget.Cloud.Project, d.Name).Do()
+	if err != nil {
+		return fmt.Errorf("error deleting FirewallRule %q: %v", d.Name, err)
+	}
+	return nil
+}
+
 type terraformNetwork struct {
-	Name *string `json:"name"`
-	CIDR *string `json:"ipv4_range"`
-	//AutoCreateSubnetworks bool `json:"auto_create_subnetworks"`
+	Name                  *string `json:"name"`
+	CIDR                  *string `json:"ipv4_range,omitempty"`
+	AutoCreateSubnetworks *bool   `json:"auto_create_subnetworks,omitempty"`
 }
 
 func (_ *Network) RenderTerraform(t *terraform.TerraformTarget, a, e, changes *Network) error {
 	tf := &terraformNetwork{
 		Name: e.Name,
 		CIDR: e.CIDR,
-		//AutoCreateSubnetworks: false,
+	}
+	if e.CIDR == nil {
+		tf.AutoCreateSubnetworks = fi.Bool(false)
 	}
 
 	return t.RenderResource("google_compute_network", *e.Name, tf)
@@ -16,6 +16,9 @@ type PersistentDisk struct {
 	VolumeType *string
 	SizeGB     *int64
 	Zone       *string
+
+	// Labels are set when the disk is created; protokube uses them to find the etcd disks
+	Labels map[string]string
 }
 
 var _ fi.CompareWithID = &PersistentDisk{}
@@ -50,6 +53,9 @@ func (e *PersistentDisk) Find(c *fi.Context) (*PersistentDisk, error) {
 	actual.VolumeType = fi.String(lastComponent(r.Type))
 	actual.Zone = fi.String(lastComponent(r.Zone))
 	actual.SizeGB = &r.SizeGb
+	if len(r.Labels) != 0 {
+		actual.Labels = r.Labels
+	}
 
 	return actual, nil
 }
@@ -79,6 +85,9 @@ func (_ *PersistentDisk) CheckChanges(a, e, changes *PersistentDisk) error {
 		if changes.VolumeType != nil {
 			return fi.CannotChangeField("VolumeType")
 		}
+		if changes.Labels != nil {
+			return fi.CannotChangeField("Labels")
+		}
 	} else {
 		if e.Zone == nil {
 			return fi.RequiredField("Zone")
@@ -97,10 +106,15 @@ func (_ *PersistentDisk) RenderGCE(t *gce.GCEAPITarget, a, e, changes *Persisten
 		Name:   *e.Name,
 		SizeGb: *e.SizeGB,
 		Type:   typeURL,
+		Labels: e.Labels,
 	}
 
 	if a == nil {
-		_, err := t.Cloud.Compute.Disks.Insert(t.Cloud.Project, *e.Zone, disk).Do()
+		op, err := t.Cloud.Compute.Disks.Insert(t.Cloud.Project, *e.Zone, disk).Do()
+		if err != nil {
+			return fmt.Errorf("error creating PersistentDisk: %v", err)
+		}
+		err = gce.WaitForOp(t.Cloud.Compute, t.Cloud.Project, op)
 		if err != nil {
 			return fmt.Errorf("error creating PersistentDisk: %v", err)
 		}
@@ -112,10 +126,11 @@ func (_ *PersistentDisk) RenderGCE(t *gce.GCEAPITarget, a, e, changes *Persisten
 }
 
 type terraformDisk struct {
-	Name       *string `json:"name"`
-	VolumeType *string `json:"type"`
-	SizeGB     *int64  `json:"size"`
-	Zone       *string `json:"zone"`
+	Name       *string           `json:"name"`
+	VolumeType *string           `json:"type"`
+	SizeGB     *int64            `json:"size"`
+	Zone       *string           `json:"zone"`
+	Labels     map[string]string `json:"labels,omitempty"`
 }
 
 func (_ *PersistentDisk) RenderTerraform(t *terraform.TerraformTarget, a, e, changes *PersistentDisk) error {
@@ -124,6 +139,7 @@ func (_ *PersistentDisk) RenderTerraform(t *terraform.TerraformTarget, a, e, cha
 		VolumeType: e.VolumeType,
 		SizeGB:     e.SizeGB,
 		Zone:       e.Zone,
+		Labels:     e.Labels,
 	}
 	return t.RenderResource("google_compute_disk", *e.Name, tf)
 }
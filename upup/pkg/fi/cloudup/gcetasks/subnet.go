@@ -27,7 +27,7 @@ func (e *Subnet) CompareWithID() *string {
 func (e *Subnet) Find(c *fi.Context) (*Subnet, error) {
 	cloud := c.Cloud.(*gce.GCECloud)
 
-	s, err := cloud.Compute.Subnetworks.Get(cloud.Project, cloud.Region, *e.Name).Do()
+	s, err := cloud.Compute.Subnetworks.Get(cloud.Project, *e.Region, *e.Name).Do()
 	if err != nil {
 		if gce.IsNotFound(err) {
 			return nil, nil
@@ -37,8 +37,8 @@ func (e *Subnet) Find(c *fi.Context) (*Subnet, error) {
 
 	actual := &Subnet{}
 	actual.Name = &s.Name
-	actual.Network = &Network{Name: &s.Network}
-	actual.Region = &s.Region
+	actual.Network = &Network{Name: fi.String(lastComponent(s.Network))}
+	actual.Region = fi.String(lastComponent(s.Region))
 	actual.CIDR = &s.IpCidrRange
 
 	return actual, nil
@@ -49,9 +49,37 @@ func (e *Subnet) Run(c *fi.Context) error {
 }
 
 func (_ *Subnet) CheckChanges(a, e, changes *Subnet) error {
+	if a == nil {
+		if e.Region == nil {
+			return fi.RequiredField("Region")
+		}
+		if e.CIDR == nil {
+			return fi.RequiredField("CIDR")
+		}
+	} else {
+		if changes.Network != nil {
+			return fi.CannotChangeField("Network")
+		}
+		if changes.Region != nil {
+			return fi.CannotChangeField("Region")
+		}
+		if changes.CIDR != nil {
+			return fi.CannotChangeField("CIDR")
+		}
+	}
 	return nil
 }
 
+func (e *Subnet) URL(project string) string {
+	u := &gce.GoogleCloudURL{
+		Project: project,
+		Region:  *e.Region,
+		Type:    "subnetworks",
+		Name:    *e.Name,
+	}
+	return u.BuildURL()
+}
+
 func (_ *Subnet) RenderGCE(t *gce.GCEAPITarget, a, e, changes *Subnet) error {
 	if a == nil {
 		glog.V(2).Infof("Creating Subnet with CIDR: %q", *e.CIDR)
@@ -59,9 +87,13 @@ func (_ *Subnet) RenderGCE(t *gce.GCEAPITarget, a, e, changes *Subnet) error {
 		subnet := &compute.Subnetwork{
 			IpCidrRange: *e.CIDR,
 			Name:        *e.Name,
-			Network:     *e.Network.Name,
+			Network:     e.Network.URL(t.Cloud.Project),
+		}
+		op, err := t.Cloud.Compute.Subnetworks.Insert(t.Cloud.Project, *e.Region, subnet).Do()
+		if err != nil {
+			return fmt.Errorf("error creating Subnet: %v", err)
 		}
-		_, err := t.Cloud.Compute.Subnetworks.Insert(t.Cloud.Project, t.Cloud.Region, subnet).Do()
+		err = gce.WaitForOp(t.Cloud.Compute, t.Cloud.Project, op)
 		if err != nil {
 			return fmt.Errorf("error creating Subnet: %v", err)
 		}
@@ -77,7 +109,7 @@ type terraformSubnet struct {
 	CIDR    *string            `json:"ip_cidr_range"`
 }
 
-func (_ *Subnet) RenderSubnet(t *terraform.TerraformTarget, a, e, changes *Subnet) error {
+func (_ *Subnet) RenderTerraform(t *terraform.TerraformTarget, a, e, changes *Subnet) error {
 	tf := &terraformSubnet{
 		Name:    e.Name,
 		Network: e.Network.TerraformName(),
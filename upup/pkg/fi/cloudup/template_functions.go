@@ -4,6 +4,8 @@ import (
 	"encoding/binary"
 	"fmt"
 	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi/cloudup/gce"
+	"k8s.io/kops/upup/pkg/fi/cloudup/gcetasks"
 	"math/big"
 	"net"
 	"sort"
@@ -49,13 +51,49 @@ func (tf *TemplateFunctions) WellKnownServiceIP(id int) (net.IP, error) {
 
 func (tf *TemplateFunctions) AddTo(dest template.FuncMap) {
 	dest["EtcdClusterMemberTags"] = tf.EtcdClusterMemberTags
+	dest["GCEEtcdClusterMemberLabels"] = tf.GCEEtcdClusterMemberLabels
+	dest["InstanceGroupZoneSizes"] = tf.InstanceGroupZoneSizes
 	dest["SharedVPC"] = tf.SharedVPC
 	dest["WellKnownServiceIP"] = tf.WellKnownServiceIP
+	dest["AccessRuleName"] = gcetasks.AccessRuleName
 }
 
 func (tf *TemplateFunctions) EtcdClusterMemberTags(etcd *api.EtcdClusterSpec, m *api.EtcdMemberSpec) map[string]string {
 	tags := make(map[string]string)
 
+	// This is the configuration of the etcd cluster
+	tags["k8s.io/etcd/"+etcd.Name] = etcdClusterMemberSpec(etcd, m)
+
+	// This says "only mount on a master"
+	tags["k8s.io/role/master"] = "1"
+
+	return tags
+}
+
+// GCEEtcdClusterMemberLabels is the equivalent of EtcdClusterMemberTags for GCE disks,
+// where the label keys & values have a restricted character set and so are encoded
+func (tf *TemplateFunctions) GCEEtcdClusterMemberLabels(etcd *api.EtcdClusterSpec, m *api.EtcdMemberSpec) (map[string]string, error) {
+	labels := make(map[string]string)
+
+	labels[gce.LabelClusterName] = gce.EncodeGCELabel(tf.cluster.Name)
+	labels[gce.LabelEtcdClusterPrefix+gce.EncodeGCELabel(etcd.Name)] = gce.EncodeGCELabel(etcdClusterMemberSpec(etcd, m))
+	labels[gce.LabelRoleMaster] = "1"
+
+	// Encoding can triple the length, so check here rather than have GCE reject the disk part-way through
+	for k, v := range labels {
+		if len(k) > gce.MaxLabelLength {
+			return nil, fmt.Errorf("GCE label %q for etcd cluster %q is longer than %d characters; use a shorter etcd cluster name", k, etcd.Name, gce.MaxLabelLength)
+		}
+		if len(v) > gce.MaxLabelLength {
+			return nil, fmt.Errorf("GCE label %s=%q for etcd member %q is longer than %d characters once encoded; use shorter cluster or etcd member names", k, v, m.Name, gce.MaxLabelLength)
+		}
+	}
+
+	return labels, nil
+}
+
+// etcdClusterMemberSpec builds the "<myname>/<allnames>" spec that protokube reads from the volume of an etcd member
+func etcdClusterMemberSpec(etcd *api.EtcdClusterSpec, m *api.EtcdMemberSpec) string {
 	var allMembers []string
 
 	for _, m := range etcd.Members {
@@ -64,13 +102,36 @@ func (tf *TemplateFunctions) EtcdClusterMemberTags(etcd *api.EtcdClusterSpec, m
 
 	sort.Strings(allMembers)
 
-	// This is the configuration of the etcd cluster
-	tags["k8s.io/etcd/"+etcd.Name] = m.Name + "/" + strings.Join(allMembers, ",")
+	return m.Name + "/" + strings.Join(allMembers, ",")
+}
 
-	// This says "only mount on a master"
-	tags["k8s.io/role/master"] = "1"
+// InstanceGroupZoneSizes divides the size of an instance group between its zones, for clouds (GCE)
+// where we create a group per zone.  The earlier zones get any remainder.
+func (tf *TemplateFunctions) InstanceGroupZoneSizes(ig *api.InstanceGroup) (map[string]int, error) {
+	if len(ig.Spec.Zones) == 0 {
+		return nil, fmt.Errorf("InstanceGroup %q must specify at least one zone", ig.Name)
+	}
 
-	return tags
+	size := 2
+	if ig.IsMaster() {
+		size = 1
+	}
+	if ig.Spec.MinSize != nil {
+		size = *ig.Spec.MinSize
+	}
+
+	zones := make([]string, len(ig.Spec.Zones))
+	copy(zones, ig.Spec.Zones)
+	sort.Strings(zones)
+
+	sizes := make(map[string]int)
+	for i, zone := range zones {
+		sizes[zone] = size / len(zones)
+		if i < size%len(zones) {
+			sizes[zone]++
+		}
+	}
+	return sizes, nil
 }
 
 // SharedVPC is a simple helper function which makes the templates for a shared VPC clearer
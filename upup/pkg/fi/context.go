@@ -18,6 +18,9 @@ type Context struct {
 	SecretStore SecretStore
 
 	CheckExisting bool
+
+	// tasks is the set of tasks being run, so that tasks can find deletions relative to the whole model
+	tasks map[string]Task
 }
 
 func NewContext(target Target, cloud Cloud, castore CAStore, secretStore SecretStore, checkExisting bool) (*Context, error) {
@@ -38,9 +41,15 @@ func NewContext(target Target, cloud Cloud, castore CAStore, secretStore SecretS
 	return c, nil
 }
 
-func (c *Context) RunTasks(taskMap map[string]Task) error {
+// RunTasks executes the tasks in dependency order; unset fields in options take the default values
+func (c *Context) RunTasks(taskMap map[string]Task, options RunTasksOptions) error {
+	options.InitDefaults()
+
+	c.tasks = taskMap
+
 	e := &executor{
 		context: c,
+		options: options,
 	}
 	return e.RunTasks(taskMap)
 }
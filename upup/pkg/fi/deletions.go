@@ -0,0 +1,115 @@
+package fi
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"sort"
+)
+
+// Deletion is a cloud object that is no longer part of the model, and should be removed
+type Deletion interface {
+	// TaskName is the type of the object being deleted, e.g. "security-group"
+	TaskName() string
+	// Item identifies the object being deleted
+	Item() string
+	// Delete removes the object
+	Delete(target Target) error
+}
+
+// ProducesDeletions is implemented by tasks that own cloud objects which are not themselves tasks
+// (for example the rules of a security group), and so can find the ones that have been removed from the model.
+type ProducesDeletions interface {
+	FindDeletions(c *Context) ([]Deletion, error)
+}
+
+// HasCloudResourceID is implemented by tasks that manage a single cloud object which is tagged as owned by the cluster.
+// It lets us recognize owned objects that no longer have a task, and so have been orphaned.
+type HasCloudResourceID interface {
+	// CloudResourceID returns the type of the object, as named by the resource listers, and its id.
+	// The id is empty if the object does not exist or has not been found.
+	CloudResourceID() (resourceType string, id string)
+}
+
+// ClaimedCloudResources returns the "type:id" keys of the cloud objects managed by the tasks.
+// Types for which some task did not know its id are returned in unidentified: we cannot safely
+// treat any object of such a type as orphaned.
+func ClaimedCloudResources(taskMap map[string]Task) (claimed map[string]bool, unidentified map[string]bool) {
+	claimed = make(map[string]bool)
+	unidentified = make(map[string]bool)
+	for _, task := range taskMap {
+		hasID, ok := task.(HasCloudResourceID)
+		if !ok {
+			continue
+		}
+		resourceType, id := hasID.CloudResourceID()
+		if id == "" {
+			unidentified[resourceType] = true
+			continue
+		}
+		claimed[resourceType+":"+id] = true
+	}
+	return claimed, unidentified
+}
+
+// AllTasks returns the tasks that are being run in this context
+func (c *Context) AllTasks() map[string]Task {
+	return c.tasks
+}
+
+// FindDeletions collects the deletions from all the tasks that implement ProducesDeletions, sorted for stable output
+func (c *Context) FindDeletions() ([]Deletion, error) {
+	var deletions []Deletion
+	for k, task := range c.tasks {
+		producer, ok := task.(ProducesDeletions)
+		if !ok {
+			continue
+		}
+		found, err := producer.FindDeletions(c)
+		if err != nil {
+			return nil, fmt.Errorf("error finding deletions for %q: %v", k, err)
+		}
+		deletions = append(deletions, found...)
+	}
+	sort.Sort(deletionsByKey(deletions))
+	return deletions, nil
+}
+
+// RunDeletions performs the deletions, or records them if this is a dry-run.
+// All deletions are attempted; the failures are returned as TaskErrors.
+func (c *Context) RunDeletions(deletions []Deletion) error {
+	if dryRun, ok := c.Target.(*DryRunTarget); ok {
+		for _, d := range deletions {
+			if err := dryRun.Delete(d); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	taskErrors := &TaskErrors{
+		Errors: make(map[string]error),
+	}
+	for _, d := range deletions {
+		key := DeletionKey(d)
+		glog.Infof("Deleting %s", key)
+		if err := d.Delete(c.Target); err != nil {
+			glog.Warningf("error deleting %s: %v", key, err)
+			taskErrors.Errors[key] = err
+		}
+	}
+	if len(taskErrors.Errors) != 0 {
+		return taskErrors
+	}
+	return nil
+}
+
+// DeletionKey is the "type:item" key used to report a deletion
+func DeletionKey(d Deletion) string {
+	return d.TaskName() + ":" + d.Item()
+}
+
+type deletionsByKey []Deletion
+
+func (a deletionsByKey) Len() int           { return len(a) }
+func (a deletionsByKey) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a deletionsByKey) Less(i, j int) bool { return DeletionKey(a[i]) < DeletionKey(a[j]) }
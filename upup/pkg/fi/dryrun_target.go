@@ -4,10 +4,12 @@ import (
 	"fmt"
 
 	"bytes"
+	"encoding/json"
 	"github.com/golang/glog"
 	"io"
 	"k8s.io/kops/upup/pkg/fi/utils"
 	"reflect"
+	"sort"
 )
 
 // DryRunTarget is a special Target that does not execute anything, but instead tracks all changes.
@@ -16,8 +18,13 @@ import (
 type DryRunTarget struct {
 	changes []*render
 
+	// deletions are the objects that would be deleted
+	deletions []Deletion
+
 	// The destination to which the final report will be printed on Finish()
 	out io.Writer
+	// The format of the final report
+	format DryRunFormat
 }
 
 type render struct {
@@ -29,9 +36,10 @@ type render struct {
 
 var _ Target = &DryRunTarget{}
 
-func NewDryRunTarget(out io.Writer) *DryRunTarget {
+func NewDryRunTarget(out io.Writer, format DryRunFormat) *DryRunTarget {
 	t := &DryRunTarget{}
 	t.out = out
+	t.format = format
 	return t
 }
 
@@ -48,6 +56,12 @@ func (t *DryRunTarget) Render(a, e, changes Task) error {
 	return nil
 }
 
+// Delete records a deletion, instead of performing it
+func (t *DryRunTarget) Delete(deletion Deletion) error {
+	t.deletions = append(t.deletions, deletion)
+	return nil
+}
+
 func IdForTask(taskMap map[string]Task, t Task) string {
 	for k, v := range taskMap {
 		if v == t {
@@ -58,7 +72,217 @@ func IdForTask(taskMap map[string]Task, t Task) string {
 	return "?"
 }
 
+// DryRunFormat is the format in which the DryRunTarget prints its report
+type DryRunFormat string
+
+const (
+	// DryRunFormatText is the human-readable "Will create / Will modify" listing
+	DryRunFormatText DryRunFormat = "text"
+	// DryRunFormatJSON prints the DryRunReport as JSON
+	DryRunFormatJSON DryRunFormat = "json"
+	// DryRunFormatYAML prints the DryRunReport as YAML
+	DryRunFormatYAML DryRunFormat = "yaml"
+)
+
+// ParseDryRunFormat validates a user-supplied output format; the empty string means text
+func ParseDryRunFormat(s string) (DryRunFormat, error) {
+	switch DryRunFormat(s) {
+	case "", DryRunFormatText:
+		return DryRunFormatText, nil
+	case DryRunFormatJSON, DryRunFormatYAML:
+		return DryRunFormat(s), nil
+	default:
+		return "", fmt.Errorf("unknown output format %q (valid values: %s, %s, %s)", s, DryRunFormatText, DryRunFormatJSON, DryRunFormatYAML)
+	}
+}
+
+// DryRunAction is the action the dry-run determined would be taken for a task
+type DryRunAction string
+
+const (
+	DryRunActionCreate DryRunAction = "create"
+	DryRunActionModify DryRunAction = "modify"
+	DryRunActionNoop   DryRunAction = "no-op"
+	DryRunActionDelete DryRunAction = "delete"
+)
+
+// DryRunReport is the machine-readable form of the dry-run plan
+type DryRunReport struct {
+	Tasks []*DryRunTaskReport `json:"tasks"`
+}
+
+// DryRunTaskReport describes the action that would be taken for a single task
+type DryRunTaskReport struct {
+	Key    string       `json:"key"`
+	Type   string       `json:"type"`
+	Action DryRunAction `json:"action"`
+
+	// Changes lists the fields that would be changed; only populated for modify
+	Changes []*DryRunFieldChange `json:"changes,omitempty"`
+}
+
+// DryRunFieldChange is a single field that differs between the actual and expected state.
+// Actual and Expected are nil (and omitted from the report) when the values can't be read, e.g. unexported fields.
+type DryRunFieldChange struct {
+	Field    string  `json:"field"`
+	Actual   *string `json:"actual,omitempty"`
+	Expected *string `json:"expected,omitempty"`
+}
+
+// fieldChanges computes the list of changed fields for a render of an existing object.
+// We can't use our reflection helpers here - we want corresponding values from a,e,c
+func (r *render) fieldChanges() ([]*DryRunFieldChange, error) {
+	var changeList []*DryRunFieldChange
+
+	valC := reflect.ValueOf(r.changes)
+	valA := reflect.ValueOf(r.a)
+	valE := reflect.ValueOf(r.e)
+	if valC.Kind() == reflect.Ptr && !valC.IsNil() {
+		valC = valC.Elem()
+	}
+	if valA.Kind() == reflect.Ptr && !valA.IsNil() {
+		valA = valA.Elem()
+	}
+	if valE.Kind() == reflect.Ptr && !valE.IsNil() {
+		valE = valE.Elem()
+	}
+	if valC.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("unhandled change type: %v", valC.Type())
+	}
+
+	for i := 0; i < valC.NumField(); i++ {
+		fieldValC := valC.Field(i)
+
+		changed := true
+		switch fieldValC.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+			changed = !fieldValC.IsNil()
+
+		case reflect.String:
+			changed = fieldValC.Interface().(string) != ""
+		}
+		if !changed {
+			continue
+		}
+
+		change := &DryRunFieldChange{
+			Field: valC.Type().Field(i).Name,
+		}
+
+		fieldValE := valE.Field(i)
+		ignored := false
+		if fieldValE.CanInterface() {
+			fieldValA := valA.Field(i)
+
+			switch fieldValE.Interface().(type) {
+			//case SimpleUnit:
+			//	ignored = true
+			default:
+				change.Actual = String(ValueAsString(fieldValA))
+				change.Expected = String(ValueAsString(fieldValE))
+			}
+		}
+		if ignored {
+			continue
+		}
+		changeList = append(changeList, change)
+	}
+
+	return changeList, nil
+}
+
+// BuildReport builds the structured report of all tasks, including those that would not change.
+// Tasks are sorted by key so that the output is stable.
+func (t *DryRunTarget) BuildReport(taskMap map[string]Task) (*DryRunReport, error) {
+	rendered := make(map[string]*DryRunTaskReport)
+	for _, r := range t.changes {
+		key := IdForTask(taskMap, r.e)
+		taskReport := &DryRunTaskReport{
+			Key:  key,
+			Type: fmt.Sprintf("%T", r.changes),
+		}
+		if r.aIsNil {
+			taskReport.Action = DryRunActionCreate
+		} else {
+			changes, err := r.fieldChanges()
+			if err != nil {
+				return nil, err
+			}
+			if len(changes) == 0 {
+				taskReport.Action = DryRunActionNoop
+			} else {
+				taskReport.Action = DryRunActionModify
+				taskReport.Changes = changes
+			}
+		}
+		rendered[key] = taskReport
+	}
+
+	var keys []string
+	for k := range taskMap {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	report := &DryRunReport{
+		Tasks: []*DryRunTaskReport{},
+	}
+	for _, k := range keys {
+		taskReport := rendered[k]
+		if taskReport == nil {
+			taskReport = &DryRunTaskReport{
+				Key:    k,
+				Type:   fmt.Sprintf("%T", taskMap[k]),
+				Action: DryRunActionNoop,
+			}
+		}
+		report.Tasks = append(report.Tasks, taskReport)
+	}
+
+	deletions := append([]Deletion(nil), t.deletions...)
+	sort.Sort(deletionsByKey(deletions))
+	for _, d := range deletions {
+		report.Tasks = append(report.Tasks, &DryRunTaskReport{
+			Key:    DeletionKey(d),
+			Type:   d.TaskName(),
+			Action: DryRunActionDelete,
+		})
+	}
+	return report, nil
+}
+
+// PrintReport writes the report of changes in the configured format
 func (t *DryRunTarget) PrintReport(taskMap map[string]Task, out io.Writer) error {
+	switch t.format {
+	case "", DryRunFormatText:
+		return t.printTextReport(taskMap, out)
+
+	case DryRunFormatJSON, DryRunFormatYAML:
+		report, err := t.BuildReport(taskMap)
+		if err != nil {
+			return err
+		}
+
+		var data []byte
+		if t.format == DryRunFormatJSON {
+			data, err = json.MarshalIndent(report, "", "  ")
+			data = append(data, '\n')
+		} else {
+			data, err = utils.YamlMarshal(report)
+		}
+		if err != nil {
+			return fmt.Errorf("error serializing dry-run report: %v", err)
+		}
+
+		_, err = out.Write(data)
+		return err
+
+	default:
+		return fmt.Errorf("unknown dry-run output format %q", t.format)
+	}
+}
+
+func (t *DryRunTarget) printTextReport(taskMap map[string]Task, out io.Writer) error {
 	b := &bytes.Buffer{}
 
 	if len(t.changes) != 0 {
@@ -72,81 +296,41 @@ func (t *DryRunTarget) PrintReport(taskMap map[string]Task, out io.Writer) error
 		}
 
 		fmt.Fprintf(b, "Will modify resources:\n")
-		// We can't use our reflection helpers here - we want corresponding values from a,e,c
 		for _, r := range t.changes {
 			if r.aIsNil {
 				continue
 			}
-			var changeList []string
 
-			valC := reflect.ValueOf(r.changes)
-			valA := reflect.ValueOf(r.a)
-			valE := reflect.ValueOf(r.e)
-			if valC.Kind() == reflect.Ptr && !valC.IsNil() {
-				valC = valC.Elem()
-			}
-			if valA.Kind() == reflect.Ptr && !valA.IsNil() {
-				valA = valA.Elem()
+			changeList, err := r.fieldChanges()
+			if err != nil {
+				return err
 			}
-			if valE.Kind() == reflect.Ptr && !valE.IsNil() {
-				valE = valE.Elem()
-			}
-			if valC.Kind() == reflect.Struct {
-				for i := 0; i < valC.NumField(); i++ {
-					fieldValC := valC.Field(i)
-
-					changed := true
-					switch fieldValC.Kind() {
-					case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
-						changed = !fieldValC.IsNil()
-
-					case reflect.String:
-						changed = fieldValC.Interface().(string) != ""
-					}
-					if !changed {
-						continue
-					}
-
-					if fieldValC.Kind() == reflect.String && fieldValC.Interface().(string) == "" {
-						// No change
-						continue
-					}
-
-					fieldValE := valE.Field(i)
-
-					description := ""
-					ignored := false
-					if fieldValE.CanInterface() {
-						fieldValA := valA.Field(i)
-
-						switch fieldValE.Interface().(type) {
-						//case SimpleUnit:
-						//	ignored = true
-						default:
-							description = fmt.Sprintf(" %v -> %v", ValueAsString(fieldValA), ValueAsString(fieldValE))
-						}
-					}
-					if ignored {
-						continue
-					}
-					changeList = append(changeList, valC.Type().Field(i).Name+description)
-				}
-			} else {
-				return fmt.Errorf("unhandled change type: %v", valC.Type())
-			}
-
 			if len(changeList) == 0 {
 				continue
 			}
 
 			fmt.Fprintf(b, "  %T\t%s\n", r.changes, IdForTask(taskMap, r.e))
 			for _, f := range changeList {
-				fmt.Fprintf(b, "    %s\n", f)
+				if f.Actual != nil && f.Expected != nil {
+					fmt.Fprintf(b, "    %s %s -> %s\n", f.Field, *f.Actual, *f.Expected)
+				} else {
+					fmt.Fprintf(b, "    %s\n", f.Field)
+				}
 			}
 			fmt.Fprintf(b, "\n")
 		}
 	}
 
+	if len(t.deletions) != 0 {
+		deletions := append([]Deletion(nil), t.deletions...)
+		sort.Sort(deletionsByKey(deletions))
+
+		fmt.Fprintf(b, "Will delete resources:\n")
+		for _, d := range deletions {
+			fmt.Fprintf(b, "  %s\t%s\n", d.TaskName(), d.Item())
+		}
+	}
+
 	_, err := out.Write(b.Bytes())
 	return err
 }
@@ -0,0 +1,106 @@
+package fi
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+type dryRunTestTask struct {
+	Name *string
+	Size *int64
+
+	secret *string
+}
+
+func (t *dryRunTestTask) Run(c *Context) error {
+	return nil
+}
+
+func TestDryRunTarget_JSONReport(t *testing.T) {
+	created := &dryRunTestTask{Name: String("created"), Size: Int64(10)}
+	modified := &dryRunTestTask{Name: String("modified"), Size: Int64(20)}
+	unchanged := &dryRunTestTask{Name: String("unchanged"), Size: Int64(30)}
+
+	taskMap := map[string]Task{
+		"created":   created,
+		"modified":  modified,
+		"unchanged": unchanged,
+	}
+
+	out := &bytes.Buffer{}
+	target := NewDryRunTarget(out, DryRunFormatJSON)
+
+	var noActual *dryRunTestTask
+	if err := target.Render(noActual, created, &dryRunTestTask{Name: created.Name, Size: created.Size}); err != nil {
+		t.Fatalf("unexpected error from Render: %v", err)
+	}
+	actual := &dryRunTestTask{Name: String("modified"), Size: Int64(10)}
+	if err := target.Render(actual, modified, &dryRunTestTask{Size: modified.Size}); err != nil {
+		t.Fatalf("unexpected error from Render: %v", err)
+	}
+
+	if err := target.Finish(taskMap); err != nil {
+		t.Fatalf("unexpected error from Finish: %v", err)
+	}
+
+	report := &DryRunReport{}
+	if err := json.Unmarshal(out.Bytes(), report); err != nil {
+		t.Fatalf("error parsing report %q: %v", out.String(), err)
+	}
+
+	if len(report.Tasks) != 3 {
+		t.Fatalf("expected 3 tasks in report, got %d", len(report.Tasks))
+	}
+
+	expectedActions := []DryRunAction{DryRunActionCreate, DryRunActionModify, DryRunActionNoop}
+	for i, task := range report.Tasks {
+		if task.Action != expectedActions[i] {
+			t.Errorf("task %q: expected action %q, got %q", task.Key, expectedActions[i], task.Action)
+		}
+		if task.Type != "*fi.dryRunTestTask" {
+			t.Errorf("task %q: unexpected type %q", task.Key, task.Type)
+		}
+	}
+
+	changes := report.Tasks[1].Changes
+	if len(changes) != 1 {
+		t.Fatalf("expected one changed field, got %v", changes)
+	}
+	if changes[0].Field != "Size" || StringValue(changes[0].Actual) != "10" || StringValue(changes[0].Expected) != "20" {
+		t.Fatalf("unexpected change: %+v", changes[0])
+	}
+}
+
+func TestParseDryRunFormat(t *testing.T) {
+	if f, err := ParseDryRunFormat(""); err != nil || f != DryRunFormatText {
+		t.Fatalf("expected empty format to default to text, got %q %v", f, err)
+	}
+	if _, err := ParseDryRunFormat("xml"); err == nil {
+		t.Fatalf("expected error for unknown format")
+	}
+}
+
+func TestDryRunTarget_OmitsUnreadableValues(t *testing.T) {
+	r := &render{
+		a:       &dryRunTestTask{Name: String("task"), secret: String("old")},
+		e:       &dryRunTestTask{Name: String("task"), secret: String("new")},
+		changes: &dryRunTestTask{secret: String("new")},
+	}
+	changes, err := r.fieldChanges()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Field != "secret" {
+		t.Fatalf("expected only the secret field to change, got %v", changes)
+	}
+
+	// A change whose values can't be read must not look like a change to empty values
+	b, err := json.Marshal(changes[0])
+	if err != nil {
+		t.Fatalf("error marshalling change: %v", err)
+	}
+	if string(b) != `{"field":"secret"}` {
+		t.Fatalf("unexpected JSON for change: %s", b)
+	}
+}
@@ -0,0 +1,500 @@
+package fi
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	crypto_rand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"k8s.io/kops/upup/pkg/fi/utils"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+	"os"
+	"path"
+	"strings"
+)
+
+// PathEncryption is the file (relative to the StateStore) that records how secrets and keys are encrypted
+const PathEncryption = "encryption"
+
+const (
+	KeyProviderLocal = "local"
+	KeyProviderKMS   = "kms"
+	// KeyProviderNone is only recorded while the state store is being decrypted; new entries are written in plaintext
+	KeyProviderNone = "none"
+)
+
+// NodeLocalKeyFile is where nodeup expects the key when the state store uses the local key provider.
+// kops never copies the key to instances (it cannot be stored alongside the secrets), so the local provider is only
+// supported for clusters whose image already contains the key at this path; otherwise use the kms provider.
+const NodeLocalKeyFile = "/etc/kops/encryption.key"
+
+// encryptedPrefix marks a file as an encrypted envelope; files without it are read as plaintext
+var encryptedPrefix = []byte("kops-encrypted:v1\n")
+
+// EncryptionConfig records how the secrets and private keys in the state store are encrypted.
+// It does not contain any key material, and is copied into the cluster spec so that nodeup can decrypt.
+type EncryptionConfig struct {
+	// Provider is the type of key provider: local or kms
+	Provider string `json:"provider"`
+
+	// KeyFingerprint identifies the key used by the local provider
+	KeyFingerprint string `json:"keyFingerprint,omitempty"`
+
+	// KMSKeyARN is the KMS key used by the kms provider
+	KMSKeyARN string `json:"kmsKeyARN,omitempty"`
+	// KMSRegion is the region of the KMS key
+	KMSRegion string `json:"kmsRegion,omitempty"`
+
+	// Previous is set while the state store is being re-encrypted: entries may still be encrypted as it describes
+	Previous *EncryptionConfig `json:"previous,omitempty"`
+}
+
+// KeyProvider protects the data keys used to encrypt each entry (envelope encryption)
+type KeyProvider interface {
+	// KeyID identifies the master key; it is recorded in each entry so we can report a clear error for the wrong key
+	KeyID() string
+	// WrapKey encrypts a data key with the master key
+	WrapKey(dataKey []byte) ([]byte, error)
+	// UnwrapKey decrypts a data key that was encrypted with WrapKey
+	UnwrapKey(wrapped []byte) ([]byte, error)
+}
+
+// envelope is the serialized form of an encrypted file
+type envelope struct {
+	KeyID      string `json:"keyID"`
+	WrappedKey []byte `json:"wrappedKey"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Encryption applies envelope encryption to state store entries
+type Encryption struct {
+	// encrypter is used to encrypt entries; if nil, entries are written in plaintext
+	encrypter KeyProvider
+	// providers can decrypt entries
+	providers []KeyProvider
+}
+
+// NewEncryption builds an Encryption that encrypts with provider.
+// Additional providers can decrypt existing entries, which is needed while changing keys.
+// If provider is nil, entries are written in plaintext.
+func NewEncryption(provider KeyProvider, additional ...KeyProvider) *Encryption {
+	e := &Encryption{encrypter: provider}
+	if provider != nil {
+		e.providers = append(e.providers, provider)
+	}
+	for _, p := range additional {
+		if p != nil {
+			e.providers = append(e.providers, p)
+		}
+	}
+	return e
+}
+
+// IsEncrypted checks if the data is an encrypted envelope
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, encryptedPrefix)
+}
+
+// Encrypt encrypts data with a new data key, unless no key provider is configured
+func (e *Encryption) Encrypt(plaintext []byte) ([]byte, error) {
+	if e == nil || e.encrypter == nil {
+		return plaintext, nil
+	}
+	provider := e.encrypter
+
+	dataKey := make([]byte, 32)
+	if _, err := crypto_rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("error generating data key: %v", err)
+	}
+
+	nonce, ciphertext, err := aesGCMSeal(dataKey, plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedKey, err := provider.WrapKey(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting data key with %s: %v", provider.KeyID(), err)
+	}
+
+	data, err := json.Marshal(&envelope{
+		KeyID:      provider.KeyID(),
+		WrappedKey: wrappedKey,
+		Nonce:      nonce,
+		Ciphertext: ciphertext,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error serializing encrypted data: %v", err)
+	}
+
+	return append(append([]byte{}, encryptedPrefix...), data...), nil
+}
+
+// Decrypt returns the plaintext of data; data that is not encrypted is returned unchanged
+func (e *Encryption) Decrypt(p vfs.Path, data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+
+	env := &envelope{}
+	if err := json.Unmarshal(data[len(encryptedPrefix):], env); err != nil {
+		return nil, fmt.Errorf("error parsing encrypted data in %s: %v", p, err)
+	}
+
+	var provider KeyProvider
+	if e != nil {
+		for _, candidate := range e.providers {
+			if candidate.KeyID() == env.KeyID {
+				provider = candidate
+				break
+			}
+		}
+	}
+	if provider == nil {
+		return nil, fmt.Errorf("%s is encrypted with key %q, which is not configured", p, env.KeyID)
+	}
+
+	dataKey, err := provider.UnwrapKey(env.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting data key for %s: %v", p, err)
+	}
+
+	plaintext, err := aesGCMOpen(dataKey, env.Nonce, env.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting %s: %v", p, err)
+	}
+	return plaintext, nil
+}
+
+func aesGCMSeal(key []byte, plaintext []byte) ([]byte, []byte, error) {
+	gcm, err := newAESGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := crypto_rand.Read(nonce); err != nil {
+		return nil, nil, fmt.Errorf("error generating nonce: %v", err)
+	}
+	return nonce, gcm.Seal(nil, nonce, plaintext, nil), nil
+}
+
+func aesGCMOpen(key []byte, nonce []byte, ciphertext []byte) ([]byte, error) {
+	gcm, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce length %d", len(nonce))
+	}
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("error building cipher: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// LocalKeyProvider wraps data keys with an AES-256 key held in a local file
+type LocalKeyProvider struct {
+	key         []byte
+	fingerprint string
+}
+
+var _ KeyProvider = &LocalKeyProvider{}
+
+// DefaultLocalKeyFile returns the path to the local key file, from $KOPS_ENCRYPTION_KEY_FILE or ~/.kops/encryption.key
+func DefaultLocalKeyFile() string {
+	if s := os.Getenv("KOPS_ENCRYPTION_KEY_FILE"); s != "" {
+		return s
+	}
+	return utils.ExpandPath("~/.kops/encryption.key")
+}
+
+// NewLocalKeyProvider builds a LocalKeyProvider from a key (32 bytes)
+func NewLocalKeyProvider(key []byte) (*LocalKeyProvider, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, was %d", len(key))
+	}
+	hash := sha256.Sum256(key)
+	return &LocalKeyProvider{
+		key:         key,
+		fingerprint: hex.EncodeToString(hash[:8]),
+	}, nil
+}
+
+// LoadLocalKeyProvider reads a key file, which holds a base64 encoded 32 byte key
+func LoadLocalKeyProvider(keyFile string) (*LocalKeyProvider, error) {
+	data, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading encryption key file %q: %v", keyFile, err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding encryption key file %q: %v", keyFile, err)
+	}
+	return NewLocalKeyProvider(key)
+}
+
+// GenerateLocalKeyFile writes a new random key to keyFile, which must not already exist
+func GenerateLocalKeyFile(keyFile string) (*LocalKeyProvider, error) {
+	key := make([]byte, 32)
+	if _, err := crypto_rand.Read(key); err != nil {
+		return nil, fmt.Errorf("error generating encryption key: %v", err)
+	}
+
+	if err := os.MkdirAll(path.Dir(keyFile), 0700); err != nil {
+		return nil, fmt.Errorf("error creating directory for encryption key file %q: %v", keyFile, err)
+	}
+
+	f, err := os.OpenFile(keyFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error creating encryption key file %q: %v", keyFile, err)
+	}
+	_, err = f.Write([]byte(base64.StdEncoding.EncodeToString(key) + "\n"))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error writing encryption key file %q: %v", keyFile, err)
+	}
+
+	return NewLocalKeyProvider(key)
+}
+
+func (p *LocalKeyProvider) Fingerprint() string {
+	return p.fingerprint
+}
+
+func (p *LocalKeyProvider) KeyID() string {
+	return KeyProviderLocal + ":" + p.fingerprint
+}
+
+func (p *LocalKeyProvider) WrapKey(dataKey []byte) ([]byte, error) {
+	nonce, ciphertext, err := aesGCMSeal(p.key, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return append(nonce, ciphertext...), nil
+}
+
+func (p *LocalKeyProvider) UnwrapKey(wrapped []byte) ([]byte, error) {
+	gcm, err := newAESGCM(p.key)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < gcm.NonceSize() {
+		return nil, fmt.Errorf("wrapped key is too short")
+	}
+	return gcm.Open(nil, wrapped[:gcm.NonceSize()], wrapped[gcm.NonceSize():], nil)
+}
+
+// BuildKeyProvider builds the KeyProvider described by config.
+// The local provider reads the key from localKeyFile, and checks it matches the recorded fingerprint.
+func BuildKeyProvider(config *EncryptionConfig, localKeyFile string) (KeyProvider, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	switch config.Provider {
+	case KeyProviderLocal:
+		provider, err := LoadLocalKeyProvider(localKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if config.KeyFingerprint != "" && provider.Fingerprint() != config.KeyFingerprint {
+			return nil, fmt.Errorf("encryption key in %q has fingerprint %s, but the state store is encrypted with key %s", localKeyFile, provider.Fingerprint(), config.KeyFingerprint)
+		}
+		return provider, nil
+
+	case KeyProviderKMS:
+		return NewKMSKeyProviderForRegion(config.KMSRegion, config.KMSKeyARN)
+
+	case KeyProviderNone:
+		return nil, nil
+
+	default:
+		return nil, fmt.Errorf("unknown encryption key provider %q", config.Provider)
+	}
+}
+
+// NewEncryptionForConfig builds the Encryption described by config.
+// If a re-encryption is in progress, entries that are still encrypted with the previous key can also be read.
+func NewEncryptionForConfig(config *EncryptionConfig, localKeyFile string) (*Encryption, error) {
+	provider, err := BuildKeyProvider(config, localKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil || config.Previous == nil {
+		return NewEncryption(provider), nil
+	}
+
+	previous, err := BuildKeyProvider(config.Previous, localKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error building the previous key provider, which is needed until the re-encryption is completed: %v", err)
+	}
+	return NewEncryption(provider, previous), nil
+}
+
+// sameKey checks if two configurations encrypt with the same key; nil and the none provider both mean plaintext
+func sameKey(a, b *EncryptionConfig) bool {
+	if a != nil && a.Provider == KeyProviderNone {
+		a = nil
+	}
+	if b != nil && b.Provider == KeyProviderNone {
+		b = nil
+	}
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Provider == b.Provider && a.KeyFingerprint == b.KeyFingerprint && a.KMSKeyARN == b.KMSKeyARN
+}
+
+func writeEncryptionConfig(base vfs.Path, config *EncryptionConfig) error {
+	configPath := base.Join(PathEncryption)
+	data, err := utils.YamlMarshal(config)
+	if err != nil {
+		return fmt.Errorf("error serializing encryption configuration: %v", err)
+	}
+	if err := configPath.WriteFile(data); err != nil {
+		return fmt.Errorf("error writing encryption configuration %s: %v", configPath, err)
+	}
+	return nil
+}
+
+// ReadEncryptionConfig reads the EncryptionConfig for the state store at base, returning nil if it is not encrypted
+func ReadEncryptionConfig(base vfs.Path) (*EncryptionConfig, error) {
+	p := base.Join(PathEncryption)
+	data, err := p.ReadFile()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading encryption configuration %s: %v", p, err)
+	}
+
+	config := &EncryptionConfig{}
+	if err := utils.YamlUnmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("error parsing encryption configuration %s: %v", p, err)
+	}
+	return config, nil
+}
+
+// ReEncryptStateStore re-encrypts every secret and private key in the state store at base with provider,
+// and then records config as the encryption configuration.  If provider is nil, the entries are decrypted.
+// Existing entries are decrypted with current (built from the recorded configuration) or with provider.
+// Before rewriting anything we record config with the existing configuration as Previous, so that the state store
+// stays readable if we are interrupted; running again with the same provider then completes the migration.
+// It returns the number of entries that were rewritten.
+func ReEncryptStateStore(base vfs.Path, current *Encryption, config *EncryptionConfig, provider KeyProvider) (int, error) {
+	decrypter := NewEncryption(provider)
+	if current != nil {
+		decrypter.providers = append(decrypter.providers, current.providers...)
+	}
+	encrypter := NewEncryption(provider)
+
+	currentConfig, err := ReadEncryptionConfig(base)
+	if err != nil {
+		return 0, err
+	}
+	previous := currentConfig
+	if currentConfig != nil && currentConfig.Previous != nil {
+		if !sameKey(currentConfig, config) {
+			return 0, fmt.Errorf("a re-encryption with the %s provider was interrupted; run it again to completion before changing keys", currentConfig.Provider)
+		}
+		previous = currentConfig.Previous
+	}
+	if !sameKey(previous, config) {
+		migration := &EncryptionConfig{Provider: KeyProviderNone}
+		if config != nil {
+			*migration = *config
+		}
+		migration.Previous = previous
+		if err := writeEncryptionConfig(base, migration); err != nil {
+			return 0, err
+		}
+	}
+
+	var entries []vfs.Path
+
+	secretsDir := base.Join("secrets")
+	secrets, err := readTreeIfExists(secretsDir)
+	if err != nil {
+		return 0, err
+	}
+	for _, p := range secrets {
+		relativePath, err := vfs.RelativePath(secretsDir, p)
+		if err != nil {
+			return 0, err
+		}
+		// Secrets are stored in a flat directory
+		if !strings.Contains(relativePath, "/") {
+			entries = append(entries, p)
+		}
+	}
+
+	privateKeys, err := readTreeIfExists(base.Join("pki", "private"))
+	if err != nil {
+		return 0, err
+	}
+	for _, p := range privateKeys {
+		if strings.HasSuffix(p.Base(), ".key") {
+			entries = append(entries, p)
+		}
+	}
+
+	count := 0
+	for _, p := range entries {
+		data, err := p.ReadFile()
+		if err != nil {
+			return count, fmt.Errorf("error reading %s: %v", p, err)
+		}
+		plaintext, err := decrypter.Decrypt(p, data)
+		if err != nil {
+			return count, err
+		}
+		encrypted, err := encrypter.Encrypt(plaintext)
+		if err != nil {
+			return count, fmt.Errorf("error encrypting %s: %v", p, err)
+		}
+		if err := p.WriteFile(encrypted); err != nil {
+			return count, fmt.Errorf("error writing %s: %v", p, err)
+		}
+		count++
+	}
+
+	// Every entry now uses the new key, so we no longer need the previous configuration
+	if config == nil {
+		configPath := base.Join(PathEncryption)
+		if err := configPath.Remove(); err != nil && !os.IsNotExist(err) {
+			return count, fmt.Errorf("error removing encryption configuration %s: %v", configPath, err)
+		}
+		return count, nil
+	}
+
+	final := *config
+	final.Previous = nil
+	if err := writeEncryptionConfig(base, &final); err != nil {
+		return count, err
+	}
+	return count, nil
+}
+
+func readTreeIfExists(p vfs.Path) ([]vfs.Path, error) {
+	paths, err := p.ReadTree()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error listing %s: %v", p, err)
+	}
+	return paths, nil
+}
@@ -0,0 +1,85 @@
+package fi
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+// KMSKeyProvider wraps data keys using an AWS KMS master key
+type KMSKeyProvider struct {
+	client kmsiface.KMSAPI
+	keyARN string
+}
+
+var _ KeyProvider = &KMSKeyProvider{}
+
+// NewKMSKeyProvider builds a KMSKeyProvider; keyARN should be the full ARN of the key
+func NewKMSKeyProvider(client kmsiface.KMSAPI, keyARN string) *KMSKeyProvider {
+	return &KMSKeyProvider{
+		client: client,
+		keyARN: keyARN,
+	}
+}
+
+// NewKMSKeyProviderForRegion builds a KMSKeyProvider using the default AWS credentials
+func NewKMSKeyProviderForRegion(region string, keyARN string) (*KMSKeyProvider, error) {
+	if keyARN == "" {
+		return nil, fmt.Errorf("KMS key must be specified")
+	}
+	if region == "" {
+		return nil, fmt.Errorf("region must be specified for KMS key %q", keyARN)
+	}
+
+	config := aws.NewConfig().WithRegion(region)
+	client := kms.New(session.New(), config)
+	return NewKMSKeyProvider(client, keyARN), nil
+}
+
+// ResolveKMSKeyARN returns the ARN for a KMS key id, ARN or alias, checking that the key exists
+func ResolveKMSKeyARN(client kmsiface.KMSAPI, keyID string) (string, error) {
+	request := &kms.DescribeKeyInput{
+		KeyId: aws.String(keyID),
+	}
+	response, err := client.DescribeKey(request)
+	if err != nil {
+		return "", fmt.Errorf("error describing KMS key %q: %v", keyID, err)
+	}
+	if response.KeyMetadata == nil || aws.StringValue(response.KeyMetadata.Arn) == "" {
+		return "", fmt.Errorf("KMS key %q not found", keyID)
+	}
+	return aws.StringValue(response.KeyMetadata.Arn), nil
+}
+
+func (p *KMSKeyProvider) KeyID() string {
+	return KeyProviderKMS + ":" + p.keyARN
+}
+
+func (p *KMSKeyProvider) WrapKey(dataKey []byte) ([]byte, error) {
+	request := &kms.EncryptInput{
+		KeyId:     aws.String(p.keyARN),
+		Plaintext: dataKey,
+	}
+	response, err := p.client.Encrypt(request)
+	if err != nil {
+		return nil, fmt.Errorf("error calling KMS Encrypt: %v", err)
+	}
+	return response.CiphertextBlob, nil
+}
+
+func (p *KMSKeyProvider) UnwrapKey(wrapped []byte) ([]byte, error) {
+	request := &kms.DecryptInput{
+		CiphertextBlob: wrapped,
+	}
+	response, err := p.client.Decrypt(request)
+	if err != nil {
+		return nil, fmt.Errorf("error calling KMS Decrypt: %v", err)
+	}
+	// The ciphertext identifies the key, so KMS will decrypt with any key we have access to; be sure it is ours
+	if keyID := aws.StringValue(response.KeyId); keyID != "" && keyID != p.keyARN {
+		return nil, fmt.Errorf("data key was encrypted with KMS key %q, expected %q", keyID, p.keyARN)
+	}
+	return response.Plaintext, nil
+}
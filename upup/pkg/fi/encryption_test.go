@@ -0,0 +1,256 @@
+package fi
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+	"testing"
+)
+
+// fakeKMS is a local stand-in for AWS KMS, which "encrypts" with a single AES key
+type fakeKMS struct {
+	kmsiface.KMSAPI
+
+	keyARN string
+	aead   cipher.AEAD
+}
+
+func newFakeKMS(t *testing.T, keyARN string) *fakeKMS {
+	block, err := aes.NewCipher(bytes.Repeat([]byte{42}, 32))
+	if err != nil {
+		t.Fatalf("error building cipher: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatalf("error building cipher: %v", err)
+	}
+	return &fakeKMS{keyARN: keyARN, aead: aead}
+}
+
+func (f *fakeKMS) Encrypt(request *kms.EncryptInput) (*kms.EncryptOutput, error) {
+	if aws.StringValue(request.KeyId) != f.keyARN {
+		return nil, fmt.Errorf("unknown key %q", aws.StringValue(request.KeyId))
+	}
+	nonce := make([]byte, f.aead.NonceSize())
+	blob := f.aead.Seal(nonce, nonce, request.Plaintext, nil)
+	return &kms.EncryptOutput{CiphertextBlob: blob, KeyId: aws.String(f.keyARN)}, nil
+}
+
+func (f *fakeKMS) Decrypt(request *kms.DecryptInput) (*kms.DecryptOutput, error) {
+	nonceSize := f.aead.NonceSize()
+	if len(request.CiphertextBlob) < nonceSize {
+		return nil, fmt.Errorf("invalid ciphertext")
+	}
+	plaintext, err := f.aead.Open(nil, request.CiphertextBlob[:nonceSize], request.CiphertextBlob[nonceSize:], nil)
+	if err != nil {
+		return nil, err
+	}
+	return &kms.DecryptOutput{Plaintext: plaintext, KeyId: aws.String(f.keyARN)}, nil
+}
+
+func TestEncryption_RoundTrip(t *testing.T) {
+	local, err := NewLocalKeyProvider(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatalf("error building local provider: %v", err)
+	}
+	other, err := NewLocalKeyProvider(bytes.Repeat([]byte{2}, 32))
+	if err != nil {
+		t.Fatalf("error building local provider: %v", err)
+	}
+	kmsProvider := NewKMSKeyProvider(newFakeKMS(t, "arn:aws:kms:us-east-1:123456789012:key/test"), "arn:aws:kms:us-east-1:123456789012:key/test")
+
+	p := vfs.NewMemFSPath(vfs.NewMemFSContext(), "secret")
+	for _, provider := range []KeyProvider{local, kmsProvider} {
+		encryption := NewEncryption(provider)
+		encrypted, err := encryption.Encrypt([]byte("hunter2"))
+		if err != nil {
+			t.Fatalf("error encrypting with %s: %v", provider.KeyID(), err)
+		}
+		if !IsEncrypted(encrypted) || bytes.Contains(encrypted, []byte("hunter2")) {
+			t.Fatalf("data was not encrypted with %s: %q", provider.KeyID(), string(encrypted))
+		}
+
+		decrypted, err := encryption.Decrypt(p, encrypted)
+		if err != nil {
+			t.Fatalf("error decrypting with %s: %v", provider.KeyID(), err)
+		}
+		if string(decrypted) != "hunter2" {
+			t.Fatalf("unexpected plaintext from %s: %q", provider.KeyID(), string(decrypted))
+		}
+
+		if _, err := NewEncryption(other).Decrypt(p, encrypted); err == nil {
+			t.Fatalf("expected error decrypting with the wrong key")
+		}
+	}
+
+	// Plaintext is passed through, so existing state stores remain readable
+	decrypted, err := NewEncryption(local).Decrypt(p, []byte("plain"))
+	if err != nil || string(decrypted) != "plain" {
+		t.Fatalf("unexpected result decrypting plaintext: %q %v", string(decrypted), err)
+	}
+}
+
+func TestReEncryptStateStore(t *testing.T) {
+	memfs := vfs.NewMemFSContext()
+	base := vfs.NewMemFSPath(memfs, "state")
+
+	// Build a plaintext state store, with a CA key and a secret
+	stateStore := newTestStateStore(t, base)
+	if _, _, err := stateStore.Secrets().GetOrCreateSecret("admin"); err != nil {
+		t.Fatalf("error creating secret: %v", err)
+	}
+
+	local, err := NewLocalKeyProvider(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatalf("error building local provider: %v", err)
+	}
+	config := &EncryptionConfig{Provider: KeyProviderLocal, KeyFingerprint: local.Fingerprint()}
+
+	count, err := ReEncryptStateStore(base.Join("concurrency.example.com"), nil, config, local)
+	if err != nil {
+		t.Fatalf("error re-encrypting state store: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("expected to re-encrypt 2 entries, got %d", count)
+	}
+
+	privateKeys, err := base.Join("concurrency.example.com", "pki", "private").ReadTree()
+	if err != nil {
+		t.Fatalf("error reading private keys: %v", err)
+	}
+	entries := append(privateKeys, base.Join("concurrency.example.com", "secrets", "admin"))
+	for _, p := range entries {
+		data, err := p.ReadFile()
+		if err != nil {
+			t.Fatalf("error reading %s: %v", p, err)
+		}
+		if !IsEncrypted(data) {
+			t.Fatalf("%s was not encrypted", p)
+		}
+	}
+
+	readConfig, err := ReadEncryptionConfig(base.Join("concurrency.example.com"))
+	if err != nil {
+		t.Fatalf("error reading encryption config: %v", err)
+	}
+	if readConfig == nil || readConfig.KeyFingerprint != local.Fingerprint() {
+		t.Fatalf("unexpected encryption config: %v", readConfig)
+	}
+
+	// The stores decrypt transparently
+	secretStore, err := NewVFSSecretStore(base.Join("concurrency.example.com", "secrets"), NewEncryption(local))
+	if err != nil {
+		t.Fatalf("error building secret store: %v", err)
+	}
+	secret, err := secretStore.FindSecret("admin")
+	if err != nil || secret == nil {
+		t.Fatalf("error reading encrypted secret: %v", err)
+	}
+	caStore, err := NewVFSCAStore(base.Join("concurrency.example.com", "pki"), false, NewEncryption(local))
+	if err != nil {
+		t.Fatalf("error building CA store: %v", err)
+	}
+	caKey, err := caStore.FindPrivateKey(CertificateId_CA)
+	if err != nil || caKey == nil {
+		t.Fatalf("error reading encrypted CA key: %v", err)
+	}
+}
+
+// failingKeyProvider wraps a KeyProvider, failing after a number of keys have been wrapped
+type failingKeyProvider struct {
+	KeyProvider
+	remaining int
+}
+
+func (p *failingKeyProvider) WrapKey(dataKey []byte) ([]byte, error) {
+	if p.remaining == 0 {
+		return nil, fmt.Errorf("simulated failure")
+	}
+	p.remaining--
+	return p.KeyProvider.WrapKey(dataKey)
+}
+
+func TestReEncryptStateStore_Interrupted(t *testing.T) {
+	memfs := vfs.NewMemFSContext()
+	base := vfs.NewMemFSPath(memfs, "state")
+	clusterBase := base.Join("concurrency.example.com")
+
+	stateStore := newTestStateStore(t, base)
+	if _, _, err := stateStore.Secrets().GetOrCreateSecret("admin"); err != nil {
+		t.Fatalf("error creating secret: %v", err)
+	}
+
+	oldKey, err := NewLocalKeyProvider(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatalf("error building local provider: %v", err)
+	}
+	oldConfig := &EncryptionConfig{Provider: KeyProviderLocal, KeyFingerprint: oldKey.Fingerprint()}
+	if _, err := ReEncryptStateStore(clusterBase, nil, oldConfig, oldKey); err != nil {
+		t.Fatalf("error encrypting state store: %v", err)
+	}
+
+	newKey, err := NewLocalKeyProvider(bytes.Repeat([]byte{2}, 32))
+	if err != nil {
+		t.Fatalf("error building local provider: %v", err)
+	}
+	newConfig := &EncryptionConfig{Provider: KeyProviderLocal, KeyFingerprint: newKey.Fingerprint()}
+
+	// Fail after the first entry has been rewritten
+	count, err := ReEncryptStateStore(clusterBase, NewEncryption(oldKey), newConfig, &failingKeyProvider{KeyProvider: newKey, remaining: 1})
+	if err == nil {
+		t.Fatalf("expected re-encryption to fail")
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 entry to be re-encrypted before the failure, got %d", count)
+	}
+
+	// The migration is recorded, so we can still read every entry
+	config, err := ReadEncryptionConfig(clusterBase)
+	if err != nil {
+		t.Fatalf("error reading encryption config: %v", err)
+	}
+	if config == nil || config.KeyFingerprint != newKey.Fingerprint() || config.Previous == nil || config.Previous.KeyFingerprint != oldKey.Fingerprint() {
+		t.Fatalf("expected the interrupted migration to be recorded, got %v", config)
+	}
+	secretStore, err := NewVFSSecretStore(clusterBase.Join("secrets"), NewEncryption(newKey, oldKey))
+	if err != nil {
+		t.Fatalf("error building secret store: %v", err)
+	}
+	if secret, err := secretStore.FindSecret("admin"); err != nil || secret == nil {
+		t.Fatalf("error reading secret during migration: %v", err)
+	}
+
+	// We can't start a different migration until this one is complete
+	otherKey, err := NewLocalKeyProvider(bytes.Repeat([]byte{3}, 32))
+	if err != nil {
+		t.Fatalf("error building local provider: %v", err)
+	}
+	otherConfig := &EncryptionConfig{Provider: KeyProviderLocal, KeyFingerprint: otherKey.Fingerprint()}
+	if _, err := ReEncryptStateStore(clusterBase, NewEncryption(newKey, oldKey), otherConfig, otherKey); err == nil {
+		t.Fatalf("expected an error changing keys during an interrupted migration")
+	}
+
+	// Running again completes the migration
+	if _, err := ReEncryptStateStore(clusterBase, NewEncryption(newKey, oldKey), newConfig, newKey); err != nil {
+		t.Fatalf("error completing re-encryption: %v", err)
+	}
+	config, err = ReadEncryptionConfig(clusterBase)
+	if err != nil {
+		t.Fatalf("error reading encryption config: %v", err)
+	}
+	if config == nil || config.KeyFingerprint != newKey.Fingerprint() || config.Previous != nil {
+		t.Fatalf("unexpected encryption config after completing migration: %v", config)
+	}
+	caStore, err := NewVFSCAStore(clusterBase.Join("pki"), false, NewEncryption(newKey))
+	if err != nil {
+		t.Fatalf("error building CA store: %v", err)
+	}
+	if caKey, err := caStore.FindPrivateKey(CertificateId_CA); err != nil || caKey == nil {
+		t.Fatalf("error reading CA key with the new key: %v", err)
+	}
+}
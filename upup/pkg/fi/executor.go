@@ -1,17 +1,85 @@
 package fi
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/golang/glog"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 )
 
-const MaxAttemptsWithNoProgress = 3
+// RunTasksOptions controls how the executor runs tasks
+type RunTasksOptions struct {
+	// MaxConcurrency is the maximum number of tasks that will be run in parallel
+	MaxConcurrency int
+
+	// MaxTaskDuration is the time we allow a single task invocation to run before treating it as failed;
+	// zero means no limit.  Tasks cannot be interrupted, so a task that times out keeps running in the background,
+	// and later attempts wait for that invocation to finish rather than running the task again.
+	MaxTaskDuration time.Duration
+
+	// MaxAttemptsWithNoProgress is the number of consecutive rounds in which no task succeeds before we give up
+	MaxAttemptsWithNoProgress int
+
+	// InitialBackoff is the delay before retrying after a round with no progress
+	InitialBackoff time.Duration
+	// MaxBackoff is the limit on the delay, which doubles after each round with no progress
+	MaxBackoff time.Duration
+}
+
+// InitDefaults populates any unset fields with the default values
+func (o *RunTasksOptions) InitDefaults() {
+	if o.MaxConcurrency <= 0 {
+		o.MaxConcurrency = 10
+	}
+	if o.MaxAttemptsWithNoProgress <= 0 {
+		o.MaxAttemptsWithNoProgress = 3
+	}
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = 10 * time.Second
+	}
+	if o.MaxBackoff < o.InitialBackoff {
+		o.MaxBackoff = 2 * time.Minute
+		if o.MaxBackoff < o.InitialBackoff {
+			o.MaxBackoff = o.InitialBackoff
+		}
+	}
+}
+
+// TaskErrors is returned by RunTasks when tasks could not be completed.
+// It records the last error from every task that failed.
+type TaskErrors struct {
+	// Errors maps the task key to the last error returned by that task
+	Errors map[string]error
+}
+
+var _ error = &TaskErrors{}
+
+// Keys returns the keys of the failed tasks, in sorted order
+func (e *TaskErrors) Keys() []string {
+	var keys []string
+	for k := range e.Errors {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (e *TaskErrors) Error() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "error running %d task(s):", len(e.Errors))
+	for _, k := range e.Keys() {
+		fmt.Fprintf(&b, "\n  %s: %v", k, e.Errors[k])
+	}
+	return b.String()
+}
 
 type executor struct {
 	context *Context
+
+	options RunTasksOptions
 }
 
 type taskState struct {
@@ -19,6 +87,12 @@ type taskState struct {
 	key          string
 	task         Task
 	dependencies []*taskState
+
+	// lastError is the error from the most recent failed execution, cleared on success
+	lastError error
+
+	// running is set while an invocation that has timed out is still in progress, and receives its result
+	running chan error
 }
 
 // RunTasks executes all the tasks, considering their dependencies
@@ -47,6 +121,7 @@ func (e *executor) RunTasks(taskMap map[string]Task) error {
 	}
 
 	noProgressCount := 0
+	backoff := e.options.InitialBackoff
 	for {
 		var canRun []*taskState
 		doneCount := 0
@@ -73,39 +148,43 @@ func (e *executor) RunTasks(taskMap map[string]Task) error {
 		}
 
 		progress := false
+		failed := 0
 
-		var tasks []*taskState
-		for _, ts := range canRun {
-			tasks = append(tasks, ts)
-		}
-
-		errors := e.forkJoin(tasks)
+		errors := e.forkJoin(canRun)
 		for i, err := range errors {
-			ts := tasks[i]
+			ts := canRun[i]
 			if err != nil {
 				glog.Warningf("error running task %q: %v", ts.key, err)
-				errors = append(errors, err)
+				ts.lastError = err
+				failed++
 			} else {
 				ts.done = true
+				ts.lastError = nil
 				progress = true
 			}
 		}
 
 		if !progress {
-			if len(errors) != 0 {
-				noProgressCount++
-				if noProgressCount == MaxAttemptsWithNoProgress {
-					return fmt.Errorf("did not make any progress executing task.  Example error: %v", errors[0])
-				} else {
-					glog.Infof("No progress made, sleeping before retrying failed tasks")
-					time.Sleep(10 * time.Second)
-				}
-			} else {
+			if failed == 0 {
 				// Logic error!
 				panic("did not make progress executing tasks; but no errors reported")
 			}
+
+			noProgressCount++
+			if noProgressCount >= e.options.MaxAttemptsWithNoProgress {
+				return e.buildTaskErrors(taskStates)
+			}
+
+			glog.Infof("No progress made, sleeping %v before retrying %d failed task(s)", backoff, failed)
+			time.Sleep(backoff)
+
+			backoff *= 2
+			if backoff > e.options.MaxBackoff {
+				backoff = e.options.MaxBackoff
+			}
 		} else {
 			noProgressCount = 0
+			backoff = e.options.InitialBackoff
 		}
 	}
 
@@ -117,32 +196,95 @@ func (e *executor) RunTasks(taskMap map[string]Task) error {
 		}
 	}
 	if len(notDone) != 0 {
+		sort.Strings(notDone)
 		return fmt.Errorf("Unable to execute tasks (circular dependency): %s", strings.Join(notDone, ", "))
 	}
 
 	return nil
 }
 
-type runnable func() error
+// buildTaskErrors collects the last error of every task that has failed and not since succeeded
+func (e *executor) buildTaskErrors(taskStates map[string]*taskState) error {
+	taskErrors := &TaskErrors{
+		Errors: make(map[string]error),
+	}
+	for k, ts := range taskStates {
+		if !ts.done && ts.lastError != nil {
+			taskErrors.Errors[k] = ts.lastError
+		}
+	}
+	return taskErrors
+}
 
+// forkJoin runs the tasks using a pool of at most MaxConcurrency workers, returning the result of each task
 func (e *executor) forkJoin(tasks []*taskState) []error {
 	if len(tasks) == 0 {
 		return nil
 	}
 
-	var wg sync.WaitGroup
+	workers := e.options.MaxConcurrency
+	if workers > len(tasks) {
+		workers = len(tasks)
+	}
+
 	results := make([]error, len(tasks))
-	for i := 0; i < len(tasks); i++ {
+
+	work := make(chan int, len(tasks))
+	for i := range tasks {
+		work <- i
+	}
+	close(work)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
 		wg.Add(1)
-		go func(ts *taskState, index int) {
-			results[index] = fmt.Errorf("function panic")
+		go func() {
 			defer wg.Done()
-			glog.V(2).Infof("Executing task %q: %v\n", ts.key, ts.task)
-			results[index] = ts.task.Run(e.context)
-		}(tasks[i], i)
+			for i := range work {
+				results[i] = e.runTask(tasks[i])
+			}
+		}()
 	}
 
 	wg.Wait()
 
 	return results
 }
+
+// runTask runs a single task, returning an error if it fails, panics or exceeds MaxTaskDuration.
+// If an earlier invocation timed out and is still running, we wait for that one instead of starting another,
+// so that a slow task can't create the same cloud resource twice.
+func (e *executor) runTask(ts *taskState) error {
+	if ts.running == nil {
+		running := make(chan error, 1)
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					running <- fmt.Errorf("panic running task: %v", r)
+				}
+			}()
+			glog.V(2).Infof("Executing task %q: %v\n", ts.key, ts.task)
+			running <- ts.task.Run(e.context)
+		}()
+		ts.running = running
+	} else {
+		glog.V(2).Infof("Task %q is still running from an earlier attempt; waiting for it", ts.key)
+	}
+
+	if e.options.MaxTaskDuration <= 0 {
+		err := <-ts.running
+		ts.running = nil
+		return err
+	}
+
+	timeout := time.NewTimer(e.options.MaxTaskDuration)
+	defer timeout.Stop()
+
+	select {
+	case err := <-ts.running:
+		ts.running = nil
+		return err
+	case <-timeout.C:
+		return fmt.Errorf("task did not complete within %v", e.options.MaxTaskDuration)
+	}
+}
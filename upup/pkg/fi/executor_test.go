@@ -0,0 +1,131 @@
+package fi
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testTask struct {
+	run func() error
+}
+
+var _ Task = &testTask{}
+var _ HasDependencies = &testTask{}
+
+func (t *testTask) Run(c *Context) error {
+	return t.run()
+}
+
+func (t *testTask) GetDependencies(tasks map[string]Task) []Task {
+	return nil
+}
+
+func testRunTasksOptions() RunTasksOptions {
+	options := RunTasksOptions{
+		MaxConcurrency:            2,
+		MaxTaskDuration:           time.Second,
+		MaxAttemptsWithNoProgress: 2,
+		InitialBackoff:            time.Millisecond,
+		MaxBackoff:                time.Millisecond,
+	}
+	options.InitDefaults()
+	return options
+}
+
+func TestRunTasks_LimitsConcurrency(t *testing.T) {
+	var mutex sync.Mutex
+	running := 0
+	maxRunning := 0
+
+	taskMap := make(map[string]Task)
+	for i := 0; i < 10; i++ {
+		taskMap[fmt.Sprintf("task%d", i)] = &testTask{
+			run: func() error {
+				mutex.Lock()
+				running++
+				if running > maxRunning {
+					maxRunning = running
+				}
+				mutex.Unlock()
+
+				time.Sleep(10 * time.Millisecond)
+
+				mutex.Lock()
+				running--
+				mutex.Unlock()
+				return nil
+			},
+		}
+	}
+
+	e := &executor{options: testRunTasksOptions()}
+	if err := e.RunTasks(taskMap); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if maxRunning > 2 {
+		t.Fatalf("expected at most 2 concurrent tasks, saw %d", maxRunning)
+	}
+}
+
+func TestRunTasks_ReportsAllFailedTasks(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	taskMap := map[string]Task{
+		"ok":      &testTask{run: func() error { return nil }},
+		"broken1": &testTask{run: func() error { return fmt.Errorf("first failure") }},
+		"broken2": &testTask{run: func() error { panic("second failure") }},
+		"slow": &testTask{run: func() error {
+			<-release
+			return nil
+		}},
+	}
+
+	e := &executor{options: testRunTasksOptions()}
+	err := e.RunTasks(taskMap)
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	taskErrors, ok := err.(*TaskErrors)
+	if !ok {
+		t.Fatalf("expected *TaskErrors, got %T: %v", err, err)
+	}
+
+	keys := taskErrors.Keys()
+	expected := []string{"broken1", "broken2", "slow"}
+	if fmt.Sprintf("%v", keys) != fmt.Sprintf("%v", expected) {
+		t.Fatalf("unexpected failed tasks: %v", keys)
+	}
+}
+
+func TestRunTasks_DoesNotRestartTimedOutTask(t *testing.T) {
+	var mutex sync.Mutex
+	runs := 0
+
+	taskMap := map[string]Task{
+		"slow": &testTask{run: func() error {
+			mutex.Lock()
+			runs++
+			mutex.Unlock()
+
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		}},
+	}
+
+	options := testRunTasksOptions()
+	options.MaxTaskDuration = 20 * time.Millisecond
+	options.MaxAttemptsWithNoProgress = 10
+
+	e := &executor{options: options}
+	if err := e.RunTasks(taskMap); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if runs != 1 {
+		t.Fatalf("expected the timed out task to be run once, was run %d times", runs)
+	}
+}
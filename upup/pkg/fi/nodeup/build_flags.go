@@ -48,6 +48,22 @@ func buildFlags(options interface{}) (string, error) {
 			vString := fmt.Sprintf("%v", v)
 			flag = fmt.Sprintf("--%s=%s", flagName, vString)
 
+		case []string:
+			if len(v) != 0 {
+				flag = fmt.Sprintf("--%s=%s", flagName, strings.Join(v, ","))
+			}
+
+		case map[string]string:
+			// Maps are written as k1=v1,k2=v2, sorted by key
+			var pairs []string
+			for k, mv := range v {
+				pairs = append(pairs, k+"="+mv)
+			}
+			sort.Strings(pairs)
+			if len(pairs) != 0 {
+				flag = fmt.Sprintf("--%s=%s", flagName, strings.Join(pairs, ","))
+			}
+
 		default:
 			return fmt.Errorf("BuildFlags of value type not handled: %T %s=%v", v, path, v)
 		}
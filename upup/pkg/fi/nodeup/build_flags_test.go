@@ -0,0 +1,48 @@
+package nodeup
+
+import (
+	"testing"
+)
+
+func TestBuildFlags(t *testing.T) {
+	type options struct {
+		Name    string            `flag:"name"`
+		Count   int               `flag:"count"`
+		Enabled *bool             `flag:"enabled"`
+		Labels  map[string]string `flag:"labels"`
+		Taints  []string          `flag:"taints"`
+		Ignored string
+	}
+
+	enabled := true
+	grid := []struct {
+		Options  *options
+		Expected string
+	}{
+		{
+			Options:  &options{},
+			Expected: "--count=0",
+		},
+		{
+			Options:  &options{Name: "a", Count: 2, Enabled: &enabled, Ignored: "x"},
+			Expected: "--count=2 --enabled=true --name=a",
+		},
+		{
+			Options: &options{
+				Labels: map[string]string{"role": "gpu", "dedicated": "ml"},
+				Taints: []string{"dedicated=ml:NoSchedule", "gpu:PreferNoSchedule"},
+			},
+			Expected: "--count=0 --labels=dedicated=ml,role=gpu --taints=dedicated=ml:NoSchedule,gpu:PreferNoSchedule",
+		},
+	}
+	for _, g := range grid {
+		actual, err := buildFlags(g.Options)
+		if err != nil {
+			t.Errorf("unexpected error building flags for %+v: %v", g.Options, err)
+			continue
+		}
+		if actual != g.Expected {
+			t.Errorf("unexpected flags for %+v: expected %q, got %q", g.Options, g.Expected, actual)
+		}
+	}
+}
@@ -15,6 +15,7 @@ import (
 type NodeUpCommand struct {
 	config         *NodeUpConfig
 	cluster        *api.Cluster
+	instanceGroup  *api.InstanceGroup
 	ConfigLocation string
 	ModelDir       string
 	AssetDir       string
@@ -83,6 +84,22 @@ func (c *NodeUpCommand) Run(out io.Writer) error {
 		return fmt.Errorf("ClusterLocation is required")
 	}
 
+	if c.config.InstanceGroupLocation != "" {
+		b, err := vfs.Context.ReadFile(c.config.InstanceGroupLocation)
+		if err != nil {
+			return fmt.Errorf("error loading InstanceGroup %q: %v", c.config.InstanceGroupLocation, err)
+		}
+
+		c.instanceGroup = &api.InstanceGroup{}
+		err = utils.YamlUnmarshal(b, c.instanceGroup)
+		if err != nil {
+			return fmt.Errorf("error parsing InstanceGroup %q: %v", c.config.InstanceGroupLocation, err)
+		}
+	} else {
+		// Nodes configured by older versions don't know their instance group
+		glog.Warningf("InstanceGroupLocation not set; instance group labels & taints will not be applied")
+	}
+
 	//if c.Config.ConfigurationStore != "" {
 	//	// TODO: If we ever delete local files, we need to filter so we only copy
 	//	// certain directories (i.e. not secrets / keys), because dest is a parent dir!
@@ -118,7 +135,7 @@ func (c *NodeUpCommand) Run(out io.Writer) error {
 
 	loader := NewLoader(c.config, c.cluster, assets, tags)
 
-	tf, err := newTemplateFunctions(c.config, c.cluster, tags)
+	tf, err := newTemplateFunctions(c.config, c.cluster, c.instanceGroup, tags)
 	if err != nil {
 		return fmt.Errorf("error initializing: %v", err)
 	}
@@ -139,7 +156,7 @@ func (c *NodeUpCommand) Run(out io.Writer) error {
 	case "direct":
 		target = &local.LocalTarget{}
 	case "dryrun":
-		target = fi.NewDryRunTarget(out)
+		target = fi.NewDryRunTarget(out, fi.DryRunFormatText)
 	case "cloudinit":
 		checkExisting = false
 		target = cloudinit.NewCloudInitTarget(out)
@@ -153,7 +170,8 @@ func (c *NodeUpCommand) Run(out io.Writer) error {
 	}
 	defer context.Close()
 
-	err = context.RunTasks(taskMap)
+	// No MaxTaskDuration: downloads and package installs can legitimately take a long time
+	err = context.RunTasks(taskMap, fi.RunTasksOptions{})
 	if err != nil {
 		glog.Exitf("error running tasks: %v", err)
 	}
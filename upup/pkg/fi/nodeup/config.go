@@ -13,6 +13,9 @@ type NodeUpConfig struct {
 
 	// ClusterLocation is the VFS path to the cluster spec
 	ClusterLocation string `json:",omitempty"`
+
+	// InstanceGroupLocation is the VFS path to the spec of the instance group this node belongs to
+	InstanceGroupLocation string `json:",omitempty"`
 }
 
 // Our client configuration structure
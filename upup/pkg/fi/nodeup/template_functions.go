@@ -6,6 +6,7 @@ import (
 	"github.com/golang/glog"
 	"k8s.io/kops/upup/pkg/api"
 	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/utils"
 	"k8s.io/kops/upup/pkg/fi/vfs"
 	"text/template"
 )
@@ -16,6 +17,8 @@ const TagMaster = "_kubernetes_master"
 type templateFunctions struct {
 	nodeupConfig *NodeUpConfig
 	cluster      *api.Cluster
+	// instanceGroup is the instance group of this node, if known
+	instanceGroup *api.InstanceGroup
 	// keyStore is populated with a KeyStore, if KeyStore is set
 	keyStore fi.CAStore
 	// secretStore is populated with a SecretStore, if SecretStore is set
@@ -25,11 +28,18 @@ type templateFunctions struct {
 }
 
 // newTemplateFunctions is the constructor for templateFunctions
-func newTemplateFunctions(nodeupConfig *NodeUpConfig, cluster *api.Cluster, tags map[string]struct{}) (*templateFunctions, error) {
+func newTemplateFunctions(nodeupConfig *NodeUpConfig, cluster *api.Cluster, instanceGroup *api.InstanceGroup, tags map[string]struct{}) (*templateFunctions, error) {
 	t := &templateFunctions{
-		nodeupConfig: nodeupConfig,
-		cluster:      cluster,
-		tags:         tags,
+		nodeupConfig:  nodeupConfig,
+		cluster:       cluster,
+		instanceGroup: instanceGroup,
+		tags:          tags,
+	}
+
+	// kops does not copy the local key to instances; it must already be on the image (see fi.NodeLocalKeyFile)
+	encryption, err := fi.NewEncryptionForConfig(cluster.Spec.Encryption, fi.NodeLocalKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error building encryption key provider: %v", err)
 	}
 
 	if cluster.Spec.SecretStore != "" {
@@ -39,7 +49,7 @@ func newTemplateFunctions(nodeupConfig *NodeUpConfig, cluster *api.Cluster, tags
 			return nil, fmt.Errorf("error building secret store path: %v", err)
 		}
 
-		secretStore, err := fi.NewVFSSecretStore(p)
+		secretStore, err := fi.NewVFSSecretStore(p, encryption)
 		if err != nil {
 			return nil, fmt.Errorf("error building secret store: %v", err)
 		}
@@ -56,7 +66,7 @@ func newTemplateFunctions(nodeupConfig *NodeUpConfig, cluster *api.Cluster, tags
 			return nil, fmt.Errorf("error building key store path: %v", err)
 		}
 
-		keyStore, err := fi.NewVFSCAStore(p, false)
+		keyStore, err := fi.NewVFSCAStore(p, false, encryption)
 		if err != nil {
 			return nil, fmt.Errorf("error building key store: %v", err)
 		}
@@ -99,14 +109,39 @@ func (t *templateFunctions) populate(dest template.FuncMap) {
 	dest["KubeProxy"] = func() *api.KubeProxyConfig {
 		return t.cluster.Spec.KubeProxy
 	}
-	dest["Kubelet"] = func() *api.KubeletConfig {
-		if t.IsMaster() {
-			return t.cluster.Spec.MasterKubelet
-		} else {
-			return t.cluster.Spec.Kubelet
+	dest["Kubelet"] = t.KubeletConfig
+	dest["ClusterName"] = func() string { return t.cluster.Name }
+}
+
+// KubeletConfig returns the kubelet configuration for this node, with the labels & taints of its instance group
+func (t *templateFunctions) KubeletConfig() *api.KubeletConfig {
+	var base *api.KubeletConfig
+	if t.IsMaster() {
+		base = t.cluster.Spec.MasterKubelet
+	} else {
+		base = t.cluster.Spec.Kubelet
+	}
+	if t.instanceGroup == nil {
+		return base
+	}
+
+	// Copy so that we don't change the cluster spec
+	config := &api.KubeletConfig{}
+	utils.JsonMergeStruct(config, base)
+
+	if len(t.instanceGroup.Spec.NodeLabels) != 0 {
+		labels := make(map[string]string)
+		for k, v := range config.NodeLabels {
+			labels[k] = v
 		}
+		for k, v := range t.instanceGroup.Spec.NodeLabels {
+			labels[k] = v
+		}
+		config.NodeLabels = labels
 	}
-	dest["ClusterName"] = func() string { return t.cluster.Name }
+	config.RegisterWithTaints = append(config.RegisterWithTaints, t.instanceGroup.Spec.Taints...)
+
+	return config
 }
 
 // IsMaster returns true if we are tagged as a master
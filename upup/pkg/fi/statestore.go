@@ -1,11 +1,14 @@
 package fi
 
 import (
+	"bytes"
 	"fmt"
+	"github.com/golang/glog"
 	"k8s.io/kops/upup/pkg/fi/utils"
 	"k8s.io/kops/upup/pkg/fi/vfs"
 	"os"
 	"strings"
+	"sync"
 )
 
 type StateStore interface {
@@ -15,8 +18,19 @@ type StateStore interface {
 	CA() CAStore
 	Secrets() SecretStore
 
+	// EncryptionConfig returns how secrets and private keys are encrypted, or nil if they are stored in plaintext
+	EncryptionConfig() *EncryptionConfig
+
 	ReadConfig(path string, config interface{}) error
+	// WriteConfig writes the configuration, recording a Revision if it has changed.
+	// If the configuration was previously read with ReadConfig and has since been changed by someone else,
+	// a *vfs.ConflictError is returned and nothing is written.
 	WriteConfig(path string, config interface{}) error
+	// DeleteConfig removes the configuration, recording a Revision
+	DeleteConfig(path string) error
+
+	// ListRevisions returns the history of configuration changes, oldest first
+	ListRevisions() ([]*Revision, error)
 
 	// ListChildren returns a list of all (direct) children of the specified path
 	// It only returns the raw names, not the prefixes
@@ -27,6 +41,13 @@ type VFSStateStore struct {
 	location vfs.Path
 	ca       CAStore
 	secrets  SecretStore
+
+	encryptionConfig *EncryptionConfig
+
+	// versions holds the version of each configuration file when we last read or wrote it,
+	// so we can detect concurrent modifications
+	mutex    sync.Mutex
+	versions map[string]string
 }
 
 var _ StateStore = &VFSStateStore{}
@@ -35,13 +56,23 @@ func NewVFSStateStore(base vfs.Path, clusterName string, dryrun bool) (*VFSState
 	location := base.Join(clusterName)
 	s := &VFSStateStore{
 		location: location,
+		versions: make(map[string]string),
 	}
 	var err error
-	s.ca, err = NewVFSCAStore(location.Join("pki"), dryrun)
+	s.encryptionConfig, err = ReadEncryptionConfig(location)
+	if err != nil {
+		return nil, err
+	}
+	encryption, err := NewEncryptionForConfig(s.encryptionConfig, DefaultLocalKeyFile())
+	if err != nil {
+		return nil, fmt.Errorf("error building encryption key provider: %v", err)
+	}
+
+	s.ca, err = NewVFSCAStore(location.Join("pki"), dryrun, encryption)
 	if err != nil {
 		return nil, fmt.Errorf("error building CA store: %v", err)
 	}
-	s.secrets, err = NewVFSSecretStore(location.Join("secrets"))
+	s.secrets, err = NewVFSSecretStore(location.Join("secrets"), encryption)
 	if err != nil {
 		return nil, fmt.Errorf("error building secret store: %v", err)
 	}
@@ -53,6 +84,10 @@ func (s *VFSStateStore) CA() CAStore {
 	return s.ca
 }
 
+func (s *VFSStateStore) EncryptionConfig() *EncryptionConfig {
+	return s.encryptionConfig
+}
+
 func (s *VFSStateStore) VFSPath() vfs.Path {
 	return s.location
 }
@@ -80,13 +115,16 @@ func (s *VFSStateStore) ListChildren(pathPrefix string) ([]string, error) {
 
 func (s *VFSStateStore) ReadConfig(path string, config interface{}) error {
 	configPath := s.location.Join(path)
-	data, err := configPath.ReadFile()
+	data, version, err := readWithVersion(configPath)
 	if err != nil {
 		if os.IsNotExist(err) {
+			// Remember that the file did not exist, so we notice if someone else creates it
+			s.setVersion(path, "")
 			return nil
 		}
 		return fmt.Errorf("error reading configuration file %s: %v", configPath, err)
 	}
+	s.setVersion(path, version)
 
 	// Yaml can't parse empty strings
 	configString := string(data)
@@ -110,9 +148,86 @@ func (s *VFSStateStore) WriteConfig(path string, config interface{}) error {
 		return fmt.Errorf("error marshalling configuration: %v", err)
 	}
 
-	err = configPath.WriteFile(data)
+	// We only record a revision when the configuration changes
+	existing, currentVersion, err := readWithVersion(configPath)
+	if err == nil {
+		if bytes.Equal(existing, data) {
+			glog.V(2).Infof("configuration file %s is unchanged", configPath)
+			s.setVersion(path, currentVersion)
+			return nil
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("error reading configuration file %s: %v", configPath, err)
+	}
+
+	versionedPath, ok := configPath.(vfs.VersionedPath)
+	if !ok {
+		glog.V(2).Infof("state store %s does not support versioning; concurrent changes will not be detected", configPath)
+		err = configPath.WriteFile(data)
+		if err != nil {
+			return fmt.Errorf("error writing configuration file %s: %v", configPath, err)
+		}
+	} else {
+		// If we read the file earlier, the write must be based on that version; otherwise this is a blind write
+		expectedVersion, found := s.getVersion(path)
+		if !found {
+			expectedVersion = currentVersion
+		}
+
+		newVersion, err := versionedPath.WriteFileIfVersion(data, expectedVersion)
+		if err != nil {
+			if vfs.IsConflict(err) {
+				return err
+			}
+			return fmt.Errorf("error writing configuration file %s: %v", configPath, err)
+		}
+		s.setVersion(path, newVersion)
+	}
+
+	return s.recordRevision(path, data, false)
+}
+
+func (s *VFSStateStore) DeleteConfig(path string) error {
+	configPath := s.location.Join(path)
+
+	// Not all backends report deleting a missing file as an error, so check first
+	_, err := configPath.ReadFile()
 	if err != nil {
-		return fmt.Errorf("error writing configuration file %s: %v", configPath, err)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("error reading configuration file %s: %v", configPath, err)
 	}
-	return nil
+
+	err = configPath.Remove()
+	if err != nil {
+		return fmt.Errorf("error deleting configuration file %s: %v", configPath, err)
+	}
+	s.setVersion(path, "")
+
+	return s.recordRevision(path, nil, true)
+}
+
+// readWithVersion reads the file, along with its version if the path supports versioning
+func readWithVersion(p vfs.Path) ([]byte, string, error) {
+	if versionedPath, ok := p.(vfs.VersionedPath); ok {
+		return versionedPath.ReadFileWithVersion()
+	}
+	data, err := p.ReadFile()
+	return data, "", err
+}
+
+func (s *VFSStateStore) getVersion(path string) (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	version, found := s.versions[path]
+	return version, found
+}
+
+func (s *VFSStateStore) setVersion(path string, version string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.versions[path] = version
 }
@@ -0,0 +1,100 @@
+package fi
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"k8s.io/kops/upup/pkg/fi/utils"
+	"os"
+	"sort"
+	"time"
+)
+
+// PathHistory is the directory (relative to the StateStore) under which we keep the Revisions
+const PathHistory = "history"
+
+// revisionIDFormat is the time format used for Revision IDs, chosen so that IDs sort chronologically
+const revisionIDFormat = "20060102T150405.000000000Z"
+
+// Revision is a recorded change to a configuration file in the StateStore
+type Revision struct {
+	// ID identifies the revision; IDs sort in the order the revisions were made
+	ID string `json:"id"`
+
+	Timestamp time.Time `json:"timestamp"`
+	Author    string    `json:"author,omitempty"`
+
+	// Path is the configuration file that was changed, relative to the StateStore
+	Path string `json:"path"`
+	// Deleted is true if the file was deleted
+	Deleted bool `json:"deleted,omitempty"`
+	// Data is the contents of the file after the change
+	Data string `json:"data,omitempty"`
+}
+
+// revisionAuthor returns the user that we record as making a change
+func revisionAuthor() string {
+	author := os.Getenv("KOPS_AUTHOR")
+	if author == "" {
+		author = os.Getenv("USER")
+	}
+	return author
+}
+
+// recordRevision stores a copy of a configuration change under PathHistory.
+// Each revision is a single file, so this works with any vfs.Path.
+func (s *VFSStateStore) recordRevision(path string, data []byte, deleted bool) error {
+	now := time.Now().UTC()
+	revision := &Revision{
+		Timestamp: now,
+		Author:    revisionAuthor(),
+		Path:      path,
+		Deleted:   deleted,
+		Data:      string(data),
+	}
+
+	// IDs are derived from the time; in the unlikely event of a collision we bump the ID
+	for attempt := 0; ; attempt++ {
+		revision.ID = now.Add(time.Duration(attempt)).Format(revisionIDFormat)
+
+		b, err := utils.YamlMarshal(revision)
+		if err != nil {
+			return fmt.Errorf("error marshalling revision: %v", err)
+		}
+
+		p := s.location.Join(PathHistory, revision.ID)
+		err = p.CreateFile(b)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) || attempt >= 10 {
+			return fmt.Errorf("error writing revision %s: %v", p, err)
+		}
+	}
+
+	glog.V(2).Infof("recorded revision %s for %s", revision.ID, path)
+	return nil
+}
+
+func (s *VFSStateStore) ListRevisions() ([]*Revision, error) {
+	ids, err := s.ListChildren(PathHistory)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(ids)
+
+	var revisions []*Revision
+	for _, id := range ids {
+		p := s.location.Join(PathHistory, id)
+		data, err := p.ReadFile()
+		if err != nil {
+			return nil, fmt.Errorf("error reading revision %s: %v", p, err)
+		}
+		revision := &Revision{}
+		err = utils.YamlUnmarshal(data, revision)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing revision %s: %v", p, err)
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, nil
+}
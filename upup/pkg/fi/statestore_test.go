@@ -0,0 +1,94 @@
+package fi
+
+import (
+	"k8s.io/kops/upup/pkg/fi/vfs"
+	"testing"
+)
+
+type testConfig struct {
+	Value string `json:"value,omitempty"`
+}
+
+func TestWriteConfig_DetectsConcurrentModification(t *testing.T) {
+	memfs := vfs.NewMemFSContext()
+	base := vfs.NewMemFSPath(memfs, "state")
+
+	first := newTestStateStore(t, base)
+	second := newTestStateStore(t, base)
+
+	err := first.WriteConfig("config", &testConfig{Value: "initial"})
+	if err != nil {
+		t.Fatalf("error writing initial config: %v", err)
+	}
+
+	config := &testConfig{}
+	if err := first.ReadConfig("config", config); err != nil {
+		t.Fatalf("error reading config: %v", err)
+	}
+	if err := second.ReadConfig("config", config); err != nil {
+		t.Fatalf("error reading config: %v", err)
+	}
+
+	err = first.WriteConfig("config", &testConfig{Value: "first"})
+	if err != nil {
+		t.Fatalf("error writing config from first store: %v", err)
+	}
+
+	err = second.WriteConfig("config", &testConfig{Value: "second"})
+	if err == nil {
+		t.Fatalf("expected conflict writing config from second store")
+	}
+	if !vfs.IsConflict(err) {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+
+	actual := &testConfig{}
+	if err := newTestStateStore(t, base).ReadConfig("config", actual); err != nil {
+		t.Fatalf("error reading config: %v", err)
+	}
+	if actual.Value != "first" {
+		t.Fatalf("expected first write to be preserved, got %q", actual.Value)
+	}
+
+	// After re-reading, the second store can write again
+	if err := second.ReadConfig("config", config); err != nil {
+		t.Fatalf("error reading config: %v", err)
+	}
+	err = second.WriteConfig("config", &testConfig{Value: "second"})
+	if err != nil {
+		t.Fatalf("error writing config after re-reading: %v", err)
+	}
+}
+
+func TestWriteConfig_DetectsConcurrentCreation(t *testing.T) {
+	memfs := vfs.NewMemFSContext()
+	base := vfs.NewMemFSPath(memfs, "state")
+
+	first := newTestStateStore(t, base)
+	second := newTestStateStore(t, base)
+
+	config := &testConfig{}
+	if err := first.ReadConfig("config", config); err != nil {
+		t.Fatalf("error reading config: %v", err)
+	}
+	if err := second.ReadConfig("config", config); err != nil {
+		t.Fatalf("error reading config: %v", err)
+	}
+
+	if err := first.WriteConfig("config", &testConfig{Value: "first"}); err != nil {
+		t.Fatalf("error writing config from first store: %v", err)
+	}
+
+	err := second.WriteConfig("config", &testConfig{Value: "second"})
+	if !vfs.IsConflict(err) {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+}
+
+func newTestStateStore(t *testing.T, base vfs.Path) *VFSStateStore {
+	s, err := NewVFSStateStore(base, "concurrency.example.com", false)
+	if err != nil {
+		t.Fatalf("error building state store: %v", err)
+	}
+	return s
+}
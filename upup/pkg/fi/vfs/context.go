@@ -6,6 +6,9 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/storage/v1"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -35,7 +38,7 @@ func (c *VFSContext) ReadFile(location string) ([]byte, error) {
 		case "metadata":
 			switch u.Host {
 			case "gce":
-				httpURL := "http://169.254.169.254/computeMetadata/v1/instance/attributes/" + u.Path
+				httpURL := "http://169.254.169.254/computeMetadata/v1/instance/attributes/" + strings.TrimPrefix(u.Path, "/")
 				httpHeaders := make(map[string]string)
 				httpHeaders["Metadata-Flavor"] = "Google"
 				return c.readHttpLocation(httpURL, httpHeaders)
@@ -69,6 +72,10 @@ func (c *VFSContext) BuildVfsPath(p string) (Path, error) {
 		return c.buildS3Path(p)
 	}
 
+	if strings.HasPrefix(p, "gs://") {
+		return c.buildGCSPath(p)
+	}
+
 	return nil, fmt.Errorf("unknown / unhandled path type: %q", p)
 }
 
@@ -139,3 +146,26 @@ func (c *VFSContext) buildS3Path(p string) (*S3Path, error) {
 	s3path := NewS3Path(s3Client, bucket, u.Path)
 	return s3path, nil
 }
+
+func (c *VFSContext) buildGCSPath(p string) (*GSPath, error) {
+	u, err := url.Parse(p)
+	if err != nil {
+		return nil, fmt.Errorf("invalid google cloud storage path: %q", err)
+	}
+
+	bucket := strings.TrimSuffix(u.Host, "/")
+
+	// TODO: Caching (of the storage client)
+	ctx := context.Background()
+	client, err := google.DefaultClient(ctx, storage.DevstorageReadWriteScope)
+	if err != nil {
+		return nil, fmt.Errorf("error building google API client: %v", err)
+	}
+	storageService, err := storage.New(client)
+	if err != nil {
+		return nil, fmt.Errorf("error building storage API client: %v", err)
+	}
+
+	gsPath := NewGSPath(storageService, bucket, u.Path)
+	return gsPath, nil
+}
@@ -166,3 +166,48 @@ func (p *FSPath) Hash(a hashing.HashAlgorithm) (*hashing.Hash, error) {
 
 	return a.HashFile(p.location)
 }
+
+var _ VersionedPath = &FSPath{}
+
+// ReadFileWithVersion implements VersionedPath; the version is the hash of the contents
+func (p *FSPath) ReadFileWithVersion() ([]byte, string, error) {
+	data, err := p.ReadFile()
+	if err != nil {
+		return nil, "", err
+	}
+	return data, hashVersion(data), nil
+}
+
+// WriteFileIfVersion implements VersionedPath.
+// We lock the parent directory (see lockDir), so that concurrent kops processes on the same
+// machine cannot interleave the check and the write.
+func (p *FSPath) WriteFileIfVersion(data []byte, version string) (string, error) {
+	dir := path.Dir(p.location)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", fmt.Errorf("error creating directories %q: %v", dir, err)
+	}
+
+	unlock, err := lockDir(dir)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	actual := ""
+	existing, err := ioutil.ReadFile(p.location)
+	if err == nil {
+		actual = hashVersion(existing)
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("error reading %q: %v", p.location, err)
+	}
+
+	if actual != version {
+		return "", &ConflictError{Path: p.location}
+	}
+
+	if err := p.WriteFile(data); err != nil {
+		return "", err
+	}
+	return hashVersion(data), nil
+}
@@ -0,0 +1,40 @@
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package vfs
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"time"
+)
+
+// lockFileName is the name of the lock file created by lockDir
+const lockFileName = ".kops.lock"
+
+// lockTimeout is how long lockDir waits for another process to release the lock
+const lockTimeout = 30 * time.Second
+
+// lockDir locks the directory on platforms without flock, by exclusively creating a lock file in it.
+// It returns a function that releases the lock by removing the file.
+// If a kops process dies while holding the lock, the file must be removed by hand; the error says so.
+func lockDir(dir string) (func(), error) {
+	lockPath := path.Join(dir, lockFileName)
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			f.Close()
+			return func() {
+				os.Remove(lockPath)
+			}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("error creating lock file %q: %v", lockPath, err)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for lock file %q; if no other kops process is running, remove it and try again", lockPath)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,28 @@
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package vfs
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockDir takes an exclusive flock on the directory, and returns a function that releases it.
+// We lock the directory rather than a lock file so that we don't leave extra files in the tree.
+func lockDir(dir string) (func(), error) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error opening directory %q for locking: %v", dir, err)
+	}
+
+	if err := syscall.Flock(int(d.Fd()), syscall.LOCK_EX); err != nil {
+		d.Close()
+		return nil, fmt.Errorf("error locking directory %q: %v", dir, err)
+	}
+
+	return func() {
+		syscall.Flock(int(d.Fd()), syscall.LOCK_UN)
+		d.Close()
+	}, nil
+}
@@ -0,0 +1,257 @@
+package vfs
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/storage/v1"
+	"io/ioutil"
+	"k8s.io/kops/upup/pkg/fi/hashing"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// GSPath is a vfs path for Google Cloud Storage
+type GSPath struct {
+	client *storage.Service
+	bucket string
+	key    string
+	md5    string
+}
+
+var _ Path = &GSPath{}
+var _ HasHash = &GSPath{}
+var _ VersionedPath = &GSPath{}
+
+func NewGSPath(client *storage.Service, bucket string, key string) *GSPath {
+	bucket = strings.TrimSuffix(bucket, "/")
+	key = strings.TrimPrefix(key, "/")
+
+	return &GSPath{
+		client: client,
+		bucket: bucket,
+		key:    key,
+	}
+}
+
+func (p *GSPath) Path() string {
+	return "gs://" + p.bucket + "/" + p.key
+}
+
+func (p *GSPath) Bucket() string {
+	return p.bucket
+}
+
+func (p *GSPath) String() string {
+	return p.Path()
+}
+
+func (p *GSPath) Remove() error {
+	err := p.client.Objects.Delete(p.bucket, p.key).Do()
+	if err != nil {
+		if isGCSNotFound(err) {
+			return os.ErrNotExist
+		}
+		return fmt.Errorf("error deleting %s: %v", p, err)
+	}
+
+	return nil
+}
+
+func (p *GSPath) Join(relativePath ...string) Path {
+	args := []string{p.key}
+	args = append(args, relativePath...)
+	joined := path.Join(args...)
+	return &GSPath{
+		client: p.client,
+		bucket: p.bucket,
+		key:    joined,
+	}
+}
+
+func (p *GSPath) WriteFile(data []byte) error {
+	glog.V(4).Infof("Writing file %q", p)
+
+	obj := &storage.Object{
+		Name: p.key,
+	}
+	_, err := p.client.Objects.Insert(p.bucket, obj).Media(bytes.NewReader(data)).Do()
+	if err != nil {
+		return fmt.Errorf("error writing %s: %v", p, err)
+	}
+
+	return nil
+}
+
+// CreateFile writes the file only if it does not already exist.
+// Unlike S3, GCS supports preconditions, so this is atomic even across processes.
+func (p *GSPath) CreateFile(data []byte) error {
+	glog.V(4).Infof("Creating file %q", p)
+
+	obj := &storage.Object{
+		Name: p.key,
+	}
+	// A generation of 0 means that the object must not exist
+	_, err := p.client.Objects.Insert(p.bucket, obj).Media(bytes.NewReader(data)).IfGenerationMatch(0).Do()
+	if err != nil {
+		if isGCSPreconditionFailed(err) {
+			return os.ErrExist
+		}
+		return fmt.Errorf("error writing %s: %v", p, err)
+	}
+
+	return nil
+}
+
+func (p *GSPath) ReadFile() ([]byte, error) {
+	data, _, err := p.ReadFileWithVersion()
+	return data, err
+}
+
+// ReadFileWithVersion implements VersionedPath; the version is the object generation
+func (p *GSPath) ReadFileWithVersion() ([]byte, string, error) {
+	glog.V(4).Infof("Reading file %q", p)
+
+	response, err := p.client.Objects.Get(p.bucket, p.key).Download()
+	if err != nil {
+		if isGCSNotFound(err) {
+			return nil, "", os.ErrNotExist
+		}
+		return nil, "", fmt.Errorf("error fetching %s: %v", p, err)
+	}
+	defer response.Body.Close()
+
+	d, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("error reading %s: %v", p, err)
+	}
+	return d, response.Header.Get("X-Goog-Generation"), nil
+}
+
+// WriteFileIfVersion implements VersionedPath, using a generation precondition on the write
+func (p *GSPath) WriteFileIfVersion(data []byte, version string) (string, error) {
+	glog.V(4).Infof("Writing file %q", p)
+
+	var generation int64
+	if version != "" {
+		var err error
+		generation, err = strconv.ParseInt(version, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid version for %s: %q", p, version)
+		}
+	}
+
+	obj := &storage.Object{
+		Name: p.key,
+	}
+	written, err := p.client.Objects.Insert(p.bucket, obj).Media(bytes.NewReader(data)).IfGenerationMatch(generation).Do()
+	if err != nil {
+		if isGCSPreconditionFailed(err) {
+			return "", &ConflictError{Path: p.Path()}
+		}
+		return "", fmt.Errorf("error writing %s: %v", p, err)
+	}
+
+	return strconv.FormatInt(written.Generation, 10), nil
+}
+
+func (p *GSPath) ReadDir() ([]Path, error) {
+	prefix := p.key
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	paths, err := p.listObjects(prefix, "/")
+	if err != nil {
+		return nil, err
+	}
+	glog.V(8).Infof("Listed files in %v: %v", p, paths)
+	return paths, nil
+}
+
+func (p *GSPath) ReadTree() ([]Path, error) {
+	// No delimiter for recursive search
+	return p.listObjects(p.key, "")
+}
+
+func (p *GSPath) listObjects(prefix string, delimiter string) ([]Path, error) {
+	call := p.client.Objects.List(p.bucket).Prefix(prefix)
+	if delimiter != "" {
+		call = call.Delimiter(delimiter)
+	}
+
+	var paths []Path
+	err := call.Pages(context.Background(), func(page *storage.Objects) error {
+		for _, o := range page.Items {
+			child := &GSPath{
+				client: p.client,
+				bucket: p.bucket,
+				key:    o.Name,
+				md5:    o.Md5Hash,
+			}
+			paths = append(paths, child)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing %s: %v", p, err)
+	}
+	return paths, nil
+}
+
+func (p *GSPath) Base() string {
+	return path.Base(p.key)
+}
+
+func (p *GSPath) PreferredHash() (*hashing.Hash, error) {
+	return p.Hash(hashing.HashAlgorithmMD5)
+}
+
+func (p *GSPath) Hash(a hashing.HashAlgorithm) (*hashing.Hash, error) {
+	if a != hashing.HashAlgorithmMD5 {
+		return nil, nil
+	}
+
+	md5 := p.md5
+	if md5 == "" {
+		obj, err := p.client.Objects.Get(p.bucket, p.key).Do()
+		if err != nil {
+			if isGCSNotFound(err) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("error fetching metadata for %s: %v", p, err)
+		}
+		md5 = obj.Md5Hash
+	}
+
+	// Composite objects do not have an MD5
+	if md5 == "" {
+		return nil, nil
+	}
+
+	// GCS reports the MD5 base64 encoded, not hex encoded as in S3 ETags
+	md5Bytes, err := base64.StdEncoding.DecodeString(md5)
+	if err != nil {
+		return nil, fmt.Errorf("md5Hash was not valid base64: %q", md5)
+	}
+
+	return &hashing.Hash{Algorithm: hashing.HashAlgorithmMD5, HashValue: md5Bytes}, nil
+}
+
+// isGCSNotFound checks if the error is a google API "not found" error
+func isGCSNotFound(err error) bool {
+	apiErr, ok := err.(*googleapi.Error)
+	return ok && apiErr.Code == http.StatusNotFound
+}
+
+// isGCSPreconditionFailed checks if the error is because an ifGenerationMatch precondition did not hold
+func isGCSPreconditionFailed(err error) bool {
+	apiErr, ok := err.(*googleapi.Error)
+	return ok && apiErr.Code == http.StatusPreconditionFailed
+}
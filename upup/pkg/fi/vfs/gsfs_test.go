@@ -0,0 +1,314 @@
+package vfs
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"google.golang.org/api/storage/v1"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeGCSObject is an object stored in the fakeGCS server
+type fakeGCSObject struct {
+	data       []byte
+	generation int64
+}
+
+// fakeGCS is a minimal in-process implementation of the GCS JSON API,
+// supporting just the calls made by GSPath
+type fakeGCS struct {
+	mutex          sync.Mutex
+	objects        map[string]*fakeGCSObject
+	lastGeneration int64
+}
+
+func newFakeGCS() *fakeGCS {
+	return &fakeGCS{
+		objects: make(map[string]*fakeGCSObject),
+	}
+}
+
+// start runs the fake server, returning the server and a storage client that talks to it
+func (f *fakeGCS) start(t *testing.T) (*httptest.Server, *storage.Service) {
+	server := httptest.NewServer(f)
+	client, err := storage.New(http.DefaultClient)
+	if err != nil {
+		t.Fatalf("error building storage client: %v", err)
+	}
+	client.BasePath = server.URL + "/storage/v1/"
+	return server, client
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	// Object names are escaped in the path, so we must use the raw path
+	p := strings.TrimPrefix(r.URL.EscapedPath(), "/upload")
+	p = strings.TrimPrefix(p, "/storage/v1/b/")
+	tokens := strings.SplitN(p, "/", 3)
+	if len(tokens) < 2 || tokens[1] != "o" {
+		writeFakeGCSError(w, http.StatusNotFound, "unknown path "+r.URL.Path)
+		return
+	}
+	bucket := tokens[0]
+
+	if len(tokens) == 2 {
+		switch r.Method {
+		case "GET":
+			f.list(w, r, bucket)
+		case "POST":
+			f.insert(w, r, bucket)
+		default:
+			writeFakeGCSError(w, http.StatusMethodNotAllowed, "unhandled method "+r.Method)
+		}
+		return
+	}
+
+	name, err := url.QueryUnescape(tokens[2])
+	if err != nil {
+		writeFakeGCSError(w, http.StatusBadRequest, "invalid object name")
+		return
+	}
+	key := bucket + "/" + name
+	obj := f.objects[key]
+	if obj == nil {
+		writeFakeGCSError(w, http.StatusNotFound, "not found: "+key)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		if r.URL.Query().Get("alt") == "media" {
+			w.Header().Set("X-Goog-Generation", strconv.FormatInt(obj.generation, 10))
+			w.Write(obj.data)
+			return
+		}
+		writeFakeGCSJSON(w, fakeGCSMetadata(bucket, name, obj))
+	case "DELETE":
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeFakeGCSError(w, http.StatusMethodNotAllowed, "unhandled method "+r.Method)
+	}
+}
+
+func (f *fakeGCS) list(w http.ResponseWriter, r *http.Request, bucket string) {
+	prefix := r.URL.Query().Get("prefix")
+	delimiter := r.URL.Query().Get("delimiter")
+
+	var names []string
+	for key := range f.objects {
+		if !strings.HasPrefix(key, bucket+"/") {
+			continue
+		}
+		name := strings.TrimPrefix(key, bucket+"/")
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if delimiter != "" && strings.Contains(name[len(prefix):], delimiter) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	response := &storage.Objects{}
+	for _, name := range names {
+		response.Items = append(response.Items, fakeGCSMetadata(bucket, name, f.objects[bucket+"/"+name]))
+	}
+	writeFakeGCSJSON(w, response)
+}
+
+func (f *fakeGCS) insert(w http.ResponseWriter, r *http.Request, bucket string) {
+	metadata, data, err := readFakeGCSUpload(r)
+	if err != nil {
+		writeFakeGCSError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	name := metadata.Name
+	if name == "" {
+		name = r.URL.Query().Get("name")
+	}
+	key := bucket + "/" + name
+
+	if s := r.URL.Query().Get("ifGenerationMatch"); s != "" {
+		expected, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			writeFakeGCSError(w, http.StatusBadRequest, "invalid ifGenerationMatch")
+			return
+		}
+		var actual int64
+		if existing := f.objects[key]; existing != nil {
+			actual = existing.generation
+		}
+		if actual != expected {
+			writeFakeGCSError(w, http.StatusPreconditionFailed, "precondition failed")
+			return
+		}
+	}
+
+	f.lastGeneration++
+	obj := &fakeGCSObject{data: data, generation: f.lastGeneration}
+	f.objects[key] = obj
+	writeFakeGCSJSON(w, fakeGCSMetadata(bucket, name, obj))
+}
+
+// readFakeGCSUpload parses a multipart upload (metadata then media), or a simple media upload
+func readFakeGCSUpload(r *http.Request) (*storage.Object, []byte, error) {
+	metadata := &storage.Object{}
+
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		data, err := ioutil.ReadAll(r.Body)
+		return metadata, data, err
+	}
+
+	reader := multipart.NewReader(r.Body, params["boundary"])
+	part, err := reader.NextPart()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading metadata part: %v", err)
+	}
+	if err := json.NewDecoder(part).Decode(metadata); err != nil {
+		return nil, nil, fmt.Errorf("error parsing metadata: %v", err)
+	}
+
+	part, err = reader.NextPart()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading media part: %v", err)
+	}
+	var data bytes.Buffer
+	if _, err := io.Copy(&data, part); err != nil {
+		return nil, nil, fmt.Errorf("error reading media: %v", err)
+	}
+	return metadata, data.Bytes(), nil
+}
+
+func fakeGCSMetadata(bucket string, name string, obj *fakeGCSObject) *storage.Object {
+	hash := md5.Sum(obj.data)
+	return &storage.Object{
+		Bucket:     bucket,
+		Name:       name,
+		Generation: obj.generation,
+		Md5Hash:    base64.StdEncoding.EncodeToString(hash[:]),
+		Size:       uint64(len(obj.data)),
+	}
+}
+
+func writeFakeGCSJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeFakeGCSError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	fmt.Fprintf(w, `{"error":{"code":%d,"message":%q}}`, code, message)
+}
+
+func TestGSPath_ReadWrite(t *testing.T) {
+	server, client := newFakeGCS().start(t)
+	defer server.Close()
+
+	base := NewGSPath(client, "state", "cluster.example.com")
+	p := base.Join("config")
+
+	if _, err := p.ReadFile(); !os.IsNotExist(err) {
+		t.Fatalf("expected not-exist reading missing file, got %v", err)
+	}
+
+	if err := p.WriteFile([]byte("hello")); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+	data, err := p.ReadFile()
+	if err != nil {
+		t.Fatalf("error reading file: %v", err)
+	}
+	if string(data) != "hello" {
+		t.Fatalf("unexpected contents: %q", string(data))
+	}
+
+	if err := p.(*GSPath).CreateFile([]byte("again")); err != os.ErrExist {
+		t.Fatalf("expected ErrExist creating existing file, got %v", err)
+	}
+
+	if err := base.Join("pki", "ca.crt").WriteFile([]byte("cert")); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+
+	children, err := base.ReadDir()
+	if err != nil {
+		t.Fatalf("error listing directory: %v", err)
+	}
+	if len(children) != 1 || children[0].Base() != "config" {
+		t.Fatalf("unexpected children: %v", children)
+	}
+
+	tree, err := base.ReadTree()
+	if err != nil {
+		t.Fatalf("error listing tree: %v", err)
+	}
+	if len(tree) != 2 {
+		t.Fatalf("unexpected tree: %v", tree)
+	}
+
+	hash, err := children[0].(HasHash).PreferredHash()
+	if err != nil {
+		t.Fatalf("error getting hash: %v", err)
+	}
+	expected := md5.Sum([]byte("hello"))
+	if hash == nil || !bytes.Equal(hash.HashValue, expected[:]) {
+		t.Fatalf("unexpected hash: %v", hash)
+	}
+
+	if err := p.Remove(); err != nil {
+		t.Fatalf("error removing file: %v", err)
+	}
+	if _, err := p.ReadFile(); !os.IsNotExist(err) {
+		t.Fatalf("expected not-exist after remove, got %v", err)
+	}
+}
+
+func TestGSPath_WriteFileIfVersion(t *testing.T) {
+	server, client := newFakeGCS().start(t)
+	defer server.Close()
+
+	p := NewGSPath(client, "state", "cluster.example.com/config")
+
+	version, err := p.WriteFileIfVersion([]byte("v1"), "")
+	if err != nil {
+		t.Fatalf("error creating file: %v", err)
+	}
+
+	_, readVersion, err := p.ReadFileWithVersion()
+	if err != nil {
+		t.Fatalf("error reading file: %v", err)
+	}
+	if readVersion != version {
+		t.Fatalf("version from read %q did not match version from write %q", readVersion, version)
+	}
+
+	if _, err := p.WriteFileIfVersion([]byte("v2"), version); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+
+	// A write based on the stale version must fail
+	_, err = p.WriteFileIfVersion([]byte("v3"), version)
+	if !IsConflict(err) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+}
@@ -0,0 +1,202 @@
+package vfs
+
+import (
+	"bytes"
+	"k8s.io/kops/upup/pkg/fi/hashing"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemFSPath is an in-memory Path implementation, intended for tests
+type MemFSPath struct {
+	context  *MemFSContext
+	location string
+}
+
+// MemFSContext holds the files of an in-memory filesystem
+type MemFSContext struct {
+	mutex sync.Mutex
+	files map[string][]byte
+
+	// clusterReadable is reported by IsClusterReadable, so tests can simulate an S3 state store
+	clusterReadable bool
+}
+
+var _ Path = &MemFSPath{}
+var _ HasHash = &MemFSPath{}
+
+func NewMemFSContext() *MemFSContext {
+	return &MemFSContext{
+		files: make(map[string][]byte),
+	}
+}
+
+// MarkClusterReadable makes IsClusterReadable return true for paths in this context
+func (c *MemFSContext) MarkClusterReadable() {
+	c.clusterReadable = true
+}
+
+func NewMemFSPath(context *MemFSContext, location string) *MemFSPath {
+	return &MemFSPath{context: context, location: path.Clean("/" + location)}
+}
+
+func (p *MemFSPath) Join(relativePath ...string) Path {
+	args := []string{p.location}
+	args = append(args, relativePath...)
+	joined := path.Join(args...)
+	return &MemFSPath{context: p.context, location: joined}
+}
+
+func (p *MemFSPath) WriteFile(data []byte) error {
+	p.context.mutex.Lock()
+	defer p.context.mutex.Unlock()
+
+	p.context.files[p.location] = append([]byte{}, data...)
+	return nil
+}
+
+func (p *MemFSPath) CreateFile(data []byte) error {
+	p.context.mutex.Lock()
+	defer p.context.mutex.Unlock()
+
+	if _, found := p.context.files[p.location]; found {
+		return os.ErrExist
+	}
+	p.context.files[p.location] = append([]byte{}, data...)
+	return nil
+}
+
+func (p *MemFSPath) ReadFile() ([]byte, error) {
+	p.context.mutex.Lock()
+	defer p.context.mutex.Unlock()
+
+	data, found := p.context.files[p.location]
+	if !found {
+		return nil, os.ErrNotExist
+	}
+	return append([]byte{}, data...), nil
+}
+
+func (p *MemFSPath) ReadDir() ([]Path, error) {
+	p.context.mutex.Lock()
+	defer p.context.mutex.Unlock()
+
+	prefix := p.location
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	children := make(map[string]bool)
+	for k := range p.context.files {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		child := strings.SplitN(k[len(prefix):], "/", 2)[0]
+		children[child] = true
+	}
+	if len(children) == 0 {
+		return nil, os.ErrNotExist
+	}
+
+	var names []string
+	for child := range children {
+		names = append(names, child)
+	}
+	sort.Strings(names)
+
+	var paths []Path
+	for _, name := range names {
+		paths = append(paths, &MemFSPath{context: p.context, location: path.Join(p.location, name)})
+	}
+	return paths, nil
+}
+
+func (p *MemFSPath) ReadTree() ([]Path, error) {
+	p.context.mutex.Lock()
+	defer p.context.mutex.Unlock()
+
+	prefix := p.location
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	var names []string
+	for k := range p.context.files {
+		if strings.HasPrefix(k, prefix) {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	var paths []Path
+	for _, name := range names {
+		paths = append(paths, &MemFSPath{context: p.context, location: name})
+	}
+	return paths, nil
+}
+
+func (p *MemFSPath) Base() string {
+	return path.Base(p.location)
+}
+
+func (p *MemFSPath) Path() string {
+	return "memfs://" + p.location
+}
+
+func (p *MemFSPath) String() string {
+	return p.Path()
+}
+
+func (p *MemFSPath) Remove() error {
+	p.context.mutex.Lock()
+	defer p.context.mutex.Unlock()
+
+	if _, found := p.context.files[p.location]; !found {
+		return os.ErrNotExist
+	}
+	delete(p.context.files, p.location)
+	return nil
+}
+
+func (p *MemFSPath) PreferredHash() (*hashing.Hash, error) {
+	return p.Hash(hashing.HashAlgorithmSHA256)
+}
+
+func (p *MemFSPath) Hash(a hashing.HashAlgorithm) (*hashing.Hash, error) {
+	data, err := p.ReadFile()
+	if err != nil {
+		return nil, err
+	}
+	return a.Hash(bytes.NewReader(data))
+}
+
+var _ VersionedPath = &MemFSPath{}
+
+// ReadFileWithVersion implements VersionedPath; the version is the hash of the contents
+func (p *MemFSPath) ReadFileWithVersion() ([]byte, string, error) {
+	data, err := p.ReadFile()
+	if err != nil {
+		return nil, "", err
+	}
+	return data, hashVersion(data), nil
+}
+
+// WriteFileIfVersion implements VersionedPath
+func (p *MemFSPath) WriteFileIfVersion(data []byte, version string) (string, error) {
+	p.context.mutex.Lock()
+	defer p.context.mutex.Unlock()
+
+	actual := ""
+	if existing, found := p.context.files[p.location]; found {
+		actual = hashVersion(existing)
+	}
+	if actual != version {
+		return "", &ConflictError{Path: p.location}
+	}
+
+	p.context.files[p.location] = append([]byte{}, data...)
+	return hashVersion(data), nil
+}
@@ -230,3 +230,166 @@ func AWSErrorCode(err error) string {
 	}
 	return ""
 }
+
+var _ VersionedPath = &S3Path{}
+
+// ReadFileWithVersion implements VersionedPath; the version is the S3 VersionId of the object.
+// The bucket must have versioning enabled for WriteFileIfVersion to work.
+func (p *S3Path) ReadFileWithVersion() ([]byte, string, error) {
+	glog.V(4).Infof("Reading file %q", p)
+
+	request := &s3.GetObjectInput{}
+	request.Bucket = aws.String(p.bucket)
+	request.Key = aws.String(p.key)
+
+	response, err := p.client.GetObject(request)
+	if err != nil {
+		if AWSErrorCode(err) == "NoSuchKey" {
+			return nil, "", os.ErrNotExist
+		}
+		return nil, "", fmt.Errorf("error fetching %s: %v", p, err)
+	}
+	defer response.Body.Close()
+
+	d, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("error reading %s: %v", p, err)
+	}
+	return d, aws.StringValue(response.VersionId), nil
+}
+
+// WriteFileIfVersion implements VersionedPath.
+// S3 has no conditional PUT, so we rely on bucket versioning: we write a new version, and then check that
+// the version immediately before ours is the one that was read.  If it is not, another writer got in first,
+// so we delete our version (leaving theirs in place) and return a *ConflictError.
+// Without versioning a concurrent write cannot be detected, so we return an error rather than writing.
+func (p *S3Path) WriteFileIfVersion(data []byte, version string) (string, error) {
+	versioned, err := p.isBucketVersioned()
+	if err != nil {
+		return "", err
+	}
+	if !versioned {
+		return "", fmt.Errorf("versioning must be enabled on bucket %q to safely update %s; "+
+			"enable it with `aws s3api put-bucket-versioning --bucket %s --versioning-configuration Status=Enabled`",
+			p.bucket, p, p.bucket)
+	}
+
+	glog.V(4).Infof("Writing file %q", p)
+
+	putRequest := &s3.PutObjectInput{}
+	putRequest.Body = bytes.NewReader(data)
+	putRequest.Bucket = aws.String(p.bucket)
+	putRequest.Key = aws.String(p.key)
+
+	putResponse, err := p.client.PutObject(putRequest)
+	if err != nil {
+		return "", fmt.Errorf("error writing %s: %v", p, err)
+	}
+
+	newVersion := aws.StringValue(putResponse.VersionId)
+	if newVersion == "" {
+		return "", fmt.Errorf("no version id returned when writing %s; is versioning enabled on bucket %q?", p, p.bucket)
+	}
+
+	previous, known, err := p.findPreviousVersion(newVersion)
+	if err != nil {
+		return "", err
+	}
+	if known && previous == version {
+		return newVersion, nil
+	}
+
+	glog.Infof("Detected concurrent write to %s; removing version %q", p, newVersion)
+	deleteRequest := &s3.DeleteObjectInput{}
+	deleteRequest.Bucket = aws.String(p.bucket)
+	deleteRequest.Key = aws.String(p.key)
+	deleteRequest.VersionId = aws.String(newVersion)
+	if _, err := p.client.DeleteObject(deleteRequest); err != nil {
+		return "", fmt.Errorf("error removing version %q of %s after a concurrent write: %v", newVersion, p, err)
+	}
+	return "", &ConflictError{Path: p.Path()}
+}
+
+// isBucketVersioned returns true if versioning is enabled on the bucket.
+// A bucket where versioning is suspended overwrites the "null" version, so it does not count.
+func (p *S3Path) isBucketVersioned() (bool, error) {
+	request := &s3.GetBucketVersioningInput{}
+	request.Bucket = aws.String(p.bucket)
+
+	response, err := p.client.GetBucketVersioning(request)
+	if err != nil {
+		return false, fmt.Errorf("error checking versioning on bucket %q: %v", p.bucket, err)
+	}
+	return aws.StringValue(response.Status) == s3.BucketVersioningStatusEnabled, nil
+}
+
+// findPreviousVersion returns the id of the version written immediately before newVersion,
+// or "" if there was none or it was a delete marker.
+// S3 lists versions and delete markers separately, so we order delete markers against versions by time;
+// if the order cannot be determined (timestamps are only to the second), known is false.
+func (p *S3Path) findPreviousVersion(newVersion string) (string, bool, error) {
+	var versions []*s3.ObjectVersion
+	var deleteMarkers []*s3.DeleteMarkerEntry
+
+	request := &s3.ListObjectVersionsInput{}
+	request.Bucket = aws.String(p.bucket)
+	request.Prefix = aws.String(p.key)
+
+	err := p.client.ListObjectVersionsPages(request, func(page *s3.ListObjectVersionsOutput, lastPage bool) bool {
+		for _, v := range page.Versions {
+			if aws.StringValue(v.Key) == p.key {
+				versions = append(versions, v)
+			}
+		}
+		for _, m := range page.DeleteMarkers {
+			if aws.StringValue(m.Key) == p.key {
+				deleteMarkers = append(deleteMarkers, m)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("error listing versions of %s: %v", p, err)
+	}
+
+	// Versions of a key are listed newest first
+	index := -1
+	for i, v := range versions {
+		if aws.StringValue(v.VersionId) == newVersion {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		return "", false, fmt.Errorf("version %q of %s not found in version listing", newVersion, p)
+	}
+
+	ours := aws.TimeValue(versions[index].LastModified)
+	previous := ""
+	if index+1 < len(versions) {
+		previous = aws.StringValue(versions[index+1].VersionId)
+	}
+
+	for _, m := range deleteMarkers {
+		t := aws.TimeValue(m.LastModified)
+		if t.After(ours) {
+			continue
+		}
+		if t.Equal(ours) {
+			return "", false, nil
+		}
+		if previous == "" {
+			return "", true, nil
+		}
+		previousTime := aws.TimeValue(versions[index+1].LastModified)
+		if t.After(previousTime) {
+			// The object was deleted between the previous version and ours
+			return "", true, nil
+		}
+		if t.Equal(previousTime) {
+			return "", false, nil
+		}
+	}
+
+	return previous, true, nil
+}
@@ -1,6 +1,7 @@
 package vfs
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/golang/glog"
 	"k8s.io/kops/upup/pkg/fi/hashing"
@@ -45,6 +46,44 @@ type HasHash interface {
 	Hash(algorithm hashing.HashAlgorithm) (*hashing.Hash, error)
 }
 
+// VersionedPath is implemented by Paths that support optimistic concurrency control,
+// so that a read-modify-write cycle fails rather than overwriting a concurrent change.
+type VersionedPath interface {
+	// ReadFileWithVersion returns the file contents, along with a token that changes whenever the file is modified
+	ReadFileWithVersion() ([]byte, string, error)
+
+	// WriteFileIfVersion writes the file, but only if the file's version still matches the version that was read.
+	// An empty version means that the file must not exist.  It returns the version after the write,
+	// or a *ConflictError if the file was modified.
+	// Whether the check and the write are atomic depends on the backend; see the implementations.
+	WriteFileIfVersion(data []byte, version string) (string, error)
+}
+
+// ConflictError is returned when a file has been modified since it was read
+type ConflictError struct {
+	Path string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s has been modified by someone else since it was read; re-read it and try again", e.Path)
+}
+
+// IsConflict checks if the error is a ConflictError
+func IsConflict(err error) bool {
+	_, ok := err.(*ConflictError)
+	return ok
+}
+
+// hashVersion computes the version for a file on a backend where we use the hash of the contents
+func hashVersion(data []byte) string {
+	hash, err := hashing.HashAlgorithmSHA256.Hash(bytes.NewReader(data))
+	if err != nil {
+		// Hashing from memory cannot fail
+		glog.Fatalf("error hashing data: %v", err)
+	}
+	return hash.String()
+}
+
 func RelativePath(base Path, child Path) (string, error) {
 	basePath := base.Path()
 	childPath := child.Path()
@@ -65,12 +104,18 @@ func IsClusterReadable(p Path) bool {
 	case *S3Path:
 		return true
 
+	case *GSPath:
+		return true
+
 	case *SSHPath:
 		return false
 
 	case *FSPath:
 		return false
 
+	case *MemFSPath:
+		return p.(*MemFSPath).context.clusterReadable
+
 	default:
 		glog.Fatalf("IsClusterReadable not implemented for type %T", p)
 		return false
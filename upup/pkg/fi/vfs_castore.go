@@ -20,14 +20,18 @@ type VFSCAStore struct {
 	basedir        vfs.Path
 	caCertificates *certificates
 	caPrivateKeys  *privateKeys
+
+	// encryption is applied to private keys; certificates are public and are stored in plaintext
+	encryption *Encryption
 }
 
 var _ CAStore = &VFSCAStore{}
 
-func NewVFSCAStore(basedir vfs.Path, dryrun bool) (CAStore, error) {
+func NewVFSCAStore(basedir vfs.Path, dryrun bool, encryption *Encryption) (CAStore, error) {
 	c := &VFSCAStore{
-		dryrun:  dryrun,
-		basedir: basedir,
+		dryrun:     dryrun,
+		basedir:    basedir,
+		encryption: encryption,
 	}
 	//err := os.MkdirAll(path.Join(basedir, "private"), 0700)
 	//if err != nil {
@@ -67,10 +71,41 @@ func (s *VFSCAStore) VFSPath() vfs.Path {
 }
 
 func (c *VFSCAStore) generateCACertificate() error {
+	serial := c.buildSerial()
+
+	err := c.createCAKeypair(serial)
+	if err != nil {
+		return err
+	}
+
+	// Make double-sure it round-trips
+	privateKeys, err := c.loadPrivateKeys(c.buildPrivateKeyPoolPath(CertificateId_CA))
+	if err != nil {
+		return err
+	}
+	if privateKeys == nil || privateKeys.primary != serial.Text(10) {
+		return fmt.Errorf("failed to round-trip CA private key")
+	}
+
+	certificates, err := c.loadCertificates(c.buildCertificatePoolPath(CertificateId_CA))
+	if err != nil {
+		return err
+	}
+
+	if certificates == nil || certificates.primary != serial.Text(10) {
+		return fmt.Errorf("failed to round-trip CA certifiacate")
+	}
+
+	c.caPrivateKeys = privateKeys
+	c.caCertificates = certificates
+	return nil
+}
+
+// createCAKeypair generates a new self-signed CA, and stores it with the specified serial
+func (c *VFSCAStore) createCAKeypair(serial *big.Int) error {
 	subject := &pkix.Name{
 		CommonName: "kubernetes",
 	}
-	serial := c.buildSerial()
 	template := &x509.Certificate{
 		Subject:               *subject,
 		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
@@ -97,33 +132,12 @@ func (c *VFSCAStore) generateCACertificate() error {
 		return err
 	}
 
-	// Make double-sure it round-trips
-	privateKeys, err := c.loadPrivateKeys(c.buildPrivateKeyPoolPath(CertificateId_CA))
-	if err != nil {
-		return err
-	}
-	if privateKeys == nil || privateKeys.primary != serial.Text(10) {
-		return fmt.Errorf("failed to round-trip CA private key")
-	}
-
 	certPath := c.buildCertificatePath(CertificateId_CA, serial)
 	err = c.storeCertificate(caCertificate, certPath)
 	if err != nil {
 		return err
 	}
 
-	// Make double-sure it round-trips
-	certificates, err := c.loadCertificates(c.buildCertificatePoolPath(CertificateId_CA))
-	if err != nil {
-		return err
-	}
-
-	if certificates == nil || certificates.primary != serial.Text(10) {
-		return fmt.Errorf("failed to round-trip CA certifiacate")
-	}
-
-	c.caPrivateKeys = privateKeys
-	c.caCertificates = certificates
 	return nil
 }
 
@@ -295,19 +309,7 @@ func (c *VFSCAStore) FindCertificatePool(id string) (*CertificatePool, error) {
 }
 
 func (c *VFSCAStore) List() ([]string, error) {
-	var ids []string
-
-	issuedDir := c.basedir.Join("issued")
-	files, err := issuedDir.ReadDir()
-	if err != nil {
-		return nil, fmt.Errorf("error reading directory %q: %v", issuedDir, err)
-	}
-
-	for _, f := range files {
-		name := f.Base()
-		ids = append(ids, name)
-	}
-	return ids, nil
+	return c.listKeypairIDs()
 }
 
 func (c *VFSCAStore) IssueCert(id string, serial *big.Int, privateKey *PrivateKey, template *x509.Certificate) (*Certificate, error) {
@@ -416,6 +418,10 @@ func (c *VFSCAStore) loadOnePrivateKey(p vfs.Path) (*PrivateKey, error) {
 		}
 		return nil, err
 	}
+	data, err = c.encryption.Decrypt(p, data)
+	if err != nil {
+		return nil, err
+	}
 	k, err := ParsePEMPrivateKey(data)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing private key from %q: %v", p, err)
@@ -509,7 +515,12 @@ func (c *VFSCAStore) storePrivateKey(privateKey *PrivateKey, p vfs.Path) error {
 		return err
 	}
 
-	return p.WriteFile(data.Bytes())
+	encrypted, err := c.encryption.Encrypt(data.Bytes())
+	if err != nil {
+		return fmt.Errorf("error encrypting private key: %v", err)
+	}
+
+	return p.WriteFile(encrypted)
 }
 
 func (c *VFSCAStore) storeCertificate(cert *Certificate, p vfs.Path) error {
@@ -0,0 +1,499 @@
+package fi
+
+import (
+	"crypto/x509"
+	"fmt"
+	"github.com/golang/glog"
+	"k8s.io/kops/upup/pkg/fi/utils"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+)
+
+// RotationPhase is the progress of a KeyRotation.  Each call to RotateKeypair advances by one phase,
+// so that the cluster can be updated to trust the new keys before the old ones are retired.
+type RotationPhase string
+
+const (
+	// RotationPhaseBundling means we are creating the new CA (CA rotation only)
+	RotationPhaseBundling RotationPhase = "bundling"
+	// RotationPhaseBundled means the new CA is published alongside the old CA, but is not yet used for signing (CA rotation only)
+	RotationPhaseBundled RotationPhase = "bundled"
+	// RotationPhasePromoting means we are making the new CA the primary CA, and re-issuing certificates (CA rotation only)
+	RotationPhasePromoting RotationPhase = "promoting"
+	// RotationPhaseIssuing means we are issuing the new keypair (leaf rotation only)
+	RotationPhaseIssuing RotationPhase = "issuing"
+	// RotationPhaseIssued means the new keypair is the primary; the old keypair is still trusted until it is retired
+	RotationPhaseIssued RotationPhase = "issued"
+)
+
+// KeyRotation records the progress of a keypair rotation, so that an interrupted rotation can be resumed
+type KeyRotation struct {
+	ID    string        `json:"id"`
+	Phase RotationPhase `json:"phase"`
+
+	// OldSerials are the keypairs that are being replaced, and will be removed when the rotation completes
+	OldSerials []string `json:"oldSerials,omitempty"`
+
+	// StagedSerial is the serial under which the new CA is published while it is bundled;
+	// it sorts before any timestamp-based serial, so the old CA remains the primary
+	StagedSerial string `json:"stagedSerial,omitempty"`
+	// PrimarySerial is the serial under which the new CA is stored once it is promoted
+	PrimarySerial string `json:"primarySerial,omitempty"`
+}
+
+func (c *VFSCAStore) buildRotationPath(id string) vfs.Path {
+	return c.basedir.Join("rotation", id)
+}
+
+// FindRotation returns the rotation in progress for the keypair, or nil if there is none
+func (c *VFSCAStore) FindRotation(id string) (*KeyRotation, error) {
+	p := c.buildRotationPath(id)
+	data, err := p.ReadFile()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading rotation state %s: %v", p, err)
+	}
+
+	r := &KeyRotation{}
+	err = utils.YamlUnmarshal(data, r)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing rotation state %s: %v", p, err)
+	}
+	return r, nil
+}
+
+func (c *VFSCAStore) writeRotation(r *KeyRotation) error {
+	p := c.buildRotationPath(r.ID)
+	data, err := utils.YamlMarshal(r)
+	if err != nil {
+		return fmt.Errorf("error serializing rotation state: %v", err)
+	}
+	err = p.WriteFile(data)
+	if err != nil {
+		return fmt.Errorf("error writing rotation state %s: %v", p, err)
+	}
+	return nil
+}
+
+func (c *VFSCAStore) deleteRotation(id string) error {
+	p := c.buildRotationPath(id)
+	err := p.Remove()
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing rotation state %s: %v", p, err)
+	}
+	return nil
+}
+
+// RotateKeypair advances the rotation of the keypair by one phase, returning the new state,
+// or nil when the rotation is complete and the old keypair has been retired.
+//
+// For a leaf keypair, the first call issues a new keypair (which becomes the primary),
+// and the second call retires the old keypair.
+//
+// For the CA, the first call publishes a new CA alongside the old CA; the second promotes it and
+// re-issues all the certificates signed by the CA; and the third retires the old CA.
+// Each phase is resumable: if it is interrupted, calling RotateKeypair again completes it.
+func (c *VFSCAStore) RotateKeypair(id string) (*KeyRotation, error) {
+	r, err := c.FindRotation(id)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		r = &KeyRotation{ID: id}
+	}
+
+	if id == CertificateId_CA {
+		return c.rotateCA(r)
+	}
+
+	switch r.Phase {
+	case "":
+		certs, err := c.loadCertificates(c.buildCertificatePoolPath(id))
+		if err != nil {
+			return nil, err
+		}
+		if certs == nil || certs.Primary() == nil {
+			return nil, fmt.Errorf("keypair %q not found", id)
+		}
+		r.OldSerials = certs.serials()
+		r.Phase = RotationPhaseIssuing
+		if err := c.writeRotation(r); err != nil {
+			return nil, err
+		}
+		fallthrough
+
+	case RotationPhaseIssuing:
+		certs, err := c.loadCertificates(c.buildCertificatePoolPath(id))
+		if err != nil {
+			return nil, err
+		}
+		if certs == nil || certs.Primary() == nil {
+			return nil, fmt.Errorf("keypair %q not found", id)
+		}
+		// If we were interrupted after issuing, the new keypair is already there
+		old := make(map[string]bool)
+		for _, s := range r.OldSerials {
+			old[s] = true
+		}
+		issued := false
+		for _, s := range certs.serials() {
+			if !old[s] {
+				issued = true
+			}
+		}
+		if !issued {
+			if err := c.reissueKeypair(id, certs.Primary()); err != nil {
+				return nil, err
+			}
+		}
+		r.Phase = RotationPhaseIssued
+		return r, c.writeRotation(r)
+
+	case RotationPhaseIssued:
+		if err := c.removeSerials(id, r.OldSerials); err != nil {
+			return nil, err
+		}
+		return nil, c.deleteRotation(id)
+
+	default:
+		return nil, fmt.Errorf("unexpected rotation phase %q for keypair %q", r.Phase, id)
+	}
+}
+
+func (c *VFSCAStore) rotateCA(r *KeyRotation) (*KeyRotation, error) {
+	switch r.Phase {
+	case "":
+		if c.caCertificates == nil {
+			return nil, fmt.Errorf("CA certificate not found")
+		}
+		r.OldSerials = c.caCertificates.serials()
+		r.StagedSerial = buildSerial(0).Text(10)
+		r.Phase = RotationPhaseBundling
+		if err := c.writeRotation(r); err != nil {
+			return nil, err
+		}
+		fallthrough
+
+	case RotationPhaseBundling:
+		if err := c.ensureStagedCA(r); err != nil {
+			return nil, err
+		}
+		r.Phase = RotationPhaseBundled
+		return r, c.writeRotation(r)
+
+	case RotationPhaseBundled:
+		r.PrimarySerial = c.buildSerial().Text(10)
+		r.Phase = RotationPhasePromoting
+		if err := c.writeRotation(r); err != nil {
+			return nil, err
+		}
+		fallthrough
+
+	case RotationPhasePromoting:
+		if err := c.promoteStagedCA(r); err != nil {
+			return nil, err
+		}
+		if err := c.reissueCertificates(); err != nil {
+			return nil, err
+		}
+		r.Phase = RotationPhaseIssued
+		return r, c.writeRotation(r)
+
+	case RotationPhaseIssued:
+		if err := c.removeSerials(CertificateId_CA, r.OldSerials); err != nil {
+			return nil, err
+		}
+		if err := c.removeUntrustedCertificates(); err != nil {
+			return nil, err
+		}
+		if err := c.loadCA(); err != nil {
+			return nil, err
+		}
+		return nil, c.deleteRotation(CertificateId_CA)
+
+	default:
+		return nil, fmt.Errorf("unexpected rotation phase %q for CA", r.Phase)
+	}
+}
+
+// ensureStagedCA creates the new CA under the staged serial, unless it was already created
+func (c *VFSCAStore) ensureStagedCA(r *KeyRotation) error {
+	serial, err := parseSerial(r.StagedSerial)
+	if err != nil {
+		return err
+	}
+
+	exists, err := c.keypairExists(CertificateId_CA, serial)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	glog.Infof("Creating new CA")
+	err = c.createCAKeypair(serial)
+	if err != nil {
+		return err
+	}
+	return c.loadCA()
+}
+
+// promoteStagedCA stores the staged CA under its primary serial (so it becomes the primary), and removes the staged copy
+func (c *VFSCAStore) promoteStagedCA(r *KeyRotation) error {
+	stagedSerial, err := parseSerial(r.StagedSerial)
+	if err != nil {
+		return err
+	}
+	primarySerial, err := parseSerial(r.PrimarySerial)
+	if err != nil {
+		return err
+	}
+
+	stagedExists, err := c.keypairExists(CertificateId_CA, stagedSerial)
+	if err != nil {
+		return err
+	}
+	if stagedExists {
+		cert, err := c.loadOneCertificate(c.buildCertificatePath(CertificateId_CA, stagedSerial))
+		if err != nil {
+			return err
+		}
+		key, err := c.loadOnePrivateKey(c.buildPrivateKeyPath(CertificateId_CA, stagedSerial))
+		if err != nil {
+			return err
+		}
+
+		glog.Infof("Promoting new CA")
+		if err := c.storePrivateKey(key, c.buildPrivateKeyPath(CertificateId_CA, primarySerial)); err != nil {
+			return err
+		}
+		if err := c.storeCertificate(cert, c.buildCertificatePath(CertificateId_CA, primarySerial)); err != nil {
+			return err
+		}
+		if err := c.removeSerials(CertificateId_CA, []string{r.StagedSerial}); err != nil {
+			return err
+		}
+	} else {
+		// We were interrupted after promoting
+		primaryExists, err := c.keypairExists(CertificateId_CA, primarySerial)
+		if err != nil {
+			return err
+		}
+		if !primaryExists {
+			return fmt.Errorf("new CA was not found under serial %s or %s", r.StagedSerial, r.PrimarySerial)
+		}
+	}
+
+	if err := c.loadCA(); err != nil {
+		return err
+	}
+	if c.caCertificates.primary != r.PrimarySerial || c.caPrivateKeys == nil || c.caPrivateKeys.primary != r.PrimarySerial {
+		return fmt.Errorf("new CA did not become the primary CA")
+	}
+	return nil
+}
+
+// reissueCertificates re-issues every certificate that is not signed by the primary CA
+func (c *VFSCAStore) reissueCertificates() error {
+	ids, err := c.listKeypairIDs()
+	if err != nil {
+		return err
+	}
+
+	ca := c.caCertificates.Primary()
+	for _, id := range ids {
+		if id == CertificateId_CA {
+			continue
+		}
+
+		certs, err := c.loadCertificates(c.buildCertificatePoolPath(id))
+		if err != nil {
+			return err
+		}
+		if certs == nil || certs.Primary() == nil {
+			continue
+		}
+		primary := certs.Primary()
+		if primary.Certificate.CheckSignatureFrom(ca.Certificate) == nil {
+			glog.V(2).Infof("Certificate %q is already signed by the new CA", id)
+			continue
+		}
+
+		if err := c.reissueKeypair(id, primary); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeUntrustedCertificates removes the keypairs that are not signed by any CA we still trust
+func (c *VFSCAStore) removeUntrustedCertificates() error {
+	if err := c.loadCA(); err != nil {
+		return err
+	}
+
+	ids, err := c.listKeypairIDs()
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if id == CertificateId_CA {
+			continue
+		}
+
+		certs, err := c.loadCertificates(c.buildCertificatePoolPath(id))
+		if err != nil {
+			return err
+		}
+		if certs == nil {
+			continue
+		}
+
+		var untrusted []string
+		for serial, cert := range certs.certificates {
+			if !c.isSignedByCA(cert) {
+				untrusted = append(untrusted, serial)
+			}
+		}
+		if len(untrusted) == len(certs.certificates) {
+			// Don't remove every keypair; this is likely a certificate that was imported
+			glog.Warningf("Keypair %q is not signed by the CA; not removing it", id)
+			continue
+		}
+		if err := c.removeSerials(id, untrusted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *VFSCAStore) isSignedByCA(cert *Certificate) bool {
+	if c.caCertificates == nil {
+		return false
+	}
+	for _, ca := range c.caCertificates.certificates {
+		if cert.Certificate.CheckSignatureFrom(ca.Certificate) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// reissueKeypair creates a new keypair with the same subject, names and usage as cert, signed by the primary CA
+func (c *VFSCAStore) reissueKeypair(id string, cert *Certificate) error {
+	glog.Infof("Re-issuing keypair %q", id)
+
+	template := &x509.Certificate{
+		Subject:               cert.Certificate.Subject,
+		DNSNames:              cert.Certificate.DNSNames,
+		EmailAddresses:        cert.Certificate.EmailAddresses,
+		IPAddresses:           cert.Certificate.IPAddresses,
+		KeyUsage:              cert.Certificate.KeyUsage,
+		ExtKeyUsage:           cert.Certificate.ExtKeyUsage,
+		BasicConstraintsValid: true,
+	}
+
+	_, _, err := c.CreateKeypair(id, template)
+	return err
+}
+
+// removeSerials removes the certificates and private keys with the specified serials
+func (c *VFSCAStore) removeSerials(id string, serials []string) error {
+	for _, s := range serials {
+		serial, err := parseSerial(s)
+		if err != nil {
+			return err
+		}
+		for _, p := range []vfs.Path{c.buildCertificatePath(id, serial), c.buildPrivateKeyPath(id, serial)} {
+			err := p.Remove()
+			if err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("error removing %s: %v", p, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *VFSCAStore) keypairExists(id string, serial *big.Int) (bool, error) {
+	for _, p := range []vfs.Path{c.buildCertificatePath(id, serial), c.buildPrivateKeyPath(id, serial)} {
+		_, err := p.ReadFile()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return false, nil
+			}
+			return false, fmt.Errorf("error reading %s: %v", p, err)
+		}
+	}
+	return true, nil
+}
+
+// listKeypairIDs returns the ids of all the certificates in the store.
+// We use ReadTree because not all vfs implementations return directories from ReadDir.
+func (c *VFSCAStore) listKeypairIDs() ([]string, error) {
+	issuedDir := c.basedir.Join("issued")
+	files, err := issuedDir.ReadTree()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading directory %q: %v", issuedDir, err)
+	}
+
+	found := make(map[string]bool)
+	for _, f := range files {
+		relativePath, err := vfs.RelativePath(issuedDir, f)
+		if err != nil {
+			return nil, err
+		}
+		tokens := strings.Split(relativePath, "/")
+		if len(tokens) == 2 {
+			found[tokens[0]] = true
+		}
+	}
+
+	var ids []string
+	for id := range found {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// loadCA reloads the CA certificates and private keys from the store
+func (c *VFSCAStore) loadCA() error {
+	caCertificates, err := c.loadCertificates(c.buildCertificatePoolPath(CertificateId_CA))
+	if err != nil {
+		return err
+	}
+	caPrivateKeys, err := c.loadPrivateKeys(c.buildPrivateKeyPoolPath(CertificateId_CA))
+	if err != nil {
+		return err
+	}
+	c.caCertificates = caCertificates
+	c.caPrivateKeys = caPrivateKeys
+	return nil
+}
+
+func (p *certificates) serials() []string {
+	var serials []string
+	for k := range p.certificates {
+		serials = append(serials, k)
+	}
+	sort.Strings(serials)
+	return serials
+}
+
+func parseSerial(s string) (*big.Int, error) {
+	serial, ok := big.NewInt(0).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid serial %q", s)
+	}
+	return serial, nil
+}
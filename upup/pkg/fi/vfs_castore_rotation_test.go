@@ -0,0 +1,180 @@
+package fi
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+	"testing"
+)
+
+func TestRotateKeypair_CA(t *testing.T) {
+	base := vfs.NewMemFSPath(vfs.NewMemFSContext(), "pki")
+	store, err := NewVFSCAStore(base, false, nil)
+	if err != nil {
+		t.Fatalf("error building CA store: %v", err)
+	}
+
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "kubecfg"},
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+	if _, _, err := store.CreateKeypair("kubecfg", template); err != nil {
+		t.Fatalf("error creating keypair: %v", err)
+	}
+	oldCA, err := store.Cert(CertificateId_CA)
+	if err != nil {
+		t.Fatalf("error reading CA: %v", err)
+	}
+
+	// Phase 1: the new CA is bundled, but the old CA remains the primary
+	rotation, err := store.RotateKeypair(CertificateId_CA)
+	if err != nil {
+		t.Fatalf("error rotating CA: %v", err)
+	}
+	if rotation == nil || rotation.Phase != RotationPhaseBundled {
+		t.Fatalf("unexpected rotation state: %v", rotation)
+	}
+	pool, err := store.CertificatePool(CertificateId_CA)
+	if err != nil {
+		t.Fatalf("error reading CA pool: %v", err)
+	}
+	if len(pool.Secondary) != 1 || !pool.Primary.Certificate.Equal(oldCA.Certificate) {
+		t.Fatalf("expected old CA to be primary, and new CA to be bundled")
+	}
+	newCA := pool.Secondary[0]
+
+	// The rotation is persisted, so a new store resumes it
+	store, err = NewVFSCAStore(base, false, nil)
+	if err != nil {
+		t.Fatalf("error building CA store: %v", err)
+	}
+
+	// Phase 2: the new CA is promoted, and the certificates are re-issued
+	rotation, err = store.RotateKeypair(CertificateId_CA)
+	if err != nil {
+		t.Fatalf("error rotating CA: %v", err)
+	}
+	if rotation == nil || rotation.Phase != RotationPhaseIssued {
+		t.Fatalf("unexpected rotation state: %v", rotation)
+	}
+	ca, err := store.Cert(CertificateId_CA)
+	if err != nil {
+		t.Fatalf("error reading CA: %v", err)
+	}
+	if !ca.Certificate.Equal(newCA.Certificate) {
+		t.Fatalf("expected new CA to be primary")
+	}
+	kubecfg, err := store.Cert("kubecfg")
+	if err != nil {
+		t.Fatalf("error reading certificate: %v", err)
+	}
+	if err := kubecfg.Certificate.CheckSignatureFrom(newCA.Certificate); err != nil {
+		t.Fatalf("expected certificate to be signed by new CA: %v", err)
+	}
+	if kubecfg.Subject.CommonName != "kubecfg" {
+		t.Fatalf("unexpected subject for re-issued certificate: %v", kubecfg.Subject)
+	}
+
+	// Phase 3: the old CA and the certificates it signed are removed
+	rotation, err = store.RotateKeypair(CertificateId_CA)
+	if err != nil {
+		t.Fatalf("error rotating CA: %v", err)
+	}
+	if rotation != nil {
+		t.Fatalf("expected rotation to be complete, was %v", rotation)
+	}
+	pool, err = store.CertificatePool(CertificateId_CA)
+	if err != nil {
+		t.Fatalf("error reading CA pool: %v", err)
+	}
+	if len(pool.Secondary) != 0 || !pool.Primary.Certificate.Equal(newCA.Certificate) {
+		t.Fatalf("expected only the new CA to remain")
+	}
+	kubecfgPool, err := store.CertificatePool("kubecfg")
+	if err != nil {
+		t.Fatalf("error reading certificate pool: %v", err)
+	}
+	if len(kubecfgPool.Secondary) != 0 {
+		t.Fatalf("expected old kubecfg certificate to be removed")
+	}
+	if found, err := store.FindRotation(CertificateId_CA); err != nil || found != nil {
+		t.Fatalf("expected rotation state to be removed: %v %v", found, err)
+	}
+}
+
+func TestRotateKeypair_ResumesIssuing(t *testing.T) {
+	base := vfs.NewMemFSPath(vfs.NewMemFSContext(), "pki")
+	store, err := NewVFSCAStore(base, false, nil)
+	if err != nil {
+		t.Fatalf("error building CA store: %v", err)
+	}
+
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "kubecfg"},
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+	if _, _, err := store.CreateKeypair("kubecfg", template); err != nil {
+		t.Fatalf("error creating keypair: %v", err)
+	}
+	oldCert, err := store.Cert("kubecfg")
+	if err != nil {
+		t.Fatalf("error reading certificate: %v", err)
+	}
+	oldSerial := oldCert.Certificate.SerialNumber.Text(10)
+
+	// Simulate a rotation that was interrupted after recording the old serials, but before issuing
+	if err := store.writeRotation(&KeyRotation{ID: "kubecfg", Phase: RotationPhaseIssuing, OldSerials: []string{oldSerial}}); err != nil {
+		t.Fatalf("error writing rotation state: %v", err)
+	}
+
+	rotation, err := store.RotateKeypair("kubecfg")
+	if err != nil {
+		t.Fatalf("error rotating keypair: %v", err)
+	}
+	if rotation == nil || rotation.Phase != RotationPhaseIssued {
+		t.Fatalf("unexpected rotation state: %v", rotation)
+	}
+	pool, err := store.CertificatePool("kubecfg")
+	if err != nil {
+		t.Fatalf("error reading certificate pool: %v", err)
+	}
+	if len(pool.Secondary) != 1 || pool.Primary.Certificate.Equal(oldCert.Certificate) {
+		t.Fatalf("expected a new primary certificate, with the old certificate still trusted")
+	}
+
+	// Resuming after the keypair was issued must not issue another
+	rotation.Phase = RotationPhaseIssuing
+	if err := store.writeRotation(rotation); err != nil {
+		t.Fatalf("error writing rotation state: %v", err)
+	}
+	if _, err := store.RotateKeypair("kubecfg"); err != nil {
+		t.Fatalf("error rotating keypair: %v", err)
+	}
+	pool, err = store.CertificatePool("kubecfg")
+	if err != nil {
+		t.Fatalf("error reading certificate pool: %v", err)
+	}
+	if len(pool.Secondary) != 1 {
+		t.Fatalf("expected 2 certificates, found %d", len(pool.Secondary)+1)
+	}
+
+	// Completing the rotation removes only the old keypair
+	rotation, err = store.RotateKeypair("kubecfg")
+	if err != nil {
+		t.Fatalf("error rotating keypair: %v", err)
+	}
+	if rotation != nil {
+		t.Fatalf("expected rotation to be complete, was %v", rotation)
+	}
+	pool, err = store.CertificatePool("kubecfg")
+	if err != nil {
+		t.Fatalf("error reading certificate pool: %v", err)
+	}
+	if len(pool.Secondary) != 0 || pool.Primary.Certificate.Equal(oldCert.Certificate) {
+		t.Fatalf("expected only the new certificate to remain")
+	}
+}
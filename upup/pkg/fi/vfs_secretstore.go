@@ -9,14 +9,17 @@ import (
 )
 
 type VFSSecretStore struct {
-	basedir vfs.Path
+	basedir    vfs.Path
+	encryption *Encryption
 }
 
 var _ SecretStore = &VFSSecretStore{}
 
-func NewVFSSecretStore(basedir vfs.Path) (SecretStore, error) {
+// NewVFSSecretStore builds a VFSSecretStore; secrets are encrypted with encryption, which may be nil for plaintext
+func NewVFSSecretStore(basedir vfs.Path, encryption *Encryption) (SecretStore, error) {
 	c := &VFSSecretStore{
-		basedir: basedir,
+		basedir:    basedir,
+		encryption: encryption,
 	}
 	//err := os.MkdirAll(path.Join(basedir), 0700)
 	//if err != nil {
@@ -115,6 +118,10 @@ func (c *VFSSecretStore) loadSecret(p vfs.Path) (*Secret, error) {
 			return nil, nil
 		}
 	}
+	data, err = c.encryption.Decrypt(p, data)
+	if err != nil {
+		return nil, err
+	}
 	s := &Secret{}
 	err = json.Unmarshal(data, s)
 	if err != nil {
@@ -129,5 +136,9 @@ func (c *VFSSecretStore) createSecret(s *Secret, p vfs.Path) error {
 	if err != nil {
 		return fmt.Errorf("error serializing secret: %v", err)
 	}
+	data, err = c.encryption.Encrypt(data)
+	if err != nil {
+		return fmt.Errorf("error encrypting secret: %v", err)
+	}
 	return p.CreateFile(data)
 }
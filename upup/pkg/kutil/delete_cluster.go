@@ -80,6 +80,8 @@ func (c *DeleteCluster) ListResources() (map[string]*ResourceTracker, error) {
 	listFunctions := []listFn{
 		ListSubnets, ListRouteTables, ListSecurityGroups,
 		ListInstances, ListDhcpOptions, ListInternetGateways, ListVPCs, ListVolumes,
+		// NAT gateways (private topology)
+		ListNatGateways,
 		// ELBs
 		ListELBs,
 		// ASG
@@ -661,6 +663,117 @@ func DescribeSubnets(cloud fi.Cloud) ([]*ec2.Subnet, error) {
 	return response.Subnets, nil
 }
 
+func DeleteNatGateway(cloud fi.Cloud, r *ResourceTracker) error {
+	c := cloud.(*awsup.AWSCloud)
+
+	id := r.ID
+
+	glog.V(2).Infof("Deleting EC2 NatGateway %q", id)
+	request := &ec2.DeleteNatGatewayInput{
+		NatGatewayId: &id,
+	}
+	_, err := c.EC2.DeleteNatGateway(request)
+	if err != nil {
+		if IsDependencyViolation(err) {
+			return err
+		}
+		return fmt.Errorf("error deleting NatGateway %q: %v", id, err)
+	}
+	return nil
+}
+
+// ListNatGateways finds the NAT gateways in the cluster subnets, along with their elastic IPs.
+// NAT gateways can't be tagged, but the elastic IP is recorded in a tag on the subnet.
+func ListNatGateways(cloud fi.Cloud, clusterName string) ([]*ResourceTracker, error) {
+	c := cloud.(*awsup.AWSCloud)
+
+	subnets, err := DescribeSubnets(cloud)
+	if err != nil {
+		return nil, err
+	}
+	if len(subnets) == 0 {
+		return nil, nil
+	}
+
+	var trackers []*ResourceTracker
+
+	var subnetIDs []string
+	elasticIPs := make(map[string]bool)
+	for _, subnet := range subnets {
+		subnetIDs = append(subnetIDs, aws.StringValue(subnet.SubnetId))
+
+		for _, tag := range subnet.Tags {
+			if aws.StringValue(tag.Key) == "kubernetes.io/nat-gateway-ip" && aws.StringValue(tag.Value) != "" {
+				elasticIPs[aws.StringValue(tag.Value)] = true
+			}
+		}
+	}
+
+	glog.V(2).Infof("Listing EC2 NatGateways")
+	request := &ec2.DescribeNatGatewaysInput{
+		Filter: []*ec2.Filter{
+			awsup.NewEC2Filter("subnet-id", subnetIDs...),
+			awsup.NewEC2Filter("state", ec2.NatGatewayStatePending, ec2.NatGatewayStateAvailable),
+		},
+	}
+	response, err := c.EC2.DescribeNatGateways(request)
+	if err != nil {
+		return nil, fmt.Errorf("error listing NatGateways: %v", err)
+	}
+
+	for _, ngw := range response.NatGateways {
+		id := aws.StringValue(ngw.NatGatewayId)
+		tracker := &ResourceTracker{
+			Name:    id,
+			ID:      id,
+			Type:    "nat-gateway",
+			deleter: DeleteNatGateway,
+		}
+
+		var blocks []string
+		blocks = append(blocks, "subnet:"+aws.StringValue(ngw.SubnetId))
+		blocks = append(blocks, "vpc:"+aws.StringValue(ngw.VpcId))
+		for _, address := range ngw.NatGatewayAddresses {
+			if address.AllocationId != nil {
+				blocks = append(blocks, "elastic-ip:"+aws.StringValue(address.AllocationId))
+			}
+			if address.PublicIp != nil {
+				elasticIPs[aws.StringValue(address.PublicIp)] = true
+			}
+		}
+		tracker.blocks = blocks
+
+		trackers = append(trackers, tracker)
+	}
+
+	if len(elasticIPs) != 0 {
+		glog.V(2).Infof("Querying EC2 Elastic IPs")
+		request := &ec2.DescribeAddressesInput{}
+		response, err := c.EC2.DescribeAddresses(request)
+		if err != nil {
+			return nil, fmt.Errorf("error describing addresses: %v", err)
+		}
+
+		for _, address := range response.Addresses {
+			ip := aws.StringValue(address.PublicIp)
+			if !elasticIPs[ip] {
+				continue
+			}
+
+			tracker := &ResourceTracker{
+				Name:    ip,
+				ID:      aws.StringValue(address.AllocationId),
+				Type:    "elastic-ip",
+				deleter: DeleteElasticIP,
+			}
+
+			trackers = append(trackers, tracker)
+		}
+	}
+
+	return trackers, nil
+}
+
 func DeleteRouteTable(cloud fi.Cloud, r *ResourceTracker) error {
 	c := cloud.(*awsup.AWSCloud)
 
@@ -0,0 +1,75 @@
+package kutil
+
+import (
+	"github.com/golang/glog"
+	"k8s.io/kops/upup/pkg/fi"
+)
+
+// orphanListFunctions are the listers for the object types that are managed as a whole by a task,
+// keyed by the resource type.  Objects of these types that are owned by the cluster but are not
+// claimed by a task have been removed from the model.
+// Instances and volumes are deliberately not included: they are created by the cloud on our behalf
+// (or hold state) and so are never matched one-to-one to a task.
+// ELBs and security groups are not included either: the kubernetes cloudprovider creates them (with the same
+// KubernetesCluster tag) for LoadBalancer services, so the tag does not prove that kops owns them.
+var orphanListFunctions = map[string]listFn{
+	"autoscaling-group": ListAutoScalingGroups,
+	"subnet":            ListSubnets,
+}
+
+// FindOrphanedResources returns the objects that are tagged as owned by the cluster, but which no longer have a task.
+// If any task of a type could not be identified, we skip that type entirely, rather than risk deleting an object that is in use.
+func FindOrphanedResources(cloud fi.Cloud, clusterName string, taskMap map[string]fi.Task) (map[string]*ResourceTracker, error) {
+	claimed, unidentified := fi.ClaimedCloudResources(taskMap)
+
+	orphans := make(map[string]*ResourceTracker)
+	for resourceType, fn := range orphanListFunctions {
+		if unidentified[resourceType] {
+			glog.V(2).Infof("Not checking for orphaned %s objects, as not all tasks have been identified", resourceType)
+			continue
+		}
+
+		trackers, err := fn(cloud, clusterName)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range trackers {
+			key := t.Type + ":" + t.ID
+			if claimed[key] {
+				continue
+			}
+			glog.V(2).Infof("Found orphaned resource %s (%s)", key, t.Name)
+			orphans[key] = t
+		}
+	}
+	return orphans, nil
+}
+
+// resourceDeletion adapts a ResourceTracker to a fi.Deletion, so that orphans can be reported by the dry-run target
+type resourceDeletion struct {
+	cloud   fi.Cloud
+	tracker *ResourceTracker
+}
+
+var _ fi.Deletion = &resourceDeletion{}
+
+func (d *resourceDeletion) TaskName() string {
+	return d.tracker.Type
+}
+
+func (d *resourceDeletion) Item() string {
+	return d.tracker.ID
+}
+
+func (d *resourceDeletion) Delete(target fi.Target) error {
+	return d.tracker.deleter(d.cloud, d.tracker)
+}
+
+// AsDeletions wraps the resources as fi.Deletion objects
+func AsDeletions(cloud fi.Cloud, resources map[string]*ResourceTracker) []fi.Deletion {
+	var deletions []fi.Deletion
+	for _, t := range resources {
+		deletions = append(deletions, &resourceDeletion{cloud: cloud, tracker: t})
+	}
+	return deletions
+}
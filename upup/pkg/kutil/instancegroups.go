@@ -0,0 +1,64 @@
+package kutil
+
+import (
+	"fmt"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/upup/pkg/fi/cloudup/gce"
+)
+
+// CloudInstanceGroup is a group of instances managed by the cloud: an autoscaling group on AWS,
+// or a (zonal) managed instance group on GCE
+type CloudInstanceGroup struct {
+	// Name is the name of the group in the cloud
+	Name string
+	// Zone is the zone of the group, for clouds where groups are zonal (GCE)
+	Zone string
+	// InstanceGroupName is the name of the kops instance group
+	InstanceGroupName string
+	IsMaster          bool
+	// IsBastion is true for bastion groups, whose instances don't register as nodes
+	IsBastion bool
+
+	MinSize    int
+	MaxSize    int
+	TargetSize int
+
+	Instances []*CloudInstance
+}
+
+// CloudInstance is an instance in a CloudInstanceGroup
+type CloudInstance struct {
+	// ID is the instance id on AWS, or the instance name on GCE; it is the last component of the node's providerID
+	ID string
+	// Running is true once the instance is in service
+	Running bool
+	// UpToDate is true if the instance was created from the group's current launch configuration / instance template
+	UpToDate bool
+}
+
+// InstanceGroupController finds and resizes the cloud instance groups of a cluster
+type InstanceGroupController interface {
+	// ListGroups returns the cloud instance groups of the cluster
+	ListGroups() ([]*CloudInstanceGroup, error)
+	// GetGroup returns the current state of the group
+	GetGroup(g *CloudInstanceGroup) (*CloudInstanceGroup, error)
+	// SetSizeLimits sets the minimum & maximum size of the group; this does nothing where groups don't have limits (GCE)
+	SetSizeLimits(g *CloudInstanceGroup, minSize int, maxSize int) error
+	// SetTargetSize sets the number of instances the group should be running
+	SetTargetSize(g *CloudInstanceGroup, size int) error
+	// DeleteInstances removes the instances from the group, decrementing its target size so they aren't replaced, and deletes them
+	DeleteInstances(g *CloudInstanceGroup, instances []*CloudInstance) error
+}
+
+// NewInstanceGroupController builds the InstanceGroupController for the cloud
+func NewInstanceGroupController(cloud fi.Cloud, clusterName string) (InstanceGroupController, error) {
+	switch cloud.ProviderID() {
+	case fi.CloudProviderAWS:
+		return &awsInstanceGroups{cloud: cloud.(*awsup.AWSCloud), clusterName: clusterName}, nil
+	case fi.CloudProviderGCE:
+		return &gceInstanceGroups{cloud: cloud.(*gce.GCECloud), clusterName: clusterName}, nil
+	default:
+		return nil, fmt.Errorf("instance groups are not supported on cloud %q", cloud.ProviderID())
+	}
+}
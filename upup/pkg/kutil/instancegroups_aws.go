@@ -0,0 +1,129 @@
+package kutil
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/golang/glog"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"strings"
+)
+
+// awsInstanceGroups is the InstanceGroupController for AWS, where instance groups are autoscaling groups
+type awsInstanceGroups struct {
+	cloud       *awsup.AWSCloud
+	clusterName string
+}
+
+var _ InstanceGroupController = &awsInstanceGroups{}
+
+func (c *awsInstanceGroups) ListGroups() ([]*CloudInstanceGroup, error) {
+	asgs, err := findAutoscalingGroups(c.cloud, c.cloud.BuildTags(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []*CloudInstanceGroup
+	for _, asg := range asgs {
+		groups = append(groups, c.buildGroup(asg))
+	}
+	return groups, nil
+}
+
+func (c *awsInstanceGroups) GetGroup(g *CloudInstanceGroup) (*CloudInstanceGroup, error) {
+	response, err := c.cloud.Autoscaling.DescribeAutoScalingGroups(&autoscaling.DescribeAutoScalingGroupsInput{
+		AutoScalingGroupNames: []*string{aws.String(g.Name)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error describing autoscaling group %q: %v", g.Name, err)
+	}
+	if len(response.AutoScalingGroups) != 1 {
+		return nil, fmt.Errorf("found %d autoscaling groups with name %q", len(response.AutoScalingGroups), g.Name)
+	}
+	return c.buildGroup(response.AutoScalingGroups[0]), nil
+}
+
+func (c *awsInstanceGroups) buildGroup(asg *autoscaling.Group) *CloudInstanceGroup {
+	g := &CloudInstanceGroup{
+		Name:       aws.StringValue(asg.AutoScalingGroupName),
+		MinSize:    int(aws.Int64Value(asg.MinSize)),
+		MaxSize:    int(aws.Int64Value(asg.MaxSize)),
+		TargetSize: int(aws.Int64Value(asg.DesiredCapacity)),
+	}
+
+	for _, tag := range asg.Tags {
+		if aws.StringValue(tag.Key) == "k8s.io/role/master" {
+			g.IsMaster = true
+		}
+		if aws.StringValue(tag.Key) == "k8s.io/role/bastion" {
+			g.IsBastion = true
+		}
+	}
+
+	// The autoscaling group is named <name>.masters.<cluster> for masters, and <name>.<cluster> otherwise
+	g.InstanceGroupName = strings.TrimSuffix(g.Name, "."+c.clusterName)
+	if g.IsMaster {
+		g.InstanceGroupName = strings.TrimSuffix(g.InstanceGroupName, ".masters")
+	}
+
+	for _, i := range asg.Instances {
+		g.Instances = append(g.Instances, &CloudInstance{
+			ID:       aws.StringValue(i.InstanceId),
+			Running:  aws.StringValue(i.LifecycleState) == autoscaling.LifecycleStateInService,
+			UpToDate: aws.StringValue(i.LaunchConfigurationName) == aws.StringValue(asg.LaunchConfigurationName),
+		})
+	}
+
+	return g
+}
+
+func (c *awsInstanceGroups) SetSizeLimits(g *CloudInstanceGroup, minSize int, maxSize int) error {
+	glog.V(2).Infof("Setting size of autoscaling group %q to %d-%d", g.Name, minSize, maxSize)
+	_, err := c.cloud.Autoscaling.UpdateAutoScalingGroup(&autoscaling.UpdateAutoScalingGroupInput{
+		AutoScalingGroupName: aws.String(g.Name),
+		MinSize:              aws.Int64(int64(minSize)),
+		MaxSize:              aws.Int64(int64(maxSize)),
+	})
+	if err != nil {
+		return fmt.Errorf("error setting size of autoscaling group %q: %v", g.Name, err)
+	}
+	return nil
+}
+
+func (c *awsInstanceGroups) SetTargetSize(g *CloudInstanceGroup, size int) error {
+	glog.V(2).Infof("Setting DesiredCapacity of autoscaling group %q to %d", g.Name, size)
+	_, err := c.cloud.Autoscaling.SetDesiredCapacity(&autoscaling.SetDesiredCapacityInput{
+		AutoScalingGroupName: aws.String(g.Name),
+		DesiredCapacity:      aws.Int64(int64(size)),
+	})
+	if err != nil {
+		return fmt.Errorf("error setting desired capacity of autoscaling group %q: %v", g.Name, err)
+	}
+	return nil
+}
+
+// DeleteInstances detaches the instances from the autoscaling group, decrementing the desired capacity, and terminates them
+func (c *awsInstanceGroups) DeleteInstances(g *CloudInstanceGroup, instances []*CloudInstance) error {
+	var instanceIDs []*string
+	for _, i := range instances {
+		instanceIDs = append(instanceIDs, aws.String(i.ID))
+	}
+
+	_, err := c.cloud.Autoscaling.DetachInstances(&autoscaling.DetachInstancesInput{
+		AutoScalingGroupName:           aws.String(g.Name),
+		InstanceIds:                    instanceIDs,
+		ShouldDecrementDesiredCapacity: aws.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("error detaching instances from autoscaling group %q: %v", g.Name, err)
+	}
+
+	_, err = c.cloud.EC2.TerminateInstances(&ec2.TerminateInstancesInput{
+		InstanceIds: instanceIDs,
+	})
+	if err != nil {
+		return fmt.Errorf("error deleting instances in autoscaling group %q: %v", g.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package kutil
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"google.golang.org/api/compute/v1"
+	"k8s.io/kops/upup/pkg/fi/cloudup/gce"
+	"strings"
+)
+
+// gceInstanceGroups is the InstanceGroupController for GCE, where instance groups are managed instance groups,
+// one per zone, named by gce.InstanceGroupManagerName
+type gceInstanceGroups struct {
+	cloud       *gce.GCECloud
+	clusterName string
+}
+
+var _ InstanceGroupController = &gceInstanceGroups{}
+
+func (c *gceInstanceGroups) ListGroups() ([]*CloudInstanceGroup, error) {
+	suffix := "-" + gce.SafeClusterName(c.clusterName)
+
+	var groups []*CloudInstanceGroup
+	pageToken := ""
+	for {
+		call := c.cloud.Compute.InstanceGroupManagers.AggregatedList(c.cloud.Project)
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+		response, err := call.Do()
+		if err != nil {
+			return nil, fmt.Errorf("error listing managed instance groups: %v", err)
+		}
+
+		for _, scoped := range response.Items {
+			for _, mig := range scoped.InstanceGroupManagers {
+				if !strings.HasSuffix(mig.Name, suffix) {
+					continue
+				}
+				g, err := c.buildGroup(mig)
+				if err != nil {
+					return nil, err
+				}
+				groups = append(groups, g)
+			}
+		}
+
+		pageToken = response.NextPageToken
+		if pageToken == "" {
+			break
+		}
+	}
+	return groups, nil
+}
+
+func (c *gceInstanceGroups) GetGroup(g *CloudInstanceGroup) (*CloudInstanceGroup, error) {
+	mig, err := c.cloud.Compute.InstanceGroupManagers.Get(c.cloud.Project, g.Zone, g.Name).Do()
+	if err != nil {
+		return nil, fmt.Errorf("error getting managed instance group %q: %v", g.Name, err)
+	}
+	return c.buildGroup(mig)
+}
+
+func (c *gceInstanceGroups) buildGroup(mig *compute.InstanceGroupManager) (*CloudInstanceGroup, error) {
+	zone := gce.LastComponent(mig.Zone)
+	templateName := gce.LastComponent(mig.InstanceTemplate)
+
+	g := &CloudInstanceGroup{
+		Name:       mig.Name,
+		Zone:       zone,
+		MinSize:    int(mig.TargetSize),
+		MaxSize:    int(mig.TargetSize),
+		TargetSize: int(mig.TargetSize),
+	}
+
+	name := strings.TrimSuffix(mig.Name, "-"+gce.SafeClusterName(c.clusterName))
+	g.InstanceGroupName = strings.TrimSuffix(name, "-"+zone)
+
+	template, err := c.cloud.Compute.InstanceTemplates.Get(c.cloud.Project, templateName).Do()
+	if err != nil {
+		return nil, fmt.Errorf("error getting instance template %q: %v", templateName, err)
+	}
+	if template.Properties != nil && template.Properties.Tags != nil {
+		for _, tag := range template.Properties.Tags.Items {
+			if tag == gce.MasterTag(c.clusterName) {
+				g.IsMaster = true
+			}
+		}
+	}
+
+	instances, err := c.cloud.Compute.InstanceGroupManagers.ListManagedInstances(c.cloud.Project, zone, mig.Name).Do()
+	if err != nil {
+		return nil, fmt.Errorf("error listing instances in managed instance group %q: %v", mig.Name, err)
+	}
+	for _, mi := range instances.ManagedInstances {
+		instanceName := gce.LastComponent(mi.Instance)
+		i := &CloudInstance{
+			ID:      instanceName,
+			Running: mi.InstanceStatus == "RUNNING" && mi.CurrentAction == "NONE",
+		}
+
+		// The MIG records the template an instance was created from in its metadata
+		if mi.InstanceStatus != "" {
+			instance, err := c.cloud.Compute.Instances.Get(c.cloud.Project, zone, instanceName).Do()
+			if err != nil {
+				if !gce.IsNotFound(err) {
+					return nil, fmt.Errorf("error getting instance %q: %v", instanceName, err)
+				}
+			} else if instance.Metadata != nil {
+				for _, item := range instance.Metadata.Items {
+					if item.Key == "instance-template" && item.Value != nil {
+						i.UpToDate = gce.LastComponent(*item.Value) == templateName
+					}
+				}
+			}
+		}
+
+		g.Instances = append(g.Instances, i)
+	}
+
+	return g, nil
+}
+
+// SetSizeLimits does nothing; managed instance groups only have a target size
+func (c *gceInstanceGroups) SetSizeLimits(g *CloudInstanceGroup, minSize int, maxSize int) error {
+	return nil
+}
+
+func (c *gceInstanceGroups) SetTargetSize(g *CloudInstanceGroup, size int) error {
+	glog.V(2).Infof("Resizing managed instance group %q to %d", g.Name, size)
+	op, err := c.cloud.Compute.InstanceGroupManagers.Resize(c.cloud.Project, g.Zone, g.Name, int64(size)).Do()
+	if err != nil {
+		return fmt.Errorf("error resizing managed instance group %q: %v", g.Name, err)
+	}
+	return gce.WaitForOp(c.cloud.Compute, c.cloud.Project, op)
+}
+
+// DeleteInstances deletes the instances through the managed instance group, which reduces its target size
+func (c *gceInstanceGroups) DeleteInstances(g *CloudInstanceGroup, instances []*CloudInstance) error {
+	request := &compute.InstanceGroupManagersDeleteInstancesRequest{}
+	for _, i := range instances {
+		url := &gce.GoogleCloudURL{
+			Project: c.cloud.Project,
+			Zone:    g.Zone,
+			Type:    "instances",
+			Name:    i.ID,
+		}
+		request.Instances = append(request.Instances, url.BuildURL())
+	}
+
+	op, err := c.cloud.Compute.InstanceGroupManagers.DeleteInstances(c.cloud.Project, g.Zone, g.Name, request).Do()
+	if err != nil {
+		return fmt.Errorf("error deleting instances in managed instance group %q: %v", g.Name, err)
+	}
+	return gce.WaitForOp(c.cloud.Compute, c.cloud.Project, op)
+}
@@ -0,0 +1,134 @@
+package kutil
+
+import (
+	"google.golang.org/api/compute/v1"
+	"k8s.io/kops/cloudmock/gce/mockcompute"
+	"k8s.io/kops/upup/pkg/fi/cloudup/gce"
+	"testing"
+	"time"
+)
+
+const testGCEProject = "testproject"
+const testGCEZone = "us-test1-a"
+
+// createManagedInstanceGroup creates a managed instance group with instances from an old instance template,
+// and then points it at a new one
+func createManagedInstanceGroup(t *testing.T, client *compute.Service, name string, size int64, tags []string) {
+	var templateLinks []string
+	for _, templateName := range []string{name + "-old", name + "-new"} {
+		op, err := client.InstanceTemplates.Insert(testGCEProject, &compute.InstanceTemplate{
+			Name: templateName,
+			Properties: &compute.InstanceProperties{
+				Tags: &compute.Tags{Items: tags},
+			},
+		}).Do()
+		if err != nil {
+			t.Fatalf("error creating instance template: %v", err)
+		}
+		templateLinks = append(templateLinks, op.TargetLink)
+	}
+
+	_, err := client.InstanceGroupManagers.Insert(testGCEProject, testGCEZone, &compute.InstanceGroupManager{
+		Name:             name,
+		BaseInstanceName: name,
+		InstanceTemplate: templateLinks[0],
+		TargetSize:       size,
+	}).Do()
+	if err != nil {
+		t.Fatalf("error creating managed instance group: %v", err)
+	}
+
+	_, err = client.InstanceGroupManagers.SetInstanceTemplate(testGCEProject, testGCEZone, name, &compute.InstanceGroupManagersSetInstanceTemplateRequest{
+		InstanceTemplate: templateLinks[1],
+	}).Do()
+	if err != nil {
+		t.Fatalf("error setting instance template: %v", err)
+	}
+}
+
+// TestRollingUpdate_GCE replaces the instances of managed instance groups whose instance template has changed,
+// where an instance is up to date if the instance-template in its metadata is the group's current template
+func TestRollingUpdate_GCE(t *testing.T) {
+	defer func(d time.Duration) { rollingUpdatePollInterval = d }(rollingUpdatePollInterval)
+	rollingUpdatePollInterval = time.Millisecond
+
+	mock := mockcompute.NewMockCompute()
+	client, err := mock.Start()
+	if err != nil {
+		t.Fatalf("error starting mock compute API: %v", err)
+	}
+	defer mock.Close()
+
+	cloud := &gce.GCECloud{
+		Compute: client,
+		Region:  "us-test1",
+		Project: testGCEProject,
+	}
+	masterName := "master-us-test1-a-us-test1-a-minimal-example-com"
+	nodesName := "nodes-us-test1-a-minimal-example-com"
+	createManagedInstanceGroup(t, client, masterName, 1, []string{gce.MasterTag("minimal.example.com")})
+	createManagedInstanceGroup(t, client, nodesName, 3, nil)
+
+	c := &RollingUpdateCluster{
+		ClusterName:  "minimal.example.com",
+		Cloud:        cloud,
+		CloudOnly:    true,
+		BatchSize:    2,
+		Surge:        1,
+		ReadyTimeout: time.Second,
+	}
+
+	nodesets, err := c.ListNodesets()
+	if err != nil {
+		t.Fatalf("error listing nodesets: %v", err)
+	}
+	if len(nodesets) != 2 {
+		t.Fatalf("expected 2 nodesets, got %d", len(nodesets))
+	}
+	if !nodesets[masterName].IsMaster || nodesets[masterName].InstanceGroupName != "master-us-test1-a" {
+		t.Fatalf("expected master nodeset to be recognized, got %v", nodesets[masterName])
+	}
+	nodes := nodesets[nodesName]
+	if nodes.InstanceGroupName != "nodes" || len(nodes.NeedUpdate) != 3 || len(nodes.Ready) != 0 {
+		t.Fatalf("expected all 3 nodes to need update, got %v", nodes)
+	}
+	before := make(map[string]bool)
+	for _, i := range nodes.NeedUpdate {
+		before[i.ID] = true
+	}
+
+	// Surging and shrinking the groups calls SetSizeLimits, which does nothing on GCE
+	err = c.RollingUpdateNodesets(nodesets)
+	if err != nil {
+		t.Fatalf("error in rolling update: %v", err)
+	}
+
+	nodesets, err = c.ListNodesets()
+	if err != nil {
+		t.Fatalf("error listing nodesets: %v", err)
+	}
+	for name, nodeset := range nodesets {
+		if len(nodeset.NeedUpdate) != 0 {
+			t.Fatalf("nodeset %q still has %d instances needing update", name, len(nodeset.NeedUpdate))
+		}
+	}
+	nodes = nodesets[nodesName]
+	if len(nodes.Ready) != 3 {
+		t.Fatalf("expected 3 updated nodes, got %d", len(nodes.Ready))
+	}
+	for _, i := range nodes.Ready {
+		if before[i.ID] {
+			t.Fatalf("expected instance %q to be replaced", i.ID)
+		}
+	}
+
+	for name, size := range map[string]int64{masterName: 1, nodesName: 3} {
+		mig, err := client.InstanceGroupManagers.Get(testGCEProject, testGCEZone, name).Do()
+		if err != nil {
+			t.Fatalf("error getting managed instance group: %v", err)
+		}
+		if mig.TargetSize != size {
+			t.Fatalf("expected target size of %q to be restored to %d, was %d", name, size, mig.TargetSize)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package kutil
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"github.com/golang/glog"
 	"os"
@@ -10,8 +12,12 @@ import (
 
 type Kubectl struct {
 	KubectlPath string
+	// Context is the kubeconfig context to use for commands against the cluster; if empty the current context is used
+	Context string
 }
 
+var _ KubernetesClient = &Kubectl{}
+
 func (k *Kubectl) GetCurrentContext() (string, error) {
 	s, err := k.execKubectl("config", "current-context")
 	if err != nil {
@@ -41,6 +47,165 @@ func (k *Kubectl) GetConfig(minify bool, output string) (string, error) {
 	return s, nil
 }
 
+// GetNodes lists the nodes registered in the cluster
+func (k *Kubectl) GetNodes() ([]*KubernetesNode, error) {
+	s, err := k.execKubectl(k.clusterArgs("get", "nodes", "--output", "json")...)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodeList struct {
+		Items []struct {
+			Metadata struct {
+				Name   string            `json:"name"`
+				Labels map[string]string `json:"labels"`
+			} `json:"metadata"`
+			Spec struct {
+				ProviderID    string `json:"providerID"`
+				Unschedulable bool   `json:"unschedulable"`
+			} `json:"spec"`
+			Status struct {
+				Conditions []struct {
+					Type   string `json:"type"`
+					Status string `json:"status"`
+				} `json:"conditions"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	err = json.Unmarshal([]byte(s), &nodeList)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing kubectl output: %v", err)
+	}
+
+	var nodes []*KubernetesNode
+	for _, item := range nodeList.Items {
+		node := &KubernetesNode{
+			Name:          item.Metadata.Name,
+			Labels:        item.Metadata.Labels,
+			ProviderID:    item.Spec.ProviderID,
+			Unschedulable: item.Spec.Unschedulable,
+		}
+		for _, condition := range item.Status.Conditions {
+			if condition.Type == "Ready" {
+				node.Ready = condition.Status == "True"
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// GetComponentStatuses returns the health of the components checked by the apiserver (the scheduler, the controller-manager & etcd)
+func (k *Kubectl) GetComponentStatuses() ([]*ComponentStatus, error) {
+	s, err := k.execKubectl(k.clusterArgs("get", "componentstatuses", "--output", "json")...)
+	if err != nil {
+		return nil, err
+	}
+
+	var statusList struct {
+		Items []struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Conditions []struct {
+				Type   string `json:"type"`
+				Status string `json:"status"`
+			} `json:"conditions"`
+		} `json:"items"`
+	}
+	err = json.Unmarshal([]byte(s), &statusList)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing kubectl output: %v", err)
+	}
+
+	var statuses []*ComponentStatus
+	for _, item := range statusList.Items {
+		status := &ComponentStatus{Name: item.Metadata.Name}
+		for _, condition := range item.Conditions {
+			if condition.Type == "Healthy" {
+				status.Healthy = condition.Status == "True"
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// GetPods lists the pods in the namespace
+func (k *Kubectl) GetPods(namespace string) ([]*KubernetesPod, error) {
+	s, err := k.execKubectl(k.clusterArgs("get", "pods", "--namespace", namespace, "--output", "json")...)
+	if err != nil {
+		return nil, err
+	}
+
+	var podList struct {
+		Items []struct {
+			Metadata struct {
+				Name   string            `json:"name"`
+				Labels map[string]string `json:"labels"`
+			} `json:"metadata"`
+			Spec struct {
+				NodeName string `json:"nodeName"`
+			} `json:"spec"`
+			Status struct {
+				Phase      string `json:"phase"`
+				Conditions []struct {
+					Type   string `json:"type"`
+					Status string `json:"status"`
+				} `json:"conditions"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	err = json.Unmarshal([]byte(s), &podList)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing kubectl output: %v", err)
+	}
+
+	var pods []*KubernetesPod
+	for _, item := range podList.Items {
+		pod := &KubernetesPod{
+			Name:     item.Metadata.Name,
+			Labels:   item.Metadata.Labels,
+			NodeName: item.Spec.NodeName,
+			Phase:    item.Status.Phase,
+		}
+		for _, condition := range item.Status.Conditions {
+			if condition.Type == "Ready" {
+				pod.Ready = condition.Status == "True"
+			}
+		}
+		pods = append(pods, pod)
+	}
+	return pods, nil
+}
+
+// CordonNode marks the node as unschedulable
+func (k *Kubectl) CordonNode(name string) error {
+	_, err := k.execKubectl(k.clusterArgs("cordon", name)...)
+	return err
+}
+
+// DrainNode evicts the pods from the node (except those managed by a DaemonSet), cordoning it first.
+// Pods using emptyDir volumes are evicted too, losing that data, as the instance is about to be replaced anyway.
+func (k *Kubectl) DrainNode(name string) error {
+	_, err := k.execKubectl(k.clusterArgs("drain", name, "--force", "--ignore-daemonsets", "--delete-local-data")...)
+	return err
+}
+
+// DeleteNode removes the node from the cluster
+func (k *Kubectl) DeleteNode(name string) error {
+	_, err := k.execKubectl(k.clusterArgs("delete", "node", name)...)
+	return err
+}
+
+// clusterArgs adds the context (if set) to the args for a kubectl command against the cluster
+func (k *Kubectl) clusterArgs(args ...string) []string {
+	if k.Context != "" {
+		args = append(args, "--context", k.Context)
+	}
+	return args
+}
+
 func (k *Kubectl) execKubectl(args ...string) (string, error) {
 	kubectlPath := k.KubectlPath
 	if kubectlPath == "" {
@@ -50,14 +215,22 @@ func (k *Kubectl) execKubectl(args ...string) (string, error) {
 	env := os.Environ()
 	cmd.Env = env
 
+	// We only parse stdout; kubectl writes warnings to stderr, which would corrupt the JSON
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
 	human := cmd.Path + strings.Join(cmd.Args, " ")
 	glog.V(2).Infof("Running command: %s", human)
-	output, err := cmd.CombinedOutput()
+	err := cmd.Run()
 	if err != nil {
-		glog.Info("error running %s:", human)
-		glog.Info(string(output))
-		return string(output), fmt.Errorf("error running kubectl")
+		glog.Infof("error running %s:", human)
+		glog.Info(stdout.String())
+		return stdout.String(), fmt.Errorf("error running kubectl: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if stderr.Len() != 0 {
+		glog.V(2).Infof("kubectl stderr: %s", stderr.String())
 	}
 
-	return string(output), err
+	return stdout.String(), nil
 }
@@ -2,12 +2,10 @@ package kutil
 
 import (
 	"fmt"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/autoscaling"
-	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/golang/glog"
 	"k8s.io/kops/upup/pkg/fi"
-	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -15,40 +13,172 @@ import (
 // RollingUpdateCluster restarts cluster nodes
 type RollingUpdateCluster struct {
 	ClusterName string
-	Region      string
 	Cloud       fi.Cloud
+
+	// K8sClient is used to drain nodes, and to check that their replacements are ready
+	K8sClient KubernetesClient
+
+	// Interval is the time to wait after each batch of instances has been replaced
+	Interval time.Duration
+	// BatchSize is the maximum number of instances in a node group that are replaced at once (masters are always replaced one at a time)
+	BatchSize int
+	// Surge is the number of extra instances a node group may launch during the update, so replacements can start before nodes are drained
+	Surge int
+	// ReadyTimeout is how long to wait for replacement instances to become ready nodes, and for the cluster to validate
+	ReadyTimeout time.Duration
+
+	// InstanceGroups limits the update to the named instance groups; if empty all instance groups are updated
+	InstanceGroups []string
+	// Force replaces all instances, even those already running the current launch configuration
+	Force bool
+	// CloudOnly replaces instances without draining nodes or checking the cluster; K8sClient is not used
+	CloudOnly bool
+
+	// instanceGroups is built from Cloud when first needed
+	instanceGroups InstanceGroupController
 }
 
-func (c *RollingUpdateCluster) ListNodesets() (map[string]*Nodeset, error) {
-	cloud := c.Cloud.(*awsup.AWSCloud)
+// KubernetesNode is the state of a node, as needed for a rolling update
+type KubernetesNode struct {
+	Name          string
+	Labels        map[string]string
+	ProviderID    string
+	Unschedulable bool
+	Ready         bool
+}
 
-	nodesets := make(map[string]*Nodeset)
+// KubernetesClient is the subset of kubernetes operations needed to replace nodes
+type KubernetesClient interface {
+	GetNodes() ([]*KubernetesNode, error)
+	CordonNode(name string) error
+	DrainNode(name string) error
+	DeleteNode(name string) error
+	GetComponentStatuses() ([]*ComponentStatus, error)
+	GetPods(namespace string) ([]*KubernetesPod, error)
+}
+
+// KubernetesPod is the state of a pod, as needed to validate a cluster
+type KubernetesPod struct {
+	Name     string
+	Labels   map[string]string
+	NodeName string
+	Phase    string
+	Ready    bool
+}
+
+// ComponentStatus is the health of a component as reported by the apiserver (e.g. etcd-0)
+type ComponentStatus struct {
+	Name    string
+	Healthy bool
+}
 
-	tags := cloud.BuildTags(nil)
+// rollingUpdatePollInterval is how often we check the state of the cloud and the cluster while waiting
+var rollingUpdatePollInterval = 10 * time.Second
 
-	asgs, err := findAutoscalingGroups(cloud, tags)
+const defaultReadyTimeout = 15 * time.Minute
+
+// controller returns the InstanceGroupController for the cloud
+func (c *RollingUpdateCluster) controller() (InstanceGroupController, error) {
+	if c.instanceGroups == nil {
+		controller, err := NewInstanceGroupController(c.Cloud, c.ClusterName)
+		if err != nil {
+			return nil, err
+		}
+		c.instanceGroups = controller
+	}
+	return c.instanceGroups, nil
+}
+
+func (c *RollingUpdateCluster) ListNodesets() (map[string]*Nodeset, error) {
+	controller, err := c.controller()
 	if err != nil {
 		return nil, err
 	}
 
-	for _, asg := range asgs {
-		nodeset := buildNodeset(asg)
+	groups, err := controller.ListGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	nodesets := make(map[string]*Nodeset)
+	for _, g := range groups {
+		nodeset := buildNodeset(g)
+
+		if len(c.InstanceGroups) != 0 && !containsString(c.InstanceGroups, nodeset.InstanceGroupName) {
+			continue
+		}
+
+		if c.Force {
+			nodeset.NeedUpdate = append(nodeset.NeedUpdate, nodeset.Ready...)
+			nodeset.Ready = nil
+			if len(nodeset.NeedUpdate) != 0 {
+				nodeset.Status = "NeedsUpdate"
+			}
+		}
+
 		nodesets[nodeset.Name] = nodeset
 	}
 
+	for _, name := range c.InstanceGroups {
+		found := false
+		for _, nodeset := range nodesets {
+			if nodeset.InstanceGroupName == name {
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("instance group %q not found", name)
+		}
+	}
+
 	return nodesets, nil
 }
 
+func containsString(l []string, s string) bool {
+	for _, v := range l {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// RollingUpdateNodesets replaces the out of date instances in the nodesets.
+// Masters are updated first, one at a time; the node groups are then updated in parallel.
 func (c *RollingUpdateCluster) RollingUpdateNodesets(nodesets map[string]*Nodeset) error {
 	if len(nodesets) == 0 {
 		return nil
 	}
 
+	// Build the controller before we start any goroutines
+	_, err := c.controller()
+	if err != nil {
+		return err
+	}
+
+	var masters []*Nodeset
+	nodes := make(map[string]*Nodeset)
+	for k, nodeset := range nodesets {
+		if nodeset.IsMaster {
+			masters = append(masters, nodeset)
+		} else {
+			nodes[k] = nodeset
+		}
+	}
+	sort.Sort(ByName(masters))
+
+	for _, nodeset := range masters {
+		err := nodeset.RollingUpdate(c)
+		if err != nil {
+			return err
+		}
+	}
+
 	var wg sync.WaitGroup
 	var resultsMutex sync.Mutex
 	results := make(map[string]error)
 
-	for k, nodeset := range nodesets {
+	for k, nodeset := range nodes {
 		wg.Add(1)
 		go func(k string, nodeset *Nodeset) {
 			resultsMutex.Lock()
@@ -56,7 +186,7 @@ func (c *RollingUpdateCluster) RollingUpdateNodesets(nodesets map[string]*Nodese
 			resultsMutex.Unlock()
 
 			defer wg.Done()
-			err := nodeset.RollingUpdate(c.Cloud)
+			err := nodeset.RollingUpdate(c)
 
 			resultsMutex.Lock()
 			results[k] = err
@@ -76,21 +206,39 @@ func (c *RollingUpdateCluster) RollingUpdateNodesets(nodesets map[string]*Nodese
 }
 
 type Nodeset struct {
-	Name       string
-	Status     string
-	Ready      []*autoscaling.Instance
-	NeedUpdate []*autoscaling.Instance
+	Name              string
+	InstanceGroupName string
+	IsMaster          bool
+	IsBastion         bool
+	MinSize           int
+	MaxSize           int
+	Status            string
+	Ready             []*CloudInstance
+	NeedUpdate        []*CloudInstance
+
+	group *CloudInstanceGroup
 }
 
-func buildNodeset(g *autoscaling.Group) *Nodeset {
+// ByName sorts nodesets by name
+type ByName []*Nodeset
+
+func (a ByName) Len() int           { return len(a) }
+func (a ByName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a ByName) Less(i, j int) bool { return a[i].Name < a[j].Name }
+
+func buildNodeset(g *CloudInstanceGroup) *Nodeset {
 	n := &Nodeset{
-		Name: aws.StringValue(g.AutoScalingGroupName),
+		Name:              g.Name,
+		InstanceGroupName: g.InstanceGroupName,
+		IsMaster:          g.IsMaster,
+		IsBastion:         g.IsBastion,
+		MinSize:           g.MinSize,
+		MaxSize:           g.MaxSize,
+		group:             g,
 	}
 
-	findLaunchConfigurationName := aws.StringValue(g.LaunchConfigurationName)
-
 	for _, i := range g.Instances {
-		if findLaunchConfigurationName == aws.StringValue(i.LaunchConfigurationName) {
+		if i.UpToDate {
 			n.Ready = append(n.Ready, i)
 		} else {
 			n.NeedUpdate = append(n.NeedUpdate, i)
@@ -106,35 +254,387 @@ func buildNodeset(g *autoscaling.Group) *Nodeset {
 	return n
 }
 
-func (n *Nodeset) RollingUpdate(cloud fi.Cloud) error {
-	c := cloud.(*awsup.AWSCloud)
+// RollingUpdate replaces the instances that need update, in batches.
+// For each batch, the nodes are drained and the instances are removed from the group (without replacement) and deleted;
+// we then wait for the replacements to register as ready nodes, and for the cluster to validate, before continuing.
+func (n *Nodeset) RollingUpdate(u *RollingUpdateCluster) error {
+	c, err := u.controller()
+	if err != nil {
+		return err
+	}
 
-	for _, i := range n.NeedUpdate {
-		glog.Infof("Stopping instance %q in nodeset %q", *i.InstanceId, n.Name)
+	if len(n.NeedUpdate) == 0 {
+		return nil
+	}
 
-		// TODO: Evacuate through k8s first?
+	batchSize := u.BatchSize
+	surge := u.Surge
+	if n.IsMaster {
+		// Masters own their etcd volumes, so a replacement can't start until the old master is gone
+		batchSize = 1
+		surge = 0
+	}
+	if batchSize < 1 {
+		batchSize = 1
+	}
 
-		// TODO: Temporarily increase size of ASG?
+	g, err := c.GetGroup(n.group)
+	if err != nil {
+		return err
+	}
+	desired := g.TargetSize
+	minSize := g.MinSize
+	maxSize := g.MaxSize
+
+	// Removing a batch may take the group below its MinSize, and surging above its MaxSize
+	updateMinSize := desired - batchSize
+	if updateMinSize < 0 {
+		updateMinSize = 0
+	}
+	if updateMinSize > minSize {
+		updateMinSize = minSize
+	}
+	updateMaxSize := maxSize + surge
+	if updateMinSize != minSize || updateMaxSize != maxSize {
+		glog.V(2).Infof("Temporarily setting size of nodeset %q to %d-%d", n.Name, updateMinSize, updateMaxSize)
+		err := c.SetSizeLimits(g, updateMinSize, updateMaxSize)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			err := c.SetSizeLimits(g, minSize, maxSize)
+			if err != nil {
+				glog.Warningf("error restoring size of nodeset %q: %v", n.Name, err)
+			}
+		}()
+	}
 
-		// TODO: Remove from ASG first so status is immediately updated?
+	// If we stop part way through a batch, the target size may still be surged, or lowered by the deleted instances
+	defer func() {
+		err := c.SetTargetSize(g, desired)
+		if err != nil {
+			glog.Warningf("error restoring target size of nodeset %q: %v", n.Name, err)
+		}
+	}()
 
-		// TODO: Batch termination, like a rolling-update
+	updated := len(n.Ready)
+	for start := 0; start < len(n.NeedUpdate); start += batchSize {
+		end := start + batchSize
+		if end > len(n.NeedUpdate) {
+			end = len(n.NeedUpdate)
+		}
+		batch := n.NeedUpdate[start:end]
+
+		surged := len(batch)
+		if surged > surge {
+			surged = surge
+		}
+		if surged > 0 {
+			// Start the replacements first
+			err := c.SetTargetSize(g, desired+surged)
+			if err != nil {
+				return err
+			}
+			updated += surged
+			err = n.waitForUpdatedInstances(u, c, g, updated)
+			if err != nil {
+				return err
+			}
+		}
 
-		request := &ec2.TerminateInstancesInput{
-			InstanceIds: []*string{i.InstanceId},
+		err := n.replaceInstances(u, c, g, batch)
+		if err != nil {
+			return err
+		}
+
+		if len(batch) > surged {
+			// Replacements were not started before draining (removing the instances decremented the target size)
+			err := c.SetTargetSize(g, desired)
+			if err != nil {
+				return err
+			}
+			updated += len(batch) - surged
+		}
+
+		err = n.waitForUpdatedInstances(u, c, g, updated)
+		if err != nil {
+			return err
 		}
-		_, err := c.EC2.TerminateInstances(request)
+
+		err = u.validateCluster()
 		if err != nil {
-			return fmt.Errorf("error deleting instance %q: %v", i.InstanceId, err)
+			return err
 		}
 
-		// TODO: Wait for node to appear back in k8s
-		time.Sleep(time.Minute)
+		if n.IsMaster {
+			err = u.validateEtcd()
+			if err != nil {
+				return err
+			}
+		}
+
+		if u.Interval > 0 {
+			glog.V(2).Infof("Waiting for %s before continuing", u.Interval)
+			time.Sleep(u.Interval)
+		}
 	}
 
 	return nil
 }
 
+// replaceInstances drains the nodes for the instances, and removes the instances from the group
+// (decrementing its target size, so the group won't replace them until we are ready) and deletes them
+func (n *Nodeset) replaceInstances(u *RollingUpdateCluster, c InstanceGroupController, g *CloudInstanceGroup, instances []*CloudInstance) error {
+	drain := !u.CloudOnly && !n.IsBastion
+
+	var nodes []*KubernetesNode
+	if drain {
+		var err error
+		nodes, err = u.getNodes()
+		if err != nil {
+			return err
+		}
+	}
+
+	var instanceIDs []string
+	var nodeNames []string
+	for _, i := range instances {
+		instanceIDs = append(instanceIDs, i.ID)
+
+		if !drain {
+			continue
+		}
+
+		node := findNodeForInstance(nodes, i.ID)
+		if node == nil {
+			glog.Warningf("No node found for instance %q in nodeset %q; will not drain", i.ID, n.Name)
+			continue
+		}
+		nodeNames = append(nodeNames, node.Name)
+
+		glog.Infof("Draining node %q (instance %q) in nodeset %q", node.Name, i.ID, n.Name)
+		err := u.K8sClient.CordonNode(node.Name)
+		if err != nil {
+			return fmt.Errorf("error cordoning node %q: %v", node.Name, err)
+		}
+		err = u.K8sClient.DrainNode(node.Name)
+		if err != nil {
+			return fmt.Errorf("error draining node %q: %v", node.Name, err)
+		}
+	}
+
+	glog.Infof("Stopping instances %v in nodeset %q", fi.DebugAsJsonString(instanceIDs), n.Name)
+	err := c.DeleteInstances(g, instances)
+	if err != nil {
+		return err
+	}
+
+	// Remove the nodes now, rather than waiting for the node controller to notice they are gone.
+	// This is only a shortcut (the instances are already gone), so we carry on if it fails.
+	for _, name := range nodeNames {
+		err := u.K8sClient.DeleteNode(name)
+		if err != nil {
+			glog.Warningf("error deleting node %q (the node controller will remove it): %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// getNodes lists the nodes, retrying until the ReadyTimeout if the apiserver can't be reached
+func (c *RollingUpdateCluster) getNodes() ([]*KubernetesNode, error) {
+	timeout := c.ReadyTimeout
+	if timeout == 0 {
+		timeout = defaultReadyTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		nodes, err := c.K8sClient.GetNodes()
+		if err == nil {
+			return nodes, nil
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("error listing nodes: %v", err)
+		}
+		glog.V(2).Infof("error listing nodes: %v", err)
+		time.Sleep(rollingUpdatePollInterval)
+	}
+}
+
+// waitForUpdatedInstances waits until the group has (at least) count running instances that are up to date
+// (and are not themselves being replaced), each registered as a ready node unless CloudOnly is set
+func (n *Nodeset) waitForUpdatedInstances(u *RollingUpdateCluster, c InstanceGroupController, g *CloudInstanceGroup, count int) error {
+	timeout := u.ReadyTimeout
+	if timeout == 0 {
+		timeout = defaultReadyTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		// Bastions never register as nodes, so we only wait for their instances to be running
+		var nodes []*KubernetesNode
+		var nodesErr error
+		checkNodes := !u.CloudOnly && !n.IsBastion
+		if checkNodes {
+			nodes, nodesErr = u.K8sClient.GetNodes()
+			if nodesErr != nil {
+				// The apiserver may not be reachable until the new master is up; we count no nodes as ready
+				glog.V(2).Infof("error listing nodes: %v", nodesErr)
+			}
+		}
+
+		ready, err := n.countReadyUpdatedInstances(c, g, checkNodes, nodes)
+		if err != nil {
+			return err
+		}
+		if ready >= count {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			if nodesErr != nil {
+				return fmt.Errorf("timeout waiting for nodeset %q: error listing nodes: %v", n.Name, nodesErr)
+			}
+			return fmt.Errorf("timeout waiting for nodeset %q: %d of %d updated instances are ready nodes", n.Name, ready, count)
+		}
+		glog.V(2).Infof("Waiting for nodeset %q: %d of %d updated instances are ready nodes", n.Name, ready, count)
+		time.Sleep(rollingUpdatePollInterval)
+	}
+}
+
+// countReadyUpdatedInstances counts the running, up to date instances in the group; if checkNodes is set, they
+// must also be ready nodes
+func (n *Nodeset) countReadyUpdatedInstances(c InstanceGroupController, g *CloudInstanceGroup, checkNodes bool, nodes []*KubernetesNode) (int, error) {
+	current, err := c.GetGroup(g)
+	if err != nil {
+		return 0, err
+	}
+
+	replacing := make(map[string]bool)
+	for _, i := range n.NeedUpdate {
+		replacing[i.ID] = true
+	}
+
+	ready := 0
+	for _, i := range current.Instances {
+		if !i.UpToDate || !i.Running || replacing[i.ID] {
+			continue
+		}
+		if !checkNodes {
+			ready++
+			continue
+		}
+		node := findNodeForInstance(nodes, i.ID)
+		if node != nil && node.Ready {
+			ready++
+		}
+	}
+	return ready, nil
+}
+
+// findNodeForInstance finds the node for the instance, matching the end of the providerID
+// (aws:///<zone>/<instanceid> or gce://<project>/<zone>/<instancename>)
+func findNodeForInstance(nodes []*KubernetesNode, instanceID string) *KubernetesNode {
+	for _, node := range nodes {
+		if node.ProviderID == "" {
+			continue
+		}
+		tokens := strings.Split(node.ProviderID, "/")
+		if tokens[len(tokens)-1] == instanceID {
+			return node
+		}
+	}
+	return nil
+}
+
+// validateCluster waits until every node in the cluster is ready, so we don't continue rolling a broken cluster
+func (c *RollingUpdateCluster) validateCluster() error {
+	if c.CloudOnly {
+		return nil
+	}
+
+	timeout := c.ReadyTimeout
+	if timeout == 0 {
+		timeout = defaultReadyTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		nodes, err := c.K8sClient.GetNodes()
+		if err != nil {
+			// The apiserver may not be reachable until the new master is up
+			glog.V(2).Infof("error listing nodes: %v", err)
+		}
+
+		var notReady []string
+		for _, node := range nodes {
+			if !node.Ready {
+				notReady = append(notReady, node.Name)
+			}
+		}
+		if err == nil && len(notReady) == 0 {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			if err != nil {
+				return fmt.Errorf("cluster did not validate: error listing nodes: %v", err)
+			}
+			return fmt.Errorf("cluster did not validate: nodes not ready: %v", notReady)
+		}
+		glog.V(2).Infof("Waiting for nodes to be ready: %v", notReady)
+		time.Sleep(rollingUpdatePollInterval)
+	}
+}
+
+// validateEtcd waits until the apiserver reports every etcd member as healthy, so we don't replace another master
+// (and lose quorum) before the replacement has rejoined the etcd cluster
+func (c *RollingUpdateCluster) validateEtcd() error {
+	if c.CloudOnly {
+		return nil
+	}
+
+	timeout := c.ReadyTimeout
+	if timeout == 0 {
+		timeout = defaultReadyTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		statuses, err := c.K8sClient.GetComponentStatuses()
+		if err != nil {
+			// The apiserver may not be reachable until the new master is up
+			glog.V(2).Infof("error getting component statuses: %v", err)
+		}
+
+		etcdCount := 0
+		var unhealthy []string
+		for _, status := range statuses {
+			if !strings.HasPrefix(status.Name, "etcd-") {
+				continue
+			}
+			etcdCount++
+			if !status.Healthy {
+				unhealthy = append(unhealthy, status.Name)
+			}
+		}
+		if err == nil && etcdCount != 0 && len(unhealthy) == 0 {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			if err != nil {
+				return fmt.Errorf("error checking etcd health: %v", err)
+			}
+			return fmt.Errorf("etcd is not healthy: unhealthy members %v (of %d)", unhealthy, etcdCount)
+		}
+		glog.V(2).Infof("Waiting for etcd to be healthy: unhealthy members %v", unhealthy)
+		time.Sleep(rollingUpdatePollInterval)
+	}
+}
+
 func (n *Nodeset) String() string {
 	return "nodeset:" + n.Name
 }
@@ -0,0 +1,380 @@
+package kutil
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"k8s.io/kops/cloudmock/aws/mockautoscaling"
+	"k8s.io/kops/cloudmock/aws/mockec2"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeKubernetesClient reports a ready node for every instance in the autoscaling groups, except for bastions
+type fakeKubernetesClient struct {
+	cloud *awsup.AWSCloud
+
+	mutex   sync.Mutex
+	drained []string
+	deleted map[string]bool
+
+	// unreachableAfterDelete is the number of GetNodes calls that fail after each DeleteNode,
+	// as happens while the apiserver on a replaced master is coming up
+	unreachableAfterDelete int
+	unreachable            int
+	// failDeleteNode makes DeleteNode fail
+	failDeleteNode bool
+
+	pods []*KubernetesPod
+}
+
+var _ KubernetesClient = &fakeKubernetesClient{}
+
+func (f *fakeKubernetesClient) GetNodes() ([]*KubernetesNode, error) {
+	response, err := f.cloud.Autoscaling.DescribeAutoScalingGroups(&autoscaling.DescribeAutoScalingGroupsInput{})
+	if err != nil {
+		return nil, err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.unreachable > 0 {
+		f.unreachable--
+		return nil, fmt.Errorf("apiserver is not reachable")
+	}
+
+	var nodes []*KubernetesNode
+	for _, g := range response.AutoScalingGroups {
+		isBastion := false
+		for _, tag := range g.Tags {
+			if aws.StringValue(tag.Key) == "k8s.io/role/bastion" {
+				isBastion = true
+			}
+		}
+		if isBastion {
+			continue
+		}
+		for _, i := range g.Instances {
+			name := "node-" + aws.StringValue(i.InstanceId)
+			if f.deleted[name] {
+				continue
+			}
+			nodes = append(nodes, &KubernetesNode{
+				Name:       name,
+				ProviderID: "aws:///us-test-1a/" + aws.StringValue(i.InstanceId),
+				Ready:      true,
+			})
+		}
+	}
+	return nodes, nil
+}
+
+func (f *fakeKubernetesClient) GetComponentStatuses() ([]*ComponentStatus, error) {
+	return []*ComponentStatus{
+		{Name: "controller-manager", Healthy: true},
+		{Name: "etcd-0", Healthy: true},
+	}, nil
+}
+
+func (f *fakeKubernetesClient) GetPods(namespace string) ([]*KubernetesPod, error) {
+	return f.pods, nil
+}
+
+func (f *fakeKubernetesClient) CordonNode(name string) error {
+	return nil
+}
+
+func (f *fakeKubernetesClient) DrainNode(name string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.drained = append(f.drained, name)
+	return nil
+}
+
+func (f *fakeKubernetesClient) DeleteNode(name string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.unreachable = f.unreachableAfterDelete
+	if f.failDeleteNode {
+		return fmt.Errorf("error deleting node %q", name)
+	}
+	f.deleted[name] = true
+	return nil
+}
+
+// createGroup creates an autoscaling group with instances from an old launch configuration, and then points it at a new one
+func createGroup(t *testing.T, cloud *awsup.AWSCloud, name string, size int64, tags map[string]string) {
+	for _, lc := range []string{name + "-old", name + "-new"} {
+		_, err := cloud.Autoscaling.CreateLaunchConfiguration(&autoscaling.CreateLaunchConfigurationInput{
+			LaunchConfigurationName: aws.String(lc),
+		})
+		if err != nil {
+			t.Fatalf("error creating launch configuration: %v", err)
+		}
+	}
+
+	request := &autoscaling.CreateAutoScalingGroupInput{
+		AutoScalingGroupName:    aws.String(name),
+		LaunchConfigurationName: aws.String(name + "-old"),
+		MinSize:                 aws.Int64(size),
+		MaxSize:                 aws.Int64(size),
+		DesiredCapacity:         aws.Int64(size),
+	}
+	for k, v := range tags {
+		request.Tags = append(request.Tags, &autoscaling.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	_, err := cloud.Autoscaling.CreateAutoScalingGroup(request)
+	if err != nil {
+		t.Fatalf("error creating autoscaling group: %v", err)
+	}
+
+	_, err = cloud.Autoscaling.UpdateAutoScalingGroup(&autoscaling.UpdateAutoScalingGroupInput{
+		AutoScalingGroupName:    aws.String(name),
+		LaunchConfigurationName: aws.String(name + "-new"),
+	})
+	if err != nil {
+		t.Fatalf("error updating autoscaling group: %v", err)
+	}
+}
+
+// buildMockCloud builds a mock cloud with a master group and a node group, whose instances all need update
+func buildMockCloud(t *testing.T) *awsup.AWSCloud {
+	mockAutoscaling := mockautoscaling.NewMockAutoscaling()
+	mockAutoscaling.RealizeGroups = true
+	cloud := &awsup.AWSCloud{
+		EC2:         mockec2.NewMockEC2("us-test-1", "us-test-1a"),
+		Autoscaling: mockAutoscaling,
+		Region:      "us-test-1",
+	}
+	cloud = cloud.WithTags(map[string]string{"KubernetesCluster": "minimal.example.com"})
+
+	createGroup(t, cloud, "master-us-test-1a.masters.minimal.example.com", 1, map[string]string{"KubernetesCluster": "minimal.example.com", "k8s.io/role/master": "1"})
+	createGroup(t, cloud, "nodes.minimal.example.com", 3, map[string]string{"KubernetesCluster": "minimal.example.com", "k8s.io/role": "node"})
+	return cloud
+}
+
+func TestRollingUpdate_DrainsAndReplacesInstances(t *testing.T) {
+	defer func(d time.Duration) { rollingUpdatePollInterval = d }(rollingUpdatePollInterval)
+	rollingUpdatePollInterval = time.Millisecond
+
+	cloud := buildMockCloud(t)
+
+	k8s := &fakeKubernetesClient{cloud: cloud, deleted: make(map[string]bool)}
+	c := &RollingUpdateCluster{
+		ClusterName:  "minimal.example.com",
+		Cloud:        cloud,
+		K8sClient:    k8s,
+		BatchSize:    2,
+		Surge:        1,
+		ReadyTimeout: time.Second,
+	}
+
+	nodesets, err := c.ListNodesets()
+	if err != nil {
+		t.Fatalf("error listing nodesets: %v", err)
+	}
+	if len(nodesets) != 2 {
+		t.Fatalf("expected 2 nodesets, got %d", len(nodesets))
+	}
+	if !nodesets["master-us-test-1a.masters.minimal.example.com"].IsMaster {
+		t.Fatalf("expected master nodeset to be recognized")
+	}
+
+	err = c.RollingUpdateNodesets(nodesets)
+	if err != nil {
+		t.Fatalf("error in rolling update: %v", err)
+	}
+
+	nodesets, err = c.ListNodesets()
+	if err != nil {
+		t.Fatalf("error listing nodesets: %v", err)
+	}
+	for name, nodeset := range nodesets {
+		if len(nodeset.NeedUpdate) != 0 {
+			t.Fatalf("nodeset %q still has %d instances needing update", name, len(nodeset.NeedUpdate))
+		}
+	}
+	if len(nodesets["nodes.minimal.example.com"].Ready) != 3 {
+		t.Fatalf("expected 3 updated nodes, got %d", len(nodesets["nodes.minimal.example.com"].Ready))
+	}
+	if len(k8s.drained) != 4 {
+		t.Fatalf("expected 4 nodes to be drained, got %v", k8s.drained)
+	}
+
+	response, err := cloud.Autoscaling.DescribeAutoScalingGroups(&autoscaling.DescribeAutoScalingGroupsInput{})
+	if err != nil {
+		t.Fatalf("error describing autoscaling groups: %v", err)
+	}
+	for _, g := range response.AutoScalingGroups {
+		if aws.Int64Value(g.MinSize) != aws.Int64Value(g.MaxSize) || aws.Int64Value(g.DesiredCapacity) != aws.Int64Value(g.MaxSize) {
+			t.Fatalf("expected size of %q to be restored, was min=%d max=%d desired=%d", aws.StringValue(g.AutoScalingGroupName),
+				aws.Int64Value(g.MinSize), aws.Int64Value(g.MaxSize), aws.Int64Value(g.DesiredCapacity))
+		}
+	}
+}
+
+func TestRollingUpdate_ForceCloudOnlyInstanceGroup(t *testing.T) {
+	defer func(d time.Duration) { rollingUpdatePollInterval = d }(rollingUpdatePollInterval)
+	rollingUpdatePollInterval = time.Millisecond
+
+	cloud := buildMockCloud(t)
+
+	c := &RollingUpdateCluster{
+		ClusterName:  "minimal.example.com",
+		Cloud:        cloud,
+		CloudOnly:    true,
+		ReadyTimeout: time.Second,
+	}
+
+	// Bring the nodes up to date first, so that only --force will replace them
+	c.InstanceGroups = []string{"nodes"}
+	nodesets, err := c.ListNodesets()
+	if err != nil {
+		t.Fatalf("error listing nodesets: %v", err)
+	}
+	if len(nodesets) != 1 || nodesets["nodes.minimal.example.com"] == nil {
+		t.Fatalf("expected only the nodes nodeset, got %v", nodesets)
+	}
+	err = c.RollingUpdateNodesets(nodesets)
+	if err != nil {
+		t.Fatalf("error in rolling update: %v", err)
+	}
+
+	nodesets, err = c.ListNodesets()
+	if err != nil {
+		t.Fatalf("error listing nodesets: %v", err)
+	}
+	before := make(map[string]bool)
+	for _, i := range nodesets["nodes.minimal.example.com"].Ready {
+		before[i.ID] = true
+	}
+	if len(before) != 3 || len(nodesets["nodes.minimal.example.com"].NeedUpdate) != 0 {
+		t.Fatalf("expected 3 updated instances, got %v", nodesets["nodes.minimal.example.com"])
+	}
+
+	c.Force = true
+	nodesets, err = c.ListNodesets()
+	if err != nil {
+		t.Fatalf("error listing nodesets: %v", err)
+	}
+	if len(nodesets["nodes.minimal.example.com"].NeedUpdate) != 3 {
+		t.Fatalf("expected --force to replace all instances, got %v", nodesets["nodes.minimal.example.com"])
+	}
+	err = c.RollingUpdateNodesets(nodesets)
+	if err != nil {
+		t.Fatalf("error in rolling update: %v", err)
+	}
+
+	c.Force = false
+	c.InstanceGroups = nil
+	nodesets, err = c.ListNodesets()
+	if err != nil {
+		t.Fatalf("error listing nodesets: %v", err)
+	}
+	for _, i := range nodesets["nodes.minimal.example.com"].Ready {
+		if before[i.ID] {
+			t.Fatalf("expected instance %q to be replaced", i.ID)
+		}
+	}
+	if len(nodesets["master-us-test-1a.masters.minimal.example.com"].NeedUpdate) != 1 {
+		t.Fatalf("expected master to be left alone")
+	}
+
+	c.InstanceGroups = []string{"missing"}
+	_, err = c.ListNodesets()
+	if err == nil {
+		t.Fatalf("expected error for unknown instance group")
+	}
+}
+
+// TestRollingUpdate_ToleratesUnreachableAPIServer replaces the master while the apiserver can't be reached,
+// checking that we keep waiting for the replacement rather than aborting, and that deleting nodes is best-effort
+func TestRollingUpdate_ToleratesUnreachableAPIServer(t *testing.T) {
+	defer func(d time.Duration) { rollingUpdatePollInterval = d }(rollingUpdatePollInterval)
+	rollingUpdatePollInterval = time.Millisecond
+
+	cloud := buildMockCloud(t)
+
+	k8s := &fakeKubernetesClient{
+		cloud:                  cloud,
+		deleted:                make(map[string]bool),
+		unreachableAfterDelete: 3,
+		failDeleteNode:         true,
+	}
+	c := &RollingUpdateCluster{
+		ClusterName:    "minimal.example.com",
+		Cloud:          cloud,
+		K8sClient:      k8s,
+		ReadyTimeout:   time.Second,
+		InstanceGroups: []string{"master-us-test-1a"},
+	}
+
+	nodesets, err := c.ListNodesets()
+	if err != nil {
+		t.Fatalf("error listing nodesets: %v", err)
+	}
+	if len(nodesets) != 1 || !nodesets["master-us-test-1a.masters.minimal.example.com"].IsMaster {
+		t.Fatalf("expected only the master nodeset, got %v", nodesets)
+	}
+
+	err = c.RollingUpdateNodesets(nodesets)
+	if err != nil {
+		t.Fatalf("error in rolling update: %v", err)
+	}
+
+	nodesets, err = c.ListNodesets()
+	if err != nil {
+		t.Fatalf("error listing nodesets: %v", err)
+	}
+	master := nodesets["master-us-test-1a.masters.minimal.example.com"]
+	if len(master.NeedUpdate) != 0 || len(master.Ready) != 1 {
+		t.Fatalf("expected the master to be replaced, got %v", master)
+	}
+}
+
+// TestRollingUpdate_Bastion checks that bastions are replaced without waiting for them to register as nodes
+func TestRollingUpdate_Bastion(t *testing.T) {
+	defer func(d time.Duration) { rollingUpdatePollInterval = d }(rollingUpdatePollInterval)
+	rollingUpdatePollInterval = time.Millisecond
+
+	cloud := buildMockCloud(t)
+	createGroup(t, cloud, "bastions.minimal.example.com", 1, map[string]string{"KubernetesCluster": "minimal.example.com", "k8s.io/role/bastion": "1"})
+
+	k8s := &fakeKubernetesClient{cloud: cloud, deleted: make(map[string]bool)}
+	c := &RollingUpdateCluster{
+		ClusterName:    "minimal.example.com",
+		Cloud:          cloud,
+		K8sClient:      k8s,
+		ReadyTimeout:   time.Second,
+		InstanceGroups: []string{"bastions"},
+	}
+
+	nodesets, err := c.ListNodesets()
+	if err != nil {
+		t.Fatalf("error listing nodesets: %v", err)
+	}
+	bastions := nodesets["bastions.minimal.example.com"]
+	if len(nodesets) != 1 || bastions == nil || !bastions.IsBastion {
+		t.Fatalf("expected only the bastions nodeset, got %v", nodesets)
+	}
+
+	err = c.RollingUpdateNodesets(nodesets)
+	if err != nil {
+		t.Fatalf("error in rolling update: %v", err)
+	}
+	if len(k8s.drained) != 0 {
+		t.Fatalf("did not expect bastions to be drained, got %v", k8s.drained)
+	}
+
+	nodesets, err = c.ListNodesets()
+	if err != nil {
+		t.Fatalf("error listing nodesets: %v", err)
+	}
+	bastions = nodesets["bastions.minimal.example.com"]
+	if len(bastions.NeedUpdate) != 0 || len(bastions.Ready) != 1 {
+		t.Fatalf("expected the bastion to be replaced, got %v", bastions)
+	}
+}
@@ -0,0 +1,185 @@
+package kutil
+
+import (
+	"fmt"
+	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi"
+	"sort"
+	"strings"
+)
+
+// ValidateCluster checks that a cluster came up: the instance groups are at their expected size, every instance
+// registered as a ready node, and the master components & kube-dns are running
+type ValidateCluster struct {
+	ClusterName    string
+	Cloud          fi.Cloud
+	InstanceGroups []*api.InstanceGroup
+	K8sClient      KubernetesClient
+}
+
+// ValidationResult is the outcome of validating a cluster
+type ValidationResult struct {
+	Passed bool               `json:"passed"`
+	Checks []*ValidationCheck `json:"checks"`
+}
+
+// ValidationCheck is the outcome of a single check
+type ValidationCheck struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
+
+// masterComponents are the static pods that run on every master, named <component>-<nodename>
+var masterComponents = []string{"kube-apiserver", "kube-controller-manager", "kube-scheduler"}
+
+// Validate runs the checks; an error is returned only if the checks could not be run
+func (v *ValidateCluster) Validate() (*ValidationResult, error) {
+	result := &ValidationResult{Passed: true}
+
+	u := &RollingUpdateCluster{
+		ClusterName: v.ClusterName,
+		Cloud:       v.Cloud,
+	}
+	nodesets, err := u.ListNodesets()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := v.K8sClient.GetNodes()
+	if err != nil {
+		return nil, fmt.Errorf("error listing nodes: %v", err)
+	}
+
+	pods, err := v.K8sClient.GetPods("kube-system")
+	if err != nil {
+		return nil, fmt.Errorf("error listing pods: %v", err)
+	}
+
+	statuses, err := v.K8sClient.GetComponentStatuses()
+	if err != nil {
+		return nil, fmt.Errorf("error getting component statuses: %v", err)
+	}
+
+	var masterNodes []string
+	for _, g := range v.InstanceGroups {
+		var nodeset *Nodeset
+		for _, n := range nodesets {
+			if n.InstanceGroupName == g.Name {
+				nodeset = n
+			}
+		}
+		if nodeset == nil {
+			result.add("InstanceGroup", g.Name, false, "no cloud instance group found")
+			continue
+		}
+
+		var instances []*CloudInstance
+		for _, l := range [][]*CloudInstance{nodeset.Ready, nodeset.NeedUpdate} {
+			for _, i := range l {
+				if i.Running {
+					instances = append(instances, i)
+				}
+			}
+		}
+
+		minSize, maxSize := nodeset.MinSize, nodeset.MaxSize
+		if g.Spec.MinSize != nil {
+			minSize = *g.Spec.MinSize
+		}
+		if g.Spec.MaxSize != nil {
+			maxSize = *g.Spec.MaxSize
+		}
+		count := len(instances)
+		if count < minSize || count > maxSize {
+			result.add("InstanceGroup", g.Name, false, fmt.Sprintf("%d instances running, expected %d-%d", count, minSize, maxSize))
+		} else {
+			result.add("InstanceGroup", g.Name, true, fmt.Sprintf("%d instances running", count))
+		}
+
+		if g.IsBastion() {
+			// Bastions don't run a kubelet
+			continue
+		}
+
+		for _, i := range instances {
+			node := findNodeForInstance(nodes, i.ID)
+			if node == nil {
+				result.add("Instance", i.ID, false, fmt.Sprintf("instance in %q has not registered as a node", g.Name))
+				continue
+			}
+			if !node.Ready {
+				result.add("Node", node.Name, false, "node is not ready")
+			} else {
+				result.add("Node", node.Name, true, "")
+			}
+			if g.IsMaster() {
+				masterNodes = append(masterNodes, node.Name)
+			}
+		}
+	}
+
+	sort.Strings(masterNodes)
+	for _, nodeName := range masterNodes {
+		for _, component := range masterComponents {
+			name := component + "-" + nodeName
+			pod := findPod(pods, name)
+			if pod == nil {
+				result.add("Pod", name, false, "pod not found")
+			} else {
+				result.addPod(pod)
+			}
+		}
+	}
+
+	dnsPods := 0
+	for _, pod := range pods {
+		if pod.Labels["k8s-app"] == "kube-dns" {
+			dnsPods++
+			result.addPod(pod)
+		}
+	}
+	if dnsPods == 0 {
+		result.add("Pod", "kube-dns", false, "no kube-dns pods found")
+	}
+
+	for _, status := range statuses {
+		if status.Healthy {
+			result.add("ComponentStatus", status.Name, true, "")
+		} else {
+			result.add("ComponentStatus", status.Name, false, "component is not healthy")
+		}
+	}
+
+	return result, nil
+}
+
+func (r *ValidationResult) add(kind string, name string, passed bool, message string) {
+	r.Checks = append(r.Checks, &ValidationCheck{
+		Kind:    kind,
+		Name:    name,
+		Passed:  passed,
+		Message: message,
+	})
+	if !passed {
+		r.Passed = false
+	}
+}
+
+func (r *ValidationResult) addPod(pod *KubernetesPod) {
+	if pod.Phase != "Running" || !pod.Ready {
+		r.add("Pod", pod.Name, false, fmt.Sprintf("pod is %s, ready=%v", strings.ToLower(pod.Phase), pod.Ready))
+	} else {
+		r.add("Pod", pod.Name, true, "")
+	}
+}
+
+func findPod(pods []*KubernetesPod, name string) *KubernetesPod {
+	for _, pod := range pods {
+		if pod.Name == name {
+			return pod
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package kutil
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi"
+	"testing"
+)
+
+func TestValidateCluster(t *testing.T) {
+	cloud := buildMockCloud(t)
+
+	masters, err := cloud.Autoscaling.DescribeAutoScalingGroups(&autoscaling.DescribeAutoScalingGroupsInput{
+		AutoScalingGroupNames: []*string{aws.String("master-us-test-1a.masters.minimal.example.com")},
+	})
+	if err != nil {
+		t.Fatalf("error describing autoscaling groups: %v", err)
+	}
+	masterNode := "node-" + aws.StringValue(masters.AutoScalingGroups[0].Instances[0].InstanceId)
+
+	k8s := &fakeKubernetesClient{cloud: cloud, deleted: make(map[string]bool)}
+	for _, component := range []string{"kube-apiserver", "kube-controller-manager", "kube-scheduler"} {
+		k8s.pods = append(k8s.pods, &KubernetesPod{Name: component + "-" + masterNode, Phase: "Running", Ready: true})
+	}
+	dns := &KubernetesPod{Name: "kube-dns-v14-abcde", Labels: map[string]string{"k8s-app": "kube-dns"}, Phase: "Running", Ready: true}
+	k8s.pods = append(k8s.pods, dns)
+
+	master := &api.InstanceGroup{}
+	master.Name = "master-us-test-1a"
+	master.Spec.Role = api.InstanceGroupRoleMaster
+
+	nodes := &api.InstanceGroup{}
+	nodes.Name = "nodes"
+	nodes.Spec.Role = api.InstanceGroupRoleNode
+
+	v := &ValidateCluster{
+		ClusterName:    "minimal.example.com",
+		Cloud:          cloud,
+		InstanceGroups: []*api.InstanceGroup{master, nodes},
+		K8sClient:      k8s,
+	}
+
+	result, err := v.Validate()
+	if err != nil {
+		t.Fatalf("error validating cluster: %v", err)
+	}
+	if !result.Passed {
+		t.Fatalf("expected validation to pass, got %s", fi.DebugAsJsonString(result))
+	}
+	// 2 instance groups, 4 nodes, 3 master components, kube-dns and etcd/controller-manager statuses
+	if len(result.Checks) != 12 {
+		t.Fatalf("expected 12 checks, got %s", fi.DebugAsJsonString(result))
+	}
+
+	nodes.Spec.MinSize = fi.Int(4)
+	dns.Phase = "Pending"
+	result, err = v.Validate()
+	if err != nil {
+		t.Fatalf("error validating cluster: %v", err)
+	}
+	if result.Passed {
+		t.Fatalf("expected validation to fail")
+	}
+	failed := make(map[string]bool)
+	for _, check := range result.Checks {
+		if !check.Passed {
+			failed[check.Kind+"/"+check.Name] = true
+		}
+	}
+	if len(failed) != 2 || !failed["InstanceGroup/nodes"] || !failed["Pod/kube-dns-v14-abcde"] {
+		t.Fatalf("unexpected failed checks: %v", failed)
+	}
+}